@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+type toolPolicyContextKey struct{}
+
+// WithToolPolicy 为单次运行限制可用工具（例如按发送者角色），nil 表示不限制
+func WithToolPolicy(ctx context.Context, policy *ToolPolicy) context.Context {
+	return context.WithValue(ctx, toolPolicyContextKey{}, policy)
+}
+
+// toolPolicyFromContext 获取单次运行的工具限制
+func toolPolicyFromContext(ctx context.Context) *ToolPolicy {
+	policy, _ := ctx.Value(toolPolicyContextKey{}).(*ToolPolicy)
+	return policy
+}
+
+// runTools 返回本次运行可用的工具
+func runTools(ctx context.Context, state *AgentState) []Tool {
+	if policy := toolPolicyFromContext(ctx); policy != nil {
+		return policy.FilterTools(state.Tools)
+	}
+	return state.Tools
+}
+
+// roleToolPolicy 将角色策略转换为工具策略，没有工具限制时返回 nil
+func roleToolPolicy(policy config.AccessRoleConfig) *ToolPolicy {
+	if len(policy.AllowedTools) == 0 && len(policy.DeniedTools) == 0 {
+		return nil
+	}
+	toolPolicy := &ToolPolicy{
+		Deny:      make(map[string]bool, len(policy.DeniedTools)),
+		Allow:     make(map[string]bool, len(policy.AllowedTools)),
+		AllowOnly: len(policy.AllowedTools) > 0,
+	}
+	for _, tool := range policy.DeniedTools {
+		toolPolicy.Deny[tool] = true
+	}
+	for _, tool := range policy.AllowedTools {
+		toolPolicy.Allow[tool] = true
+	}
+	return toolPolicy
+}
+
+// admitInbound 按发送者角色检查 Agent 访问权限和速率限制，拒绝时直接回复发送者（调用方需持有读锁）
+func (m *AgentManager) admitInbound(ctx context.Context, msg *bus.InboundMessage, agent *Agent) bool {
+	if m.access == nil {
+		return true
+	}
+
+	role := m.access.Role(msg.Channel, msg.SenderID)
+	agentID := m.agentIDOf(agent)
+	if !m.access.CanReachAgent(role, agentID) {
+		logger.Warn("Inbound message denied, agent not allowed for role",
+			zap.String("channel", msg.Channel),
+			zap.String("sender_id", msg.SenderID),
+			zap.String("role", role),
+			zap.String("agent_id", agentID))
+		m.publishText(ctx, msg.Channel, msg.ChatID, "Sorry, you don't have access to this assistant.", nil)
+		return false
+	}
+	if !m.access.AllowMessage(msg.Channel, msg.SenderID, role) {
+		logger.Warn("Inbound message denied, rate limit exceeded",
+			zap.String("channel", msg.Channel),
+			zap.String("sender_id", msg.SenderID),
+			zap.String("role", role))
+		m.publishText(ctx, msg.Channel, msg.ChatID, "You're sending messages too quickly. Please wait a minute and try again.", nil)
+		return false
+	}
+	return true
+}
+
+// applyAccessPolicy 按发送者角色限制本次运行的工具，并用角色预算收紧运行预算
+func (m *AgentManager) applyAccessPolicy(ctx context.Context, channel, senderID string, budget *RunBudget) (context.Context, *RunBudget) {
+	if m.access == nil {
+		return ctx, budget
+	}
+
+	role := m.access.Role(channel, senderID)
+	policy := m.access.Policy(role)
+	if toolPolicy := roleToolPolicy(policy); toolPolicy != nil {
+		ctx = WithToolPolicy(ctx, toolPolicy)
+	}
+	if policy.Budget != nil {
+		budget = budget.Tighten(NewRunBudget(policy.Budget))
+	}
+
+	logger.Debug("Access policy applied",
+		zap.String("channel", channel),
+		zap.String("sender_id", senderID),
+		zap.String("role", role))
+	return ctx, budget
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smallnest/goclaw/internal/access"
+	"github.com/smallnest/goclaw/providers"
+)
+
+// toolListProvider 记录每次调用提供的工具，并请求调用 exec
+type toolListProvider struct {
+	scriptedProvider
+	offered []string
+}
+
+func (p *toolListProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, options ...providers.ChatOption) (*providers.Response, error) {
+	if p.offered == nil {
+		for _, tool := range tools {
+			p.offered = append(p.offered, tool.Name)
+		}
+	}
+	return p.scriptedProvider.Chat(ctx, messages, tools, options...)
+}
+
+func TestGuestToolPolicy(t *testing.T) {
+	var executed, clicked, read int
+	provider := &toolListProvider{scriptedProvider: scriptedProvider{calls: []providers.ToolCall{
+		{ID: "1", Name: "exec", Params: map[string]interface{}{"command": "ls"}},
+	}}}
+	state := NewAgentState()
+	state.Tools = []Tool{
+		countingTool{name: "exec", output: "files", executed: &executed},
+		countingTool{name: "browser_click", output: "clicked", executed: &clicked},
+		countingTool{name: "read_file", output: "text", executed: &read},
+	}
+	orch := NewOrchestrator(&LoopConfig{Provider: provider, MaxIterations: 5}, state)
+	go func() {
+		for range orch.Subscribe() {
+		}
+	}()
+
+	guest := roleToolPolicy(access.DefaultRoles()[access.RoleGuest])
+	ctx := WithToolPolicy(context.Background(), guest)
+	prompt := AgentMessage{Role: RoleUser, Content: []ContentBlock{TextContent{Text: "list files"}}}
+	if _, err := orch.Run(ctx, []AgentMessage{prompt}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(provider.offered) != 1 || provider.offered[0] != "read_file" {
+		t.Errorf("guest should only be offered read_file, got %v", provider.offered)
+	}
+	if executed != 0 {
+		t.Error("guest must not be able to call exec")
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/smallnest/goclaw/agent/tools"
 	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"go.uber.org/zap"
@@ -24,6 +28,8 @@ type Agent struct {
 	context      *ContextBuilder
 	workspace    string
 	skillsLoader *SkillsLoader
+	budget       *RunBudget
+	checkpoints  *tools.FileCheckpoints
 
 	mu        sync.RWMutex
 	state     *AgentState
@@ -41,6 +47,12 @@ type NewAgentConfig struct {
 	Workspace    string
 	MaxIteration int
 	SkillsLoader *SkillsLoader
+	Budget       *RunBudget
+	OutputSpool  *tools.OutputSpool
+	AuditLog     *audit.Log
+	Redactor     *redact.Redactor
+	// FileCheckpoints keeps pre-images of edited files per run; nil disables them
+	FileCheckpoints *tools.FileCheckpoints
 }
 
 // NewAgent creates a new agent
@@ -78,6 +90,11 @@ func NewAgent(cfg *NewAgentConfig) (*Agent, error) {
 		Provider:         cfg.Provider,
 		SessionMgr:       cfg.SessionMgr,
 		MaxIterations:    cfg.MaxIteration,
+		Budget:           cfg.Budget,
+		OutputSpool:      cfg.OutputSpool,
+		AuditLog:         cfg.AuditLog,
+		Redactor:         cfg.Redactor,
+		Untrusted:        cfg.Context.UntrustedContentPolicy(),
 		ConvertToLLM:     defaultConvertToLLM,
 		TransformContext: nil,
 		Skills:           skills,
@@ -104,6 +121,8 @@ func NewAgent(cfg *NewAgentConfig) (*Agent, error) {
 		context:      cfg.Context,
 		workspace:    cfg.Workspace,
 		skillsLoader: cfg.SkillsLoader,
+		budget:       cfg.Budget,
+		checkpoints:  cfg.FileCheckpoints,
 		state:        state,
 		eventSubs:    make([]chan *Event, 0),
 		running:      false,
@@ -194,7 +213,9 @@ func (a *Agent) processMessages(ctx context.Context) {
 				continue
 			}
 
-			a.handleInboundMessage(ctx, msg)
+			msgCtx, span := consumeInboundSpan(ctx, msg)
+			a.handleInboundMessage(msgCtx, msg)
+			span.End()
 		}
 	}
 }
@@ -241,7 +262,13 @@ func (a *Agent) handleInboundMessage(ctx context.Context, msg *bus.InboundMessag
 	}
 
 	// Run agent
+	ctx = tools.WithSessionKey(ctx, sessionKey)
+	ctx = tools.WithSenderID(ctx, msg.SenderID)
+	runID := uuid.New().String()
+	ctx = tools.WithRunID(ctx, runID)
 	finalMessages, err := a.orchestrator.Run(ctx, []AgentMessage{agentMsg})
+	// Files edited before a failure can still be restored
+	changes := finishFileChanges(a.checkpoints, sessionKey, runID, msg.Content, a.workspace)
 	if err != nil {
 		logger.Error("Agent execution failed", zap.Error(err))
 
@@ -253,10 +280,13 @@ func (a *Agent) handleInboundMessage(ctx context.Context, msg *bus.InboundMessag
 	// Update session
 	a.updateSession(sess, finalMessages)
 
-	// Publish response
+	// Publish response, listing the files this run changed
 	if len(finalMessages) > 0 {
 		lastMsg := finalMessages[len(finalMessages)-1]
 		if lastMsg.Role == RoleAssistant {
+			if changes != "" {
+				lastMsg = appendText(lastMsg, fmt.Sprintf("\n\n---\nFiles changed:\n%s\n\nRun `goclaw checkpoints restore %s` to revert these changes.", changes, shortRunID(runID)))
+			}
 			a.publishToBus(ctx, msg.Channel, msg.ChatID, lastMsg)
 		}
 	}
@@ -446,6 +476,11 @@ func (a *Agent) GetCurrentChatID() string {
 	return "main"
 }
 
+// GetBudget returns the agent's run budget (nil when unlimited)
+func (a *Agent) GetBudget() *RunBudget {
+	return a.budget
+}
+
 // GetOrchestrator 获取 orchestrator（供 AgentManager 使用）
 func (a *Agent) GetOrchestrator() *Orchestrator {
 	return a.orchestrator
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/internal/audit"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// configChangeTools 修改配置的工具，审计记录类型为 config_change
+var configChangeTools = map[string]bool{
+	"update_config": true,
+}
+
+// auditToolCall 将已执行的工具调用写入审计日志，结果只记录摘要
+func (o *Orchestrator) auditToolCall(ctx context.Context, tc ToolCallContent, content []ContentBlock, err error, duration time.Duration) {
+	if o.config.AuditLog == nil {
+		return
+	}
+
+	entry := newToolAuditEntry(ctx, tc)
+	entry.Approval = audit.ApprovalAuto
+	entry.DurationMs = duration.Milliseconds()
+	if err != nil {
+		entry.Status = audit.StatusError
+		entry.Error = err.Error()
+	} else {
+		text := extractToolResultContent(content)
+		entry.Status = audit.StatusOK
+		entry.ResultDigest = audit.Digest(text)
+		entry.ResultSize = len(text)
+	}
+	o.appendAudit(entry)
+}
+
+// auditSkippedToolCall 将未执行的工具调用（例如超出运行预算）写入审计日志
+func (o *Orchestrator) auditSkippedToolCall(ctx context.Context, tc ToolCallContent, reason string) {
+	if o.config.AuditLog == nil {
+		return
+	}
+
+	entry := newToolAuditEntry(ctx, tc)
+	entry.Status = audit.StatusSkipped
+	entry.Error = reason
+	o.appendAudit(entry)
+}
+
+// auditBlockedToolCall 将等待用户确认而未执行的工具调用写入审计日志
+func (o *Orchestrator) auditBlockedToolCall(ctx context.Context, tc ToolCallContent, reason string) {
+	if o.config.AuditLog == nil {
+		return
+	}
+
+	entry := newToolAuditEntry(ctx, tc)
+	entry.Status = audit.StatusSkipped
+	entry.Approval = audit.ApprovalRequired
+	entry.Error = reason
+	o.appendAudit(entry)
+}
+
+// newToolAuditEntry 根据上下文中的会话、Agent、发送者和消息来源创建审计记录
+func newToolAuditEntry(ctx context.Context, tc ToolCallContent) *audit.Entry {
+	entry := &audit.Entry{
+		Type:       audit.TypeToolCall,
+		SenderID:   tools.SenderIDFromContext(ctx),
+		AgentID:    tools.AgentIDFromContext(ctx),
+		SessionKey: tools.SessionKeyFromContext(ctx),
+		Tool:       tc.Name,
+		ToolCallID: tc.ID,
+		Arguments:  audit.RedactArguments(tc.Arguments),
+	}
+	if configChangeTools[tc.Name] {
+		entry.Type = audit.TypeConfigChange
+	}
+	if origin := tools.DeliveryContextFromContext(ctx); origin != nil {
+		entry.Channel = origin.Channel
+		entry.AccountID = origin.AccountID
+		entry.ChatID = origin.To
+	}
+	return entry
+}
+
+// appendAudit 追加审计记录，写入失败只记录日志，不影响运行
+func (o *Orchestrator) appendAudit(entry *audit.Entry) {
+	if err := o.config.AuditLog.Append(entry); err != nil {
+		logger.Error("Failed to write audit entry",
+			zap.String("tool_name", entry.Tool),
+			zap.Error(err))
+	}
+}
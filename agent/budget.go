@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/providers"
+)
+
+// StopReasonBudgetExceeded is the stop reason emitted on EventTurnEnd when a run budget is hit
+const StopReasonBudgetExceeded = "budget_exceeded"
+
+// RunBudget limits a single agent run. Zero values mean "no limit".
+type RunBudget struct {
+	MaxWallTime    time.Duration
+	MaxTotalTokens int
+	MaxCost        float64
+	MaxIterations  int
+	// MaxToolCalls limits calls per tool name; "*" applies to every tool without an explicit entry
+	MaxToolCalls map[string]int
+
+	// Pricing used to estimate cost from token usage (USD per million tokens)
+	InputCostPerMillion  float64
+	OutputCostPerMillion float64
+}
+
+// NewRunBudget converts a budget config into a RunBudget. Returns nil for a nil config.
+func NewRunBudget(cfg *config.RunBudgetConfig) *RunBudget {
+	if cfg == nil {
+		return nil
+	}
+
+	b := &RunBudget{
+		MaxWallTime:          time.Duration(cfg.MaxWallTimeSeconds) * time.Second,
+		MaxTotalTokens:       cfg.MaxTotalTokens,
+		MaxCost:              cfg.MaxCost,
+		InputCostPerMillion:  cfg.InputCostPerMillion,
+		OutputCostPerMillion: cfg.OutputCostPerMillion,
+	}
+	if len(cfg.MaxToolCalls) > 0 {
+		b.MaxToolCalls = make(map[string]int, len(cfg.MaxToolCalls))
+		for name, limit := range cfg.MaxToolCalls {
+			b.MaxToolCalls[name] = limit
+		}
+	}
+	return b
+}
+
+// Override returns a copy of b where every limit set in other replaces the one in b.
+// Used to layer per-agent budgets over the defaults.
+func (b *RunBudget) Override(other *RunBudget) *RunBudget {
+	if b == nil {
+		return other.clone()
+	}
+	result := b.clone()
+	if other == nil {
+		return result
+	}
+
+	if other.MaxWallTime > 0 {
+		result.MaxWallTime = other.MaxWallTime
+	}
+	if other.MaxTotalTokens > 0 {
+		result.MaxTotalTokens = other.MaxTotalTokens
+	}
+	if other.MaxCost > 0 {
+		result.MaxCost = other.MaxCost
+	}
+	if other.MaxIterations > 0 {
+		result.MaxIterations = other.MaxIterations
+	}
+	if other.InputCostPerMillion > 0 {
+		result.InputCostPerMillion = other.InputCostPerMillion
+	}
+	if other.OutputCostPerMillion > 0 {
+		result.OutputCostPerMillion = other.OutputCostPerMillion
+	}
+	for name, limit := range other.MaxToolCalls {
+		if result.MaxToolCalls == nil {
+			result.MaxToolCalls = make(map[string]int)
+		}
+		result.MaxToolCalls[name] = limit
+	}
+	return result
+}
+
+// Tighten returns a copy of b where each limit is the stricter of b and other.
+// Used to apply per-channel caps on top of an agent's budget.
+func (b *RunBudget) Tighten(other *RunBudget) *RunBudget {
+	if b == nil {
+		return other.clone()
+	}
+	result := b.clone()
+	if other == nil {
+		return result
+	}
+
+	result.MaxWallTime = time.Duration(minLimit(int64(result.MaxWallTime), int64(other.MaxWallTime)))
+	result.MaxTotalTokens = int(minLimit(int64(result.MaxTotalTokens), int64(other.MaxTotalTokens)))
+	result.MaxIterations = int(minLimit(int64(result.MaxIterations), int64(other.MaxIterations)))
+	if other.MaxCost > 0 && (result.MaxCost == 0 || other.MaxCost < result.MaxCost) {
+		result.MaxCost = other.MaxCost
+	}
+	if result.InputCostPerMillion == 0 {
+		result.InputCostPerMillion = other.InputCostPerMillion
+	}
+	if result.OutputCostPerMillion == 0 {
+		result.OutputCostPerMillion = other.OutputCostPerMillion
+	}
+	for name, limit := range other.MaxToolCalls {
+		if result.MaxToolCalls == nil {
+			result.MaxToolCalls = make(map[string]int)
+		}
+		result.MaxToolCalls[name] = int(minLimit(int64(result.MaxToolCalls[name]), int64(limit)))
+	}
+	return result
+}
+
+// IsZero reports whether the budget imposes no limits
+func (b *RunBudget) IsZero() bool {
+	if b == nil {
+		return true
+	}
+	return b.MaxWallTime <= 0 && b.MaxTotalTokens <= 0 && b.MaxCost <= 0 &&
+		b.MaxIterations <= 0 && len(b.MaxToolCalls) == 0
+}
+
+// Unpriced reports whether a cost limit is set without pricing to estimate cost, so it can never trigger
+func (b *RunBudget) Unpriced() bool {
+	return b != nil && b.MaxCost > 0 && b.InputCostPerMillion == 0 && b.OutputCostPerMillion == 0
+}
+
+// toolCallLimit returns the call limit for a tool (0 = unlimited)
+func (b *RunBudget) toolCallLimit(toolName string) int {
+	if b == nil || len(b.MaxToolCalls) == 0 {
+		return 0
+	}
+	if limit, ok := b.MaxToolCalls[toolName]; ok {
+		return limit
+	}
+	return b.MaxToolCalls["*"]
+}
+
+func (b *RunBudget) clone() *RunBudget {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	if b.MaxToolCalls != nil {
+		c.MaxToolCalls = make(map[string]int, len(b.MaxToolCalls))
+		for name, limit := range b.MaxToolCalls {
+			c.MaxToolCalls[name] = limit
+		}
+	}
+	return &c
+}
+
+// minLimit returns the stricter of two limits where 0 means unlimited
+func minLimit(a, b int64) int64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type runBudgetContextKey struct{}
+
+// WithRunBudget attaches a per-run budget to the context; it takes precedence over LoopConfig.Budget
+func WithRunBudget(ctx context.Context, budget *RunBudget) context.Context {
+	return context.WithValue(ctx, runBudgetContextKey{}, budget)
+}
+
+// RunBudgetFromContext returns the budget attached by WithRunBudget, if any
+func RunBudgetFromContext(ctx context.Context) *RunBudget {
+	budget, _ := ctx.Value(runBudgetContextKey{}).(*RunBudget)
+	return budget
+}
+
+// BudgetReport summarizes resource usage of a run
+type BudgetReport struct {
+	Reason           string         `json:"reason,omitempty"`
+	ElapsedMs        int64          `json:"elapsed_ms"`
+	Iterations       int            `json:"iterations"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	TotalTokens      int            `json:"total_tokens"`
+	Cost             float64        `json:"cost"`
+	ToolCalls        map[string]int `json:"tool_calls,omitempty"`
+}
+
+// BudgetTracker tracks resource usage of a single run against a RunBudget
+type BudgetTracker struct {
+	budget           *RunBudget
+	startedAt        time.Time
+	iterations       int
+	promptTokens     int
+	completionTokens int
+	totalTokens      int
+	toolCalls        map[string]int
+	exceeded         string
+}
+
+// NewBudgetTracker creates a tracker; a nil budget tracks usage without enforcing limits
+func NewBudgetTracker(budget *RunBudget) *BudgetTracker {
+	return &BudgetTracker{
+		budget:    budget,
+		startedAt: time.Now(),
+		toolCalls: make(map[string]int),
+	}
+}
+
+// RecordIteration counts one LLM call
+func (t *BudgetTracker) RecordIteration() {
+	t.iterations++
+}
+
+// RecordUsage adds token usage reported by the provider
+func (t *BudgetTracker) RecordUsage(usage providers.Usage) {
+	t.promptTokens += usage.PromptTokens
+	t.completionTokens += usage.CompletionTokens
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+	t.totalTokens += total
+}
+
+// Cost returns the estimated cost of the run so far
+func (t *BudgetTracker) Cost() float64 {
+	if t.budget == nil {
+		return 0
+	}
+	return float64(t.promptTokens)*t.budget.InputCostPerMillion/1e6 +
+		float64(t.completionTokens)*t.budget.OutputCostPerMillion/1e6
+}
+
+// Check returns a non-empty reason once any run-level limit has been reached
+func (t *BudgetTracker) Check() string {
+	if t.exceeded != "" {
+		return t.exceeded
+	}
+	if t.budget == nil {
+		return ""
+	}
+
+	b := t.budget
+	switch {
+	case b.MaxWallTime > 0 && time.Since(t.startedAt) >= b.MaxWallTime:
+		t.exceeded = fmt.Sprintf("wall time limit of %s reached", b.MaxWallTime)
+	case b.MaxTotalTokens > 0 && t.totalTokens >= b.MaxTotalTokens:
+		t.exceeded = fmt.Sprintf("token limit of %d reached (%d used)", b.MaxTotalTokens, t.totalTokens)
+	case b.MaxCost > 0 && t.Cost() >= b.MaxCost:
+		t.exceeded = fmt.Sprintf("cost limit of $%.4f reached ($%.4f used)", b.MaxCost, t.Cost())
+	case b.MaxIterations > 0 && t.iterations >= b.MaxIterations:
+		t.exceeded = fmt.Sprintf("iteration limit of %d reached", b.MaxIterations)
+	}
+	return t.exceeded
+}
+
+// AllowToolCall counts a call to toolName and reports whether it fits the budget.
+// A rejected call marks the budget as exceeded.
+func (t *BudgetTracker) AllowToolCall(toolName string) bool {
+	if t.exceeded != "" {
+		return false
+	}
+	limit := t.budget.toolCallLimit(toolName)
+	if limit > 0 && t.toolCalls[toolName] >= limit {
+		t.exceeded = fmt.Sprintf("tool call limit of %d for %s reached", limit, toolName)
+		return false
+	}
+	t.toolCalls[toolName]++
+	return true
+}
+
+// Exceeded returns the reason the budget was exceeded, or "" if it was not
+func (t *BudgetTracker) Exceeded() string {
+	return t.exceeded
+}
+
+// Report returns a snapshot of the current usage
+func (t *BudgetTracker) Report() *BudgetReport {
+	toolCalls := make(map[string]int, len(t.toolCalls))
+	for name, count := range t.toolCalls {
+		toolCalls[name] = count
+	}
+	return &BudgetReport{
+		Reason:           t.exceeded,
+		ElapsedMs:        time.Since(t.startedAt).Milliseconds(),
+		Iterations:       t.iterations,
+		PromptTokens:     t.promptTokens,
+		CompletionTokens: t.completionTokens,
+		TotalTokens:      t.totalTokens,
+		Cost:             t.Cost(),
+		ToolCalls:        toolCalls,
+	}
+}
+
+// buildBudgetSummaryPrompt builds the instruction for the final summarization turn
+func buildBudgetSummaryPrompt(report *BudgetReport) string {
+	var sb strings.Builder
+	sb.WriteString("[System notice] This run has been stopped because its budget was exceeded: ")
+	sb.WriteString(report.Reason)
+	sb.WriteString(".\n\n")
+	sb.WriteString(fmt.Sprintf("Usage: %d LLM calls, %d tokens, %s elapsed", report.Iterations, report.TotalTokens, formatDuration(report.ElapsedMs)))
+	if len(report.ToolCalls) > 0 {
+		names := make([]string, 0, len(report.ToolCalls))
+		for name := range report.ToolCalls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		calls := make([]string, 0, len(names))
+		for _, name := range names {
+			calls = append(calls, fmt.Sprintf("%s×%d", name, report.ToolCalls[name]))
+		}
+		sb.WriteString(", tool calls: ")
+		sb.WriteString(strings.Join(calls, ", "))
+	}
+	sb.WriteString(".\n\n")
+	sb.WriteString("You cannot call any more tools. Reply to the user with a short summary of what was done so far, ")
+	sb.WriteString("what remains unfinished, and how they can continue (for example by sending a follow-up message).")
+	return sb.String()
+}
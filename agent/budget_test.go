@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/providers"
+)
+
+// loopingProvider 每次都请求调用工具的模拟提供商
+type loopingProvider struct {
+	calls     int
+	lastTools int
+}
+
+func (p *loopingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, options ...providers.ChatOption) (*providers.Response, error) {
+	p.calls++
+	p.lastTools = len(tools)
+	if len(tools) == 0 {
+		return &providers.Response{Content: "summary", Usage: providers.Usage{TotalTokens: 10}}, nil
+	}
+	return &providers.Response{
+		ToolCalls: []providers.ToolCall{
+			{ID: "call", Name: "echo", Params: map[string]interface{}{}},
+			{ID: "call2", Name: "echo", Params: map[string]interface{}{}},
+		},
+		Usage: providers.Usage{PromptTokens: 900, CompletionTokens: 100, TotalTokens: 1000},
+	}, nil
+}
+
+func (p *loopingProvider) ChatWithTools(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, options ...providers.ChatOption) (*providers.Response, error) {
+	return p.Chat(ctx, messages, tools, options...)
+}
+
+func (p *loopingProvider) Close() error { return nil }
+
+// echoTool 简单的测试工具
+type echoTool struct{}
+
+func (echoTool) Name() string               { return "echo" }
+func (echoTool) Description() string        { return "echo" }
+func (echoTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (echoTool) Execute(ctx context.Context, params map[string]any, onUpdate func(ToolResult)) (ToolResult, error) {
+	return ToolResult{Content: []ContentBlock{TextContent{Text: "ok"}}}, nil
+}
+
+func runWithBudget(t *testing.T, budget *RunBudget) ([]AgentMessage, *loopingProvider, []*Event) {
+	t.Helper()
+
+	provider := &loopingProvider{}
+	state := NewAgentState()
+	state.Tools = []Tool{echoTool{}}
+	orch := NewOrchestrator(&LoopConfig{Provider: provider, MaxIterations: 50}, state)
+
+	var events []*Event
+	done := make(chan struct{})
+	go func() {
+		for ev := range orch.Subscribe() {
+			events = append(events, ev)
+			if ev.Type == EventAgentEnd {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	prompt := AgentMessage{Role: RoleUser, Content: []ContentBlock{TextContent{Text: "go"}}}
+	msgs, err := orch.Run(WithRunBudget(context.Background(), budget), []AgentMessage{prompt})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for agent end event")
+	}
+	return msgs, provider, events
+}
+
+func TestRunBudgetTokenLimit(t *testing.T) {
+	msgs, provider, events := runWithBudget(t, &RunBudget{MaxTotalTokens: 2500})
+
+	// 3 tool-calling turns use 3000 tokens, then one summarization turn without tools
+	if provider.calls != 4 {
+		t.Errorf("expected 4 LLM calls, got %d", provider.calls)
+	}
+	if provider.lastTools != 0 {
+		t.Errorf("summarization turn should not offer tools, got %d", provider.lastTools)
+	}
+
+	last := msgs[len(msgs)-1]
+	if last.Role != RoleAssistant || extractTextContent(last) != "summary" {
+		t.Errorf("expected final summary message, got %+v", last)
+	}
+	if last.Metadata["stop_reason"] != StopReasonBudgetExceeded {
+		t.Errorf("expected stop_reason %q, got %v", StopReasonBudgetExceeded, last.Metadata["stop_reason"])
+	}
+
+	var found bool
+	for _, ev := range events {
+		if ev.Type == EventTurnEnd && ev.StopReason == StopReasonBudgetExceeded {
+			found = true
+			if ev.Budget == nil || ev.Budget.TotalTokens != 3000 {
+				t.Errorf("unexpected budget report: %+v", ev.Budget)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected turn_end event with budget_exceeded stop reason")
+	}
+}
+
+func TestRunBudgetToolCallLimit(t *testing.T) {
+	msgs, _, _ := runWithBudget(t, &RunBudget{MaxToolCalls: map[string]int{"echo": 3}})
+
+	executed, skipped := 0, 0
+	for _, msg := range msgs {
+		if msg.Role != RoleToolResult {
+			continue
+		}
+		if msg.Metadata["error"] == StopReasonBudgetExceeded {
+			skipped++
+		} else {
+			executed++
+		}
+	}
+	if executed != 3 {
+		t.Errorf("expected 3 executed tool calls, got %d", executed)
+	}
+	// The 4th call is rejected, and every call still gets a result
+	if skipped != 1 {
+		t.Errorf("expected 1 skipped tool call, got %d", skipped)
+	}
+}
+
+func TestRunBudgetTighten(t *testing.T) {
+	agentBudget := &RunBudget{MaxTotalTokens: 1000, MaxToolCalls: map[string]int{"exec": 5}}
+	channelBudget := &RunBudget{MaxTotalTokens: 5000, MaxWallTime: time.Minute, MaxToolCalls: map[string]int{"exec": 2}}
+
+	merged := agentBudget.Tighten(channelBudget)
+	if merged.MaxTotalTokens != 1000 {
+		t.Errorf("expected tokens 1000, got %d", merged.MaxTotalTokens)
+	}
+	if merged.MaxWallTime != time.Minute {
+		t.Errorf("expected wall time 1m, got %s", merged.MaxWallTime)
+	}
+	if merged.MaxToolCalls["exec"] != 2 {
+		t.Errorf("expected exec limit 2, got %d", merged.MaxToolCalls["exec"])
+	}
+	if agentBudget.MaxToolCalls["exec"] != 5 {
+		t.Error("Tighten must not modify the receiver")
+	}
+
+	var nilBudget *RunBudget
+	if !nilBudget.IsZero() || nilBudget.Override(nil) != nil {
+		t.Error("nil budget should be unlimited")
+	}
+}
+
+// blockingEchoTool 一直运行到上下文取消的 echo 工具
+type blockingEchoTool struct{ echoTool }
+
+func (blockingEchoTool) Execute(ctx context.Context, params map[string]any, onUpdate func(ToolResult)) (ToolResult, error) {
+	<-ctx.Done()
+	return ToolResult{}, ctx.Err()
+}
+
+func TestRunBudgetWallTimeStopsRunningTool(t *testing.T) {
+	provider := &loopingProvider{}
+	state := NewAgentState()
+	state.Tools = []Tool{blockingEchoTool{}}
+	orch := NewOrchestrator(&LoopConfig{Provider: provider, MaxIterations: 50}, state)
+	go func() {
+		for range orch.Subscribe() {
+		}
+	}()
+
+	started := time.Now()
+	prompt := AgentMessage{Role: RoleUser, Content: []ContentBlock{TextContent{Text: "go"}}}
+	ctx := WithRunBudget(context.Background(), &RunBudget{MaxWallTime: 100 * time.Millisecond})
+	msgs, err := orch.Run(ctx, []AgentMessage{prompt})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("run should stop at the wall time limit, took %s", elapsed)
+	}
+	last := msgs[len(msgs)-1]
+	if last.Metadata["stop_reason"] != StopReasonBudgetExceeded || extractTextContent(last) != "summary" {
+		t.Errorf("expected budget summary, got %+v", last)
+	}
+}
@@ -0,0 +1,32 @@
+package agent
+
+import "context"
+
+// Checkpoint phases passed to a CheckpointFunc
+const (
+	CheckpointPhaseStarted     = "started"
+	CheckpointPhaseLLMResponse = "llm_response"
+	CheckpointPhaseToolResult  = "tool_result"
+)
+
+// CheckpointFunc persists the messages of a run in progress.
+// It is called after every LLM response and every tool result with the full message list.
+type CheckpointFunc func(phase string, messages []AgentMessage)
+
+type checkpointContextKey struct{}
+
+// WithCheckpointFunc attaches a checkpoint hook to the context of a single run
+func WithCheckpointFunc(ctx context.Context, fn CheckpointFunc) context.Context {
+	return context.WithValue(ctx, checkpointContextKey{}, fn)
+}
+
+// checkpoint invokes the run's checkpoint hook, if any, with messages followed by pending
+func (o *Orchestrator) checkpoint(ctx context.Context, phase string, messages, pending []AgentMessage) {
+	fn, ok := ctx.Value(checkpointContextKey{}).(CheckpointFunc)
+	if !ok || fn == nil {
+		return
+	}
+	all := make([]AgentMessage, 0, len(messages)+len(pending))
+	all = append(all, messages...)
+	fn(phase, append(all, pending...))
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/session"
+	"go.uber.org/zap"
+)
+
+// 会话元数据中的交接相关键
+const (
+	sessionActiveAgentKey = "active_agent" // 当前接管会话的 Agent（覆盖通道绑定）
+	sessionHandoffKey     = "handoff"      // 最近一次交接的说明
+)
+
+// HandoffNote 交接说明
+type HandoffNote struct {
+	FromAgent string    `json:"from_agent"`
+	ToAgent   string    `json:"to_agent"`
+	Summary   string    `json:"summary"`
+	Reason    string    `json:"reason,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// inboundSessionKey 生成入站消息的会话键（包含 account_id 以区分不同账号的消息）
+func inboundSessionKey(msg *bus.InboundMessage) string {
+	if msg.ChatID == "default" || msg.ChatID == "" {
+		return fmt.Sprintf("%s:%s:%d", msg.Channel, msg.AccountID, msg.Timestamp.Unix())
+	}
+	return fmt.Sprintf("%s:%s:%s", msg.Channel, msg.AccountID, msg.ChatID)
+}
+
+// agentIDOf 返回 Agent 的 ID（调用方需持有读锁）
+func (m *AgentManager) agentIDOf(agent *Agent) string {
+	for id, a := range m.agents {
+		if a == agent {
+			return id
+		}
+	}
+	return ""
+}
+
+// sessionAgent 返回接管会话的 Agent；没有交接时返回通道绑定的 Agent（调用方需持有读锁）
+func (m *AgentManager) sessionAgent(sessionKey string, bound *Agent) (*Agent, string) {
+	boundID := m.agentIDOf(bound)
+
+	sess, err := m.sessionMgr.GetOrCreate(sessionKey)
+	if err != nil {
+		return bound, boundID
+	}
+	activeID := getSessionString(sess, sessionActiveAgentKey)
+	if activeID == "" {
+		return bound, boundID
+	}
+	if agent, ok := m.agents[activeID]; ok {
+		return agent, activeID
+	}
+
+	logger.Warn("Session handed off to unknown agent, using bound agent",
+		zap.String("session_key", sessionKey),
+		zap.String("agent_id", activeID))
+	return bound, boundID
+}
+
+// setupHandoff 注册 handoff 工具
+func (m *AgentManager) setupHandoff() {
+	if m.tools == nil || m.tools.Has(tools.HandoffToolName) {
+		return
+	}
+	if err := m.tools.RegisterExisting(tools.NewHandoffTool(m.handoffTargets, m.handleHandoff)); err != nil {
+		logger.Error("Failed to register handoff tool", zap.Error(err))
+		return
+	}
+	logger.Info("Handoff tool registered", zap.Int("agents", len(m.cfg.Agents.List)))
+}
+
+// handoffTargets 返回可以接手对话的 Agent 列表
+func (m *AgentManager) handoffTargets() []tools.HandoffTarget {
+	if m.cfg == nil {
+		return nil
+	}
+
+	targets := make([]tools.HandoffTarget, 0, len(m.cfg.Agents.List))
+	for _, agentCfg := range m.cfg.Agents.List {
+		description, _ := agentCfg.Metadata["description"].(string)
+		targets = append(targets, tools.HandoffTarget{
+			ID:          agentCfg.ID,
+			Name:        agentCfg.Name,
+			Description: description,
+		})
+	}
+	return targets
+}
+
+// handleHandoff 执行交接：修改会话的接管 Agent、记录交接说明并通知用户
+// 在 Agent 运行期间被 handoff 工具调用，此时 RouteInbound 已持有读锁
+func (m *AgentManager) handleHandoff(ctx context.Context, req *tools.HandoffRequest) (string, error) {
+	target, ok := m.agents[req.TargetAgentID]
+	if !ok || target == nil {
+		return "", fmt.Errorf("unknown agent: %s", req.TargetAgentID)
+	}
+
+	sess, err := m.sessionMgr.GetOrCreate(req.SessionKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to load session: %w", err)
+	}
+
+	// 当前接管的 Agent 以及通道绑定的 Agent
+	var bound *Agent
+	var boundID string
+	if req.Origin != nil {
+		if agent, err := m.resolveAgent(req.Origin.Channel, req.Origin.AccountID); err == nil {
+			bound, boundID = agent, m.agentIDOf(agent)
+		}
+	}
+	fromID := getSessionString(sess, sessionActiveAgentKey)
+	if fromID == "" {
+		fromID = boundID
+	}
+	if fromID == req.TargetAgentID {
+		return "", fmt.Errorf("the conversation is already handled by agent %s", req.TargetAgentID)
+	}
+
+	note := &HandoffNote{
+		FromAgent: fromID,
+		ToAgent:   req.TargetAgentID,
+		Summary:   req.Summary,
+		Reason:    req.Reason,
+		At:        time.Now(),
+	}
+
+	// 交还给通道绑定的 Agent 时清除覆盖
+	if target == bound {
+		sess.SetMetadata(sessionActiveAgentKey, nil)
+	} else {
+		sess.SetMetadata(sessionActiveAgentKey, req.TargetAgentID)
+	}
+	sess.SetMetadata(sessionHandoffKey, note)
+	if err := m.sessionMgr.Save(sess); err != nil {
+		logger.Error("Failed to save session", zap.Error(err))
+	}
+
+	logger.Info("Conversation handed off",
+		zap.String("session_key", req.SessionKey),
+		zap.String("from_agent", fromID),
+		zap.String("to_agent", req.TargetAgentID))
+
+	targetName := m.agentDisplayName(req.TargetAgentID)
+	if req.Origin != nil && req.Origin.To != "" {
+		text := fmt.Sprintf("🔀 You are now talking to %s.", targetName)
+		if req.Reason != "" {
+			text += " " + req.Reason
+		}
+		m.publishText(ctx, req.Origin.Channel, req.Origin.To, text, nil)
+	}
+
+	return fmt.Sprintf("The conversation has been handed off to %s (%s). It will answer the user's next message and has received your summary. "+
+		"Do not continue working on the request; end your turn with at most a short sentence to the user.", targetName, req.TargetAgentID), nil
+}
+
+// handoffContext 返回接手 Agent 的交接说明（作为系统提示词片段）
+func handoffContext(sess *session.Session, agentID string) string {
+	note := getHandoffNote(sess)
+	if note == nil || note.ToAgent != agentID {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Handoff\n\n")
+	if note.FromAgent != "" {
+		sb.WriteString(fmt.Sprintf("This conversation was handed off to you by agent `%s`", note.FromAgent))
+	} else {
+		sb.WriteString("This conversation was handed off to you")
+	}
+	sb.WriteString(fmt.Sprintf(" at %s. Continue helping the user from here.\n", note.At.Format(time.RFC3339)))
+	if note.Reason != "" {
+		sb.WriteString(fmt.Sprintf("\nReason: %s\n", note.Reason))
+	}
+	sb.WriteString("\nHandoff note:\n")
+	sb.WriteString(note.Summary)
+	sb.WriteString("\n\nIf the request belongs to another agent, use the handoff tool to transfer the conversation (including back to the previous agent).")
+	return sb.String()
+}
+
+// agentDisplayName 返回 Agent 的显示名称
+func (m *AgentManager) agentDisplayName(agentID string) string {
+	if m.cfg != nil {
+		for _, agentCfg := range m.cfg.Agents.List {
+			if agentCfg.ID == agentID && agentCfg.Name != "" {
+				return agentCfg.Name
+			}
+		}
+	}
+	return agentID
+}
+
+// getHandoffNote 读取会话中的交接说明（从磁盘加载后是 map 形式）
+func getHandoffNote(sess *session.Session) *HandoffNote {
+	switch v := sess.GetMetadata(sessionHandoffKey).(type) {
+	case nil:
+		return nil
+	case *HandoffNote:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var note HandoffNote
+		if err := json.Unmarshal(data, &note); err != nil || note.ToAgent == "" {
+			return nil
+		}
+		return &note
+	}
+}
+
+// getSessionString 读取会话元数据中的字符串
+func getSessionString(sess *session.Session, key string) string {
+	value, _ := sess.GetMetadata(key).(string)
+	return value
+}
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/session"
+)
+
+func TestHandoffRoundTrip(t *testing.T) {
+	sessionMgr, err := session.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create session manager: %v", err)
+	}
+
+	triage, billing := &Agent{}, &Agent{}
+	m := &AgentManager{
+		agents:       map[string]*Agent{"triage": triage, "billing": billing},
+		bindings:     make(map[string]*BindingEntry),
+		defaultAgent: triage,
+		bus:          bus.NewMessageBus(10),
+		sessionMgr:   sessionMgr,
+		cfg: &config.Config{Agents: config.AgentsConfig{List: []config.AgentConfig{
+			{ID: "triage", Name: "Triage"},
+			{ID: "billing", Name: "Billing", Metadata: map[string]interface{}{"description": "Invoices and refunds"}},
+		}}},
+	}
+
+	msg := &bus.InboundMessage{Channel: "telegram", AccountID: "bot", ChatID: "42", Timestamp: time.Now()}
+	sessionKey := inboundSessionKey(msg)
+	origin := &tools.DeliveryContext{Channel: "telegram", AccountID: "bot", To: "42"}
+
+	tool := tools.NewHandoffTool(m.handoffTargets, m.handleHandoff)
+	if !strings.Contains(tool.Description(), "billing (Billing): Invoices and refunds") {
+		t.Errorf("description should list targets:\n%s", tool.Description())
+	}
+
+	ctx := tools.WithDeliveryContext(tools.WithSessionKey(context.Background(), sessionKey), origin)
+	if _, err := tool.Execute(ctx, map[string]interface{}{"agent_id": "billing", "summary": "User wants a refund for order 7"}); err != nil {
+		t.Fatalf("handoff failed: %v", err)
+	}
+
+	// 用户收到通知
+	out, err := m.bus.ConsumeOutbound(context.Background())
+	if err != nil || out.ChatID != "42" || !strings.Contains(out.Content, "Billing") {
+		t.Errorf("expected notification to chat 42, got %+v (err=%v)", out, err)
+	}
+
+	// 后续消息交给 billing，且 billing 能看到交接说明
+	if agent, id := m.sessionAgent(sessionKey, triage); agent != billing || id != "billing" {
+		t.Fatalf("expected session to be routed to billing, got %q", id)
+	}
+	sess, _ := sessionMgr.GetOrCreate(sessionKey)
+	if note := handoffContext(sess, "billing"); !strings.Contains(note, "refund for order 7") {
+		t.Errorf("billing should receive the handoff note, got %q", note)
+	}
+	if note := handoffContext(sess, "triage"); note != "" {
+		t.Errorf("triage should not receive the note, got %q", note)
+	}
+
+	// 不能交接给当前 Agent
+	if _, err := tool.Execute(ctx, map[string]interface{}{"agent_id": "billing", "summary": "again"}); err == nil {
+		t.Error("expected error when handing off to the current agent")
+	}
+
+	// 反向交接回绑定的 Agent 清除覆盖
+	if _, err := tool.Execute(ctx, map[string]interface{}{"agent_id": "triage", "summary": "Refund done"}); err != nil {
+		t.Fatalf("reverse handoff failed: %v", err)
+	}
+	if agent, _ := m.sessionAgent(sessionKey, triage); agent != triage {
+		t.Error("expected session to be routed back to triage")
+	}
+	if sess.GetMetadata(sessionActiveAgentKey) != nil {
+		t.Error("active agent override should be cleared after handing back")
+	}
+	if note := getHandoffNote(sess); note == nil || note.FromAgent != "billing" || note.ToAgent != "triage" {
+		t.Errorf("unexpected handoff note: %+v", note)
+	}
+}
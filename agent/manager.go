@@ -10,7 +10,11 @@ import (
 	"github.com/smallnest/goclaw/agent/tools"
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/access"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
+	"github.com/smallnest/goclaw/internal/telemetry"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"go.uber.org/zap"
@@ -33,6 +37,16 @@ type AgentManager struct {
 	subagentRegistry  *SubagentRegistry
 	subagentAnnouncer *SubagentAnnouncer
 	dataDir           string
+	outputSpool       *tools.OutputSpool
+	auditLog          *audit.Log
+	redactor          *redact.Redactor
+	access            *access.Controller
+	fileCheckpoints   *tools.FileCheckpoints
+	subagentRuns      map[string]*subagentRun // runID -> 运行中（或刚结束）的分身
+	subagentQueue     []*subagentRun          // 超出并发限制、等待启动的分身
+	subagentActive    map[string]int          // agentID -> 运行中的分身数
+	subagentRunning   int                     // 运行中的分身总数
+	subagentMu        sync.Mutex
 }
 
 // BindingEntry Agent 绑定条目
@@ -45,13 +59,18 @@ type BindingEntry struct {
 
 // NewAgentManagerConfig AgentManager 配置
 type NewAgentManagerConfig struct {
-	Bus            *bus.MessageBus
-	Provider       providers.Provider
-	SessionMgr     *session.Manager
-	Tools          *ToolRegistry
-	DataDir        string          // 数据目录，用于存储分身注册表
-	ContextBuilder *ContextBuilder // 上下文构建器
-	SkillsLoader   *SkillsLoader   // 技能加载器
+	Bus             *bus.MessageBus
+	Provider        providers.Provider
+	SessionMgr      *session.Manager
+	Tools           *ToolRegistry
+	DataDir         string                 // 数据目录，用于存储分身注册表
+	ContextBuilder  *ContextBuilder        // 上下文构建器
+	SkillsLoader    *SkillsLoader          // 技能加载器
+	OutputSpool     *tools.OutputSpool     // 超长工具输出缓存
+	AuditLog        *audit.Log             // 工具调用审计日志
+	Redactor        *redact.Redactor       // 工具结果脱敏
+	Access          *access.Controller     // 按发送者角色的访问控制，nil 表示不限制
+	FileCheckpoints *tools.FileCheckpoints // 文件修改前的内容，用于 /undo，nil 表示不保存
 }
 
 // NewAgentManager 创建 Agent 管理器
@@ -74,6 +93,13 @@ func NewAgentManager(cfg *NewAgentManagerConfig) *AgentManager {
 		dataDir:           cfg.DataDir,
 		contextBuilder:    cfg.ContextBuilder,
 		skillsLoader:      cfg.SkillsLoader,
+		outputSpool:       cfg.OutputSpool,
+		auditLog:          cfg.AuditLog,
+		redactor:          cfg.Redactor,
+		access:            cfg.Access,
+		fileCheckpoints:   cfg.FileCheckpoints,
+		subagentRuns:      make(map[string]*subagentRun),
+		subagentActive:    make(map[string]int),
 	}
 }
 
@@ -121,6 +147,14 @@ func (m *AgentManager) SetupFromConfig(cfg *config.Config, contextBuilder *Conte
 
 	logger.Info("Setting up agents from config")
 
+	// 多个 Agent 时注册交接工具（需在创建 Agent 之前，Agent 创建时会快照工具列表）
+	if len(cfg.Agents.List) > 1 {
+		m.setupHandoff()
+	}
+
+	// 分身工具同样需要在创建 Agent 之前注册
+	m.setupSubagentSupport(cfg, contextBuilder)
+
 	// 1. 创建 Agent 实例
 	for _, agentCfg := range cfg.Agents.List {
 		if err := m.createAgent(agentCfg, contextBuilder, cfg); err != nil {
@@ -157,9 +191,6 @@ func (m *AgentManager) SetupFromConfig(cfg *config.Config, contextBuilder *Conte
 		}
 	}
 
-	// 4. 设置分身支持
-	m.setupSubagentSupport(cfg, contextBuilder)
-
 	logger.Info("Agent manager setup complete",
 		zap.Int("agents", len(m.agents)),
 		zap.Int("bindings", len(m.bindings)))
@@ -173,6 +204,10 @@ func (m *AgentManager) setupSubagentSupport(cfg *config.Config, contextBuilder *
 	if err := m.subagentRegistry.LoadFromDisk(); err != nil {
 		logger.Warn("Failed to load subagent registry", zap.Error(err))
 	}
+	// 上次进程退出时未结束的分身已不存在
+	if n := m.subagentRegistry.FailUnfinished("interrupted by restart"); n > 0 {
+		logger.Warn("Marked unfinished subagent runs as failed", zap.Int("runs", n))
+	}
 
 	// 设置分身运行完成回调
 	m.subagentRegistry.SetOnRunComplete(func(runID string, record *SubagentRunRecord) {
@@ -214,15 +249,22 @@ func (m *AgentManager) setupSubagentSupport(cfg *config.Config, contextBuilder *
 		}
 		return agentID
 	})
-	spawnTool.SetOnSpawn(func(result *tools.SubagentSpawnResult) error {
-		return m.handleSubagentSpawn(result)
-	})
+	spawnTool.SetOnSpawn(m.handleSubagentSpawn)
 
 	// 注册工具
 	if err := m.tools.RegisterExisting(spawnTool); err != nil {
 		logger.Error("Failed to register sessions_spawn tool", zap.Error(err))
 	}
 
+	// 注册分身控制工具（subagents_list、subagent_wait 等）
+	for _, tool := range tools.NewSubagentControlTools(m).GetTools() {
+		if err := m.tools.RegisterExisting(tool); err != nil {
+			logger.Error("Failed to register subagent control tool",
+				zap.String("tool", tool.Name()),
+				zap.Error(err))
+		}
+	}
+
 	logger.Info("Subagent support configured")
 }
 
@@ -257,24 +299,6 @@ func (a *subagentRegistryAdapter) RegisterRun(params *tools.SubagentRunParams) e
 	})
 }
 
-// handleSubagentSpawn 处理分身生成
-func (m *AgentManager) handleSubagentSpawn(result *tools.SubagentSpawnResult) error {
-	// 解析子会话密钥
-	_, subagentID, isSubagent := ParseAgentSessionKey(result.ChildSessionKey)
-	if !isSubagent {
-		return fmt.Errorf("invalid subagent session key: %s", result.ChildSessionKey)
-	}
-
-	// TODO: 启动分身运行
-	// 这里需要创建新的 Agent 实例来运行分身任务
-	logger.Info("Subagent spawn handled",
-		zap.String("run_id", result.RunID),
-		zap.String("subagent_id", subagentID),
-		zap.String("child_session_key", result.ChildSessionKey))
-
-	return nil
-}
-
 // sendToSession 发送消息到指定会话
 func (m *AgentManager) sendToSession(sessionKey, message string) error {
 	// 解析会话密钥获取 agent ID
@@ -321,6 +345,20 @@ func (m *AgentManager) createAgent(cfg config.AgentConfig, contextBuilder *Conte
 		maxIterations = 15
 	}
 
+	// 运行预算：默认预算被 Agent 自身预算覆盖
+	budget := NewRunBudget(globalCfg.Agents.Defaults.Budget).Override(NewRunBudget(cfg.Budget))
+	if budget.Unpriced() {
+		logger.Warn("Budget max_cost is set but no pricing is configured, the cost limit has no effect",
+			zap.String("agent_id", cfg.ID))
+	}
+	for channel, channelBudget := range globalCfg.Agents.Defaults.ChannelBudgets {
+		if budget.Tighten(NewRunBudget(&channelBudget)).Unpriced() {
+			logger.Warn("Channel budget max_cost is set but no pricing is configured, the cost limit has no effect",
+				zap.String("agent_id", cfg.ID),
+				zap.String("channel", channel))
+		}
+	}
+
 	// 创建 Agent
 	agent, err := NewAgent(&NewAgentConfig{
 		Bus:          m.bus,
@@ -331,6 +369,10 @@ func (m *AgentManager) createAgent(cfg config.AgentConfig, contextBuilder *Conte
 		Workspace:    workspace,
 		MaxIteration: maxIterations,
 		SkillsLoader: m.skillsLoader,
+		Budget:       budget,
+		OutputSpool:  m.outputSpool,
+		AuditLog:     m.auditLog,
+		Redactor:     m.redactor,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create agent %s: %w", cfg.ID, err)
@@ -341,6 +383,11 @@ func (m *AgentManager) createAgent(cfg config.AgentConfig, contextBuilder *Conte
 		agent.SetSystemPrompt(cfg.SystemPrompt)
 	}
 
+	// 按 Agent 的 MCP 配置过滤工具
+	if cfg.MCP != nil {
+		agent.SetTools(m.agentTools(cfg.ID))
+	}
+
 	// 存储到管理器
 	m.agents[cfg.ID] = agent
 
@@ -390,29 +437,43 @@ func (m *AgentManager) RouteInbound(ctx context.Context, msg *bus.InboundMessage
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	bound, err := m.resolveAgent(msg.Channel, msg.AccountID)
+	if err != nil {
+		return err
+	}
+
+	// 会话被交接给其他 Agent 时由接手的 Agent 处理
+	agent, _ := m.sessionAgent(inboundSessionKey(msg), bound)
+
+	// 按发送者角色检查 Agent 访问权限和速率限制
+	if !m.admitInbound(ctx, msg, agent) {
+		return nil
+	}
+
+	// 处理消息
+	return m.handleInboundMessage(ctx, msg, agent)
+}
+
+// resolveAgent 根据通道和账号查找处理消息的 Agent（调用方需持有读锁）
+func (m *AgentManager) resolveAgent(channel, accountID string) (*Agent, error) {
 	// 构建绑定键
-	bindingKey := fmt.Sprintf("%s:%s", msg.Channel, msg.AccountID)
+	bindingKey := fmt.Sprintf("%s:%s", channel, accountID)
 
 	// 查找绑定的 Agent
-	entry, ok := m.bindings[bindingKey]
-	var agent *Agent
-	if ok {
-		agent = entry.Agent
+	if entry, ok := m.bindings[bindingKey]; ok {
 		logger.Debug("Message routed by binding",
 			zap.String("binding_key", bindingKey),
 			zap.String("agent_id", entry.AgentID))
-	} else if m.defaultAgent != nil {
+		return entry.Agent, nil
+	}
+	if m.defaultAgent != nil {
 		// 使用默认 Agent
-		agent = m.defaultAgent
 		logger.Debug("Message routed to default agent",
-			zap.String("channel", msg.Channel),
-			zap.String("account_id", msg.AccountID))
-	} else {
-		return fmt.Errorf("no agent found for message: %s", bindingKey)
+			zap.String("channel", channel),
+			zap.String("account_id", accountID))
+		return m.defaultAgent, nil
 	}
-
-	// 处理消息
-	return m.handleInboundMessage(ctx, msg, agent)
+	return nil, fmt.Errorf("no agent found for message: %s", bindingKey)
 }
 
 // handleInboundMessage 处理入站消息
@@ -424,9 +485,8 @@ func (m *AgentManager) handleInboundMessage(ctx context.Context, msg *bus.Inboun
 		zap.String("chat_id", msg.ChatID))
 
 	// 生成会话键（包含 account_id 以区分不同账号的消息）
-	sessionKey := fmt.Sprintf("%s:%s:%s", msg.Channel, msg.AccountID, msg.ChatID)
+	sessionKey := inboundSessionKey(msg)
 	if msg.ChatID == "default" || msg.ChatID == "" {
-		sessionKey = fmt.Sprintf("%s:%s:%d", msg.Channel, msg.AccountID, msg.Timestamp.Unix())
 		logger.Info("Creating fresh session", zap.String("session_key", sessionKey))
 	}
 
@@ -437,6 +497,27 @@ func (m *AgentManager) handleInboundMessage(ctx context.Context, msg *bus.Inboun
 		return err
 	}
 
+	// /retry 重新执行被中断的运行
+	if isRetryCommand(msg.Content) {
+		retryMsg, ok := m.prepareRetry(ctx, sess, msg)
+		if !ok {
+			return nil
+		}
+		msg = retryMsg
+	}
+
+	// /status 直接回复会话状态，不调用 LLM
+	if isStatusCommand(msg.Content) {
+		m.publishText(ctx, msg.Channel, msg.ChatID, m.sessionStatus(sess, m.agentIDOf(agent)), nil)
+		return nil
+	}
+
+	// /undo 恢复上一次运行修改过的文件，不调用 LLM
+	if isUndoCommand(msg.Content) {
+		m.publishText(ctx, msg.Channel, msg.ChatID, m.undoLastRun(sess, msg.Content), nil)
+		return nil
+	}
+
 	// 转换为 Agent 消息
 	agentMsg := AgentMessage{
 		Role:      RoleUser,
@@ -458,11 +539,41 @@ func (m *AgentManager) handleInboundMessage(ctx context.Context, msg *bus.Inboun
 	// 获取 Agent 的 orchestrator
 	orchestrator := agent.GetOrchestrator()
 
+	// 工具按会话隔离数据（例如输出缓存）
+	ctx = tools.WithSessionKey(ctx, sessionKey)
+	ctx = tools.WithAgentID(ctx, m.agentIDOf(agent))
+	ctx = tools.WithSenderID(ctx, msg.SenderID)
+	ctx = tools.WithDeliveryContext(ctx, &tools.DeliveryContext{
+		Channel:   msg.Channel,
+		AccountID: msg.AccountID,
+		To:        msg.ChatID,
+	})
+
+	// 接手的 Agent 在系统提示词中看到交接说明
+	if note := handoffContext(sess, m.agentIDOf(agent)); note != "" {
+		ctx = WithSystemContext(ctx, note)
+	}
+	// 用户撤销了上一次运行的文件修改
+	if note := undoContext(sess); note != "" {
+		ctx = WithSystemContext(ctx, note)
+	}
+
+	// 应用运行预算（通道预算和发送者角色预算只会收紧 Agent 预算），并按角色限制工具
+	ctx, budget := m.applyAccessPolicy(ctx, msg.Channel, msg.SenderID, m.resolveRunBudget(agent, msg.Channel))
+	if !budget.IsZero() {
+		ctx = WithRunBudget(ctx, budget)
+	}
+
 	// 加载历史消息并添加当前消息
 	history := sess.GetHistory(-1) // -1 表示加载所有历史消息
 	historyAgentMsgs := sessionMessagesToAgentMessages(history)
 	allMessages := append(historyAgentMsgs, agentMsg)
 
+	// 每次 LLM 响应和工具结果后保存检查点，进程重启后可以恢复
+	cp := newRunCheckpoint(sessionKey, msg)
+	ctx = tools.WithRunID(ctx, cp.RunID)
+	ctx = m.trackRun(ctx, sess, cp, allMessages, len(history))
+
 	logger.Info("About to call orchestrator.Run",
 		zap.String("session_key", sessionKey),
 		zap.Int("history_count", len(history)),
@@ -493,9 +604,16 @@ func (m *AgentManager) handleInboundMessage(ctx context.Context, msg *bus.Inboun
 					return getErr
 				}
 				// Retry with fresh session (no history)
-				finalMessages, retryErr := orchestrator.Run(ctx, []AgentMessage{agentMsg})
+				retryCtx := m.trackRun(ctx, sess, cp, []AgentMessage{agentMsg}, 0)
+				finalMessages, retryErr := orchestrator.Run(retryCtx, []AgentMessage{agentMsg})
+				if !runInterrupted(retryCtx, retryErr) {
+					sess.ClearCheckpoint()
+				}
 				if retryErr != nil {
 					logger.Error("Agent execution failed on retry", zap.Error(retryErr))
+					if saveErr := m.sessionMgr.Save(sess); saveErr != nil {
+						logger.Error("Failed to save session", zap.Error(saveErr))
+					}
 					return retryErr
 				}
 				// Update session with new messages
@@ -511,23 +629,43 @@ func (m *AgentManager) handleInboundMessage(ctx context.Context, msg *bus.Inboun
 			}
 		}
 		logger.Error("Agent execution failed", zap.Error(err))
+		// 进程退出等原因取消的运行保留检查点，重启后恢复；其他错误清除检查点，已修改的文件仍可以 /undo
+		if !runInterrupted(ctx, err) {
+			sess.ClearCheckpoint()
+		}
+		finishFileChanges(m.fileCheckpoints, sessionKey, cp.RunID, msg.Content, m.dataDir)
+		if saveErr := m.sessionMgr.Save(sess); saveErr != nil {
+			logger.Error("Failed to save session", zap.Error(saveErr))
+		}
 		return err
 	}
 
 	// 更新会话（只保存新产生的消息）
+	sess.ClearCheckpoint()
 	m.updateSession(sess, finalMessages, len(history))
 
-	// 发布响应
+	// 发布响应（附加本次修改的文件列表）
 	if len(finalMessages) > 0 {
 		lastMsg := finalMessages[len(finalMessages)-1]
 		if lastMsg.Role == RoleAssistant {
-			m.publishToBus(ctx, msg.Channel, msg.ChatID, lastMsg)
+			m.publishToBus(ctx, msg.Channel, msg.ChatID, m.withFileChanges(lastMsg, sessionKey, cp.RunID, msg.Content))
 		}
 	}
 
 	return nil
 }
 
+// resolveRunBudget 解析 Agent 在指定通道上的运行预算
+func (m *AgentManager) resolveRunBudget(agent *Agent, channel string) *RunBudget {
+	budget := agent.GetBudget()
+	if m.cfg != nil {
+		if channelBudget, ok := m.cfg.Agents.Defaults.ChannelBudgets[channel]; ok {
+			budget = budget.Tighten(NewRunBudget(&channelBudget))
+		}
+	}
+	return budget
+}
+
 // updateSession 更新会话
 func (m *AgentManager) updateSession(sess *session.Session, messages []AgentMessage, historyLen int) {
 	// 只保存新产生的消息（不包括历史消息）
@@ -536,7 +674,35 @@ func (m *AgentManager) updateSession(sess *session.Session, messages []AgentMess
 		newMessages = messages[historyLen:]
 	}
 
-	for _, msg := range newMessages {
+	for _, sessMsg := range agentMessagesToSessionMessages(newMessages) {
+		sess.AddMessage(sessMsg)
+	}
+
+	if err := m.sessionMgr.Save(sess); err != nil {
+		logger.Error("Failed to save session", zap.Error(err))
+	}
+}
+
+// publishToBus 发布消息到总线
+func (m *AgentManager) publishToBus(ctx context.Context, channel, chatID string, msg AgentMessage) {
+	content := extractTextContent(msg)
+
+	outbound := &bus.OutboundMessage{
+		Channel:   channel,
+		ChatID:    chatID,
+		Content:   content,
+		Timestamp: time.Unix(msg.Timestamp/1000, 0),
+	}
+
+	if err := m.bus.PublishOutbound(ctx, outbound); err != nil {
+		logger.Error("Failed to publish outbound", zap.Error(err))
+	}
+}
+
+// agentMessagesToSessionMessages 将 Agent 消息转换为 session 消息
+func agentMessagesToSessionMessages(messages []AgentMessage) []session.Message {
+	result := make([]session.Message, 0, len(messages))
+	for _, msg := range messages {
 		sessMsg := session.Message{
 			Role:      string(msg.Role),
 			Content:   extractTextContent(msg),
@@ -568,28 +734,9 @@ func (m *AgentManager) updateSession(sess *session.Session, messages []AgentMess
 			}
 		}
 
-		sess.AddMessage(sessMsg)
-	}
-
-	if err := m.sessionMgr.Save(sess); err != nil {
-		logger.Error("Failed to save session", zap.Error(err))
-	}
-}
-
-// publishToBus 发布消息到总线
-func (m *AgentManager) publishToBus(ctx context.Context, channel, chatID string, msg AgentMessage) {
-	content := extractTextContent(msg)
-
-	outbound := &bus.OutboundMessage{
-		Channel:   channel,
-		ChatID:    chatID,
-		Content:   content,
-		Timestamp: time.Unix(msg.Timestamp/1000, 0),
-	}
-
-	if err := m.bus.PublishOutbound(ctx, outbound); err != nil {
-		logger.Error("Failed to publish outbound", zap.Error(err))
+		result = append(result, sessMsg)
 	}
+	return result
 }
 
 // sessionMessagesToAgentMessages 将 session 消息转换为 Agent 消息
@@ -668,8 +815,11 @@ func (m *AgentManager) Start(ctx context.Context) error {
 		}
 	}
 
-	// 启动消息处理器
-	go m.processMessages(ctx)
+	// 启动消息处理器（先恢复上次中断的运行）
+	go func() {
+		m.recoverInterruptedRuns(ctx)
+		m.processMessages(ctx)
+	}()
 
 	return nil
 }
@@ -707,7 +857,11 @@ func (m *AgentManager) processMessages(ctx context.Context) {
 				continue
 			}
 
-			if err := m.RouteInbound(ctx, msg); err != nil {
+			// 处理消息的 span 接在入站消息的链路上，回复也会继承它
+			msgCtx, span := consumeInboundSpan(ctx, msg)
+			err = m.RouteInbound(msgCtx, msg)
+			telemetry.End(span, err)
+			if err != nil {
 				logger.Error("Failed to route message",
 					zap.String("channel", msg.Channel),
 					zap.String("account_id", msg.AccountID),
@@ -734,11 +888,16 @@ func (m *AgentManager) GetToolsInfo() (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	for _, tool := range existingTools {
-		result[tool.Name()] = map[string]interface{}{
+		info := map[string]interface{}{
 			"name":        tool.Name(),
 			"description": tool.Description(),
 			"parameters":  tool.Parameters(),
 		}
+		// 分身生成工具附带并发、排队和深度限制
+		if tool.Name() == "sessions_spawn" {
+			info["limits"] = m.SubagentStats()
+		}
+		result[tool.Name()] = info
 	}
 
 	return result, nil
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/access"
+)
+
+// mcpTool MCP 服务器提供的工具实现此接口，返回所属的服务器
+type mcpTool interface {
+	MCPServer() string
+}
+
+// allowMCPTool 按 Agent 的 MCP 配置判断工具是否可用，非 MCP 工具不受影响
+func allowMCPTool(cfg *config.AgentMCPConfig, tool tools.Tool) bool {
+	remote, ok := tool.(mcpTool)
+	if !ok || cfg == nil {
+		return true
+	}
+
+	if len(cfg.Servers) > 0 && !matchAnyTool(cfg.Servers, remote.MCPServer()) {
+		return false
+	}
+	if matchAnyTool(cfg.DenyTools, tool.Name()) {
+		return false
+	}
+	return len(cfg.AllowTools) == 0 || matchAnyTool(cfg.AllowTools, tool.Name())
+}
+
+// matchAnyTool 判断名称是否匹配任一模式（支持 * 通配）
+func matchAnyTool(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if access.MatchTool(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// agentTools 返回 Agent 可用的工具（调用方需持有锁）
+func (m *AgentManager) agentTools(agentID string) []Tool {
+	var mcpCfg *config.AgentMCPConfig
+	if cfg := m.agentConfig(agentID); cfg != nil {
+		mcpCfg = cfg.MCP
+	}
+
+	all := m.tools.ListExisting()
+	allowed := make([]tools.Tool, 0, len(all))
+	for _, tool := range all {
+		if allowMCPTool(mcpCfg, tool) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return ToAgentTools(allowed)
+}
+
+// RefreshTools 工具注册表变化后（例如 MCP 服务器上线或工具列表变化）更新所有 Agent 的工具列表
+func (m *AgentManager) RefreshTools() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for id, agent := range m.agents {
+		agent.SetTools(m.agentTools(id))
+	}
+}
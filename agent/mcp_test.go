@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+)
+
+// fakeMCPTool 模拟 MCP 服务器提供的工具
+type fakeMCPTool struct {
+	server string
+	name   string
+}
+
+func (t fakeMCPTool) Name() string        { return t.server + "__" + t.name }
+func (t fakeMCPTool) Description() string { return t.name }
+func (t fakeMCPTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t fakeMCPTool) MCPServer() string { return t.server }
+func (t fakeMCPTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+// plainTool 内置工具
+type plainTool struct{ name string }
+
+func (t plainTool) Name() string        { return t.name }
+func (t plainTool) Description() string { return t.name }
+func (t plainTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t plainTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func TestAllowMCPTool(t *testing.T) {
+	cfg := &config.AgentMCPConfig{
+		Servers:   []string{"github", "docs"},
+		DenyTools: []string{"github__delete_*"},
+	}
+
+	cases := []struct {
+		tool    tools.Tool
+		allowed bool
+	}{
+		{fakeMCPTool{"github", "list_issues"}, true},
+		{fakeMCPTool{"github", "delete_repo"}, false},
+		{fakeMCPTool{"slack", "post"}, false},
+		{plainTool{name: "exec"}, true},
+	}
+	for _, c := range cases {
+		if got := allowMCPTool(cfg, c.tool); got != c.allowed {
+			t.Errorf("%s: allowed=%v, want %v", c.tool.Name(), got, c.allowed)
+		}
+	}
+
+	if !allowMCPTool(nil, fakeMCPTool{"slack", "post"}) {
+		t.Error("agents without mcp config should see every MCP tool")
+	}
+}
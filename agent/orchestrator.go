@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/smallnest/goclaw/agent/tools"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/metrics"
+	"github.com/smallnest/goclaw/internal/telemetry"
 	"github.com/smallnest/goclaw/providers"
 	"go.uber.org/zap"
 )
@@ -37,6 +40,9 @@ func (o *Orchestrator) Run(ctx context.Context, prompts []AgentMessage) ([]Agent
 	ctx, cancel := context.WithCancel(ctx)
 	o.cancelFunc = cancel
 
+	ctx, span := o.startRunSpan(ctx)
+	start := time.Now()
+
 	// Initialize state with prompts
 	newMessages := make([]AgentMessage, len(prompts))
 	copy(newMessages, prompts)
@@ -48,6 +54,8 @@ func (o *Orchestrator) Run(ctx context.Context, prompts []AgentMessage) ([]Agent
 
 	// Main loop
 	finalMessages, err := o.runLoop(ctx, currentState)
+	telemetry.End(span, err)
+	metrics.ObserveRun(metricsAgentID(ctx), time.Since(start), err)
 
 	logger.Info("=== Orchestrator Run End ===",
 		zap.Int("final_messages_count", len(finalMessages)),
@@ -71,6 +79,19 @@ func (o *Orchestrator) Run(ctx context.Context, prompts []AgentMessage) ([]Agent
 // runLoop implements the main agent loop logic
 func (o *Orchestrator) runLoop(ctx context.Context, state *AgentState) ([]AgentMessage, error) {
 	firstTurn := true
+	budget := o.resolveBudget(ctx)
+	tracker := NewBudgetTracker(budget)
+	defer recordRunUsage(ctx, tracker)
+	schemaFailures := NewSchemaFailureTracker()
+
+	// LLM and tool calls stop at the wall time limit instead of running past it;
+	// the budget summary turn still uses ctx
+	runCtx := ctx
+	if budget != nil && budget.MaxWallTime > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, budget.MaxWallTime)
+		defer cancel()
+	}
 
 	// Check for steering messages at start
 	pendingMessages := o.fetchSteeringMessages()
@@ -98,21 +119,35 @@ func (o *Orchestrator) runLoop(ctx context.Context, state *AgentState) ([]AgentM
 				pendingMessages = []AgentMessage{}
 			}
 
+			// Stop before the next LLM call if the budget is used up
+			if tracker.Check() != "" {
+				return o.finishOverBudget(ctx, state, tracker)
+			}
+
 			// Stream assistant response
-			assistantMsg, err := o.streamAssistantResponse(ctx, state)
+			assistantMsg, err := o.streamAssistantResponse(runCtx, state)
 			if err != nil {
+				if ctx.Err() == nil && tracker.Check() != "" {
+					return o.finishOverBudget(ctx, state, tracker)
+				}
 				o.emitErrorEnd(state, err)
 				return state.Messages, err
 			}
 
+			tracker.RecordIteration()
+			if usage, ok := assistantMsg.Metadata["usage"].(providers.Usage); ok {
+				tracker.RecordUsage(usage)
+			}
+
 			state.AddMessage(assistantMsg)
+			o.checkpoint(ctx, CheckpointPhaseLLMResponse, state.Messages, nil)
 
 			// Check for tool calls
 			toolCalls := extractToolCalls(assistantMsg)
 			hasMoreToolCalls = len(toolCalls) > 0
 
 			if hasMoreToolCalls {
-				results, steering := o.executeToolCalls(ctx, toolCalls, state)
+				results, steering := o.executeToolCalls(runCtx, toolCalls, state, tracker, schemaFailures)
 				steeringAfterTools = len(steering) > 0
 
 				// Add tool result messages
@@ -120,6 +155,10 @@ func (o *Orchestrator) runLoop(ctx context.Context, state *AgentState) ([]AgentM
 					state.AddMessage(result)
 				}
 
+				if tracker.Check() != "" {
+					return o.finishOverBudget(ctx, state, tracker)
+				}
+
 				// If steering messages arrived, skip remaining tools
 				if steeringAfterTools {
 					pendingMessages = steering
@@ -183,7 +222,7 @@ func (o *Orchestrator) streamAssistantResponse(ctx context.Context, state *Agent
 	}
 
 	// Prepare tool definitions
-	toolDefs := convertToToolDefinitions(state.Tools)
+	toolDefs := convertToToolDefinitions(runTools(ctx, state))
 
 	// Emit message start
 	o.emit(NewEvent(EventMessageStart))
@@ -213,6 +252,20 @@ func (o *Orchestrator) streamAssistantResponse(ctx context.Context, state *Agent
 			Content: state.SystemPrompt,
 		})
 	}
+
+	// Append per-run context (e.g. handoff notes) and the session task list to the system prompt
+	extra := systemContextFromContext(ctx)
+	if todos := o.sessionTodos(ctx); todos != "" {
+		extra = append(append([]string(nil), extra...), todos)
+	}
+	if len(extra) > 0 {
+		section := strings.Join(extra, "\n\n")
+		if len(fullMessages) > 0 {
+			fullMessages[0].Content += "\n\n" + section
+		} else {
+			fullMessages = append(fullMessages, providers.Message{Role: "system", Content: section})
+		}
+	}
 	fullMessages = append(fullMessages, providerMsgs...)
 
 	logger.Info("=== Calling LLM ===",
@@ -244,13 +297,106 @@ func (o *Orchestrator) streamAssistantResponse(ctx context.Context, state *Agent
 	return assistantMsg, nil
 }
 
+// resolveBudget returns the budget for this run, including the MaxIterations guard
+func (o *Orchestrator) resolveBudget(ctx context.Context) *RunBudget {
+	budget := o.config.Budget
+	if ctxBudget := RunBudgetFromContext(ctx); ctxBudget != nil {
+		budget = ctxBudget
+	}
+	if o.config.MaxIterations > 0 {
+		budget = budget.Tighten(&RunBudget{MaxIterations: o.config.MaxIterations})
+	}
+	return budget
+}
+
+// finishOverBudget ends a run whose budget was exceeded with a tool-less summarization turn
+func (o *Orchestrator) finishOverBudget(ctx context.Context, state *AgentState, tracker *BudgetTracker) ([]AgentMessage, error) {
+	report := tracker.Report()
+	logger.Warn("=== Run Budget Exceeded ===",
+		zap.String("session_key", state.SessionKey),
+		zap.String("reason", report.Reason),
+		zap.Int("iterations", report.Iterations),
+		zap.Int("total_tokens", report.TotalTokens),
+		zap.Float64("cost", report.Cost),
+		zap.Int64("elapsed_ms", report.ElapsedMs))
+
+	// The notice is only sent to the LLM, it is not kept in the conversation
+	summaryState := state.Clone()
+	summaryState.Tools = nil
+	summaryState.AddMessage(AgentMessage{
+		Role:      RoleUser,
+		Content:   []ContentBlock{TextContent{Text: buildBudgetSummaryPrompt(report)}},
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	summaryMsg, err := o.streamAssistantResponse(ctx, summaryState)
+	if err != nil {
+		logger.Error("Budget summarization turn failed", zap.Error(err))
+		summaryMsg = AgentMessage{
+			Role: RoleAssistant,
+			Content: []ContentBlock{TextContent{Text: fmt.Sprintf(
+				"I had to stop because this run exceeded its budget (%s). Send a follow-up message if you want me to continue.",
+				report.Reason)}},
+			Timestamp: time.Now().UnixMilli(),
+			Metadata:  map[string]any{},
+		}
+	}
+	if summaryMsg.Metadata == nil {
+		summaryMsg.Metadata = map[string]any{}
+	}
+	summaryMsg.Metadata["stop_reason"] = StopReasonBudgetExceeded
+	summaryMsg.Metadata["budget"] = report
+	state.AddMessage(summaryMsg)
+
+	o.emit(NewEvent(EventTurnEnd).WithStopReason(StopReasonBudgetExceeded).WithBudget(report))
+
+	return state.Messages, nil
+}
+
+// budgetSkippedResult builds the tool result for a call skipped because the budget was exceeded
+func budgetSkippedResult(tc ToolCallContent, reason string) AgentMessage {
+	return AgentMessage{
+		Role:      RoleToolResult,
+		Content:   []ContentBlock{TextContent{Text: fmt.Sprintf("Tool call skipped: run budget exceeded (%s)", reason)}},
+		Timestamp: time.Now().UnixMilli(),
+		Metadata: map[string]any{
+			"tool_call_id": tc.ID,
+			"tool_name":    tc.Name,
+			"error":        StopReasonBudgetExceeded,
+		},
+	}
+}
+
 // executeToolCalls executes tool calls with interruption support
-func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCallContent, state *AgentState) ([]AgentMessage, []AgentMessage) {
+func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCallContent, state *AgentState, tracker *BudgetTracker, schemaFailures *SchemaFailureTracker) ([]AgentMessage, []AgentMessage) {
 	results := make([]AgentMessage, 0, len(toolCalls))
 
 	logger.Info("=== Execute Tool Calls Start ===",
 		zap.Int("count", len(toolCalls)))
 	for _, tc := range toolCalls {
+		// Every tool call needs a result, so calls over budget are answered without running them
+		if tracker.Check() != "" || !tracker.AllowToolCall(tc.Name) {
+			logger.Warn("Tool call skipped, run budget exceeded",
+				zap.String("tool_id", tc.ID),
+				zap.String("tool_name", tc.Name),
+				zap.String("reason", tracker.Exceeded()))
+			results = append(results, budgetSkippedResult(tc, tracker.Exceeded()))
+			metrics.RecordToolCall(tc.Name, metrics.StatusSkipped)
+			o.auditSkippedToolCall(ctx, tc, "run budget exceeded: "+tracker.Exceeded())
+			continue
+		}
+
+		// Side-effecting calls after untrusted content wait for the user to confirm
+		if o.config.Untrusted.CallRequiresApproval(tc) && (untrustedSinceUser(state.Messages) || untrustedSinceUser(results)) {
+			logger.Warn("Tool call blocked, approval required after untrusted content",
+				zap.String("tool_id", tc.ID),
+				zap.String("tool_name", tc.Name))
+			results = append(results, approvalRequiredResult(tc))
+			metrics.RecordToolCall(tc.Name, metrics.StatusSkipped)
+			o.auditBlockedToolCall(ctx, tc, "approval required after untrusted content")
+			continue
+		}
+
 		logger.Info("Tool call start",
 			zap.String("tool_id", tc.ID),
 			zap.String("tool_name", tc.Name),
@@ -258,10 +404,14 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 
 		// Emit tool execution start
 		o.emit(NewEvent(EventToolExecutionStart).WithToolExecution(tc.ID, tc.Name, tc.Arguments))
+		toolCtx, span := startToolSpan(ctx, tc)
+		// The sandbox picks the image configured for the skills loaded so far
+		toolCtx = tools.WithLoadedSkills(toolCtx, state.LoadedSkills)
+		started := time.Now()
 
-		// Find tool
+		// Find tool (tools hidden by the run's tool policy cannot be called)
 		var tool Tool
-		for _, t := range state.Tools {
+		for _, t := range runTools(ctx, state) {
 			if t.Name() == tc.Name {
 				tool = t
 				break
@@ -270,6 +420,21 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 
 		var result ToolResult
 		var err error
+		var schemaErr error
+		untrusted := false
+
+		// Validate arguments against the tool schema, repairing common mistakes
+		args := tc.Arguments
+		if tool != nil {
+			var repairs []string
+			args, repairs, schemaErr = validateToolArguments(tool, tc.Arguments)
+			if len(repairs) > 0 {
+				logger.Info("Tool arguments repaired",
+					zap.String("tool_id", tc.ID),
+					zap.String("tool_name", tc.Name),
+					zap.Strings("repairs", repairs))
+			}
+		}
 
 		if tool == nil {
 			err = fmt.Errorf("tool %s not found", tc.Name)
@@ -280,11 +445,24 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 			logger.Error("Tool not found",
 				zap.String("tool_name", tc.Name),
 				zap.String("tool_id", tc.ID))
+		} else if schemaErr != nil {
+			failures := schemaFailures.RecordFailure(tc.Name)
+			err = schemaErr
+			result = ToolResult{
+				Content: []ContentBlock{TextContent{Text: formatSchemaError(tool, schemaErr, failures)}},
+				Details: map[string]any{"error": ErrInvalidToolArguments, "schema_failures": failures},
+			}
+			logger.Warn("Tool arguments failed schema validation",
+				zap.String("tool_id", tc.ID),
+				zap.String("tool_name", tc.Name),
+				zap.Int("consecutive_failures", failures),
+				zap.Error(schemaErr))
 		} else {
+			schemaFailures.RecordSuccess(tc.Name)
 			state.AddPendingTool(tc.ID)
 
 			// Execute tool with streaming support
-			result, err = tool.Execute(ctx, tc.Arguments, func(partial ToolResult) {
+			result, err = tool.Execute(toolCtx, args, func(partial ToolResult) {
 				// Emit update event
 				o.emit(NewEvent(EventToolExecutionUpdate).
 					WithToolExecution(tc.ID, tc.Name, tc.Arguments).
@@ -292,7 +470,19 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 			})
 
 			state.RemovePendingTool(tc.ID)
+
+			if err == nil {
+				untrusted = o.config.Untrusted.IsUntrusted(tc.Name) || o.readsUntrustedSpool(toolCtx, tc)
+				result.Content = o.redactOutput(result.Content)
+				result.Content = o.spoolOutput(toolCtx, tc, result.Content, untrusted)
+				if untrusted {
+					result.Content = o.config.Untrusted.Wrap(toolCtx, tc, result.Content)
+				}
+			}
 		}
+		telemetry.End(span, err)
+		recordToolCall(tc.Name, err)
+		o.auditToolCall(ctx, tc, result.Content, err, time.Since(started))
 
 		// Log tool execution result
 		if err != nil {
@@ -320,13 +510,25 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 			Metadata:  map[string]any{"tool_call_id": tc.ID, "tool_name": tc.Name},
 		}
 
-		if err != nil {
+		if err == nil && untrusted {
+			resultMsg.Metadata["untrusted"] = true
+		}
+
+		if schemaErr != nil {
+			// Keep the structured validation message so the model can self-correct
+			resultMsg.Metadata["error"] = ErrInvalidToolArguments
+			resultMsg.Metadata["validation_errors"] = schemaErr.Error()
+			resultMsg.Metadata["schema_failures"] = schemaFailures.Failures(tc.Name)
+		} else if err != nil {
 			resultMsg.Metadata["error"] = err.Error()
 			result.Content = []ContentBlock{TextContent{Text: err.Error()}}
 		}
 
 		results = append(results, resultMsg)
 
+		// Persist progress so a restart does not lose finished tool calls
+		o.checkpoint(ctx, CheckpointPhaseToolResult, state.Messages, results)
+
 		// Check for use_skill and update LoadedSkills
 		if tc.Name == "use_skill" && err == nil {
 			if skillName, ok := tc.Arguments["skill_name"].(string); ok && skillName != "" {
@@ -366,6 +568,66 @@ func (o *Orchestrator) executeToolCalls(ctx context.Context, toolCalls []ToolCal
 	return results, nil
 }
 
+// redactOutput masks secrets in text output before it is shown to the model or spooled
+func (o *Orchestrator) redactOutput(content []ContentBlock) []ContentBlock {
+	if o.config.Redactor == nil {
+		return content
+	}
+	redacted := make([]ContentBlock, len(content))
+	for i, block := range content {
+		if text, ok := block.(TextContent); ok {
+			text.Text = o.config.Redactor.String(text.Text)
+			block = text
+		}
+		redacted[i] = block
+	}
+	return redacted
+}
+
+// readsUntrustedSpool reports whether a read_output call pages through spooled output of an untrusted tool
+func (o *Orchestrator) readsUntrustedSpool(ctx context.Context, tc ToolCallContent) bool {
+	if o.config.Untrusted == nil || o.config.OutputSpool == nil || tc.Name != tools.ReadOutputToolName {
+		return false
+	}
+	handle, _ := tc.Arguments["handle"].(string)
+	return o.config.OutputSpool.Untrusted(tools.SessionKeyFromContext(ctx), handle)
+}
+
+// spoolOutput replaces oversized text output with a preview and a read_output handle.
+// Output of untrusted tools is marked so read_output wraps it as well
+func (o *Orchestrator) spoolOutput(ctx context.Context, tc ToolCallContent, content []ContentBlock, untrusted bool) []ContentBlock {
+	if o.config.OutputSpool == nil {
+		return content
+	}
+
+	text := extractToolResultContent(content)
+	preview, spooled, err := o.config.OutputSpool.Spool(tools.SessionKeyFromContext(ctx), tc.Name, text, untrusted)
+	if err != nil {
+		logger.Warn("Failed to spool tool output, returning it inline",
+			zap.String("tool_id", tc.ID),
+			zap.String("tool_name", tc.Name),
+			zap.Error(err))
+		return content
+	}
+	if !spooled {
+		return content
+	}
+
+	logger.Info("Tool output spooled",
+		zap.String("tool_id", tc.ID),
+		zap.String("tool_name", tc.Name),
+		zap.Int("output_length", len(text)))
+
+	// Keep non-text blocks (e.g. images) and replace the text with the preview
+	result := []ContentBlock{TextContent{Text: preview}}
+	for _, block := range content {
+		if _, ok := block.(TextContent); !ok {
+			result = append(result, block)
+		}
+	}
+	return result
+}
+
 // emit sends an event to the event channel
 func (o *Orchestrator) emit(event *Event) {
 	if o.eventChan != nil {
@@ -399,6 +661,18 @@ func (o *Orchestrator) fetchFollowUpMessages() []AgentMessage {
 	return o.state.DequeueFollowUpMessages()
 }
 
+// sessionTodos renders the task list of the running session for the system prompt
+func (o *Orchestrator) sessionTodos(ctx context.Context) string {
+	if o.config.ContextBuilder == nil || o.config.SessionMgr == nil {
+		return ""
+	}
+	sess, err := o.config.SessionMgr.GetOrCreate(tools.SessionKeyFromContext(ctx))
+	if err != nil {
+		return ""
+	}
+	return o.config.ContextBuilder.buildTodos(sess.GetTodos())
+}
+
 // Stop stops the orchestrator
 func (o *Orchestrator) Stop() {
 	if o.cancelFunc != nil {
@@ -496,7 +770,7 @@ func convertFromProviderResponse(response *providers.Response) AgentMessage {
 		Role:      RoleAssistant,
 		Content:   content,
 		Timestamp: time.Now().UnixMilli(),
-		Metadata:  map[string]any{"stop_reason": response.FinishReason},
+		Metadata:  map[string]any{"stop_reason": response.FinishReason, "usage": response.Usage},
 	}
 }
 
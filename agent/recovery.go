@@ -0,0 +1,368 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/session"
+	"go.uber.org/zap"
+)
+
+// RetryCommand 用户重新执行被中断运行的命令
+const RetryCommand = "/retry"
+
+// 恢复模式
+const (
+	RecoveryModeNotify = "notify"
+	RecoveryModeResume = "resume"
+	RecoveryModeOff    = "off"
+)
+
+// defaultReadOnlyTools 续跑时可以安全重新执行的工具（无副作用）
+var defaultReadOnlyTools = []string{
+	"read_file",
+	"list_dir",
+	"glob",
+	"grep",
+	"read_config",
+	"web_search",
+	"web_fetch",
+	"smart_search",
+	"memory_search",
+}
+
+// newRunCheckpoint 为入站消息创建运行检查点
+func newRunCheckpoint(sessionKey string, msg *bus.InboundMessage) *session.RunCheckpoint {
+	now := time.Now()
+	return &session.RunCheckpoint{
+		RunID:      uuid.New().String(),
+		SessionKey: sessionKey,
+		Status:     session.CheckpointStatusRunning,
+		Phase:      CheckpointPhaseStarted,
+		Channel:    msg.Channel,
+		AccountID:  msg.AccountID,
+		ChatID:     msg.ChatID,
+		SenderID:   msg.SenderID,
+		Prompt:     msg.Content,
+		StartedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// trackRun 保存初始检查点，并返回在每次 LLM 响应和工具结果后更新检查点的上下文
+// messages 为传给 orchestrator 的全部消息，historyLen 之前的部分已保存在会话中
+func (m *AgentManager) trackRun(ctx context.Context, sess *session.Session, cp *session.RunCheckpoint, messages []AgentMessage, historyLen int) context.Context {
+	save := func(phase string, messages []AgentMessage) {
+		if historyLen < len(messages) {
+			cp.Messages = agentMessagesToSessionMessages(messages[historyLen:])
+		}
+		cp.Todos = sess.GetTodos()
+		cp.Phase = phase
+		cp.Status = session.CheckpointStatusRunning
+		cp.UpdatedAt = time.Now()
+		sess.SetCheckpoint(cp)
+		if err := m.sessionMgr.Save(sess); err != nil {
+			logger.Warn("Failed to save run checkpoint",
+				zap.String("session_key", sess.Key),
+				zap.String("run_id", cp.RunID),
+				zap.Error(err))
+		}
+	}
+
+	save(CheckpointPhaseStarted, messages)
+	return WithCheckpointFunc(ctx, save)
+}
+
+// runInterrupted 判断运行是否因上下文取消而停止（例如进程退出时 Orchestrator.Stop），
+// 此时检查点需要保留，重启后恢复
+func runInterrupted(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) || ctx.Err() != nil
+}
+
+// isRetryCommand 判断消息是否为 /retry 命令
+func isRetryCommand(content string) bool {
+	return isChatCommand(content, RetryCommand)
+}
+
+// isChatCommand 判断消息是否为指定命令（兼容 Telegram 的 /command@bot 形式）
+func isChatCommand(content, command string) bool {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return false
+	}
+	return strings.SplitN(fields[0], "@", 2)[0] == command
+}
+
+// prepareRetry 将 /retry 命令替换为被中断运行的原始消息
+// 没有可重试的运行时通知用户并返回 false
+func (m *AgentManager) prepareRetry(ctx context.Context, sess *session.Session, msg *bus.InboundMessage) (*bus.InboundMessage, bool) {
+	cp := sess.GetCheckpoint()
+	if cp == nil || cp.Prompt == "" {
+		m.publishText(ctx, msg.Channel, msg.ChatID, "There is no interrupted run to retry.", nil)
+		return nil, false
+	}
+
+	logger.Info("Retrying interrupted run",
+		zap.String("session_key", sess.Key),
+		zap.String("run_id", cp.RunID))
+
+	// 中断运行的部分消息被丢弃，原始消息会重新执行
+	sess.ClearCheckpoint()
+
+	retry := *msg
+	retry.Content = cp.Prompt
+	return &retry, true
+}
+
+// recoverInterruptedRuns 在启动时检测上次进程退出时仍在运行的 Agent 运行
+func (m *AgentManager) recoverInterruptedRuns(ctx context.Context) {
+	mode := RecoveryModeNotify
+	if m.cfg != nil && m.cfg.Agents.Defaults.Recovery.Mode != "" {
+		mode = m.cfg.Agents.Defaults.Recovery.Mode
+	}
+	if mode == RecoveryModeOff {
+		return
+	}
+
+	checkpoints, err := m.sessionMgr.ListCheckpoints()
+	if err != nil {
+		logger.Error("Failed to scan sessions for interrupted runs", zap.Error(err))
+		return
+	}
+
+	for _, cp := range checkpoints {
+		// interrupted 状态表示已经通知过用户，等待 /retry
+		if cp.Status != session.CheckpointStatusRunning {
+			continue
+		}
+
+		sess, err := m.sessionMgr.GetOrCreate(cp.SessionKey)
+		if err != nil {
+			logger.Error("Failed to load session of interrupted run",
+				zap.String("session_key", cp.SessionKey),
+				zap.Error(err))
+			continue
+		}
+
+		logger.Warn("Detected interrupted run",
+			zap.String("session_key", cp.SessionKey),
+			zap.String("run_id", cp.RunID),
+			zap.String("phase", cp.Phase),
+			zap.Int("messages", len(cp.Messages)),
+			zap.String("mode", mode))
+
+		if mode == RecoveryModeResume {
+			// 续跑在消息处理器启动前依次执行，避免与同一会话的新消息并发运行
+			m.resumeOrNotify(ctx, sess, cp)
+			continue
+		}
+
+		m.notifyInterruptedRun(ctx, sess, cp)
+	}
+}
+
+// resumeOrNotify 续跑被中断的运行，失败时通知用户；再次被中断时保留检查点
+func (m *AgentManager) resumeOrNotify(ctx context.Context, sess *session.Session, cp *session.RunCheckpoint) {
+	err := m.resumeRun(ctx, sess, cp)
+	if err == nil {
+		return
+	}
+	if runInterrupted(ctx, err) {
+		logger.Warn("Resumed run interrupted again",
+			zap.String("session_key", cp.SessionKey),
+			zap.String("run_id", cp.RunID),
+			zap.Error(err))
+		return
+	}
+	logger.Error("Failed to resume interrupted run",
+		zap.String("session_key", cp.SessionKey),
+		zap.String("run_id", cp.RunID),
+		zap.Error(err))
+	m.notifyInterruptedRun(ctx, sess, cp)
+}
+
+// notifyInterruptedRun 通知用户运行被中断，并提供一键重试
+func (m *AgentManager) notifyInterruptedRun(ctx context.Context, sess *session.Session, cp *session.RunCheckpoint) {
+	cp.Status = session.CheckpointStatusInterrupted
+	cp.UpdatedAt = time.Now()
+	sess.SetCheckpoint(cp)
+	if err := m.sessionMgr.Save(sess); err != nil {
+		logger.Error("Failed to save session", zap.Error(err))
+	}
+
+	progress := ""
+	if len(cp.Todos) > 0 {
+		progress = "\n\nProgress before the restart:\n" + tools.FormatTodoList(cp.Todos)
+	}
+	text := fmt.Sprintf("⚠️ Your previous request was interrupted by a restart before it finished:\n\n%s%s\n\nReply %s to run it again.",
+		truncateString(cp.Prompt, 200), progress, RetryCommand)
+	m.publishText(ctx, cp.Channel, cp.ChatID, text, map[string]interface{}{
+		"quick_replies": []string{RetryCommand},
+	})
+}
+
+// resumeRun 从检查点继续被中断的运行
+// 未完成的工具调用中只有无副作用的工具会被重新执行
+func (m *AgentManager) resumeRun(ctx context.Context, sess *session.Session, cp *session.RunCheckpoint) error {
+	// 与 RouteInbound 一致，运行期间持有读锁（handoff 工具依赖这一点）
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bound, err := m.resolveAgent(cp.Channel, cp.AccountID)
+	if err != nil {
+		return err
+	}
+	agent, agentID := m.sessionAgent(cp.SessionKey, bound)
+
+	runMessages := sessionMessagesToAgentMessages(cp.Messages)
+	if len(runMessages) == 0 {
+		runMessages = []AgentMessage{{
+			Role:      RoleUser,
+			Content:   []ContentBlock{TextContent{Text: cp.Prompt}},
+			Timestamp: cp.StartedAt.UnixMilli(),
+		}}
+	}
+
+	// 最终回复已经生成，只是会话没来得及更新
+	last := runMessages[len(runMessages)-1]
+	if last.Role == RoleAssistant && len(extractToolCalls(last)) == 0 {
+		sess.ClearCheckpoint()
+		m.updateSession(sess, runMessages, 0)
+		m.publishToBus(ctx, cp.Channel, cp.ChatID, last)
+		return nil
+	}
+
+	runMessages = append(runMessages, m.replayPendingToolCalls(ctx, agent, runMessages)...)
+
+	// 任务清单跟随检查点恢复，续跑时模型能看到已完成的步骤
+	if len(cp.Todos) > 0 && len(sess.GetTodos()) == 0 {
+		sess.SetTodos(cp.Todos)
+	}
+
+	history := sess.GetHistory(-1)
+	allMessages := append(sessionMessagesToAgentMessages(history), runMessages...)
+
+	ctx = tools.WithSessionKey(ctx, cp.SessionKey)
+	ctx = tools.WithAgentID(ctx, agentID)
+	ctx, budget := m.applyAccessPolicy(ctx, cp.Channel, cp.SenderID, m.resolveRunBudget(agent, cp.Channel))
+	if !budget.IsZero() {
+		ctx = WithRunBudget(ctx, budget)
+	}
+	ctx = tools.WithRunID(ctx, cp.RunID)
+	ctx = m.trackRun(ctx, sess, cp, allMessages, len(history))
+
+	logger.Info("Resuming interrupted run",
+		zap.String("session_key", cp.SessionKey),
+		zap.String("run_id", cp.RunID),
+		zap.Int("run_messages", len(runMessages)))
+
+	finalMessages, err := agent.GetOrchestrator().Run(ctx, allMessages)
+	if err != nil {
+		return err
+	}
+
+	sess.ClearCheckpoint()
+	m.updateSession(sess, finalMessages, len(history))
+
+	if len(finalMessages) > 0 {
+		lastMsg := finalMessages[len(finalMessages)-1]
+		if lastMsg.Role == RoleAssistant {
+			m.publishToBus(ctx, cp.Channel, cp.ChatID, m.withFileChanges(lastMsg, cp.SessionKey, cp.RunID, cp.Prompt))
+		}
+	}
+	return nil
+}
+
+// replayPendingToolCalls 为最后一条助手消息中没有结果的工具调用补齐结果
+// 只读工具会重新执行，其他工具返回中断说明，避免重复产生副作用
+func (m *AgentManager) replayPendingToolCalls(ctx context.Context, agent *Agent, messages []AgentMessage) []AgentMessage {
+	lastAssistant := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleAssistant {
+			lastAssistant = i
+			break
+		}
+	}
+	if lastAssistant < 0 {
+		return nil
+	}
+
+	answered := make(map[string]bool)
+	for _, msg := range messages[lastAssistant+1:] {
+		if msg.Role == RoleToolResult {
+			if id, ok := msg.Metadata["tool_call_id"].(string); ok {
+				answered[id] = true
+			}
+		}
+	}
+
+	readOnly := make(map[string]bool)
+	readOnlyTools := defaultReadOnlyTools
+	if m.cfg != nil && len(m.cfg.Agents.Defaults.Recovery.ReadOnlyTools) > 0 {
+		readOnlyTools = m.cfg.Agents.Defaults.Recovery.ReadOnlyTools
+	}
+	for _, name := range readOnlyTools {
+		readOnly[name] = true
+	}
+
+	tools := make(map[string]Tool)
+	for _, tool := range agent.GetState().Tools {
+		tools[tool.Name()] = tool
+	}
+
+	var results []AgentMessage
+	for _, tc := range extractToolCalls(messages[lastAssistant]) {
+		if answered[tc.ID] {
+			continue
+		}
+
+		resultMsg := AgentMessage{
+			Role:      RoleToolResult,
+			Timestamp: time.Now().UnixMilli(),
+			Metadata:  map[string]any{"tool_call_id": tc.ID, "tool_name": tc.Name},
+		}
+
+		tool, ok := tools[tc.Name]
+		if ok && readOnly[tc.Name] {
+			logger.Info("Re-executing read-only tool of interrupted run",
+				zap.String("tool_id", tc.ID),
+				zap.String("tool_name", tc.Name))
+			result, err := tool.Execute(ctx, tc.Arguments, func(ToolResult) {})
+			resultMsg.Content = result.Content
+			if err != nil {
+				resultMsg.Metadata["error"] = err.Error()
+				resultMsg.Content = []ContentBlock{TextContent{Text: err.Error()}}
+			}
+		} else {
+			resultMsg.Metadata["error"] = "interrupted"
+			resultMsg.Content = []ContentBlock{TextContent{Text: fmt.Sprintf(
+				"Tool call %s was interrupted by a restart and was not re-executed because it may have side effects. "+
+					"Check whether it completed before calling it again.", tc.Name)}}
+		}
+
+		results = append(results, resultMsg)
+	}
+	return results
+}
+
+// publishText 发布纯文本消息到总线
+func (m *AgentManager) publishText(ctx context.Context, channel, chatID, content string, metadata map[string]interface{}) {
+	outbound := &bus.OutboundMessage{
+		Channel:   channel,
+		ChatID:    chatID,
+		Content:   content,
+		Metadata:  metadata,
+		Timestamp: time.Now(),
+	}
+
+	if err := m.bus.PublishOutbound(ctx, outbound); err != nil {
+		logger.Error("Failed to publish outbound", zap.Error(err))
+	}
+}
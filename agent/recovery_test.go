@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// namedTool 指定名称的测试工具
+type namedTool struct {
+	echoTool
+	name  string
+	calls *int
+}
+
+func (t namedTool) Name() string { return t.name }
+func (t namedTool) Execute(ctx context.Context, params map[string]any, onUpdate func(ToolResult)) (ToolResult, error) {
+	*t.calls++
+	return t.echoTool.Execute(ctx, params, onUpdate)
+}
+
+func TestReplayPendingToolCalls(t *testing.T) {
+	readCalls, writeCalls := 0, 0
+	state := NewAgentState()
+	state.Tools = []Tool{
+		namedTool{name: "read_file", calls: &readCalls},
+		namedTool{name: "write_file", calls: &writeCalls},
+	}
+	agent := &Agent{state: state}
+	m := &AgentManager{}
+
+	messages := []AgentMessage{
+		{Role: RoleUser, Content: []ContentBlock{TextContent{Text: "go"}}},
+		{Role: RoleAssistant, Content: []ContentBlock{
+			ToolCallContent{ID: "1", Name: "read_file"},
+			ToolCallContent{ID: "2", Name: "write_file"},
+			ToolCallContent{ID: "3", Name: "read_file"},
+		}},
+		{Role: RoleToolResult, Metadata: map[string]any{"tool_call_id": "1"}},
+	}
+
+	results := m.replayPendingToolCalls(context.Background(), agent, messages)
+	if len(results) != 2 {
+		t.Fatalf("expected results for 2 pending calls, got %d", len(results))
+	}
+	if readCalls != 1 {
+		t.Errorf("expected read-only tool to be re-executed once, got %d", readCalls)
+	}
+	if writeCalls != 0 {
+		t.Errorf("side-effecting tool must not be re-executed, got %d calls", writeCalls)
+	}
+	if results[0].Metadata["tool_call_id"] != "2" || results[0].Metadata["error"] != "interrupted" {
+		t.Errorf("expected interrupted result for write_file, got %+v", results[0].Metadata)
+	}
+	if results[1].Metadata["tool_call_id"] != "3" || results[1].Metadata["error"] != nil {
+		t.Errorf("expected successful result for read_file, got %+v", results[1].Metadata)
+	}
+}
+
+func TestIsRetryCommand(t *testing.T) {
+	for content, want := range map[string]bool{
+		"/retry":        true,
+		" /retry@bot ":  true,
+		"/retry please": true,
+		"/retrying":     false,
+		"retry":         false,
+	} {
+		if got := isRetryCommand(content); got != want {
+			t.Errorf("isRetryCommand(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	ctx := context.Background()
+	if runInterrupted(ctx, errors.New("provider error")) {
+		t.Error("an ordinary error should clear the checkpoint")
+	}
+	if !runInterrupted(ctx, fmt.Errorf("agent loop failed: %w", context.Canceled)) {
+		t.Error("a cancelled run should keep its checkpoint")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if !runInterrupted(cancelled, errors.New("request failed")) {
+		t.Error("a run whose context was cancelled should keep its checkpoint")
+	}
+}
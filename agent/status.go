@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/session"
+)
+
+// StatusCommand 查看会话状态和任务清单的命令
+const StatusCommand = "/status"
+
+// isStatusCommand 判断消息是否为 /status 命令
+func isStatusCommand(content string) bool {
+	return isChatCommand(content, StatusCommand)
+}
+
+// sessionStatus 生成 /status 的回复：当前 Agent、消息数、中断的运行、分身和任务清单
+func (m *AgentManager) sessionStatus(sess *session.Session, agentID string) string {
+	var sb strings.Builder
+	sb.WriteString("📋 Session status\n\n")
+	if agentID != "" {
+		sb.WriteString(fmt.Sprintf("Agent: %s\n", m.agentDisplayName(agentID)))
+	}
+	sb.WriteString(fmt.Sprintf("Messages: %d\n", len(sess.GetHistory(-1))))
+
+	if cp := sess.GetCheckpoint(); cp != nil && cp.Status == session.CheckpointStatusInterrupted {
+		sb.WriteString(fmt.Sprintf("Interrupted run: %s (reply %s to run it again)\n", truncateString(cp.Prompt, 80), RetryCommand))
+	}
+
+	if m.subagentRegistry != nil {
+		running := 0
+		infos := m.ListSubagents(sess.Key)
+		for _, info := range infos {
+			if !info.Finished() {
+				running++
+			}
+		}
+		if len(infos) > 0 {
+			sb.WriteString(fmt.Sprintf("Subagents: %d active, %d total\n", running, len(infos)))
+		}
+	}
+
+	sb.WriteString("\nTasks:\n")
+	sb.WriteString(tools.FormatTodoList(sess.GetTodos()))
+	return sb.String()
+}
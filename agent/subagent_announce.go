@@ -2,8 +2,10 @@ package agent
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/smallnest/goclaw/internal/access"
 	"github.com/smallnest/goclaw/internal/logger"
 	"go.uber.org/zap"
 )
@@ -58,6 +60,8 @@ func (a *SubagentAnnouncer) RunAnnounceFlow(params *SubagentAnnounceParams) erro
 			statusLabel = "completed successfully"
 		case "timeout":
 			statusLabel = "timed out"
+		case "cancelled":
+			statusLabel = "was cancelled"
 		case "error":
 			statusLabel = fmt.Sprintf("failed: %s", params.Outcome.Error)
 		default:
@@ -254,13 +258,29 @@ func normalizeText(s string) string {
 // DefaultToolDenyList 默认拒绝的工具列表
 var DefaultToolDenyList = []string{
 	"sessions_spawn", // 防止嵌套创建
-	"sessions_list",  // 会话管理 - 主 Agent 协调
+	"subagents_list", // 分身控制 - 只有创建者需要
+	"subagent_wait",
+	"subagent_cancel",
+	"subagent_send",
+	"subagent_result",
+	"sessions_list", // 会话管理 - 主 Agent 协调
 	"sessions_history",
 	"sessions_delete",
+	"handoff", // 分身不与用户对话，不能交接会话
 	"gateway", // 系统管理 - 分身不应操作
 	"cron",    // 定时任务
 }
 
+// NestedSubagentTools 允许嵌套（未达到最大深度）时分身可以使用的工具
+var NestedSubagentTools = []string{
+	"sessions_spawn",
+	"subagents_list",
+	"subagent_wait",
+	"subagent_cancel",
+	"subagent_send",
+	"subagent_result",
+}
+
 // ResolveToolPolicy 解析工具策略
 func ResolveToolPolicy(denyTools []string, allowTools []string) *ToolPolicy {
 	policy := &ToolPolicy{
@@ -289,6 +309,42 @@ func ResolveToolPolicy(denyTools []string, allowTools []string) *ToolPolicy {
 	return policy
 }
 
+// AllowNesting 允许分身继续创建和管理分身（配置中显式拒绝的工具除外）
+func (p *ToolPolicy) AllowNesting(denyTools []string) {
+	explicit := make(map[string]bool, len(denyTools))
+	for _, tool := range denyTools {
+		explicit[tool] = true
+	}
+	for _, tool := range NestedSubagentTools {
+		if !explicit[tool] {
+			delete(p.Deny, tool)
+		}
+	}
+}
+
+// FilterTools 过滤出策略允许的工具
+func (p *ToolPolicy) FilterTools(tools []Tool) []Tool {
+	result := make([]Tool, 0, len(tools))
+	for _, tool := range tools {
+		if p.IsToolAllowed(tool.Name()) {
+			result = append(result, tool)
+		}
+	}
+	return result
+}
+
+// DeniedTools 返回被拒绝的工具名称（已排序）
+func (p *ToolPolicy) DeniedTools() []string {
+	names := make([]string, 0, len(p.Deny))
+	for name, denied := range p.Deny {
+		if denied {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ToolPolicy 工具策略
 type ToolPolicy struct {
 	Deny      map[string]bool
@@ -296,22 +352,35 @@ type ToolPolicy struct {
 	AllowOnly bool
 }
 
-// IsToolAllowed 检查工具是否被允许
+// IsToolAllowed 检查工具是否被允许，名单中的 browser_* 等通配模式也参与匹配
 func (p *ToolPolicy) IsToolAllowed(toolName string) bool {
 	// 先检查拒绝列表（优先）
-	if p.Deny[toolName] {
+	if matchToolSet(p.Deny, toolName) {
 		return false
 	}
 
 	// 如果是 allow-only 模式，检查是否在允许列表中
 	if p.AllowOnly {
-		return p.Allow[toolName]
+		return matchToolSet(p.Allow, toolName)
 	}
 
 	// 默认允许
 	return true
 }
 
+// matchToolSet 判断工具名是否在名单中（精确匹配或通配模式）
+func matchToolSet(set map[string]bool, toolName string) bool {
+	if set[toolName] {
+		return true
+	}
+	for pattern, enabled := range set {
+		if enabled && access.MatchTool(pattern, toolName) {
+			return true
+		}
+	}
+	return false
+}
+
 // WaitForSubagentCompletion 等待分身完成
 func WaitForSubagentCompletion(runID string, timeoutSeconds int, waitFunc func(string, int) (*SubagentCompletion, error)) (*SubagentCompletion, error) {
 	timeout := time.Duration(timeoutSeconds) * time.Second
@@ -0,0 +1,221 @@
+package agent
+
+import (
+	"context"
+	"sort"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// 分身限制的默认值
+const (
+	defaultSubagentMaxConcurrent = 8
+	defaultSubagentMaxDepth      = 1
+)
+
+type subagentDepthKey struct{}
+
+// withSubagentDepth 记录当前运行的分身嵌套深度（主 Agent 为 0）
+func withSubagentDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, subagentDepthKey{}, depth)
+}
+
+// subagentDepthFromContext 获取当前运行的分身嵌套深度
+func subagentDepthFromContext(ctx context.Context) int {
+	depth, _ := ctx.Value(subagentDepthKey{}).(int)
+	return depth
+}
+
+// agentConfig 获取 Agent 配置
+func (m *AgentManager) agentConfig(agentID string) *config.AgentConfig {
+	if m.cfg == nil {
+		return nil
+	}
+	for i := range m.cfg.Agents.List {
+		if m.cfg.Agents.List[i].ID == agentID {
+			return &m.cfg.Agents.List[i]
+		}
+	}
+	return nil
+}
+
+// subagentMaxConcurrent 返回全局最大并发分身数
+func (m *AgentManager) subagentMaxConcurrent() int {
+	if m.cfg != nil && m.cfg.Agents.Defaults.Subagents != nil && m.cfg.Agents.Defaults.Subagents.MaxConcurrent > 0 {
+		return m.cfg.Agents.Defaults.Subagents.MaxConcurrent
+	}
+	return defaultSubagentMaxConcurrent
+}
+
+// subagentAgentMaxConcurrent 返回单个 Agent 的最大并发分身数，0 表示只受全局限制
+func (m *AgentManager) subagentAgentMaxConcurrent(agentID string) int {
+	if agentCfg := m.agentConfig(agentID); agentCfg != nil && agentCfg.Subagents != nil {
+		return agentCfg.Subagents.MaxConcurrent
+	}
+	return 0
+}
+
+// subagentMaxDepth 返回最大嵌套深度
+func (m *AgentManager) subagentMaxDepth() int {
+	if m.cfg != nil && m.cfg.Agents.Defaults.Subagents != nil && m.cfg.Agents.Defaults.Subagents.MaxDepth > 0 {
+		return m.cfg.Agents.Defaults.Subagents.MaxDepth
+	}
+	return defaultSubagentMaxDepth
+}
+
+// subagentToolPolicy 解析分身的工具策略
+// 未达到最大深度时允许分身继续创建和管理分身
+func (m *AgentManager) subagentToolPolicy(agentID string, depth int) *ToolPolicy {
+	var denyTools, allowTools []string
+	if agentCfg := m.agentConfig(agentID); agentCfg != nil && agentCfg.Subagents != nil {
+		denyTools = agentCfg.Subagents.DenyTools
+		allowTools = agentCfg.Subagents.AllowTools
+	}
+
+	policy := ResolveToolPolicy(denyTools, allowTools)
+	if depth < m.subagentMaxDepth() {
+		policy.AllowNesting(denyTools)
+	}
+	return policy
+}
+
+// scheduleSubagent 有空闲名额时立即启动分身，否则排队；返回是否排队
+func (m *AgentManager) scheduleSubagent(run *subagentRun) bool {
+	m.subagentMu.Lock()
+	defer m.subagentMu.Unlock()
+
+	m.trackSubagentRunLocked(run)
+	if m.canStartSubagentLocked(run.agentID) {
+		m.startSubagentLocked(run)
+		return false
+	}
+
+	m.subagentQueue = append(m.subagentQueue, run)
+	logger.Info("Subagent queued",
+		zap.String("run_id", run.runID),
+		zap.String("agent_id", run.agentID),
+		zap.Int("running", m.subagentRunning),
+		zap.Int("queued", len(m.subagentQueue)))
+	return true
+}
+
+// canStartSubagentLocked 检查全局和 Agent 的并发限制（调用方需持有 subagentMu）
+func (m *AgentManager) canStartSubagentLocked(agentID string) bool {
+	if m.subagentRunning >= m.subagentMaxConcurrent() {
+		return false
+	}
+	if max := m.subagentAgentMaxConcurrent(agentID); max > 0 && m.subagentActive[agentID] >= max {
+		return false
+	}
+	return true
+}
+
+// startSubagentLocked 启动分身（调用方需持有 subagentMu）
+func (m *AgentManager) startSubagentLocked(run *subagentRun) {
+	if m.subagentActive == nil {
+		m.subagentActive = make(map[string]int)
+	}
+	m.subagentActive[run.agentID]++
+	m.subagentRunning++
+
+	// 分身不随父运行结束而取消，只受自身超时和 subagent_cancel 控制；超时从开始运行时计算
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if run.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), run.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	run.mu.Lock()
+	run.started = true
+	run.cancel = cancel
+	run.mu.Unlock()
+
+	go m.runSubagent(ctx, run)
+}
+
+// releaseSubagentSlot 分身结束后释放名额并启动排队中的分身
+func (m *AgentManager) releaseSubagentSlot(run *subagentRun) {
+	m.subagentMu.Lock()
+	defer m.subagentMu.Unlock()
+
+	m.subagentRunning--
+	m.subagentActive[run.agentID]--
+
+	remaining := m.subagentQueue[:0]
+	for _, queued := range m.subagentQueue {
+		if m.canStartSubagentLocked(queued.agentID) {
+			m.startSubagentLocked(queued)
+		} else {
+			remaining = append(remaining, queued)
+		}
+	}
+	m.subagentQueue = remaining
+}
+
+// dequeueSubagent 从队列中移除尚未开始的分身，返回是否移除
+func (m *AgentManager) dequeueSubagent(run *subagentRun) bool {
+	m.subagentMu.Lock()
+	defer m.subagentMu.Unlock()
+
+	for i, queued := range m.subagentQueue {
+		if queued == run {
+			m.subagentQueue = append(m.subagentQueue[:i], m.subagentQueue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SubagentStats 返回分身的运行、排队数量和限制
+func (m *AgentManager) SubagentStats() *tools.SubagentStats {
+	m.subagentMu.Lock()
+	stats := &tools.SubagentStats{
+		Running:       m.subagentRunning,
+		Queued:        len(m.subagentQueue),
+		MaxConcurrent: m.subagentMaxConcurrent(),
+		MaxDepth:      m.subagentMaxDepth(),
+		Agents:        make(map[string]*tools.SubagentAgentStats),
+	}
+	queuedByAgent := make(map[string]int)
+	for _, run := range m.subagentQueue {
+		queuedByAgent[run.agentID]++
+	}
+	for agentID, running := range m.subagentActive {
+		if running > 0 || queuedByAgent[agentID] > 0 {
+			stats.Agents[agentID] = &tools.SubagentAgentStats{Running: running}
+		}
+	}
+	for agentID, queued := range queuedByAgent {
+		if stats.Agents[agentID] == nil {
+			stats.Agents[agentID] = &tools.SubagentAgentStats{}
+		}
+		stats.Agents[agentID].Queued = queued
+	}
+	m.subagentMu.Unlock()
+
+	// 已配置的 Agent 都列出限制和工具策略
+	if m.cfg != nil {
+		for _, agentCfg := range m.cfg.Agents.List {
+			if stats.Agents[agentCfg.ID] == nil {
+				stats.Agents[agentCfg.ID] = &tools.SubagentAgentStats{}
+			}
+		}
+	}
+	for agentID, agentStats := range stats.Agents {
+		policy := m.subagentToolPolicy(agentID, 1)
+		agentStats.MaxConcurrent = m.subagentAgentMaxConcurrent(agentID)
+		agentStats.DeniedTools = policy.DeniedTools()
+		if policy.AllowOnly {
+			for name := range policy.Allow {
+				agentStats.AllowedTools = append(agentStats.AllowedTools, name)
+			}
+			sort.Strings(agentStats.AllowedTools)
+		}
+	}
+	return stats
+}
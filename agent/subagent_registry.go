@@ -5,18 +5,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/smallnest/goclaw/agent/tools"
 	"github.com/smallnest/goclaw/internal/logger"
 	"go.uber.org/zap"
 )
 
 // SubagentRunOutcome 分身运行结果
 type SubagentRunOutcome struct {
-	Status string `json:"status"` // ok, error, timeout, unknown
+	Status string `json:"status"` // ok, error, timeout, cancelled, unknown
 	Error  string `json:"error,omitempty"`
 }
 
@@ -30,21 +32,23 @@ type DeliveryContext struct {
 
 // SubagentRunRecord 分身运行记录
 type SubagentRunRecord struct {
-	RunID               string              `json:"run_id"`
-	ChildSessionKey     string              `json:"child_session_key"`
-	RequesterSessionKey string              `json:"requester_session_key"`
-	RequesterOrigin     *DeliveryContext    `json:"requester_origin,omitempty"`
-	RequesterDisplayKey string              `json:"requester_display_key"`
-	Task                string              `json:"task"`
-	Cleanup             string              `json:"cleanup"` // delete, keep
-	Label               string              `json:"label,omitempty"`
-	CreatedAt           int64               `json:"created_at"`
-	StartedAt           *int64              `json:"started_at,omitempty"`
-	EndedAt             *int64              `json:"ended_at,omitempty"`
-	Outcome             *SubagentRunOutcome `json:"outcome,omitempty"`
-	ArchiveAtMs         *int64              `json:"archive_at_ms,omitempty"`
-	CleanupCompletedAt  *int64              `json:"cleanup_completed_at,omitempty"`
-	CleanupHandled      bool                `json:"cleanup_handled"`
+	RunID               string               `json:"run_id"`
+	ChildSessionKey     string               `json:"child_session_key"`
+	RequesterSessionKey string               `json:"requester_session_key"`
+	RequesterOrigin     *DeliveryContext     `json:"requester_origin,omitempty"`
+	RequesterDisplayKey string               `json:"requester_display_key"`
+	Task                string               `json:"task"`
+	Cleanup             string               `json:"cleanup"` // delete, keep
+	Label               string               `json:"label,omitempty"`
+	CreatedAt           int64                `json:"created_at"`
+	StartedAt           *int64               `json:"started_at,omitempty"`
+	EndedAt             *int64               `json:"ended_at,omitempty"`
+	Outcome             *SubagentRunOutcome  `json:"outcome,omitempty"`
+	Summary             string               `json:"summary,omitempty"` // 分身的最终回复
+	Usage               *tools.SubagentUsage `json:"usage,omitempty"`   // LLM 调用和 token 用量
+	ArchiveAtMs         *int64               `json:"archive_at_ms,omitempty"`
+	CleanupCompletedAt  *int64               `json:"cleanup_completed_at,omitempty"`
+	CleanupHandled      bool                 `json:"cleanup_handled"`
 }
 
 // SubagentRegistry 分身注册表
@@ -157,6 +161,35 @@ func (r *SubagentRegistry) ListRunsForRequester(requesterSessionKey string) []*S
 	return result
 }
 
+// SnapshotRun 获取运行记录的副本（可在锁外安全读取）
+func (r *SubagentRegistry) SnapshotRun(runID string) (SubagentRunRecord, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.runs[runID]
+	if !ok {
+		return SubagentRunRecord{}, false
+	}
+	return *record, true
+}
+
+// SnapshotRuns 获取请求者所有运行记录的副本，按创建时间排序；requesterSessionKey 为空时返回全部
+func (r *SubagentRegistry) SnapshotRuns(requesterSessionKey string) []SubagentRunRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]SubagentRunRecord, 0, len(r.runs))
+	for _, record := range r.runs {
+		if requesterSessionKey == "" || record.RequesterSessionKey == requesterSessionKey {
+			result = append(result, *record)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+	return result
+}
+
 // MarkCompleted 标记分身运行完成
 func (r *SubagentRegistry) MarkCompleted(runID string, outcome *SubagentRunOutcome, endedAt *int64) error {
 	r.mu.Lock()
@@ -183,6 +216,60 @@ func (r *SubagentRegistry) MarkCompleted(runID string, outcome *SubagentRunOutco
 	return nil
 }
 
+// MarkStarted 记录分身实际开始运行的时间（排队的分身开始时调用）
+func (r *SubagentRegistry) MarkStarted(runID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.runs[runID]; ok {
+		now := time.Now().UnixMilli()
+		record.StartedAt = &now
+	}
+}
+
+// SetUsage 记录分身的 LLM 调用和 token 用量
+func (r *SubagentRegistry) SetUsage(runID string, usage tools.SubagentUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.runs[runID]; ok {
+		record.Usage = &usage
+	}
+}
+
+// SetSummary 记录分身的最终回复
+func (r *SubagentRegistry) SetSummary(runID, summary string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.runs[runID]; ok {
+		record.Summary = summary
+	}
+}
+
+// FailUnfinished 将没有结果的运行标记为失败（进程重启后这些运行已不存在）
+func (r *SubagentRegistry) FailUnfinished(reason string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	count := 0
+	for _, record := range r.runs {
+		if record.Outcome != nil {
+			continue
+		}
+		record.Outcome = &SubagentRunOutcome{Status: "error", Error: reason}
+		record.EndedAt = &now
+		count++
+	}
+	if count > 0 {
+		if err := r.saveToDisk(); err != nil {
+			logger.Error("Failed to save subagent registry", zap.Error(err))
+		}
+	}
+	return count
+}
+
 // ReleaseRun 释放运行记录
 func (r *SubagentRegistry) ReleaseRun(runID string) {
 	r.mu.Lock()
@@ -0,0 +1,698 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/providers"
+	"github.com/smallnest/goclaw/session"
+	"go.uber.org/zap"
+)
+
+// 已结束的分身在内存中保留的时间（注册表记录可能已被清理）
+const finishedSubagentRetention = time.Hour
+
+// 每个分身在内存中保留的最近事件数
+const maxSubagentEvents = 500
+
+// subagentRun 运行中、排队中（或刚结束）的分身
+type subagentRun struct {
+	runID           string
+	childSessionKey string
+	agent           *Agent
+	agentID         string
+	record          *SubagentRunRecord // 生成时的记录快照
+	timeout         time.Duration
+	depth           int
+	policy          *ToolPolicy
+	accessPolicy    *ToolPolicy // 触发分身的发送者角色的工具限制
+	done            chan struct{}
+
+	mu        sync.Mutex
+	started   bool
+	cancel    context.CancelFunc // 开始运行后设置
+	steering  []AgentMessage
+	cancelled bool
+	final     *SubagentRunRecord // 结束时的记录快照
+	endedAt   time.Time
+	messages  []AgentMessage // 运行中的消息快照，用于查看实时记录
+	usage     tools.SubagentUsage
+	events    []tools.SubagentEvent
+	lastSeq   int
+}
+
+// recordEvent 记录运行事件，只保留最近 maxSubagentEvents 条
+func (r *subagentRun) recordEvent(eventType, tool, text string, isError bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastSeq++
+	r.events = append(r.events, tools.SubagentEvent{
+		Seq:     r.lastSeq,
+		Time:    time.Now(),
+		Type:    eventType,
+		Tool:    tool,
+		Text:    truncateEventText(text),
+		IsError: isError,
+	})
+	if len(r.events) > maxSubagentEvents {
+		r.events = append([]tools.SubagentEvent(nil), r.events[len(r.events)-maxSubagentEvents:]...)
+	}
+}
+
+// eventsSince 返回序号大于 since 的事件
+func (r *subagentRun) eventsSince(since int) []tools.SubagentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []tools.SubagentEvent
+	for _, event := range r.events {
+		if event.Seq > since {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// observe 记录 orchestrator 事件中的工具调用
+func (r *subagentRun) observe(event *Event) {
+	switch event.Type {
+	case EventToolExecutionStart:
+		args, _ := json.Marshal(event.ToolArgs)
+		r.recordEvent(tools.SubagentEventToolStart, event.ToolName, string(args), false)
+	case EventToolExecutionEnd:
+		text := ""
+		if event.ToolResult != nil {
+			text = extractTextContent(AgentMessage{Content: event.ToolResult.Content})
+		}
+		r.recordEvent(tools.SubagentEventToolEnd, event.ToolName, text, event.ToolError)
+	}
+}
+
+// checkpoint 保存消息快照并统计用量，新的模型回复记录为事件
+func (r *subagentRun) checkpoint(phase string, messages []AgentMessage) {
+	usage := tools.SubagentUsage{}
+	for _, msg := range messages {
+		if msg.Role != RoleAssistant {
+			continue
+		}
+		usage.LLMCalls++
+		if u, ok := msg.Metadata["usage"].(providers.Usage); ok {
+			usage.PromptTokens += u.PromptTokens
+			usage.CompletionTokens += u.CompletionTokens
+			usage.TotalTokens += u.TotalTokens
+		}
+	}
+
+	r.mu.Lock()
+	r.messages = append([]AgentMessage(nil), messages...)
+	r.usage = usage
+	r.mu.Unlock()
+
+	if phase == CheckpointPhaseLLMResponse && len(messages) > 0 {
+		if text := strings.TrimSpace(extractTextContent(messages[len(messages)-1])); text != "" {
+			r.recordEvent(tools.SubagentEventAssistant, "", text, false)
+		}
+	}
+}
+
+// truncateEventText 截断事件文本
+func truncateEventText(text string) string {
+	const maxLen = 500
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) <= maxLen {
+		return text
+	}
+	return string([]rune(text)[:maxLen]) + "..."
+}
+
+// steer 添加父 Agent 发来的指令，分身在下一步之前会看到
+func (r *subagentRun) steer(msg AgentMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steering = append(r.steering, msg)
+}
+
+// dequeueSteering 取出所有待处理的指令
+func (r *subagentRun) dequeueSteering() []AgentMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msgs := r.steering
+	r.steering = nil
+	return msgs
+}
+
+// finished 是否已结束
+func (r *subagentRun) finished() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStarted 是否已开始运行（否则仍在排队）
+func (r *subagentRun) isStarted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+// handleSubagentSpawn 启动分身运行，超出并发限制时排队
+// 在父 Agent 运行期间被 sessions_spawn 调用，此时 RouteInbound 已持有读锁
+func (m *AgentManager) handleSubagentSpawn(ctx context.Context, result *tools.SubagentSpawnResult) error {
+	agentID, _, isSubagent := ParseAgentSessionKey(result.ChildSessionKey)
+	if !isSubagent {
+		return fmt.Errorf("invalid subagent session key: %s", result.ChildSessionKey)
+	}
+
+	record, ok := m.subagentRegistry.SnapshotRun(result.RunID)
+	if !ok {
+		return fmt.Errorf("subagent run not registered: %s", result.RunID)
+	}
+
+	depth := subagentDepthFromContext(ctx) + 1
+	if maxDepth := m.subagentMaxDepth(); depth > maxDepth {
+		m.subagentRegistry.ReleaseRun(result.RunID)
+		return fmt.Errorf("subagent depth limit reached (max_depth %d)", maxDepth)
+	}
+
+	agent := m.agents[agentID]
+	if agent == nil {
+		agent = m.defaultAgent
+	}
+	if agent == nil {
+		m.subagentRegistry.ReleaseRun(result.RunID)
+		return fmt.Errorf("no agent found for subagent: %s", agentID)
+	}
+
+	run := &subagentRun{
+		runID:           result.RunID,
+		childSessionKey: result.ChildSessionKey,
+		agent:           agent,
+		agentID:         agentID,
+		record:          &record,
+		timeout:         m.subagentTimeout(agentID, result.RunTimeoutSeconds),
+		depth:           depth,
+		policy:          m.subagentToolPolicy(agentID, depth),
+		accessPolicy:    toolPolicyFromContext(ctx),
+		done:            make(chan struct{}),
+	}
+
+	if m.scheduleSubagent(run) {
+		stats := m.SubagentStats()
+		result.Warning = fmt.Sprintf("concurrency limit reached (%d running, max %d); the subagent is queued and will start when a slot frees up", stats.Running, stats.MaxConcurrent)
+	}
+
+	logger.Info("Subagent spawned",
+		zap.String("run_id", result.RunID),
+		zap.String("agent_id", agentID),
+		zap.Int("depth", depth),
+		zap.Bool("queued", !run.isStarted()),
+		zap.String("child_session_key", result.ChildSessionKey))
+	return nil
+}
+
+// subagentTimeout 返回分身运行超时：工具参数 > Agent 配置 > 默认配置，0 表示不限制
+func (m *AgentManager) subagentTimeout(agentID string, requested int) time.Duration {
+	seconds := requested
+	if seconds <= 0 && m.cfg != nil {
+		if agentCfg := m.agentConfig(agentID); agentCfg != nil && agentCfg.Subagents != nil {
+			seconds = agentCfg.Subagents.TimeoutSeconds
+		}
+		if seconds <= 0 && m.cfg.Agents.Defaults.Subagents != nil {
+			seconds = m.cfg.Agents.Defaults.Subagents.TimeoutSeconds
+		}
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// runSubagent 在独立会话中执行分身任务
+func (m *AgentManager) runSubagent(ctx context.Context, run *subagentRun) {
+	defer m.releaseSubagentSlot(run)
+	defer run.cancel()
+
+	m.subagentRegistry.MarkStarted(run.runID)
+	record := run.record
+
+	orchestrator := m.newSubagentOrchestrator(run)
+	go func() {
+		for event := range orchestrator.Subscribe() {
+			run.observe(event)
+		}
+	}()
+	defer orchestrator.Stop()
+
+	ctx = tools.WithSessionKey(ctx, run.childSessionKey)
+	ctx = tools.WithAgentID(ctx, run.agentID)
+	ctx = withSubagentDepth(ctx, run.depth)
+	if run.accessPolicy != nil {
+		ctx = WithToolPolicy(ctx, run.accessPolicy)
+	}
+	ctx = WithCheckpointFunc(ctx, run.checkpoint)
+	ctx = WithSystemContext(ctx, BuildSubagentSystemPrompt(&SubagentSystemPromptParams{
+		RequesterSessionKey: record.RequesterSessionKey,
+		RequesterOrigin:     record.RequesterOrigin,
+		ChildSessionKey:     run.childSessionKey,
+		Label:               record.Label,
+		Task:                record.Task,
+	}))
+	channel := ""
+	if record.RequesterOrigin != nil {
+		channel = record.RequesterOrigin.Channel
+	}
+	if budget := m.resolveRunBudget(run.agent, channel); !budget.IsZero() {
+		ctx = WithRunBudget(ctx, budget)
+	}
+
+	taskMsg := AgentMessage{
+		Role:      RoleUser,
+		Content:   []ContentBlock{TextContent{Text: record.Task}},
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	finalMessages, err := orchestrator.Run(ctx, []AgentMessage{taskMsg})
+	if len(finalMessages) == 0 {
+		finalMessages = []AgentMessage{taskMsg}
+	}
+
+	outcome := &SubagentRunOutcome{Status: "ok"}
+	run.mu.Lock()
+	cancelled := run.cancelled
+	run.mu.Unlock()
+	switch {
+	case cancelled:
+		outcome = &SubagentRunOutcome{Status: "cancelled", Error: "cancelled by parent agent"}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		outcome = &SubagentRunOutcome{Status: "timeout", Error: "run timed out"}
+	case err != nil:
+		outcome = &SubagentRunOutcome{Status: "error", Error: err.Error()}
+	}
+
+	m.finishSubagent(run, finalMessages, outcome)
+}
+
+// finishSubagent 保存分身会话并记录结果
+func (m *AgentManager) finishSubagent(run *subagentRun, finalMessages []AgentMessage, outcome *SubagentRunOutcome) {
+	// 保存分身会话，供 subagent_result 查看完整记录
+	if sess, err := m.sessionMgr.GetOrCreate(run.childSessionKey); err == nil {
+		m.updateSession(sess, finalMessages, 0)
+	}
+
+	if summary := lastAssistantText(finalMessages); summary != "" {
+		m.subagentRegistry.SetSummary(run.runID, summary)
+	}
+	run.mu.Lock()
+	usage := run.usage
+	run.mu.Unlock()
+	if usage.LLMCalls > 0 {
+		m.subagentRegistry.SetUsage(run.runID, usage)
+	}
+
+	// 先保存快照：MarkCompleted 之后记录可能因 cleanup=delete 被删除
+	final := *run.record
+	if snapshot, ok := m.subagentRegistry.SnapshotRun(run.runID); ok {
+		final = snapshot
+	}
+	endedAt := time.Now()
+	endedAtMs := endedAt.UnixMilli()
+	final.Outcome = outcome
+	final.EndedAt = &endedAtMs
+
+	run.mu.Lock()
+	run.final = &final
+	run.endedAt = endedAt
+	run.mu.Unlock()
+
+	if err := m.subagentRegistry.MarkCompleted(run.runID, outcome, &endedAtMs); err != nil {
+		logger.Warn("Failed to mark subagent completed",
+			zap.String("run_id", run.runID),
+			zap.Error(err))
+	}
+	close(run.done)
+
+	logger.Info("Subagent finished",
+		zap.String("run_id", run.runID),
+		zap.String("status", outcome.Status),
+		zap.String("error", outcome.Error))
+}
+
+// newSubagentOrchestrator 为分身创建独立的 orchestrator，使用独立的状态和指令队列
+// 分身的工具集按工具策略过滤
+func (m *AgentManager) newSubagentOrchestrator(run *subagentRun) *Orchestrator {
+	loopConfig := *run.agent.GetOrchestrator().config
+	loopConfig.GetSteeringMessages = func() ([]AgentMessage, error) {
+		return run.dequeueSteering(), nil
+	}
+	loopConfig.GetFollowUpMessages = func() ([]AgentMessage, error) {
+		return nil, nil
+	}
+
+	state := run.agent.state.Clone()
+	state.SessionKey = run.childSessionKey
+	state.Messages = make([]AgentMessage, 0)
+	state.SteeringQueue = make([]AgentMessage, 0)
+	state.FollowUpQueue = make([]AgentMessage, 0)
+	if run.policy != nil {
+		state.Tools = run.policy.FilterTools(state.Tools)
+	}
+
+	return NewOrchestrator(&loopConfig, state)
+}
+
+// trackSubagentRunLocked 记录分身，并清理早已结束的分身（调用方需持有 subagentMu）
+func (m *AgentManager) trackSubagentRunLocked(run *subagentRun) {
+	if m.subagentRuns == nil {
+		m.subagentRuns = make(map[string]*subagentRun)
+	}
+	for id, r := range m.subagentRuns {
+		r.mu.Lock()
+		expired := !r.endedAt.IsZero() && time.Since(r.endedAt) > finishedSubagentRetention
+		r.mu.Unlock()
+		if expired {
+			delete(m.subagentRuns, id)
+		}
+	}
+	m.subagentRuns[run.runID] = run
+}
+
+// liveSubagentRun 获取内存中的分身运行
+func (m *AgentManager) liveSubagentRun(runID string) *subagentRun {
+	m.subagentMu.Lock()
+	defer m.subagentMu.Unlock()
+	return m.subagentRuns[runID]
+}
+
+// subagentRecord 获取分身运行记录（注册表中没有时使用内存中的结束快照）
+func (m *AgentManager) subagentRecord(requesterSessionKey, runID string) (*SubagentRunRecord, error) {
+	var record *SubagentRunRecord
+	if snapshot, ok := m.subagentRegistry.SnapshotRun(runID); ok {
+		record = &snapshot
+	} else if run := m.liveSubagentRun(runID); run != nil {
+		run.mu.Lock()
+		record = run.final
+		run.mu.Unlock()
+	}
+
+	if record == nil || (requesterSessionKey != "" && record.RequesterSessionKey != requesterSessionKey) {
+		return nil, fmt.Errorf("subagent run not found: %s", runID)
+	}
+	return record, nil
+}
+
+// ListSubagents 列出分身运行；requesterSessionKey 为空时列出全部
+func (m *AgentManager) ListSubagents(requesterSessionKey string) []*tools.SubagentInfo {
+	seen := make(map[string]bool)
+	var infos []*tools.SubagentInfo
+	for _, record := range m.subagentRegistry.SnapshotRuns(requesterSessionKey) {
+		record := record
+		seen[record.RunID] = true
+		infos = append(infos, m.subagentInfo(&record))
+	}
+
+	// 注册表中已清理但仍在内存中的分身
+	m.subagentMu.Lock()
+	for id, run := range m.subagentRuns {
+		run.mu.Lock()
+		final := run.final
+		run.mu.Unlock()
+		if seen[id] || final == nil {
+			continue
+		}
+		if requesterSessionKey == "" || final.RequesterSessionKey == requesterSessionKey {
+			infos = append(infos, subagentInfo(final))
+		}
+	}
+	m.subagentMu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt.Before(infos[j].StartedAt)
+	})
+	return infos
+}
+
+// GetSubagent 获取分身运行信息
+func (m *AgentManager) GetSubagent(requesterSessionKey, runID string) (*tools.SubagentInfo, error) {
+	record, err := m.subagentRecord(requesterSessionKey, runID)
+	if err != nil {
+		return nil, err
+	}
+	return m.subagentInfo(record), nil
+}
+
+// WaitSubagents 等待分身结束；runIDs 为空时等待请求者所有运行中的分身
+func (m *AgentManager) WaitSubagents(ctx context.Context, requesterSessionKey string, runIDs []string, all bool, timeout time.Duration) ([]*tools.SubagentInfo, bool, error) {
+	if len(runIDs) == 0 {
+		for _, info := range m.ListSubagents(requesterSessionKey) {
+			if !info.Finished() {
+				runIDs = append(runIDs, info.RunID)
+			}
+		}
+		if len(runIDs) == 0 {
+			return nil, true, nil
+		}
+	}
+
+	pending := make(map[string]<-chan struct{})
+	for _, runID := range runIDs {
+		if _, err := m.subagentRecord(requesterSessionKey, runID); err != nil {
+			return nil, false, err
+		}
+		if run := m.liveSubagentRun(runID); run != nil && !run.finished() {
+			pending[runID] = run.done
+		}
+	}
+
+	remaining := len(pending)
+	satisfied := remaining == 0 || (!all && remaining < len(runIDs))
+	if !satisfied {
+		finished := make(chan struct{}, remaining)
+		stop := make(chan struct{})
+		defer close(stop)
+		for _, done := range pending {
+			go func(done <-chan struct{}) {
+				select {
+				case <-done:
+					finished <- struct{}{}
+				case <-stop:
+				}
+			}(done)
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+	wait:
+		for !satisfied {
+			select {
+			case <-finished:
+				remaining--
+				satisfied = remaining == 0 || !all
+			case <-timer.C:
+				break wait
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+		}
+	}
+
+	infos := make([]*tools.SubagentInfo, 0, len(runIDs))
+	for _, runID := range runIDs {
+		info, err := m.GetSubagent(requesterSessionKey, runID)
+		if err != nil {
+			return nil, false, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, satisfied, nil
+}
+
+// CancelSubagent 取消运行中的分身
+func (m *AgentManager) CancelSubagent(requesterSessionKey, runID string) (*tools.SubagentInfo, error) {
+	if _, err := m.subagentRecord(requesterSessionKey, runID); err != nil {
+		return nil, err
+	}
+
+	run := m.liveSubagentRun(runID)
+	if run == nil || run.finished() {
+		info, err := m.GetSubagent(requesterSessionKey, runID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("subagent %s is not running (status %s)", runID, info.Status)
+	}
+
+	// 排队中的分身直接结束
+	if m.dequeueSubagent(run) {
+		m.finishSubagent(run, []AgentMessage{{
+			Role:      RoleUser,
+			Content:   []ContentBlock{TextContent{Text: run.record.Task}},
+			Timestamp: time.Now().UnixMilli(),
+		}}, &SubagentRunOutcome{Status: "cancelled", Error: "cancelled by parent agent before it started"})
+		logger.Info("Queued subagent cancelled", zap.String("run_id", runID))
+		return m.GetSubagent(requesterSessionKey, runID)
+	}
+
+	run.mu.Lock()
+	run.cancelled = true
+	cancel := run.cancel
+	run.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	// 等待分身退出，以便返回最终状态
+	select {
+	case <-run.done:
+	case <-time.After(5 * time.Second):
+	}
+
+	logger.Info("Subagent cancelled", zap.String("run_id", runID))
+	return m.GetSubagent(requesterSessionKey, runID)
+}
+
+// SendToSubagent 向运行中的分身发送指令
+func (m *AgentManager) SendToSubagent(requesterSessionKey, runID, message string) error {
+	if _, err := m.subagentRecord(requesterSessionKey, runID); err != nil {
+		return err
+	}
+
+	run := m.liveSubagentRun(runID)
+	if run == nil || run.finished() {
+		return fmt.Errorf("subagent %s is not running", runID)
+	}
+
+	run.steer(AgentMessage{
+		Role:      RoleUser,
+		Content:   []ContentBlock{TextContent{Text: "[Message from the parent agent]\n" + message}},
+		Timestamp: time.Now().UnixMilli(),
+	})
+	return nil
+}
+
+// SubagentTranscript 返回分身会话的完整记录
+func (m *AgentManager) SubagentTranscript(requesterSessionKey, runID string) (string, error) {
+	record, err := m.subagentRecord(requesterSessionKey, runID)
+	if err != nil {
+		return "", err
+	}
+
+	// 运行中的分身使用内存中的消息快照，结束后读取分身会话
+	info := m.subagentInfo(record)
+	var history []session.Message
+	if run := m.liveSubagentRun(runID); run != nil && !info.Finished() {
+		run.mu.Lock()
+		history = agentMessagesToSessionMessages(run.messages)
+		run.mu.Unlock()
+	} else {
+		sess, err := m.sessionMgr.GetOrCreate(record.ChildSessionKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to load subagent session: %w", err)
+		}
+		history = sess.GetHistory(-1)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Transcript of subagent %s", runID))
+	if !info.Finished() {
+		sb.WriteString(fmt.Sprintf(" (still %s)", info.Status))
+	}
+	sb.WriteString(":\n")
+	for _, msg := range history {
+		sb.WriteString(fmt.Sprintf("\n[%s]", msg.Role))
+		for _, tc := range msg.ToolCalls {
+			sb.WriteString(fmt.Sprintf(" call %s(%v)", tc.Name, tc.Params))
+		}
+		if msg.Content != "" {
+			sb.WriteString("\n")
+			sb.WriteString(msg.Content)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// subagentInfo 转换分身信息，尚未开始运行的分身标记为排队中，运行中的分身使用实时用量
+func (m *AgentManager) subagentInfo(record *SubagentRunRecord) *tools.SubagentInfo {
+	info := subagentInfo(record)
+	if info.Running() {
+		if run := m.liveSubagentRun(record.RunID); run != nil {
+			run.mu.Lock()
+			if !run.started {
+				info.Status = tools.SubagentStatusQueued
+			}
+			if run.usage.LLMCalls > 0 {
+				usage := run.usage
+				info.Usage = &usage
+			}
+			run.mu.Unlock()
+		}
+	}
+	return info
+}
+
+// SubagentEvents 返回分身序号大于 since 的工具和回复事件（只保留在内存中）
+func (m *AgentManager) SubagentEvents(requesterSessionKey, runID string, since int) ([]tools.SubagentEvent, error) {
+	if _, err := m.subagentRecord(requesterSessionKey, runID); err != nil {
+		return nil, err
+	}
+	run := m.liveSubagentRun(runID)
+	if run == nil {
+		return nil, nil
+	}
+	return run.eventsSince(since), nil
+}
+
+// subagentInfo 将运行记录转换为工具使用的分身信息
+func subagentInfo(record *SubagentRunRecord) *tools.SubagentInfo {
+	agentID, _, _ := ParseAgentSessionKey(record.ChildSessionKey)
+	info := &tools.SubagentInfo{
+		RunID:               record.RunID,
+		AgentID:             agentID,
+		ChildSessionKey:     record.ChildSessionKey,
+		RequesterSessionKey: record.RequesterSessionKey,
+		Label:               record.Label,
+		Task:                record.Task,
+		Status:              tools.SubagentStatusRunning,
+		Summary:             record.Summary,
+		Usage:               record.Usage,
+		StartedAt:           time.UnixMilli(record.CreatedAt),
+	}
+	if origin := record.RequesterOrigin; origin != nil {
+		info.RequesterOrigin = &tools.DeliveryContext{
+			Channel:   origin.Channel,
+			AccountID: origin.AccountID,
+			To:        origin.To,
+			ThreadID:  origin.ThreadID,
+		}
+	}
+	if record.StartedAt != nil {
+		info.StartedAt = time.UnixMilli(*record.StartedAt)
+	}
+	if record.EndedAt != nil {
+		info.EndedAt = time.UnixMilli(*record.EndedAt)
+	}
+	if record.Outcome != nil {
+		info.Status = record.Outcome.Status
+		info.Error = record.Outcome.Error
+	}
+	return info
+}
+
+// lastAssistantText 返回最后一条助手消息的文本
+func lastAssistantText(messages []AgentMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleAssistant {
+			if text := strings.TrimSpace(extractTextContent(messages[i])); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/providers"
+	"github.com/smallnest/goclaw/session"
+)
+
+// gatedProvider 在 release 关闭前阻塞，之后回显最后一条用户消息
+type gatedProvider struct {
+	release chan struct{}
+}
+
+func (p *gatedProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, options ...providers.ChatOption) (*providers.Response, error) {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	last := ""
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			last = msg.Content
+		}
+	}
+	return &providers.Response{Content: "answer: " + last}, nil
+}
+
+func (p *gatedProvider) ChatWithTools(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, options ...providers.ChatOption) (*providers.Response, error) {
+	return p.Chat(ctx, messages, tools, options...)
+}
+
+func (p *gatedProvider) Close() error { return nil }
+
+func newSubagentTestManager(t *testing.T, provider providers.Provider) *AgentManager {
+	t.Helper()
+
+	sessionMgr, err := session.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create session manager: %v", err)
+	}
+
+	state := NewAgentState()
+	agent := &Agent{
+		orchestrator: NewOrchestrator(&LoopConfig{Provider: provider, MaxIterations: 5}, state),
+		state:        state,
+	}
+	return &AgentManager{
+		agents:           map[string]*Agent{"main": agent},
+		defaultAgent:     agent,
+		sessionMgr:       sessionMgr,
+		subagentRegistry: NewSubagentRegistry(t.TempDir()),
+	}
+}
+
+func spawnTestSubagent(t *testing.T, m *AgentManager, requester, task string) string {
+	t.Helper()
+
+	runID := GenerateRunID()
+	childKey := GenerateChildSessionKey("main")
+	if err := m.subagentRegistry.RegisterRun(&SubagentRunParams{
+		RunID:               runID,
+		ChildSessionKey:     childKey,
+		RequesterSessionKey: requester,
+		Task:                task,
+		Cleanup:             "delete",
+	}); err != nil {
+		t.Fatalf("failed to register run: %v", err)
+	}
+	if err := m.handleSubagentSpawn(context.Background(), &tools.SubagentSpawnResult{RunID: runID, ChildSessionKey: childKey}); err != nil {
+		t.Fatalf("failed to spawn subagent: %v", err)
+	}
+	return runID
+}
+
+func TestSubagentControl(t *testing.T) {
+	provider := &gatedProvider{release: make(chan struct{})}
+	m := newSubagentTestManager(t, provider)
+
+	research := spawnTestSubagent(t, m, "parent", "research topic A")
+	other := spawnTestSubagent(t, m, "parent", "research topic B")
+
+	infos := m.ListSubagents("parent")
+	if len(infos) != 2 || !infos[0].Running() || !infos[1].Running() {
+		t.Fatalf("expected 2 running subagents, got %+v", infos)
+	}
+	if len(m.ListSubagents("someone-else")) != 0 {
+		t.Error("subagents must not be visible to other sessions")
+	}
+	if _, err := m.CancelSubagent("someone-else", other); err == nil {
+		t.Error("other sessions must not be able to cancel subagents")
+	}
+
+	// 超时返回
+	if _, done, err := m.WaitSubagents(context.Background(), "parent", nil, true, 50*time.Millisecond); err != nil || done {
+		t.Fatalf("expected wait to time out, got done=%v err=%v", done, err)
+	}
+
+	// 取消一个，引导另一个
+	info, err := m.CancelSubagent("parent", other)
+	if err != nil || info.Status != "cancelled" {
+		t.Fatalf("expected cancelled subagent, got %+v (err=%v)", info, err)
+	}
+	if err := m.SendToSubagent("parent", research, "focus on pricing"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if err := m.SendToSubagent("parent", other, "too late"); err == nil {
+		t.Error("expected error when sending to a finished subagent")
+	}
+
+	close(provider.release)
+	infos, done, err := m.WaitSubagents(context.Background(), "parent", []string{research}, true, 5*time.Second)
+	if err != nil || !done {
+		t.Fatalf("wait failed: done=%v err=%v", done, err)
+	}
+	if infos[0].Status != "ok" || !strings.Contains(infos[0].Summary, "focus on pricing") {
+		t.Errorf("expected steered final answer, got %+v", infos[0])
+	}
+	if infos[0].RequesterSessionKey != "parent" || infos[0].Usage == nil || infos[0].Usage.LLMCalls == 0 {
+		t.Errorf("expected requester and usage to be reported, got %+v", infos[0])
+	}
+
+	// 看板事件可以增量获取
+	events, err := m.SubagentEvents("", research, 0)
+	if err != nil || len(events) == 0 || events[len(events)-1].Type != tools.SubagentEventAssistant {
+		t.Fatalf("expected assistant events, got %+v (err=%v)", events, err)
+	}
+	if more, _ := m.SubagentEvents("", research, events[len(events)-1].Seq); len(more) != 0 {
+		t.Errorf("expected no events after the last sequence, got %+v", more)
+	}
+
+	// cleanup=delete 清理注册表后仍能获取结果
+	time.Sleep(50 * time.Millisecond)
+	transcript, err := m.SubagentTranscript("parent", research)
+	if err != nil {
+		t.Fatalf("transcript failed: %v", err)
+	}
+	if !strings.Contains(transcript, "research topic A") || !strings.Contains(transcript, "answer:") {
+		t.Errorf("unexpected transcript:\n%s", transcript)
+	}
+	if info, err := m.GetSubagent("parent", research); err != nil || info.Status != "ok" {
+		t.Errorf("expected finished subagent to stay available, got %+v (err=%v)", info, err)
+	}
+}
+
+func TestSubagentLimits(t *testing.T) {
+	provider := &gatedProvider{release: make(chan struct{})}
+	m := newSubagentTestManager(t, provider)
+	m.cfg = &config.Config{}
+	m.cfg.Agents.Defaults.Subagents = &config.SubagentsConfig{MaxConcurrent: 1, MaxDepth: 2}
+	m.cfg.Agents.List = []config.AgentConfig{{
+		ID:        "main",
+		Subagents: &config.AgentSubagentConfig{DenyTools: []string{"subagent_send"}},
+	}}
+
+	calls := 0
+	m.defaultAgent.state.Tools = []Tool{
+		namedTool{name: "read_file", calls: &calls},
+		namedTool{name: "sessions_spawn", calls: &calls},
+		namedTool{name: "subagent_send", calls: &calls},
+		namedTool{name: "cron", calls: &calls},
+	}
+
+	first := spawnTestSubagent(t, m, "parent", "first task")
+	second := spawnTestSubagent(t, m, "parent", "second task")
+	third := spawnTestSubagent(t, m, "parent", "third task")
+
+	stats := m.SubagentStats()
+	if stats.Running != 1 || stats.Queued != 2 {
+		t.Fatalf("expected 1 running and 2 queued, got %s", stats)
+	}
+	if info, _ := m.GetSubagent("parent", second); info.Status != tools.SubagentStatusQueued {
+		t.Fatalf("expected second run to be queued, got %s", info.Status)
+	}
+	if denied := stats.Agents["main"].DeniedTools; !containsString(denied, "subagent_send") || containsString(denied, "sessions_spawn") {
+		t.Errorf("unexpected denied tools at depth 1: %v", denied)
+	}
+
+	// 取消排队中的分身不会占用名额
+	if info, err := m.CancelSubagent("parent", third); err != nil || info.Status != "cancelled" {
+		t.Fatalf("expected queued run to be cancelled, got %+v (err=%v)", info, err)
+	}
+
+	close(provider.release)
+	infos, done, err := m.WaitSubagents(context.Background(), "parent", []string{first, second}, true, 5*time.Second)
+	if err != nil || !done || infos[0].Status != "ok" || infos[1].Status != "ok" {
+		t.Fatalf("expected queued run to complete after the first, got %+v (done=%v err=%v)", infos, done, err)
+	}
+
+	// 分身工具集按策略过滤：未达最大深度可嵌套，显式拒绝和默认拒绝的工具被移除
+	run := &subagentRun{agent: m.defaultAgent, policy: m.subagentToolPolicy("main", 1)}
+	var names []string
+	for _, tool := range m.newSubagentOrchestrator(run).state.Tools {
+		names = append(names, tool.Name())
+	}
+	if strings.Join(names, ",") != "read_file,sessions_spawn" {
+		t.Errorf("unexpected child tools at depth 1: %v", names)
+	}
+	run.policy = m.subagentToolPolicy("main", 2)
+	if n := len(m.newSubagentOrchestrator(run).state.Tools); n != 1 {
+		t.Errorf("expected only read_file at max depth, got %d tools", n)
+	}
+
+	// 超过最大深度时拒绝生成
+	runID := GenerateRunID()
+	childKey := GenerateChildSessionKey("main")
+	if err := m.subagentRegistry.RegisterRun(&SubagentRunParams{RunID: runID, ChildSessionKey: childKey, Task: "too deep"}); err != nil {
+		t.Fatalf("failed to register run: %v", err)
+	}
+	err = m.handleSubagentSpawn(withSubagentDepth(context.Background(), 2), &tools.SubagentSpawnResult{RunID: runID, ChildSessionKey: childKey})
+	if err == nil || !strings.Contains(err.Error(), "max_depth 2") {
+		t.Errorf("expected depth limit error, got %v", err)
+	}
+	if _, ok := m.subagentRegistry.SnapshotRun(runID); ok {
+		t.Error("rejected run must be released from the registry")
+	}
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -97,6 +97,16 @@ func (a *toolAdapter) Execute(ctx context.Context, params map[string]any, onUpda
 		existingParams[k] = v
 	}
 
+	// Stream partial output of long-running tools as updates
+	if onUpdate != nil {
+		ctx = tools.WithProgress(ctx, func(text string) {
+			onUpdate(ToolResult{
+				Content: []ContentBlock{TextContent{Text: text}},
+				Details: map[string]any{"partial": true},
+			})
+		})
+	}
+
 	// Execute using existing tool
 	resultStr, err := a.tool.Execute(ctx, existingParams)
 
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// ToolRunner 在 Agent 循环之外执行单个工具调用（例如 goclaw mcp serve），
+// 与 Agent 循环共用参数校验、脱敏、输出缓存、不可信内容标记、确认规则和审计
+type ToolRunner struct {
+	config *LoopConfig
+	tools  []Tool
+}
+
+// NewToolRunner 创建工具执行器，config 中只使用 Redactor、OutputSpool、Untrusted 和 AuditLog
+func NewToolRunner(config *LoopConfig, tools []Tool) *ToolRunner {
+	return &ToolRunner{config: config, tools: tools}
+}
+
+// Tools 返回可调用的工具
+func (r *ToolRunner) Tools() []Tool {
+	return r.tools
+}
+
+// NeedsApproval 判断在 history 之后进行这次调用是否需要用户确认
+func (r *ToolRunner) NeedsApproval(tc ToolCallContent, history []AgentMessage) bool {
+	return r.config.Untrusted.CallRequiresApproval(tc) && untrustedSinceUser(history)
+}
+
+// Run 执行工具调用并返回结果消息，history 是调用方会话中之前的消息（用于确认规则），
+// 失败或被拦截时结果消息的 Metadata["error"] 不为空
+func (r *ToolRunner) Run(ctx context.Context, tc ToolCallContent, history []AgentMessage) AgentMessage {
+	state := NewAgentState()
+	state.Tools = r.tools
+	state.Messages = history
+
+	// 不创建事件通道，单次调用没有订阅者
+	o := &Orchestrator{config: r.config, state: state}
+	results, _ := o.executeToolCalls(ctx, []ToolCallContent{tc}, state, NewBudgetTracker(nil), NewSchemaFailureTracker())
+	if len(results) == 0 {
+		return AgentMessage{
+			Role:      RoleToolResult,
+			Timestamp: time.Now().UnixMilli(),
+			Metadata:  map[string]any{"tool_call_id": tc.ID, "tool_name": tc.Name, "error": "tool call was not executed"},
+		}
+	}
+	return results[0]
+}
+
+// UserApproval 返回表示用户已确认的消息，追加到 history 后解除不可信内容之后的确认要求
+func UserApproval(text string) AgentMessage {
+	return AgentMessage{
+		Role:      RoleUser,
+		Content:   []ContentBlock{TextContent{Text: text}},
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
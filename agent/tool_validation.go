@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/smallnest/goclaw/agent/tools"
+)
+
+// ErrInvalidToolArguments is the error code stored in tool result metadata for schema failures
+const ErrInvalidToolArguments = "invalid_arguments"
+
+// schemaFailureThreshold is the number of consecutive schema failures of one tool
+// after which the model is told to stop retrying blindly
+const schemaFailureThreshold = 3
+
+// SchemaFailureTracker counts consecutive schema validation failures per tool within a run
+type SchemaFailureTracker struct {
+	toolFailures map[string]int // tool_name -> consecutive failure count
+	totalCount   int
+}
+
+// NewSchemaFailureTracker creates a schema failure tracker
+func NewSchemaFailureTracker() *SchemaFailureTracker {
+	return &SchemaFailureTracker{
+		toolFailures: make(map[string]int),
+	}
+}
+
+// RecordFailure records a schema failure and returns the consecutive failure count for the tool
+func (t *SchemaFailureTracker) RecordFailure(toolName string) int {
+	t.toolFailures[toolName]++
+	t.totalCount++
+	return t.toolFailures[toolName]
+}
+
+// RecordSuccess resets the consecutive failure count of a tool
+func (t *SchemaFailureTracker) RecordSuccess(toolName string) {
+	if t.toolFailures[toolName] > 0 {
+		t.toolFailures[toolName] = 0
+	}
+}
+
+// Failures returns the consecutive failure count of a tool
+func (t *SchemaFailureTracker) Failures(toolName string) int {
+	return t.toolFailures[toolName]
+}
+
+// Total returns the total number of schema failures in the run
+func (t *SchemaFailureTracker) Total() int {
+	return t.totalCount
+}
+
+// validateToolArguments validates and repairs tool call arguments against the tool's schema
+func validateToolArguments(tool Tool, args map[string]any) (map[string]any, []string, error) {
+	return tools.ValidateAndCoerce(args, tool.Parameters())
+}
+
+// formatSchemaError builds the message returned to the model when arguments fail validation
+func formatSchemaError(tool Tool, err error, failures int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Invalid arguments for tool %q:\n", tool.Name()))
+
+	if verrs, ok := err.(tools.ValidationErrors); ok {
+		for _, verr := range verrs {
+			sb.WriteString("- ")
+			sb.WriteString(verr.String())
+			sb.WriteString("\n")
+		}
+	} else {
+		sb.WriteString("- ")
+		sb.WriteString(err.Error())
+		sb.WriteString("\n")
+	}
+
+	if schema, marshalErr := json.Marshal(tool.Parameters()); marshalErr == nil {
+		sb.WriteString("\nExpected parameters schema:\n")
+		sb.Write(schema)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\nThe tool was not executed. Fix the arguments and call it again.")
+	if failures >= schemaFailureThreshold {
+		sb.WriteString(fmt.Sprintf(" This tool has now failed validation %d times in a row; "+
+			"re-read the schema carefully, or use a different approach instead of repeating the same call.", failures))
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// strictTool 需要 text 参数的测试工具
+type strictTool struct {
+	echoTool
+	calls int
+}
+
+func (t *strictTool) Name() string { return "echo" }
+func (t *strictTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"text": map[string]any{"type": "string"},
+		},
+		"required": []string{"text"},
+	}
+}
+func (t *strictTool) Execute(ctx context.Context, params map[string]any, onUpdate func(ToolResult)) (ToolResult, error) {
+	t.calls++
+	return t.echoTool.Execute(ctx, params, onUpdate)
+}
+
+func TestExecuteToolCallsSchemaValidation(t *testing.T) {
+	tool := &strictTool{}
+	state := NewAgentState()
+	state.Tools = []Tool{tool}
+	orch := NewOrchestrator(&LoopConfig{Provider: &loopingProvider{}, MaxIterations: 50}, state)
+	go func() {
+		for range orch.Subscribe() {
+		}
+	}()
+
+	prompt := AgentMessage{Role: RoleUser, Content: []ContentBlock{TextContent{Text: "go"}}}
+	msgs, err := orch.Run(WithRunBudget(context.Background(), &RunBudget{MaxIterations: 3}), []AgentMessage{prompt})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if tool.calls != 0 {
+		t.Errorf("tool with invalid arguments must not be executed, got %d calls", tool.calls)
+	}
+
+	var failures []AgentMessage
+	for _, msg := range msgs {
+		if msg.Role == RoleToolResult && msg.Metadata["error"] == ErrInvalidToolArguments {
+			failures = append(failures, msg)
+		}
+	}
+	if len(failures) != 4 {
+		t.Fatalf("expected 4 schema failures, got %d", len(failures))
+	}
+
+	first := extractTextContent(failures[0])
+	if !strings.Contains(first, "text: required field missing") || strings.Contains(first, "in a row") {
+		t.Errorf("unexpected first validation message: %s", first)
+	}
+	last := failures[len(failures)-1]
+	if last.Metadata["schema_failures"] != 4 || !strings.Contains(extractTextContent(last), "4 times in a row") {
+		t.Errorf("expected repeated failures to be counted, got %v: %s", last.Metadata["schema_failures"], extractTextContent(last))
+	}
+}
@@ -133,29 +133,10 @@ func ToSchema(tool Tool) map[string]interface{} {
 	}
 }
 
-// ValidateParameters 验证参数
+// ValidateParameters 验证参数（不修复参数，只返回错误）
 func ValidateParameters(params map[string]interface{}, schema map[string]interface{}) error {
-	// 获取 required 字段
-	required := []string{}
-	if req, ok := schema["required"].([]interface{}); ok {
-		for _, r := range req {
-			if s, ok := r.(string); ok {
-				required = append(required, s)
-			}
-		}
-	}
-
-	// 检查必需字段
-	for _, field := range required {
-		if _, ok := params[field]; !ok {
-			return &ValidationError{
-				Field:   field,
-				Message: "required field missing",
-			}
-		}
-	}
-
-	return nil
+	_, _, err := ValidateAndCoerce(params, schema)
+	return err
 }
 
 // ValidationError 参数验证错误
@@ -0,0 +1,97 @@
+package tools
+
+import "context"
+
+type sessionKeyContextKey struct{}
+
+type deliveryContextKey struct{}
+
+type agentIDContextKey struct{}
+
+type senderIDContextKey struct{}
+
+type runIDContextKey struct{}
+
+type progressContextKey struct{}
+
+type loadedSkillsContextKey struct{}
+
+// WithSessionKey 将当前会话键写入上下文，供按会话隔离数据的工具使用
+func WithSessionKey(ctx context.Context, sessionKey string) context.Context {
+	return context.WithValue(ctx, sessionKeyContextKey{}, sessionKey)
+}
+
+// SessionKeyFromContext 获取上下文中的会话键，没有时返回 "default"
+func SessionKeyFromContext(ctx context.Context) string {
+	if key, ok := ctx.Value(sessionKeyContextKey{}).(string); ok && key != "" {
+		return key
+	}
+	return "default"
+}
+
+// WithDeliveryContext 将当前消息的来源（通道、账号、聊天）写入上下文
+func WithDeliveryContext(ctx context.Context, origin *DeliveryContext) context.Context {
+	return context.WithValue(ctx, deliveryContextKey{}, origin)
+}
+
+// DeliveryContextFromContext 获取上下文中的消息来源，没有时返回 nil
+func DeliveryContextFromContext(ctx context.Context) *DeliveryContext {
+	origin, _ := ctx.Value(deliveryContextKey{}).(*DeliveryContext)
+	return origin
+}
+
+// WithAgentID 将处理当前消息的 Agent ID 写入上下文
+func WithAgentID(ctx context.Context, agentID string) context.Context {
+	return context.WithValue(ctx, agentIDContextKey{}, agentID)
+}
+
+// AgentIDFromContext 获取上下文中的 Agent ID，没有时返回空字符串
+func AgentIDFromContext(ctx context.Context) string {
+	agentID, _ := ctx.Value(agentIDContextKey{}).(string)
+	return agentID
+}
+
+// WithSenderID 将触发当前运行的发送者 ID 写入上下文
+func WithSenderID(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, senderIDContextKey{}, senderID)
+}
+
+// SenderIDFromContext 获取上下文中的发送者 ID，没有时返回空字符串
+func SenderIDFromContext(ctx context.Context) string {
+	senderID, _ := ctx.Value(senderIDContextKey{}).(string)
+	return senderID
+}
+
+// WithRunID 将当前运行的 ID 写入上下文，文件检查点按运行保存
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDContextKey{}, runID)
+}
+
+// RunIDFromContext 获取上下文中的运行 ID，没有时返回空字符串
+func RunIDFromContext(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDContextKey{}).(string)
+	return runID
+}
+
+// WithProgress 将工具执行中的进度回调写入上下文，长时间运行的工具通过它流式输出
+func WithProgress(ctx context.Context, fn func(text string)) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, fn)
+}
+
+// ReportProgress 报告工具执行中产生的部分输出，上下文中没有回调时忽略
+func ReportProgress(ctx context.Context, text string) {
+	if fn, ok := ctx.Value(progressContextKey{}).(func(string)); ok && fn != nil && text != "" {
+		fn(text)
+	}
+}
+
+// WithLoadedSkills 将当前运行已加载的技能写入上下文，沙箱据此选择技能配置的镜像
+func WithLoadedSkills(ctx context.Context, skills []string) context.Context {
+	return context.WithValue(ctx, loadedSkillsContextKey{}, skills)
+}
+
+// LoadedSkillsFromContext 获取上下文中已加载的技能，按加载顺序排列
+func LoadedSkillsFromContext(ctx context.Context) []string {
+	skills, _ := ctx.Value(loadedSkillsContextKey{}).([]string)
+	return skills
+}
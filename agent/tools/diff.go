@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContextLines unified diff 的上下文行数
+	diffContextLines = 3
+
+	// maxDiffTrace Myers 算法保存的状态上限（整数个数），超过时整段替换
+	maxDiffTrace = 10_000_000
+
+	// noNewlineMarker 文件末尾没有换行时 diff 中的标记行
+	noNewlineMarker = "\n\\ No newline at end of file"
+)
+
+// diffOp 编辑序列中的一行，kind 为 ' '、'-' 或 '+'
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines 计算把 a 变为 b 的最短编辑序列（Myers 算法）
+func diffLines(a, b []string) []diffOp {
+	// 先去掉相同的首尾，减少计算量
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myers 返回编辑序列；差异太大时退化为删除全部再新增全部
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAllOps(a, b)
+	}
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		if (d+1)*len(v) > maxDiffTrace {
+			return replaceAllOps(a, b)
+		}
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset)
+			}
+		}
+	}
+	return replaceAllOps(a, b)
+}
+
+// backtrack 从 Myers 算法的状态中还原编辑序列
+func backtrack(a, b []string, trace [][]int, offset int) []diffOp {
+	x, y := len(a), len(b)
+	var ops []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[prevY]})
+			} else {
+				ops = append(ops, diffOp{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAllOps 删除 a 的全部行再新增 b 的全部行
+func replaceAllOps(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// diffableLines 将文件内容拆分为行，末尾没有换行时在最后一行附加标记，
+// 这样只有末尾换行不同的文件也会产生差异
+func diffableLines(content string) []string {
+	f := splitText(content)
+	if !f.eofNewline && len(f.lines) > 0 {
+		f.lines[len(f.lines)-1] += noNewlineMarker
+	}
+	return f.lines
+}
+
+// DiffStat 返回从 oldText 到 newText 新增和删除的行数
+func DiffStat(oldText, newText string) (added, removed int) {
+	for _, op := range diffLines(diffableLines(oldText), diffableLines(newText)) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// UnifiedDiff 生成 unified diff；内容相同时返回空字符串。
+// 空的 oldName 或 newName 表示文件不存在（/dev/null）
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	ops := diffLines(diffableLines(oldText), diffableLines(newText))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", diffName(oldName, "a/"), diffName(newName, "b/"))
+
+	// oldLine/newLine 为 ops[i] 之前的行号（从 0 开始）
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// 从变更向前取上下文，再向后合并间隔不超过 2*diffContextLines 的变更
+		start := max(i-diffContextLines, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(ops))
+				break
+			}
+			end = run
+		}
+
+		hunkOld, hunkNew := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			body.WriteByte(op.kind)
+			body.WriteString(op.text)
+			body.WriteByte('\n')
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount))
+		sb.WriteString(body.String())
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// diffName 返回 ---/+++ 行中的文件名
+func diffName(name, prefix string) string {
+	if name == "" {
+		return "/dev/null"
+	}
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return prefix + name
+}
+
+// hunkRange 格式化块头中的起始行和行数（行数为 0 时起始行为前一行）
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,424 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeepRuns 每个会话默认保留的检查点数
+	defaultKeepRuns = 20
+
+	// checkpointManifest 检查点清单文件名
+	checkpointManifest = "manifest.json"
+)
+
+// ErrFilesChangedSinceRun 文件在运行结束后又被修改，恢复会覆盖这些修改
+var ErrFilesChangedSinceRun = errors.New("files changed since the run")
+
+// FileCheckpoints 保存 Agent 在每次运行中修改的文件的原始内容，用于撤销。
+// 目录结构为 dir/<会话>/<运行 ID>/，包含清单和修改前的文件内容
+type FileCheckpoints struct {
+	dir      string
+	keepRuns int
+	mu       sync.Mutex
+}
+
+// FileCheckpoint 一次运行的文件检查点
+type FileCheckpoint struct {
+	RunID      string          `json:"run_id"`
+	SessionKey string          `json:"session_key"`
+	Prompt     string          `json:"prompt,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	RestoredAt *time.Time      `json:"restored_at,omitempty"`
+	Files      []*FileSnapshot `json:"files"`
+
+	dir string
+}
+
+// FileSnapshot 一个文件在运行开始修改之前的状态
+type FileSnapshot struct {
+	Path    string      `json:"path"`           // 解析符号链接后的真实路径
+	Existed bool        `json:"existed"`        // 修改前文件是否存在
+	Mode    os.FileMode `json:"mode,omitempty"` // 修改前的权限
+	Blob    string      `json:"blob,omitempty"` // 修改前内容的文件名
+	After   string      `json:"after,omitempty"`
+	Added   int         `json:"added"`
+	Removed int         `json:"removed"`
+}
+
+// NewFileCheckpoints 创建文件检查点存储，keepRuns 为每个会话保留的运行数
+func NewFileCheckpoints(dir string, keepRuns int) *FileCheckpoints {
+	if keepRuns <= 0 {
+		keepRuns = defaultKeepRuns
+	}
+	return &FileCheckpoints{dir: dir, keepRuns: keepRuns}
+}
+
+// Snapshot 在文件第一次被本次运行修改之前保存它的内容；path 为已检查过的真实路径。
+// 没有会话或运行 ID 时不保存
+func (c *FileCheckpoints) Snapshot(sessionKey, runID, path string) error {
+	if sessionKey == "" || runID == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir := c.runDir(sessionKey, runID)
+	cp, err := loadCheckpoint(dir)
+	if os.IsNotExist(err) {
+		cp = &FileCheckpoint{RunID: runID, SessionKey: sessionKey, CreatedAt: time.Now(), dir: dir}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		defer c.prune(sessionKey)
+	} else if err != nil {
+		return err
+	}
+	for _, f := range cp.Files {
+		if f.Path == path {
+			return nil
+		}
+	}
+
+	snap := &FileSnapshot{Path: path}
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return fmt.Errorf("cannot checkpoint %s: not a regular file", path)
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		snap.Existed = true
+		snap.Mode = info.Mode().Perm()
+		snap.Blob = fmt.Sprintf("%d.orig", len(cp.Files)+1)
+		if err := os.WriteFile(filepath.Join(dir, snap.Blob), data, 0600); err != nil {
+			return err
+		}
+	}
+	cp.Files = append(cp.Files, snap)
+	return cp.save()
+}
+
+// Finish 在运行结束时记录文件修改后的状态和统计，返回检查点；运行没有修改文件时返回 nil
+func (c *FileCheckpoints) Finish(sessionKey, runID, prompt string) (*FileCheckpoint, error) {
+	if sessionKey == "" || runID == "" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, err := loadCheckpoint(c.runDir(sessionKey, runID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cp.Prompt = prompt
+	cp.FinishedAt = &now
+	for _, f := range cp.Files {
+		before, _ := cp.Before(f)
+		after, exists := readCurrent(f.Path)
+		f.After = contentHash(after, exists)
+		f.Added, f.Removed = DiffStat(before, after)
+	}
+	return cp, cp.save()
+}
+
+// List 返回会话的检查点，最新的在前；sessionKey 为空时返回所有会话的检查点
+func (c *FileCheckpoints) List(sessionKey string) ([]*FileCheckpoint, error) {
+	var sessionDirs []string
+	if sessionKey != "" {
+		sessionDirs = []string{c.sessionDir(sessionKey)}
+	} else {
+		entries, err := os.ReadDir(c.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				sessionDirs = append(sessionDirs, filepath.Join(c.dir, e.Name()))
+			}
+		}
+	}
+
+	var checkpoints []*FileCheckpoint
+	for _, sessionDir := range sessionDirs {
+		entries, err := os.ReadDir(sessionDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			cp, err := loadCheckpoint(filepath.Join(sessionDir, e.Name()))
+			if err != nil {
+				continue
+			}
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+	return checkpoints, nil
+}
+
+// Find 按运行 ID 或其唯一前缀查找检查点
+func (c *FileCheckpoints) Find(runID string) (*FileCheckpoint, error) {
+	if runID == "" {
+		return nil, fmt.Errorf("run ID is required")
+	}
+	all, err := c.List("")
+	if err != nil {
+		return nil, err
+	}
+	var found []*FileCheckpoint
+	for _, cp := range all {
+		if cp.RunID == runID {
+			return cp, nil
+		}
+		if strings.HasPrefix(cp.RunID, runID) {
+			found = append(found, cp)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no checkpoint for run %s", runID)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("run ID prefix %s is ambiguous (%d matches)", runID, len(found))
+}
+
+// Latest 返回会话中最近一次尚未恢复的检查点，没有时返回 nil
+func (c *FileCheckpoints) Latest(sessionKey string) (*FileCheckpoint, error) {
+	checkpoints, err := c.List(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range checkpoints {
+		if cp.RestoredAt == nil {
+			return cp, nil
+		}
+	}
+	return nil, nil
+}
+
+// Restore 将检查点中的文件恢复到运行修改之前的状态：原来存在的文件写回原内容，
+// 运行中新建的文件被删除。运行结束后文件又被修改过时返回 ErrFilesChangedSinceRun，force 时仍然恢复
+func (c *FileCheckpoints) Restore(cp *FileCheckpoint, force bool) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !force {
+		if changed := cp.Changed(); len(changed) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrFilesChangedSinceRun, strings.Join(changed, ", "))
+		}
+	}
+
+	var restored []string
+	for _, f := range cp.Files {
+		if err := cp.restoreFile(f); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+		restored = append(restored, f.Path)
+	}
+	now := time.Now()
+	cp.RestoredAt = &now
+	return restored, cp.save()
+}
+
+// CleanupSession 删除会话的所有检查点
+func (c *FileCheckpoints) CleanupSession(sessionKey string) error {
+	return os.RemoveAll(c.sessionDir(sessionKey))
+}
+
+// Before 返回文件修改前的内容，文件原来不存在时返回空字符串
+func (cp *FileCheckpoint) Before(f *FileSnapshot) (string, error) {
+	if !f.Existed {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(cp.dir, f.Blob))
+	return string(data), err
+}
+
+// Changed 返回运行结束后又被修改过的文件
+func (cp *FileCheckpoint) Changed() []string {
+	if cp.FinishedAt == nil {
+		return nil
+	}
+	var changed []string
+	for _, f := range cp.Files {
+		current, exists := readCurrent(f.Path)
+		if contentHash(current, exists) != f.After {
+			changed = append(changed, f.Path)
+		}
+	}
+	return changed
+}
+
+// Diff 返回从修改前到当前内容的 unified diff
+func (cp *FileCheckpoint) Diff() (string, error) {
+	var sb strings.Builder
+	for _, f := range cp.Files {
+		before, err := cp.Before(f)
+		if err != nil {
+			return "", err
+		}
+		after, exists := readCurrent(f.Path)
+		oldName, newName := f.Path, f.Path
+		if !f.Existed {
+			oldName = ""
+		}
+		if !exists {
+			newName = ""
+		}
+		sb.WriteString(UnifiedDiff(oldName, newName, before, after))
+	}
+	return sb.String(), nil
+}
+
+// Summary 返回修改过的文件列表（A 新增、M 修改、D 删除），路径相对 base 显示
+func (cp *FileCheckpoint) Summary(base string) string {
+	var sb strings.Builder
+	for _, f := range cp.Files {
+		deleted := f.After == contentHash("", false)
+		status := "M"
+		switch {
+		case !f.Existed && deleted:
+			continue // 运行中创建后又删除
+		case !f.Existed:
+			status = "A"
+		case deleted:
+			status = "D"
+		case f.Added == 0 && f.Removed == 0:
+			continue
+		}
+		path := f.Path
+		if rel, err := filepath.Rel(base, path); err == nil && base != "" && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		fmt.Fprintf(&sb, "%s %s (+%d -%d)\n", status, path, f.Added, f.Removed)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// restoreFile 恢复单个文件；当前路径是符号链接时替换链接本身，不写到链接目标
+func (cp *FileCheckpoint) restoreFile(f *FileSnapshot) error {
+	if info, err := os.Lstat(f.Path); err == nil && (!f.Existed || info.Mode()&os.ModeSymlink != 0) {
+		if err := os.Remove(f.Path); err != nil {
+			return err
+		}
+	}
+	if !f.Existed {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(cp.dir, f.Blob))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.Path, data, f.Mode); err != nil {
+		return err
+	}
+	return os.Chmod(f.Path, f.Mode)
+}
+
+// save 写入清单
+func (cp *FileCheckpoint) save() error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cp.dir, checkpointManifest), data, 0600)
+}
+
+// loadCheckpoint 读取运行目录中的清单
+func loadCheckpoint(dir string) (*FileCheckpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointManifest))
+	if err != nil {
+		return nil, err
+	}
+	var cp FileCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	cp.dir = dir
+	return &cp, nil
+}
+
+// prune 删除会话中超出保留数量的旧检查点
+func (c *FileCheckpoints) prune(sessionKey string) {
+	entries, err := os.ReadDir(c.sessionDir(sessionKey))
+	if err != nil {
+		return
+	}
+	var checkpoints []*FileCheckpoint
+	for _, e := range entries {
+		if cp, err := loadCheckpoint(filepath.Join(c.sessionDir(sessionKey), e.Name())); err == nil {
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+	if len(checkpoints) <= c.keepRuns {
+		return
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+	for _, cp := range checkpoints[c.keepRuns:] {
+		_ = os.RemoveAll(cp.dir)
+	}
+}
+
+// sessionDir 返回会话的检查点目录
+func (c *FileCheckpoints) sessionDir(sessionKey string) string {
+	return filepath.Join(c.dir, safeFileName(sessionKey))
+}
+
+// runDir 返回运行的检查点目录
+func (c *FileCheckpoints) runDir(sessionKey, runID string) string {
+	return filepath.Join(c.sessionDir(sessionKey), safeFileName(runID))
+}
+
+// readCurrent 读取文件当前内容，文件不存在时 exists 为 false
+func readCurrent(path string) (content string, exists bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// contentHash 返回内容的摘要，不存在的文件为 "absent"
+func contentHash(content string, exists bool) string {
+	if !exists {
+		return "absent"
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileCheckpointsRestore(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{
+		"a.txt":     "one\ntwo\n",
+		"gone.txt":  "bye\n",
+		"other.txt": "untouched\n",
+	})
+	store := NewFileCheckpoints(t.TempDir(), 0)
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	fsTool.SetCheckpoints(store)
+	ctx := WithRunID(WithSessionKey(context.Background(), "chat:1"), "run-1")
+
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": "a.txt", "old_string": "two", "new_string": "TWO"}); err != nil {
+		t.Fatal(err)
+	}
+	// 同一运行中第二次修改不覆盖最初的内容
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": "a.txt", "old_string": "one", "new_string": "ONE"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsTool.WriteFile(ctx, map[string]interface{}{"path": "new.txt", "content": "fresh\n"}); err != nil {
+		t.Fatal(err)
+	}
+	patch := "--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n"
+	if _, err := fsTool.ApplyPatch(ctx, map[string]interface{}{"patch": patch}); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := store.Finish("chat:1", "run-1", "rename things")
+	if err != nil || cp == nil {
+		t.Fatalf("Finish = %v, %v", cp, err)
+	}
+	summary := cp.Summary(ws)
+	for _, want := range []string{"M a.txt (+2 -2)", "A new.txt (+1 -0)", "D gone.txt (+0 -1)"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+	diff, err := cp.Diff()
+	if err != nil || !strings.Contains(diff, "-one\n-two\n+ONE\n+TWO\n") {
+		t.Errorf("diff = %q, %v", diff, err)
+	}
+
+	latest, err := store.Latest("chat:1")
+	if err != nil || latest == nil || latest.RunID != "run-1" {
+		t.Fatalf("Latest = %v, %v", latest, err)
+	}
+	if _, err := store.Restore(latest, false); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a.txt": "one\ntwo\n", "gone.txt": "bye\n", "other.txt": "untouched\n"}
+	for name, content := range want {
+		if data, _ := os.ReadFile(filepath.Join(ws, name)); string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ws, "new.txt")); !os.IsNotExist(err) {
+		t.Error("file created by the run should be removed")
+	}
+	if latest, _ := store.Latest("chat:1"); latest != nil {
+		t.Error("restored checkpoint should not be returned again")
+	}
+}
+
+func TestFileCheckpointsChangedSinceRun(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{"a.txt": "v1\n"})
+	store := NewFileCheckpoints(t.TempDir(), 0)
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	fsTool.SetCheckpoints(store)
+	ctx := WithRunID(WithSessionKey(context.Background(), "chat:1"), "run-1")
+
+	if _, err := fsTool.WriteFile(ctx, map[string]interface{}{"path": "a.txt", "content": "v2\n"}); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Finish("chat:1", "run-1", "bump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 运行结束后用户又改了文件
+	writeTree(t, ws, map[string]string{"a.txt": "v3\n"})
+	if _, err := store.Restore(cp, false); !errors.Is(err, ErrFilesChangedSinceRun) {
+		t.Fatalf("expected ErrFilesChangedSinceRun, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "a.txt")); string(data) != "v3\n" {
+		t.Errorf("a.txt overwritten without force: %q", data)
+	}
+	if _, err := store.Restore(cp, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "a.txt")); string(data) != "v1\n" {
+		t.Errorf("a.txt = %q after forced restore", data)
+	}
+}
+
+func TestFileCheckpointsFindAndPrune(t *testing.T) {
+	ws := t.TempDir()
+	path := filepath.Join(ws, "a.txt")
+	store := NewFileCheckpoints(t.TempDir(), 2)
+
+	for _, runID := range []string{"aaa-1", "aaa-2", "bbb-3"} {
+		if err := store.Snapshot("chat:1", runID, path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(runID), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Finish("chat:1", runID, runID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := store.List("chat:1")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %d checkpoints, %v; want 2", len(list), err)
+	}
+	if _, err := store.Find("aaa-1"); err == nil {
+		t.Error("oldest run should have been pruned")
+	}
+	if cp, err := store.Find("bbb"); err != nil || cp.RunID != "bbb-3" {
+		t.Errorf("Find(prefix) = %v, %v", cp, err)
+	}
+	if err := store.Snapshot("chat:1", "bbb-4", path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Find("bbb"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected ambiguous prefix error, got %v", err)
+	}
+
+	// 没有运行 ID 时不保存
+	if err := store.Snapshot("chat:1", "", path); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := store.Finish("chat:1", "", ""); cp != nil || err != nil {
+		t.Errorf("Finish without run ID = %v, %v", cp, err)
+	}
+}
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	cases := []struct{ old, new string }{
+		{"a\nb\nc\n", "a\nB\nc\n"},
+		{"", "new\nfile\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\nELEVEN\n12\n13\n"},
+		{"no newline", "no newline\n"},
+	}
+	for _, c := range cases {
+		diff := UnifiedDiff("f.txt", "f.txt", c.old, c.new)
+		files, err := parsePatch(diff)
+		if err != nil {
+			t.Fatalf("parsePatch(%q): %v", diff, err)
+		}
+		got := splitText(c.old)
+		for _, r := range applyHunks(got, files[0].hunks) {
+			if r.conflict != "" {
+				t.Fatalf("applyHunks(%q): %s", diff, r.conflict)
+			}
+		}
+		if got.String() != c.new {
+			t.Errorf("round trip of\n%s= %q, want %q", diff, got.String(), c.new)
+		}
+	}
+
+	if added, removed := DiffStat("a\nb\n", "a\nc\nd\n"); added != 2 || removed != 1 {
+		t.Errorf("DiffStat = +%d -%d, want +2 -1", added, removed)
+	}
+	if diff := UnifiedDiff("f", "f", "same\n", "same\n"); diff != "" {
+		t.Errorf("identical content produced a diff: %q", diff)
+	}
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,38 +11,92 @@ import (
 
 // FileSystemTool 文件系统工具
 type FileSystemTool struct {
-	allowedPaths []string
-	deniedPaths  []string
-	workspace    string // 工作区路径，用于配置文件更新
+	jail        *PathJail
+	configJail  *PathJail        // 配置文件只能位于工作区
+	workspace   string           // 工作区路径，用于配置文件更新
+	checkpoints *FileCheckpoints // 修改前的文件内容，nil 表示不保存
 }
 
-// NewFileSystemTool 创建文件系统工具
+// NewFileSystemTool 创建文件系统工具，没有允许列表时只能访问工作区
 func NewFileSystemTool(allowedPaths, deniedPaths []string, workspace string) *FileSystemTool {
 	return &FileSystemTool{
-		allowedPaths: allowedPaths,
-		deniedPaths:  deniedPaths,
-		workspace:    workspace,
+		jail:       NewPathJail(allowedPaths, deniedPaths, workspace),
+		configJail: NewPathJail([]string{workspace}, deniedPaths, workspace),
+		workspace:  workspace,
 	}
 }
 
-// ReadFile 读取文件
+// Jail 返回文件访问范围（Shell 工具的工作目录使用同一范围）
+func (t *FileSystemTool) Jail() *PathJail {
+	return t.jail
+}
+
+// SetCheckpoints 设置文件检查点存储，写入、编辑和补丁修改文件前保存原始内容
+func (t *FileSystemTool) SetCheckpoints(checkpoints *FileCheckpoints) {
+	t.checkpoints = checkpoints
+}
+
+// snapshot 在本次运行第一次修改文件前保存它的内容
+func (t *FileSystemTool) snapshot(ctx context.Context, path string) error {
+	if t.checkpoints == nil {
+		return nil
+	}
+	resolved, err := t.jail.Resolve(path)
+	if err != nil {
+		return accessError(path, err)
+	}
+	if err := t.checkpoints.Snapshot(SessionKeyFromContext(ctx), RunIDFromContext(ctx), resolved); err != nil {
+		return fmt.Errorf("failed to save checkpoint of %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadFile 读取文件。指定 offset 或 limit 时只返回对应行，并带行号
 func (t *FileSystemTool) ReadFile(ctx context.Context, params map[string]interface{}) (string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("path parameter is required")
 	}
 
-	// 检查路径权限
-	if !t.isAllowed(path) {
-		return "", fmt.Errorf("access to path %s is not allowed", path)
+	content, err := t.jail.ReadFile(path)
+	if err != nil {
+		return "", accessError(path, err)
 	}
 
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
+	offset, hasOffset := params["offset"].(float64)
+	limit, hasLimit := params["limit"].(float64)
+	if !hasOffset && !hasLimit {
+		return string(content), nil
 	}
+	return numberLines(string(content), int(offset), int(limit)), nil
+}
 
-	return string(content), nil
+// numberLines 返回从第 offset 行（从 1 开始）起的 limit 行，每行前加行号；
+// limit 不大于 0 时返回到文件末尾
+func numberLines(content string, offset, limit int) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	if offset < 1 {
+		offset = 1
+	}
+	if offset > len(lines) {
+		return fmt.Sprintf("(file has %d lines, offset %d is past the end)", len(lines), offset)
+	}
+	end := len(lines)
+	if limit > 0 && offset-1+limit < end {
+		end = offset - 1 + limit
+	}
+
+	var sb strings.Builder
+	for i := offset - 1; i < end; i++ {
+		fmt.Fprintf(&sb, "%6d\t%s\n", i+1, lines[i])
+	}
+	if end < len(lines) {
+		fmt.Fprintf(&sb, "(%d more lines, continue with offset %d)\n", len(lines)-end, end+1)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
 }
 
 // WriteFile 写入文件
@@ -56,26 +111,19 @@ func (t *FileSystemTool) WriteFile(ctx context.Context, params map[string]interf
 		return "", fmt.Errorf("content parameter is required")
 	}
 
-	// 检查路径权限
-	if !t.isAllowed(path) {
-		return "", fmt.Errorf("access to path %s is not allowed", path)
-	}
-
-	// 确保目录存在
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := t.snapshot(ctx, path); err != nil {
 		return "", err
 	}
 
-	// 写入文件
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		return "", err
+	// 写入文件（自动创建目录，不会通过符号链接写到允许范围之外）
+	if err := t.jail.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", accessError(path, err)
 	}
 
 	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), path), nil
 }
 
-// EditFile 编辑文件（精确字符串替换）
+// EditFile 编辑文件（精确字符串替换）。old_string 必须唯一，除非设置 replace_all
 func (t *FileSystemTool) EditFile(ctx context.Context, params map[string]interface{}) (string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
@@ -91,55 +139,128 @@ func (t *FileSystemTool) EditFile(ctx context.Context, params map[string]interfa
 	if !ok {
 		return "", fmt.Errorf("new_string parameter is required")
 	}
-
-	// 检查路径权限
-	if !t.isAllowed(path) {
-		return "", fmt.Errorf("access to path %s is not allowed", path)
-	}
+	replaceAll, _ := params["replace_all"].(bool)
 
 	// 读取文件内容
-	content, err := os.ReadFile(path)
+	content, err := t.jail.ReadFile(path)
 	if err != nil {
+		if errors.Is(err, ErrPathNotAllowed) {
+			return "", accessError(path, err)
+		}
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	fileContent := string(content)
-
-	// 检查旧字符串是否存在
-	if !strings.Contains(fileContent, oldStr) {
-		return "", fmt.Errorf("old_string not found in file. Please verify the exact text to replace.")
-	}
-
-	// 计算替换次数
-	occurrences := strings.Count(fileContent, oldStr)
-
 	// 执行替换
-	newContent := strings.ReplaceAll(fileContent, oldStr, newStr)
+	newContent, occurrences, err := replaceString(string(content), oldStr, newStr, replaceAll)
+	if err != nil {
+		return "", err
+	}
 
 	// 写入文件
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+	if err := t.snapshot(ctx, path); err != nil {
+		return "", err
+	}
+	if err := t.jail.WriteFile(path, []byte(newContent), 0644); err != nil {
+		if errors.Is(err, ErrPathNotAllowed) {
+			return "", accessError(path, err)
+		}
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return fmt.Sprintf("Successfully replaced %d occurrence(s) in %s", occurrences, path), nil
 }
 
-// ListDir 列出目录
-func (t *FileSystemTool) ListDir(ctx context.Context, params map[string]interface{}) (string, error) {
+// MultiEdit 对同一个文件按顺序执行多处替换，任何一处失败时文件保持不变
+func (t *FileSystemTool) MultiEdit(ctx context.Context, params map[string]interface{}) (string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
 		return "", fmt.Errorf("path parameter is required")
 	}
 
-	// 检查路径权限
-	if !t.isAllowed(path) {
-		return "", fmt.Errorf("access to path %s is not allowed", path)
+	edits, ok := params["edits"].([]interface{})
+	if !ok || len(edits) == 0 {
+		return "", fmt.Errorf("edits parameter is required")
 	}
 
-	entries, err := os.ReadDir(path)
+	content, err := t.jail.ReadFile(path)
 	if err != nil {
+		if errors.Is(err, ErrPathNotAllowed) {
+			return "", accessError(path, err)
+		}
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// 在内存中依次应用，后面的编辑看到的是前面编辑之后的内容
+	newContent := string(content)
+	total := 0
+	for i, e := range edits {
+		edit, ok := e.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("edit %d: must be an object with old_string and new_string", i+1)
+		}
+		oldStr, ok := edit["old_string"].(string)
+		if !ok {
+			return "", fmt.Errorf("edit %d: old_string is required", i+1)
+		}
+		newStr, ok := edit["new_string"].(string)
+		if !ok {
+			return "", fmt.Errorf("edit %d: new_string is required", i+1)
+		}
+		replaceAll, _ := edit["replace_all"].(bool)
+
+		var n int
+		newContent, n, err = replaceString(newContent, oldStr, newStr, replaceAll)
+		if err != nil {
+			return "", fmt.Errorf("edit %d: %w (no changes were written)", i+1, err)
+		}
+		total += n
+	}
+
+	if err := t.snapshot(ctx, path); err != nil {
 		return "", err
 	}
+	if err := t.jail.WriteFileAtomic(path, []byte(newContent), 0644); err != nil {
+		if errors.Is(err, ErrPathNotAllowed) {
+			return "", accessError(path, err)
+		}
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return fmt.Sprintf("Successfully applied %d edit(s) (%d replacement(s)) to %s", len(edits), total, path), nil
+}
+
+// replaceString 替换 content 中的 oldStr，返回新内容和替换次数。
+// 未设置 replaceAll 时 oldStr 必须恰好出现一次
+func replaceString(content, oldStr, newStr string, replaceAll bool) (string, int, error) {
+	if oldStr == "" {
+		return "", 0, fmt.Errorf("old_string must not be empty")
+	}
+	if oldStr == newStr {
+		return "", 0, fmt.Errorf("old_string and new_string are identical")
+	}
+
+	occurrences := strings.Count(content, oldStr)
+	if occurrences == 0 {
+		return "", 0, fmt.Errorf("old_string not found in file. Please verify the exact text to replace.")
+	}
+	if occurrences > 1 && !replaceAll {
+		return "", 0, fmt.Errorf("old_string matches %d locations. Include more surrounding context to make it unique, or set replace_all to replace every occurrence.", occurrences)
+	}
+
+	return strings.ReplaceAll(content, oldStr, newStr), occurrences, nil
+}
+
+// ListDir 列出目录
+func (t *FileSystemTool) ListDir(ctx context.Context, params map[string]interface{}) (string, error) {
+	path, ok := params["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path parameter is required")
+	}
+
+	entries, err := t.jail.ReadDir(path)
+	if err != nil {
+		return "", accessError(path, err)
+	}
 
 	var result []string
 	for _, entry := range entries {
@@ -153,35 +274,17 @@ func (t *FileSystemTool) ListDir(ctx context.Context, params map[string]interfac
 	return strings.Join(result, "\n"), nil
 }
 
-// isAllowed 检查路径是否允许访问
-func (t *FileSystemTool) isAllowed(path string) bool {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return false
-	}
-
-	// 检查拒绝列表（转换为绝对路径）
-	for _, denied := range t.deniedPaths {
-		absDenied, err := filepath.Abs(denied)
-		if err == nil && strings.HasPrefix(absPath, absDenied) {
-			return false
-		}
-	}
+// IsAllowed 检查路径是否允许访问（MCP 资源等工具以外的读取也使用此规则）
+func (t *FileSystemTool) IsAllowed(path string) bool {
+	return t.jail.Allowed(path)
+}
 
-	// 如果没有允许列表，允许所有路径
-	if len(t.allowedPaths) == 0 {
-		return true
+// accessError 将越界错误转换为统一的提示
+func accessError(path string, err error) error {
+	if errors.Is(err, ErrPathNotAllowed) {
+		return fmt.Errorf("access to path %s is not allowed", path)
 	}
-
-	// 检查允许列表（转换为绝对路径）
-	for _, allowed := range t.allowedPaths {
-		absAllowed, err := filepath.Abs(allowed)
-		if err == nil && strings.HasPrefix(absPath, absAllowed) {
-			return true
-		}
-	}
-
-	return false
+	return err
 }
 
 // UpdateConfig 更新配置文件
@@ -220,8 +323,8 @@ func (t *FileSystemTool) UpdateConfig(ctx context.Context, params map[string]int
 		return "", fmt.Errorf("failed to create workspace directory: %w", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	// 写入文件（配置文件是指向工作区外的符号链接时拒绝写入）
+	if err := t.configJail.WriteFile(path, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -255,7 +358,7 @@ func (t *FileSystemTool) ReadConfig(ctx context.Context, params map[string]inter
 	path := filepath.Join(t.workspace, filename)
 
 	// 读取文件
-	content, err := os.ReadFile(path)
+	content, err := t.configJail.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Sprintf("Config file %s does not exist yet. Use update_config to create it.", filename), nil
@@ -271,7 +374,7 @@ func (t *FileSystemTool) GetTools() []Tool {
 	tools := []Tool{
 		NewBaseTool(
 			"read_file",
-			"Read the contents of a file",
+			"Read the contents of a file. Pass offset and limit to read a range of a large file; ranged reads are prefixed with line numbers.",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -279,6 +382,14 @@ func (t *FileSystemTool) GetTools() []Tool {
 						"type":        "string",
 						"description": "Path to the file to read",
 					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Line number to start reading from (1-based)",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of lines to read",
+					},
 				},
 				"required": []string{"path"},
 			},
@@ -305,7 +416,7 @@ func (t *FileSystemTool) GetTools() []Tool {
 		),
 		NewBaseTool(
 			"edit_file",
-			"Replace all occurrences of old_string with new_string in a file. Use for precise edits to existing files.",
+			"Replace old_string with new_string in a file. old_string must match exactly once; add surrounding context to make it unique, or set replace_all to replace every occurrence.",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -321,11 +432,71 @@ func (t *FileSystemTool) GetTools() []Tool {
 						"type":        "string",
 						"description": "The new text to replace old_string with",
 					},
+					"replace_all": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Replace every occurrence instead of requiring a unique match (default false)",
+					},
 				},
 				"required": []string{"path", "old_string", "new_string"},
 			},
 			t.EditFile,
 		),
+		NewBaseTool(
+			"multi_edit",
+			"Apply several edits to one file in order. Each edit follows the edit_file rules and sees the result of the previous ones. If any edit fails, the file is left unchanged.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the file to edit",
+					},
+					"edits": map[string]interface{}{
+						"type":        "array",
+						"description": "Edits to apply in order",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"old_string": map[string]interface{}{
+									"type":        "string",
+									"description": "The exact text to be replaced",
+								},
+								"new_string": map[string]interface{}{
+									"type":        "string",
+									"description": "The new text to replace old_string with",
+								},
+								"replace_all": map[string]interface{}{
+									"type":        "boolean",
+									"description": "Replace every occurrence (default false)",
+								},
+							},
+							"required": []string{"old_string", "new_string"},
+						},
+					},
+				},
+				"required": []string{"path", "edits"},
+			},
+			t.MultiEdit,
+		),
+		NewBaseTool(
+			"apply_patch",
+			"Apply a unified diff (diff -u or git diff) that may add, delete, rename and modify several files. Hunks are located by their context even when line numbers are off. If any hunk does not apply, nothing is written and the conflicting hunks are reported with the closest matching lines. Use dry_run to check a patch first.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"patch": map[string]interface{}{
+						"type":        "string",
+						"description": "The unified diff. Paths are relative to the workspace; a/ and b/ prefixes are stripped. Use /dev/null as the old path to create a file and as the new path to delete one.",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only report what would change (default false)",
+					},
+				},
+				"required": []string{"patch"},
+			},
+			t.ApplyPatch,
+		),
 		NewBaseTool(
 			"list_dir",
 			"List contents of a directory",
@@ -341,6 +512,78 @@ func (t *FileSystemTool) GetTools() []Tool {
 			},
 			t.ListDir,
 		),
+		NewBaseTool(
+			"glob",
+			"Find files by glob pattern (e.g. **/*.go, src/**/*.{ts,tsx}). * does not cross directories, ** matches any depth. Respects .gitignore. Results are sorted newest first.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pattern": map[string]interface{}{
+						"type":        "string",
+						"description": "Glob pattern matched against paths relative to path",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory to search (default: workspace)",
+					},
+					"include_ignored": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also return files ignored by .gitignore (default false)",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Maximum number of files to return (default %d, max %d)", defaultGlobLimit, maxGlobLimit),
+					},
+				},
+				"required": []string{"pattern"},
+			},
+			t.Glob,
+		),
+		NewBaseTool(
+			"grep",
+			"Search file contents with a regular expression (RE2 syntax). Respects .gitignore and skips binary files. Output is path:line:text; context lines use path-line-text.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pattern": map[string]interface{}{
+						"type":        "string",
+						"description": "Regular expression to search for",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File or directory to search (default: workspace)",
+					},
+					"glob": map[string]interface{}{
+						"type":        "string",
+						"description": "Only search files matching this glob (e.g. *.go, cmd/**/*.go)",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"enum":        grepFileTypeNames(),
+						"description": "Only search files of this type",
+					},
+					"ignore_case": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Case-insensitive search (default false)",
+					},
+					"context": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Lines of context around each match (max %d)", maxGrepContext),
+					},
+					"output_mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"content", "files", "count"},
+						"description": "content: matching lines (default), files: matching file paths, count: matches per file",
+					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Maximum number of matches (or files) to return (default %d, max %d)", defaultGrepResults, maxGrepResults),
+					},
+				},
+				"required": []string{"pattern"},
+			},
+			t.Grep,
+		),
 	}
 
 	// 添加配置文件管理工具
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileRange(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{"a.txt": "one\ntwo\nthree\nfour\n"})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	out, err := fsTool.ReadFile(ctx, map[string]interface{}{"path": "a.txt"})
+	if err != nil || out != "one\ntwo\nthree\nfour\n" {
+		t.Errorf("plain read = %q, %v", out, err)
+	}
+
+	out, err = fsTool.ReadFile(ctx, map[string]interface{}{"path": "a.txt", "offset": float64(2), "limit": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "     2\ttwo\n     3\tthree\n(1 more lines, continue with offset 4)"
+	if out != want {
+		t.Errorf("ranged read = %q, want %q", out, want)
+	}
+
+	out, _ = fsTool.ReadFile(ctx, map[string]interface{}{"path": "a.txt", "offset": float64(9)})
+	if !strings.Contains(out, "past the end") {
+		t.Errorf("offset past the end = %q", out)
+	}
+}
+
+func TestEditFileUniqueMatch(t *testing.T) {
+	ws := t.TempDir()
+	path := filepath.Join(ws, "a.go")
+	writeTree(t, ws, map[string]string{"a.go": "x := 1\ny := 1\n"})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	_, err := fsTool.EditFile(ctx, map[string]interface{}{"path": path, "old_string": "1", "new_string": "2"})
+	if err == nil || !strings.Contains(err.Error(), "matches 2 locations") {
+		t.Errorf("ambiguous edit err = %v", err)
+	}
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": path, "old_string": "y := 1", "new_string": "y := 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": path, "old_string": " := ", "new_string": " = ", "replace_all": true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "x = 1\ny = 2\n" {
+		t.Errorf("content = %q", data)
+	}
+
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": path, "old_string": "", "new_string": "z"}); err == nil {
+		t.Error("empty old_string should fail")
+	}
+}
+
+func TestMultiEditAtomic(t *testing.T) {
+	ws := t.TempDir()
+	path := filepath.Join(ws, "a.txt")
+	writeTree(t, ws, map[string]string{"a.txt": "alpha beta gamma"})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	_, err := fsTool.MultiEdit(ctx, map[string]interface{}{
+		"path": path,
+		"edits": []interface{}{
+			map[string]interface{}{"old_string": "alpha", "new_string": "ALPHA"},
+			map[string]interface{}{"old_string": "delta", "new_string": "DELTA"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "edit 2") {
+		t.Errorf("failing edit err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "alpha beta gamma" {
+		t.Errorf("file changed after a failed multi_edit: %q", data)
+	}
+
+	// 后面的编辑基于前面编辑的结果
+	out, err := fsTool.MultiEdit(ctx, map[string]interface{}{
+		"path": path,
+		"edits": []interface{}{
+			map[string]interface{}{"old_string": "alpha", "new_string": "delta"},
+			map[string]interface{}{"old_string": "delta beta", "new_string": "done"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "done gamma" {
+		t.Errorf("content = %q (%s)", data, out)
+	}
+}
@@ -0,0 +1,302 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultGlobLimit glob 默认返回的文件数
+	defaultGlobLimit = 200
+
+	// maxGlobLimit glob 最多返回的文件数
+	maxGlobLimit = 1000
+
+	// maxWalkEntries 单次遍历最多访问的目录项，防止在巨大的目录树上卡住
+	maxWalkEntries = 100000
+)
+
+// errWalkLimit 遍历的目录项超过上限
+var errWalkLimit = errors.New("too many files, narrow the path")
+
+// MatchGlob 判断以 / 分隔的相对路径是否匹配 glob 模式：* 和 ? 不跨目录，
+// ** 匹配任意层目录，支持 [abc] 字符类和 {a,b} 分支
+func MatchGlob(pattern, name string) bool {
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 逐段匹配，** 段匹配零个或多个路径段
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// 连续的 ** 等价于一个
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces 展开 {a,b} 分支（支持嵌套）
+func expandBraces(pattern string) []string {
+	depth, start := 0, -1
+	for i, c := range pattern {
+		switch c {
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			var out []string
+			prefix, suffix := pattern[:start], pattern[i+1:]
+			for _, alt := range splitAlternatives(pattern[start+1 : i]) {
+				out = append(out, expandBraces(prefix+alt+suffix)...)
+			}
+			return out
+		}
+	}
+	return []string{pattern}
+}
+
+// splitAlternatives 按顶层逗号拆分分支
+func splitAlternatives(s string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i, c := range s {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// ignoreRule .gitignore 中的一条规则
+type ignoreRule struct {
+	base    string // .gitignore 所在目录（相对遍历根目录，根目录为空）
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// gitIgnore 遍历过程中累积的 .gitignore 规则，后出现的规则优先
+type gitIgnore struct {
+	rules []ignoreRule
+}
+
+// load 读取目录下的 .gitignore，rel 为目录相对遍历根目录的路径
+func (g *gitIgnore) load(dir, rel string) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: rel}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// 不含 / 的模式匹配任意层级，含 / 的模式相对 .gitignore 所在目录
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// ignored 判断相对遍历根目录的路径是否被忽略
+func (g *gitIgnore) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			name = rel[len(rule.base)+1:]
+		}
+		if MatchGlob(rule.pattern, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// walkFiles 遍历允许范围内的目录，跳过 .git、被拒绝的路径和（可选）.gitignore 忽略的文件，
+// fn 收到文件的真实路径和以 / 分隔的相对路径
+func (t *FileSystemTool) walkFiles(ctx context.Context, root string, useGitignore bool, fn func(path, rel string, d fs.DirEntry) error) error {
+	ignore := &gitIgnore{}
+	visited := 0
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无法读取的子目录跳过，根目录的错误返回
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		visited++
+		if visited > maxWalkEntries {
+			return errWalkLimit
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if p != root {
+				if d.Name() == ".git" || !t.jail.Allowed(p) || (useGitignore && ignore.ignored(rel, true)) {
+					return filepath.SkipDir
+				}
+			}
+			if useGitignore {
+				if rel == "." {
+					rel = ""
+				}
+				ignore.load(p, rel)
+			}
+			return nil
+		}
+		if useGitignore && ignore.ignored(rel, false) {
+			return nil
+		}
+		if !t.jail.Allowed(p) {
+			return nil
+		}
+		return fn(p, rel, d)
+	})
+}
+
+// Glob 按 glob 模式查找文件，按修改时间从新到旧排序
+func (t *FileSystemTool) Glob(ctx context.Context, params map[string]interface{}) (string, error) {
+	pattern, ok := params["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("pattern parameter is required")
+	}
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+
+	base, _ := params["path"].(string)
+	if base == "" {
+		base = t.workspace
+	}
+	root, err := t.jail.Dir(base)
+	if err != nil {
+		return "", accessError(base, err)
+	}
+
+	limit := defaultGlobLimit
+	if n, ok := params["limit"].(float64); ok && n > 0 {
+		limit = min(int(n), maxGlobLimit)
+	}
+	includeIgnored, _ := params["include_ignored"].(bool)
+
+	type match struct {
+		path    string
+		modTime int64
+	}
+	var matches []match
+	err = t.walkFiles(ctx, root, !includeIgnored, func(p, rel string, d fs.DirEntry) error {
+		if !MatchGlob(pattern, rel) {
+			return nil
+		}
+		var modTime int64
+		if info, err := d.Info(); err == nil {
+			modTime = info.ModTime().UnixNano()
+		}
+		matches = append(matches, match{path: filepath.Join(base, filepath.FromSlash(rel)), modTime: modTime})
+		return nil
+	})
+	truncatedWalk := errors.Is(err, errWalkLimit)
+	if err != nil && !truncatedWalk {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No files match %s in %s", pattern, base), nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].modTime > matches[j].modTime })
+
+	var sb strings.Builder
+	for i, m := range matches {
+		if i == limit {
+			break
+		}
+		sb.WriteString(m.path)
+		sb.WriteString("\n")
+	}
+	if len(matches) > limit {
+		fmt.Fprintf(&sb, "(showing %d of %d matches, newest first; narrow the pattern or raise limit)\n", limit, len(matches))
+	}
+	if truncatedWalk {
+		fmt.Fprintf(&sb, "(stopped after %d entries; narrow the path)\n", maxWalkEntries)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
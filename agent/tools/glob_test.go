@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree 在 root 下按相对路径创建文件
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/app/main.go", true},
+		{"cmd/**", "cmd/app/main.go", true},
+		{"cmd/**/main.go", "cmd/main.go", true},
+		{"cmd/**/main.go", "pkg/main.go", false},
+		{"src/*.{ts,tsx}", "src/app.tsx", true},
+		{"src/*.{ts,tsx}", "src/app.js", false},
+		{"{a,b/{c,d}}/x", "b/d/x", true},
+		{"file?.txt", "file1.txt", true},
+		{"[ab]*.md", "c.md", false},
+	}
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestGlobGitignore(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{
+		".gitignore":          "*.log\nbuild/\n!keep.log\n",
+		"main.go":             "package main",
+		"app.log":             "log",
+		"keep.log":            "kept",
+		"build/out.go":        "package out",
+		"pkg/.gitignore":      "/gen.go\n",
+		"pkg/gen.go":          "package pkg",
+		"pkg/lib.go":          "package pkg",
+		"pkg/sub/gen.go":      "package sub",
+		".git/config":         "[core]",
+		"node_modules/x/a.go": "package a",
+	})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	out, err := fsTool.Glob(ctx, map[string]interface{}{"pattern": "**/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		rel, _ := filepath.Rel(ws, line)
+		found[filepath.ToSlash(rel)] = true
+	}
+	for _, want := range []string{"main.go", "keep.log", "pkg/lib.go", "pkg/sub/gen.go", "node_modules/x/a.go"} {
+		if !found[want] {
+			t.Errorf("expected %s in:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"app.log", "build/out.go", "pkg/gen.go", ".git/config"} {
+		if found[unwanted] {
+			t.Errorf("%s should be ignored:\n%s", unwanted, out)
+		}
+	}
+
+	out, err = fsTool.Glob(ctx, map[string]interface{}{"pattern": "**/*.log", "include_ignored": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "app.log") {
+		t.Errorf("include_ignored should return ignored files:\n%s", out)
+	}
+}
+
+func TestGlobSortAndLimit(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+	now := time.Now()
+	for i, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(ws, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	out, err := fsTool.Glob(context.Background(), map[string]interface{}{"pattern": "*.txt", "path": ws, "limit": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "c.txt") || !strings.HasSuffix(lines[1], "b.txt") {
+		t.Errorf("expected newest first with a truncation note, got:\n%s", out)
+	}
+	if !strings.Contains(lines[2], "2 of 3") {
+		t.Errorf("expected truncation note, got %q", lines[2])
+	}
+}
+
+func TestGlobOutsideJail(t *testing.T) {
+	ws := t.TempDir()
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	if _, err := fsTool.Glob(context.Background(), map[string]interface{}{"pattern": "*", "path": filepath.Dir(ws)}); err == nil {
+		t.Error("glob outside the workspace should be refused")
+	}
+}
+
+func TestGrep(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{
+		".gitignore":    "vendor/\n",
+		"main.go":       "package main\n\nfunc main() {\n\tTODO()\n}\n",
+		"util.go":       "package main\n// todo: later\n",
+		"notes.md":      "TODO write docs\n",
+		"vendor/dep.go": "TODO in vendor\n",
+		"data.bin":      "TODO\x00binary",
+	})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	out, err := fsTool.Grep(ctx, map[string]interface{}{"pattern": "TODO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, filepath.Join(ws, "main.go")+":4:\tTODO()") {
+		t.Errorf("expected path:line:text output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "notes.md:1:") {
+		t.Errorf("expected notes.md match, got:\n%s", out)
+	}
+	for _, unwanted := range []string{"vendor", "data.bin", "util.go"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("%s should not match:\n%s", unwanted, out)
+		}
+	}
+
+	out, err = fsTool.Grep(ctx, map[string]interface{}{"pattern": "todo", "ignore_case": true, "type": "go", "output_mode": "files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "\n") != 1 || !strings.Contains(out, "main.go") || !strings.Contains(out, "util.go") {
+		t.Errorf("expected main.go and util.go, got:\n%s", out)
+	}
+
+	out, err = fsTool.Grep(ctx, map[string]interface{}{"pattern": "TODO", "glob": "*.go", "context": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		filepath.Join(ws, "main.go") + "-3-func main() {",
+		filepath.Join(ws, "main.go") + ":4:\tTODO()",
+		filepath.Join(ws, "main.go") + "-5-}",
+	}, "\n")
+	if out != want {
+		t.Errorf("context output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = fsTool.Grep(ctx, map[string]interface{}{"pattern": "a", "path": filepath.Join(ws, "main.go"), "max_results": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, ":1:package main") || !strings.Contains(out, "capped at 1") {
+		t.Errorf("expected one capped result, got:\n%s", out)
+	}
+
+	if _, err := fsTool.Grep(ctx, map[string]interface{}{"pattern": "("}); err == nil {
+		t.Error("invalid regex should fail")
+	}
+}
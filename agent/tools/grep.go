@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultGrepResults grep 默认返回的匹配数
+	defaultGrepResults = 100
+
+	// maxGrepResults grep 最多返回的匹配数
+	maxGrepResults = 500
+
+	// maxGrepContext 最多的上下文行数
+	maxGrepContext = 10
+
+	// maxGrepFileSize 超过此大小的文件不搜索
+	maxGrepFileSize = 5 * 1024 * 1024
+
+	// maxGrepLineLength 输出的行超过此长度时截断
+	maxGrepLineLength = 500
+
+	// binarySniffSize 判断二进制文件时检查的字节数
+	binarySniffSize = 8000
+)
+
+// errGrepLimit 匹配数达到上限，停止遍历
+var errGrepLimit = errors.New("grep result limit reached")
+
+// grepFileTypes grep 的 type 参数对应的文件模式
+var grepFileTypes = map[string][]string{
+	"go":     {"*.go"},
+	"py":     {"*.py", "*.pyi"},
+	"js":     {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"ts":     {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"rust":   {"*.rs"},
+	"java":   {"*.java"},
+	"c":      {"*.c", "*.h"},
+	"cpp":    {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.h"},
+	"rb":     {"*.rb"},
+	"php":    {"*.php"},
+	"sh":     {"*.sh", "*.bash", "*.zsh"},
+	"md":     {"*.md", "*.markdown"},
+	"json":   {"*.json"},
+	"yaml":   {"*.yaml", "*.yml"},
+	"toml":   {"*.toml"},
+	"html":   {"*.html", "*.htm"},
+	"css":    {"*.css", "*.scss", "*.less"},
+	"sql":    {"*.sql"},
+	"proto":  {"*.proto"},
+	"swift":  {"*.swift"},
+	"kotlin": {"*.kt", "*.kts"},
+}
+
+// grepMatchesType 判断文件名是否属于指定类型
+func grepMatchesType(fileType, name string) bool {
+	for _, pattern := range grepFileTypes[fileType] {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// grepFileTypeNames 返回支持的文件类型（用于错误提示和参数说明）
+func grepFileTypeNames() []string {
+	names := make([]string, 0, len(grepFileTypes))
+	for name := range grepFileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Grep 按正则表达式搜索文件内容
+func (t *FileSystemTool) Grep(ctx context.Context, params map[string]interface{}) (string, error) {
+	pattern, ok := params["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("pattern parameter is required")
+	}
+	if ignoreCase, _ := params["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	base, _ := params["path"].(string)
+	if base == "" {
+		base = t.workspace
+	}
+	globPattern, _ := params["glob"].(string)
+	globPattern = strings.TrimPrefix(filepath.ToSlash(globPattern), "./")
+	fileType, _ := params["type"].(string)
+	if fileType != "" {
+		if _, ok := grepFileTypes[fileType]; !ok {
+			return "", fmt.Errorf("unknown file type %q (supported: %s)", fileType, strings.Join(grepFileTypeNames(), ", "))
+		}
+	}
+
+	contextLines := 0
+	if n, ok := params["context"].(float64); ok && n > 0 {
+		contextLines = min(int(n), maxGrepContext)
+	}
+	maxResults := defaultGrepResults
+	if n, ok := params["max_results"].(float64); ok && n > 0 {
+		maxResults = min(int(n), maxGrepResults)
+	}
+	mode, _ := params["output_mode"].(string)
+	if mode == "" {
+		mode = "content"
+	}
+	if mode != "content" && mode != "files" && mode != "count" {
+		return "", fmt.Errorf("output_mode must be content, files or count")
+	}
+
+	resolved, err := t.jail.Resolve(base)
+	if err != nil {
+		return "", accessError(base, err)
+	}
+
+	var (
+		sb        strings.Builder
+		results   int
+		truncated bool
+	)
+	search := func(p, display string) error {
+		data, err := t.jail.ReadFileLimit(p, maxGrepFileSize)
+		if err != nil || bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) >= 0 {
+			return nil
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		var matched []int
+		for i, line := range lines {
+			if re.MatchString(line) {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) == 0 {
+			return nil
+		}
+
+		if results >= maxResults {
+			truncated = true
+			return errGrepLimit
+		}
+		switch mode {
+		case "files":
+			sb.WriteString(display + "\n")
+			results++
+		case "count":
+			fmt.Fprintf(&sb, "%s:%d\n", display, len(matched))
+			results++
+		default:
+			if sb.Len() > 0 && contextLines > 0 {
+				sb.WriteString("--\n")
+			}
+			last := -1
+			for _, m := range matched {
+				if results >= maxResults {
+					truncated = true
+					break
+				}
+				start := max(m-contextLines, last+1)
+				if last >= 0 && start > last+1 && contextLines > 0 {
+					sb.WriteString("--\n")
+				}
+				for i := start; i <= min(m+contextLines, len(lines)-1); i++ {
+					sep := "-"
+					if re.MatchString(lines[i]) {
+						sep = ":"
+					}
+					fmt.Fprintf(&sb, "%s%s%d%s%s\n", display, sep, i+1, sep, truncateLine(lines[i]))
+					last = i
+				}
+				results++
+			}
+		}
+		if truncated {
+			return errGrepLimit
+		}
+		return nil
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		err = search(base, base)
+	} else {
+		root, dirErr := t.jail.Dir(base)
+		if dirErr != nil {
+			return "", accessError(base, dirErr)
+		}
+		err = t.walkFiles(ctx, root, true, func(p, rel string, d fs.DirEntry) error {
+			if fileType != "" && !grepMatchesType(fileType, d.Name()) {
+				return nil
+			}
+			if globPattern != "" && !MatchGlob(globPattern, rel) && !MatchGlob(globPattern, d.Name()) {
+				return nil
+			}
+			return search(p, filepath.Join(base, filepath.FromSlash(rel)))
+		})
+	}
+	truncatedWalk := errors.Is(err, errWalkLimit)
+	if err != nil && !truncatedWalk && !errors.Is(err, errGrepLimit) {
+		return "", err
+	}
+
+	if results == 0 {
+		return fmt.Sprintf("No matches for %s in %s", params["pattern"], base), nil
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "(results capped at %d; narrow the pattern, path or glob)\n", maxResults)
+	}
+	if truncatedWalk {
+		fmt.Fprintf(&sb, "(stopped after %d entries; narrow the path)\n", maxWalkEntries)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// truncateLine 截断过长的行
+func truncateLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if len(line) <= maxGrepLineLength {
+		return line
+	}
+	cut := maxGrepLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "..."
+}
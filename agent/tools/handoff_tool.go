@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HandoffToolName handoff 工具名称
+const HandoffToolName = "handoff"
+
+// HandoffTarget 可以接手对话的 Agent
+type HandoffTarget struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// HandoffRequest 交接请求
+type HandoffRequest struct {
+	SessionKey    string
+	Origin        *DeliveryContext
+	TargetAgentID string
+	Summary       string
+	Reason        string
+}
+
+// HandoffTool 将当前对话交接给另一个 Agent
+type HandoffTool struct {
+	getTargets func() []HandoffTarget
+	onHandoff  func(ctx context.Context, req *HandoffRequest) (string, error)
+}
+
+// NewHandoffTool 创建交接工具
+// getTargets 返回所有可交接的 Agent，onHandoff 执行交接并返回给模型的结果
+func NewHandoffTool(getTargets func() []HandoffTarget, onHandoff func(ctx context.Context, req *HandoffRequest) (string, error)) *HandoffTool {
+	return &HandoffTool{
+		getTargets: getTargets,
+		onHandoff:  onHandoff,
+	}
+}
+
+// Name 返回工具名称
+func (t *HandoffTool) Name() string {
+	return HandoffToolName
+}
+
+// Description 返回工具描述
+func (t *HandoffTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Hand the current conversation over to another agent. ")
+	sb.WriteString("The other agent answers all following user messages in this chat and receives your summary. ")
+	sb.WriteString("Use it when the request is better handled by another agent; the other agent can hand the conversation back the same way.")
+
+	targets := t.getTargets()
+	if len(targets) > 0 {
+		sb.WriteString("\n\nAvailable agents:")
+		for _, target := range targets {
+			sb.WriteString("\n- ")
+			sb.WriteString(target.ID)
+			if target.Name != "" && target.Name != target.ID {
+				sb.WriteString(fmt.Sprintf(" (%s)", target.Name))
+			}
+			if target.Description != "" {
+				sb.WriteString(": ")
+				sb.WriteString(target.Description)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Parameters 返回工具参数定义
+func (t *HandoffTool) Parameters() map[string]interface{} {
+	agentID := map[string]interface{}{
+		"type":        "string",
+		"description": "ID of the agent that should take over the conversation.",
+	}
+	if targets := t.getTargets(); len(targets) > 0 {
+		ids := make([]string, 0, len(targets))
+		for _, target := range targets {
+			ids = append(ids, target.ID)
+		}
+		agentID["enum"] = ids
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"agent_id": agentID,
+			"summary": map[string]interface{}{
+				"type":        "string",
+				"description": "Handoff note for the receiving agent: what the user wants, what has been done so far and any relevant details.",
+			},
+			"reason": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional short reason shown to the user.",
+			},
+		},
+		"required": []string{"agent_id", "summary"},
+	}
+}
+
+// Execute 执行交接
+func (t *HandoffTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	agentID, _ := params["agent_id"].(string)
+	summary, _ := params["summary"].(string)
+	reason, _ := params["reason"].(string)
+
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return "", fmt.Errorf("agent_id parameter is required")
+	}
+	if strings.TrimSpace(summary) == "" {
+		return "", fmt.Errorf("summary parameter is required")
+	}
+
+	return t.onHandoff(ctx, &HandoffRequest{
+		SessionKey:    SessionKeyFromContext(ctx),
+		Origin:        DeliveryContextFromContext(ctx),
+		TargetAgentID: agentID,
+		Summary:       strings.TrimSpace(summary),
+		Reason:        strings.TrimSpace(reason),
+	})
+}
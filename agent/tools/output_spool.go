@@ -0,0 +1,314 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ReadOutputToolName read_output 工具名称
+const ReadOutputToolName = "read_output"
+
+const (
+	defaultSpoolMaxChars  = 20000
+	defaultSpoolHeadChars = 3000
+	defaultSpoolTailChars = 2000
+	defaultReadLength     = 8000
+	maxReadLength         = 20000
+	maxGrepMatches        = 200
+)
+
+var spoolHandlePattern = regexp.MustCompile(`^out-[a-f0-9]{8,32}$`)
+
+// untrustedMarkerSuffix 标记文件的后缀，表示同名缓存输出来自不可信工具
+const untrustedMarkerSuffix = ".untrusted"
+
+// OutputSpool 将超长的工具输出写入工作区中的缓存文件，只把首尾预览和句柄返回给模型
+type OutputSpool struct {
+	dir       string
+	maxChars  int
+	limits    map[string]int
+	headChars int
+	tailChars int
+}
+
+// NewOutputSpool 创建输出缓存
+// dir 为缓存根目录，maxChars 为默认输出上限，limits 为按工具名覆盖的上限（0 表示不限制）
+func NewOutputSpool(dir string, maxChars int, limits map[string]int, headChars, tailChars int) *OutputSpool {
+	if maxChars <= 0 {
+		maxChars = defaultSpoolMaxChars
+	}
+	if headChars <= 0 {
+		headChars = defaultSpoolHeadChars
+	}
+	if tailChars <= 0 {
+		tailChars = defaultSpoolTailChars
+	}
+	return &OutputSpool{
+		dir:       dir,
+		maxChars:  maxChars,
+		limits:    limits,
+		headChars: headChars,
+		tailChars: tailChars,
+	}
+}
+
+// Limit 返回工具的输出上限（0 表示不限制）
+func (s *OutputSpool) Limit(toolName string) int {
+	if toolName == ReadOutputToolName {
+		return 0
+	}
+	if limit, ok := s.limits[toolName]; ok {
+		return limit
+	}
+	return s.maxChars
+}
+
+// Spool 在输出超过上限时写入缓存文件并返回预览；未超过上限时原样返回 spooled=false。
+// untrusted 为 true 时记录输出来自不可信工具，read_output 读取时同样加上不可信标记
+func (s *OutputSpool) Spool(sessionKey, toolName, output string, untrusted bool) (string, bool, error) {
+	limit := s.Limit(toolName)
+	if limit <= 0 || len(output) <= limit {
+		return output, false, nil
+	}
+
+	dir := s.sessionDir(sessionKey)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return output, false, err
+	}
+
+	handle := "out-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+	if err := os.WriteFile(filepath.Join(dir, handle+".log"), []byte(output), 0644); err != nil {
+		return output, false, err
+	}
+	if untrusted {
+		if err := os.WriteFile(filepath.Join(dir, handle+untrustedMarkerSuffix), nil, 0644); err != nil {
+			return output, false, err
+		}
+	}
+
+	head := cutPrefix(output, s.headChars)
+	tail := cutSuffix(output, s.tailChars)
+	lines := strings.Count(output, "\n") + 1
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Output of %s is too large (%d characters, %d lines) and was saved as handle %q.]\n\n",
+		toolName, len(output), lines, handle))
+	sb.WriteString("--- first part ---\n")
+	sb.WriteString(head)
+	sb.WriteString(fmt.Sprintf("\n\n... %d characters omitted ...\n\n", len(output)-len(head)-len(tail)))
+	sb.WriteString("--- last part ---\n")
+	sb.WriteString(tail)
+	sb.WriteString(fmt.Sprintf("\n\n[Use %s with handle %q and offset/length to page through the full output, or grep to search it.]",
+		ReadOutputToolName, handle))
+	return sb.String(), true, nil
+}
+
+// Untrusted 判断缓存输出是否来自不可信工具
+func (s *OutputSpool) Untrusted(sessionKey, handle string) bool {
+	if !spoolHandlePattern.MatchString(handle) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(s.sessionDir(sessionKey), handle+untrustedMarkerSuffix))
+	return err == nil
+}
+
+// Read 读取缓存输出的一段，offset 和 length 以字符（字节）为单位
+func (s *OutputSpool) Read(sessionKey, handle string, offset, length int) (string, error) {
+	data, err := s.load(sessionKey, handle)
+	if err != nil {
+		return "", err
+	}
+
+	if length <= 0 {
+		length = defaultReadLength
+	}
+	if length > maxReadLength {
+		length = maxReadLength
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(data) {
+		return fmt.Sprintf("[%s: offset %d is past the end of the output (%d characters)]", handle, offset, len(data)), nil
+	}
+
+	end := offset + length
+	if end > len(data) {
+		end = len(data)
+	}
+	// 避免截断多字节字符
+	for offset > 0 && offset < len(data) && !utf8.RuneStart(data[offset]) {
+		offset--
+	}
+	for end < len(data) && !utf8.RuneStart(data[end]) {
+		end++
+	}
+
+	header := fmt.Sprintf("[%s: characters %d-%d of %d", handle, offset, end, len(data))
+	if end < len(data) {
+		header += fmt.Sprintf("; continue with offset %d", end)
+	}
+	return header + "]\n" + string(data[offset:end]), nil
+}
+
+// Grep 在缓存输出中按正则搜索，返回带行号的匹配行
+func (s *OutputSpool) Grep(sessionKey, handle, pattern string) (string, error) {
+	data, err := s.load(sessionKey, handle)
+	if err != nil {
+		return "", err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid grep pattern: %w", err)
+	}
+
+	var sb strings.Builder
+	matches := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if !re.MatchString(line) {
+			continue
+		}
+		matches++
+		if matches > maxGrepMatches {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%d: %s\n", lineNo, line))
+	}
+
+	if matches == 0 {
+		return fmt.Sprintf("[%s: no lines match %q]", handle, pattern), nil
+	}
+	header := fmt.Sprintf("[%s: %d matching lines", handle, matches)
+	if matches > maxGrepMatches {
+		header += fmt.Sprintf(", showing first %d", maxGrepMatches)
+	}
+	return header + "]\n" + sb.String(), nil
+}
+
+// CleanupSession 删除会话的所有缓存输出
+func (s *OutputSpool) CleanupSession(sessionKey string) error {
+	return os.RemoveAll(s.sessionDir(sessionKey))
+}
+
+// ReadOutput read_output 工具实现
+func (s *OutputSpool) ReadOutput(ctx context.Context, params map[string]interface{}) (string, error) {
+	handle, ok := params["handle"].(string)
+	if !ok || handle == "" {
+		return "", fmt.Errorf("handle parameter is required")
+	}
+	sessionKey := SessionKeyFromContext(ctx)
+
+	if pattern, ok := params["grep"].(string); ok && pattern != "" {
+		return s.Grep(sessionKey, handle, pattern)
+	}
+
+	offset, _ := params["offset"].(float64)
+	length, _ := params["length"].(float64)
+	return s.Read(sessionKey, handle, int(offset), int(length))
+}
+
+// GetTools 获取输出缓存相关工具
+func (s *OutputSpool) GetTools() []Tool {
+	return []Tool{
+		NewBaseTool(
+			ReadOutputToolName,
+			"Read a large tool output that was saved to a handle. Page through it with offset/length, or search it with grep.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"handle": map[string]interface{}{
+						"type":        "string",
+						"description": "Output handle, e.g. out-1a2b3c4d5e6f",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Character offset to start reading from (default 0)",
+					},
+					"length": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Number of characters to read (default %d, max %d)", defaultReadLength, maxReadLength),
+					},
+					"grep": map[string]interface{}{
+						"type":        "string",
+						"description": "Regular expression; when set, returns matching lines with line numbers instead of a page",
+					},
+				},
+				"required": []string{"handle"},
+			},
+			s.ReadOutput,
+		),
+	}
+}
+
+// load 读取缓存文件
+func (s *OutputSpool) load(sessionKey, handle string) ([]byte, error) {
+	if !spoolHandlePattern.MatchString(handle) {
+		return nil, fmt.Errorf("invalid output handle: %s", handle)
+	}
+	data, err := os.ReadFile(filepath.Join(s.sessionDir(sessionKey), handle+".log"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("output %s not found (it may belong to another session or have been cleaned up)", handle)
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// sessionDir 返回会话的缓存目录
+func (s *OutputSpool) sessionDir(sessionKey string) string {
+	return filepath.Join(s.dir, safeFileName(sessionKey))
+}
+
+// safeFileName 将会话键等标识转换为可用作目录名的字符串
+func safeFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' || r == '.' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// cutPrefix 截取前 n 个字节，尽量在换行处断开
+func cutPrefix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	head := s[:n]
+	if i := strings.LastIndexByte(head, '\n'); i > n/2 {
+		head = head[:i]
+	}
+	return head
+}
+
+// cutSuffix 截取后 n 个字节，尽量在换行处断开
+func cutSuffix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	tail := s[start:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+	return tail
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func spoolTestOutput() string {
+	var sb strings.Builder
+	for i := 1; i <= 1000; i++ {
+		sb.WriteString(fmt.Sprintf("line %04d: some log output\n", i))
+	}
+	return sb.String()
+}
+
+func TestOutputSpoolPreviewAndRead(t *testing.T) {
+	spool := NewOutputSpool(t.TempDir(), 1000, map[string]int{"read_file": 0}, 200, 100)
+	output := spoolTestOutput()
+
+	// 未超过上限或不限制的工具原样返回
+	if _, spooled, _ := spool.Spool("s1", "exec", "short", false); spooled {
+		t.Error("short output should not be spooled")
+	}
+	if _, spooled, _ := spool.Spool("s1", "read_file", output, false); spooled {
+		t.Error("tool with limit 0 should not be spooled")
+	}
+
+	preview, spooled, err := spool.Spool("s1", "exec", output, false)
+	if err != nil || !spooled {
+		t.Fatalf("expected output to be spooled, got spooled=%v err=%v", spooled, err)
+	}
+	if len(preview) > 1000 {
+		t.Errorf("preview too long: %d characters", len(preview))
+	}
+	if !strings.Contains(preview, "line 0001") || !strings.Contains(preview, "line 1000") {
+		t.Errorf("preview should contain head and tail:\n%s", preview)
+	}
+
+	handle := regexp.MustCompile(`out-[a-f0-9]+`).FindString(preview)
+	if handle == "" {
+		t.Fatalf("preview should contain a handle:\n%s", preview)
+	}
+
+	ctx := WithSessionKey(context.Background(), "s1")
+	page, err := spool.ReadOutput(ctx, map[string]interface{}{"handle": handle, "offset": float64(27 * 500), "length": float64(27)})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !strings.HasSuffix(page, "line 0501: some log output\n") {
+		t.Errorf("unexpected page:\n%s", page)
+	}
+
+	matches, err := spool.ReadOutput(ctx, map[string]interface{}{"handle": handle, "grep": "line 09[0-9]9"})
+	if err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	if !strings.Contains(matches, "10 matching lines") || !strings.Contains(matches, "999: line 0999") {
+		t.Errorf("unexpected grep result:\n%s", matches)
+	}
+
+	// 只有不可信工具的输出带有标记
+	if spool.Untrusted("s1", handle) {
+		t.Error("output of exec should not be marked untrusted")
+	}
+	preview, _, _ = spool.Spool("s1", "web_fetch", output, true)
+	fetched := regexp.MustCompile(`out-[a-f0-9]+`).FindString(preview)
+	if !spool.Untrusted("s1", fetched) || spool.Untrusted("s2", fetched) {
+		t.Error("output of web_fetch should be marked untrusted in its own session")
+	}
+
+	// 其他会话无法读取
+	otherCtx := WithSessionKey(context.Background(), "s2")
+	if _, err := spool.ReadOutput(otherCtx, map[string]interface{}{"handle": handle}); err == nil {
+		t.Error("handle should not be readable from another session")
+	}
+
+	if err := spool.CleanupSession("s1"); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if _, err := spool.ReadOutput(ctx, map[string]interface{}{"handle": handle}); err == nil {
+		t.Error("handle should be gone after cleanup")
+	}
+}
+
+func TestOutputSpoolRejectsInvalidHandle(t *testing.T) {
+	spool := NewOutputSpool(t.TempDir(), 0, nil, 0, 0)
+	if _, err := spool.Read("s1", "../../etc/passwd", 0, 0); err == nil {
+		t.Error("expected error for path traversal handle")
+	}
+}
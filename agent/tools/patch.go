@@ -0,0 +1,723 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxPatchFuzz 匹配失败时最多忽略的首尾上下文行数
+	maxPatchFuzz = 2
+
+	// maxConflictLines 冲突说明中最多展示的行数
+	maxConflictLines = 12
+)
+
+// hunkHeaderRe 匹配 @@ -l,s +l,s @@ 头
+var hunkHeaderRe = regexp.MustCompile(`^@@+ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@+`)
+
+// filePatch 补丁中针对一个文件的修改。新增文件的 oldPath 为空，删除文件的 newPath 为空
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []*patchHunk
+}
+
+// patchHunk 补丁中的一个块
+type patchHunk struct {
+	header   string
+	oldStart int
+	newStart int
+	lines    []hunkLine
+}
+
+// hunkLine 块中的一行，op 为 ' '（上下文）、'-'（删除）或 '+'（新增）
+type hunkLine struct {
+	op        byte
+	text      string
+	noNewline bool // 后面跟着 "\ No newline at end of file"
+}
+
+// oldLines 返回块中旧文件一侧的行
+func (h *patchHunk) oldLines() []string {
+	var lines []string
+	for _, l := range h.lines {
+		if l.op != '+' {
+			lines = append(lines, l.text)
+		}
+	}
+	return lines
+}
+
+// newLines 返回块中新文件一侧的行
+func (h *patchHunk) newLines() []string {
+	var lines []string
+	for _, l := range h.lines {
+		if l.op != '-' {
+			lines = append(lines, l.text)
+		}
+	}
+	return lines
+}
+
+// parsePatch 解析 unified diff 和 git diff，支持多文件、新增、删除和重命名。
+// 块头中的行数只用于判断块的结束，模型数错行数时按内容继续解析
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		files   []*filePatch
+		cur     *filePatch
+		git     bool // 当前文件来自 diff --git，路径带 a/ b/ 前缀
+		headers bool // 当前文件已经有 ---/+++ 头
+	)
+	startFile := func(isGit bool) {
+		cur = &filePatch{}
+		files = append(files, cur)
+		git, headers = isGit, false
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile(true)
+			cur.oldPath, cur.newPath = parseGitDiffHeader(line[len("diff --git "):])
+
+		case cur != nil && git && !headers && strings.HasPrefix(line, "new file mode"):
+			cur.oldPath = ""
+
+		case cur != nil && git && !headers && strings.HasPrefix(line, "deleted file mode"):
+			cur.newPath = ""
+
+		case cur != nil && git && !headers && strings.HasPrefix(line, "rename from "):
+			cur.oldPath = parsePatchPath(line[len("rename from "):])
+
+		case cur != nil && git && !headers && strings.HasPrefix(line, "rename to "):
+			cur.newPath = parsePatchPath(line[len("rename to "):])
+
+		case cur != nil && git && !headers && (strings.HasPrefix(line, "GIT binary patch") || strings.HasPrefix(line, "Binary files ")):
+			return nil, fmt.Errorf("binary patches are not supported (%s)", cur.displayPath())
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || !git || headers || len(cur.hunks) > 0 {
+				startFile(false)
+			}
+			oldPath := parsePatchPath(lines[i][4:])
+			newPath := parsePatchPath(lines[i+1][4:])
+			i++
+			// a/ b/ 前缀：git diff 一定有，普通 diff -u 输出中两边同时有时才去掉
+			if git || (hasPatchPrefix(oldPath, "a/") && hasPatchPrefix(newPath, "b/")) {
+				oldPath = strings.TrimPrefix(oldPath, "a/")
+				newPath = strings.TrimPrefix(newPath, "b/")
+			}
+			cur.oldPath, cur.newPath = oldPath, newPath
+			headers = true
+
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil || (!headers && !git) {
+				return nil, fmt.Errorf("line %d: hunk without a file header (--- a/path, +++ b/path)", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, h)
+			i = next - 1
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found; expected a unified diff (--- a/path, +++ b/path, @@ ... @@)")
+	}
+	for _, f := range files {
+		if f.oldPath == "" && f.newPath == "" {
+			return nil, fmt.Errorf("patch has a file section without a path")
+		}
+		if f.oldPath != "" && f.newPath != "" && f.oldPath == f.newPath && len(f.hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks in patch", f.newPath)
+		}
+	}
+	return files, nil
+}
+
+// parseHunk 解析从 lines[start]（块头）开始的块，返回块和下一个未处理的行号
+func parseHunk(lines []string, start int) (*patchHunk, int, error) {
+	m := hunkHeaderRe.FindStringSubmatch(lines[start])
+	if m == nil {
+		return nil, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, lines[start])
+	}
+	oldStart, _ := strconv.Atoi(m[1])
+	oldCount := 1
+	if m[2] != "" {
+		oldCount, _ = strconv.Atoi(m[2])
+	}
+	newStart, _ := strconv.Atoi(m[3])
+	newCount := 1
+	if m[4] != "" {
+		newCount, _ = strconv.Atoi(m[4])
+	}
+	h := &patchHunk{header: m[0], oldStart: oldStart, newStart: newStart}
+
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		pending := oldSeen < oldCount || newSeen < newCount
+
+		if strings.HasPrefix(line, `\`) {
+			if len(h.lines) > 0 {
+				h.lines[len(h.lines)-1].noNewline = true
+			}
+			continue
+		}
+		if startsPatchSection(lines, i) {
+			break
+		}
+
+		var op byte
+		text := ""
+		switch {
+		case line == "":
+			// 模型常把空的上下文行输出为空行
+			if !pending && !continuesHunk(lines, i+1) {
+				return h, i, nil
+			}
+			op = ' '
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			op, text = line[0], line[1:]
+		default:
+			return h, i, nil
+		}
+		h.lines = append(h.lines, hunkLine{op: op, text: text})
+		if op != '+' {
+			oldSeen++
+		}
+		if op != '-' {
+			newSeen++
+		}
+	}
+	if len(h.lines) == 0 {
+		return nil, 0, fmt.Errorf("line %d: empty hunk", start+1)
+	}
+	return h, i, nil
+}
+
+// startsPatchSection 判断 lines[i] 是否开始新的文件段或块
+func startsPatchSection(lines []string, i int) bool {
+	line := lines[i]
+	switch {
+	case strings.HasPrefix(line, "@@"), strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "Index: "):
+		return true
+	case strings.HasPrefix(line, "--- "):
+		return i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	}
+	return false
+}
+
+// continuesHunk 判断 lines[i] 是否仍属于当前块
+func continuesHunk(lines []string, i int) bool {
+	if i >= len(lines) || startsPatchSection(lines, i) {
+		return false
+	}
+	line := lines[i]
+	return line == "" || line[0] == ' ' || line[0] == '-' || line[0] == '+' || line[0] == '\\'
+}
+
+// parseGitDiffHeader 从 "a/old b/new" 中取出路径（只有重命名、模式变更等没有 ---/+++ 头时使用）
+func parseGitDiffHeader(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		fields := strings.SplitN(s, `" `, 2)
+		if len(fields) == 2 {
+			return strings.TrimPrefix(parsePatchPath(fields[0]+`"`), "a/"), strings.TrimPrefix(parsePatchPath(fields[1]), "b/")
+		}
+	}
+	if idx := strings.Index(s, " b/"); strings.HasPrefix(s, "a/") && idx > 0 {
+		return s[2:idx], s[idx+3:]
+	}
+	return "", ""
+}
+
+// parsePatchPath 解析 ---/+++ 行中的路径：去掉时间戳，处理引号，/dev/null 返回空
+func parsePatchPath(s string) string {
+	if idx := strings.Index(s, "\t"); idx >= 0 {
+		s = s[:idx]
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// hasPatchPrefix 判断路径是否带有指定前缀，/dev/null 视为带有
+func hasPatchPrefix(path, prefix string) bool {
+	return path == "" || strings.HasPrefix(path, prefix)
+}
+
+// displayPath 返回用于提示的文件路径
+func (f *filePatch) displayPath() string {
+	if f.newPath != "" {
+		return f.newPath
+	}
+	return f.oldPath
+}
+
+// hunkResult 一个块的应用结果
+type hunkResult struct {
+	line     int    // 应用位置（从 1 开始）
+	offset   int    // 与块头行号的偏差
+	fuzz     string // 使用的模糊匹配方式
+	conflict string // 无法应用时的说明
+}
+
+// textFile 按行保存的文件内容，记录换行符和末尾换行
+type textFile struct {
+	lines      []string
+	crlf       bool
+	eofNewline bool
+}
+
+// splitText 将文件内容拆分为行
+func splitText(content string) *textFile {
+	f := &textFile{crlf: strings.Contains(content, "\r\n"), eofNewline: true}
+	if content == "" {
+		return f
+	}
+	f.eofNewline = strings.HasSuffix(content, "\n")
+	body := strings.TrimSuffix(content, "\n")
+	f.lines = strings.Split(body, "\n")
+	if f.crlf {
+		for i, line := range f.lines {
+			f.lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return f
+}
+
+// String 还原文件内容
+func (f *textFile) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	eol := "\n"
+	if f.crlf {
+		eol = "\r\n"
+	}
+	s := strings.Join(f.lines, eol)
+	if f.eofNewline {
+		s += eol
+	}
+	return s
+}
+
+// lineNormalizers 逐级放宽的行比较方式：精确、忽略行尾空白、忽略所有空白差异
+var lineNormalizers = []struct {
+	name string
+	fn   func(string) string
+}{
+	{"", func(s string) string { return s }},
+	{"trailing whitespace", func(s string) string { return strings.TrimRight(s, " \t\r") }},
+	{"whitespace", func(s string) string { return strings.Join(strings.Fields(s), " ") }},
+}
+
+// applyHunks 依次将块应用到文件上，返回每个块的结果；有冲突的块被跳过
+func applyHunks(file *textFile, hunks []*patchHunk) []hunkResult {
+	results := make([]hunkResult, len(hunks))
+	origLen := len(file.lines)
+	minPos := 0
+	for i, h := range hunks {
+		delta := len(file.lines) - origLen
+		expected := h.oldStart - 1 + delta
+		if h.oldStart == 0 {
+			expected = 0
+		}
+
+		pos, trimStart, trimEnd, fuzz, ok := findHunk(file.lines, h, expected, minPos)
+		if !ok {
+			results[i] = hunkResult{conflict: describeConflict(file.lines, h, expected)}
+			continue
+		}
+
+		hl := h.lines[trimStart : len(h.lines)-trimEnd]
+		var replacement []string
+		fi := pos
+		for _, l := range hl {
+			switch l.op {
+			case ' ':
+				replacement = append(replacement, file.lines[fi]) // 保留文件中的原始行
+				fi++
+			case '-':
+				fi++
+			case '+':
+				replacement = append(replacement, l.text)
+			}
+		}
+		atEOF := fi == len(file.lines)
+		lines := append([]string{}, file.lines[:pos]...)
+		lines = append(lines, replacement...)
+		file.lines = append(lines, file.lines[fi:]...)
+
+		// 块覆盖到文件末尾时，以新内容的最后一行决定末尾是否有换行
+		if atEOF && trimEnd == 0 {
+			for j := len(hl) - 1; j >= 0; j-- {
+				if hl[j].op != '-' {
+					file.eofNewline = !hl[j].noNewline
+					break
+				}
+			}
+		}
+
+		minPos = pos + len(replacement)
+		if trimStart+trimEnd > 0 {
+			ignored := fmt.Sprintf("ignored %d context line(s)", trimStart+trimEnd)
+			if fuzz != "" {
+				fuzz += ", " + ignored
+			} else {
+				fuzz = ignored
+			}
+		}
+		results[i] = hunkResult{line: pos + 1, offset: pos - (expected + trimStart), fuzz: fuzz}
+	}
+	return results
+}
+
+// findHunk 查找块在文件中的位置：优先精确匹配，离块头行号越近越优先；
+// 找不到时逐级放宽空白比较，再忽略最多 maxPatchFuzz 行首尾上下文
+func findHunk(lines []string, h *patchHunk, expected, minPos int) (pos, trimStart, trimEnd int, fuzz string, ok bool) {
+	leading, trailing := 0, 0
+	for leading < len(h.lines) && h.lines[leading].op == ' ' {
+		leading++
+	}
+	for trailing < len(h.lines)-leading && h.lines[len(h.lines)-1-trailing].op == ' ' {
+		trailing++
+	}
+
+	for f := 0; f <= maxPatchFuzz; f++ {
+		ts, te := min(f, leading), min(f, trailing)
+		if f > 0 && ts == min(f-1, leading) && te == min(f-1, trailing) {
+			continue
+		}
+		var old []string
+		for _, l := range h.lines[ts : len(h.lines)-te] {
+			if l.op != '+' {
+				old = append(old, l.text)
+			}
+		}
+		if len(old) == 0 {
+			// 纯插入：插入到块头指定的位置
+			if f > 0 {
+				break
+			}
+			return min(max(expected, minPos), len(lines)), 0, 0, "", true
+		}
+		for _, norm := range lineNormalizers {
+			if p, found := searchLines(lines, old, expected+ts, minPos, norm.fn); found {
+				return p, ts, te, norm.name, true
+			}
+		}
+	}
+	return 0, 0, 0, "", false
+}
+
+// searchLines 从 expected 开始向两侧搜索与 want 相同的连续行
+func searchLines(lines, want []string, expected, minPos int, norm func(string) string) (int, bool) {
+	last := len(lines) - len(want)
+	if last < minPos {
+		return 0, false
+	}
+	expected = min(max(expected, minPos), last)
+	for d := 0; expected-d >= minPos || expected+d <= last; d++ {
+		if p := expected + d; p <= last && linesEqual(lines[p:p+len(want)], want, norm) {
+			return p, true
+		}
+		if p := expected - d; d > 0 && p >= minPos && linesEqual(lines[p:p+len(want)], want, norm) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// linesEqual 按比较方式判断两组行是否相同
+func linesEqual(a, b []string, norm func(string) string) bool {
+	for i := range a {
+		if norm(a[i]) != norm(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeConflict 生成块无法应用的说明：期望的内容、文件中最接近的位置，以及块是否已经应用过
+func describeConflict(lines []string, h *patchHunk, expected int) string {
+	var sb strings.Builder
+	old := h.oldLines()
+	norm := lineNormalizers[len(lineNormalizers)-1].fn
+
+	if newSide := h.newLines(); len(newSide) > 0 {
+		if _, found := searchLines(lines, newSide, expected, 0, norm); found {
+			sb.WriteString("the new content is already present; this hunk looks already applied\n")
+		}
+	}
+
+	fmt.Fprintf(&sb, "expected near line %d:\n", expected+1)
+	for i, l := range old {
+		if i == maxConflictLines {
+			fmt.Fprintf(&sb, "  ... (%d more)\n", len(old)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  | %s\n", l)
+	}
+
+	// 按相同行数找最接近的位置，同分时取离块头最近的
+	best, bestScore := -1, 0
+	for p := 0; p+len(old) <= len(lines); p++ {
+		score := 0
+		for i, l := range old {
+			if norm(lines[p+i]) == norm(l) {
+				score++
+			}
+		}
+		if score > bestScore || (score == bestScore && score > 0 && absInt(p-expected) < absInt(best-expected)) {
+			best, bestScore = p, score
+		}
+	}
+	if best < 0 {
+		if len(old) > len(lines) {
+			fmt.Fprintf(&sb, "the file has only %d lines\n", len(lines))
+		} else {
+			sb.WriteString("no similar lines found in the file\n")
+		}
+		return strings.TrimRight(sb.String(), "\n")
+	}
+
+	fmt.Fprintf(&sb, "closest match at line %d (%d of %d lines match), file has:\n", best+1, bestScore, len(old))
+	for i := 0; i < len(old); i++ {
+		if i == maxConflictLines {
+			fmt.Fprintf(&sb, "  ... (%d more)\n", len(old)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  %d | %s\n", best+i+1, lines[best+i])
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// patchChange 补丁对一个文件的最终修改
+type patchChange struct {
+	patch   *filePatch
+	results []hunkResult
+	content string
+	added   int
+	removed int
+}
+
+// ApplyPatch 应用 unified diff。先在内存中应用所有文件，任何块冲突时不写入任何文件；
+// dry_run 只报告结果
+func (t *FileSystemTool) ApplyPatch(ctx context.Context, params map[string]interface{}) (string, error) {
+	text, ok := params["patch"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("patch parameter is required")
+	}
+	dryRun, _ := params["dry_run"].(bool)
+
+	files, err := parsePatch(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	var (
+		changes   []*patchChange
+		conflicts []string
+		pending   = make(map[string]*string) // 同一补丁中多次修改同一文件时使用前面的结果
+	)
+	for _, f := range files {
+		change, conflict := t.preparePatch(f, pending)
+		if conflict != "" {
+			conflicts = append(conflicts, conflict)
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	report := patchReport(changes)
+	if len(conflicts) > 0 {
+		msg := fmt.Sprintf("patch does not apply, no files were changed.\n\n%s", strings.Join(conflicts, "\n\n"))
+		if report != "" {
+			msg += "\n\nThese parts would apply:\n" + report
+		}
+		if dryRun {
+			return "Dry run: " + msg, nil
+		}
+		return "", errors.New(msg)
+	}
+	if dryRun {
+		return fmt.Sprintf("Dry run: patch applies cleanly to %d file(s), nothing was written.\n%s", len(changes), report), nil
+	}
+
+	// 先保存所有受影响文件的原始内容，再开始写入
+	for _, c := range changes {
+		for _, path := range []string{c.patch.oldPath, c.patch.newPath} {
+			if path == "" {
+				continue
+			}
+			if err := t.snapshot(ctx, path); err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, c := range changes {
+		f := c.patch
+		if f.newPath != "" {
+			if err := t.jail.WriteFile(f.newPath, []byte(c.content), 0644); err != nil {
+				return "", fmt.Errorf("failed to write %s: %w", f.newPath, accessError(f.newPath, err))
+			}
+		}
+		if f.oldPath != "" && f.oldPath != f.newPath {
+			if err := t.jail.Remove(f.oldPath); err != nil {
+				return "", fmt.Errorf("failed to remove %s: %w", f.oldPath, accessError(f.oldPath, err))
+			}
+		}
+	}
+	return fmt.Sprintf("Applied patch to %d file(s):\n%s", len(changes), report), nil
+}
+
+// preparePatch 在内存中应用一个文件的补丁，返回修改或冲突说明
+func (t *FileSystemTool) preparePatch(f *filePatch, pending map[string]*string) (*patchChange, string) {
+	path := f.displayPath()
+	for _, p := range []string{f.oldPath, f.newPath} {
+		if p == "" {
+			continue
+		}
+		if _, err := t.jail.Resolve(p); err != nil {
+			return nil, fmt.Sprintf("%s: %v", p, accessError(p, err))
+		}
+	}
+
+	var content string
+	if f.oldPath == "" {
+		if t.patchTargetExists(f.newPath, pending) {
+			return nil, fmt.Sprintf("%s: file already exists; use a patch against the existing file", f.newPath)
+		}
+	} else {
+		if c, ok := pending[f.oldPath]; ok {
+			if c == nil {
+				return nil, fmt.Sprintf("%s: file was deleted earlier in this patch", f.oldPath)
+			}
+			content = *c
+		} else {
+			data, err := t.jail.ReadFile(f.oldPath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, fmt.Sprintf("%s: file does not exist; use --- /dev/null to create it", f.oldPath)
+				}
+				return nil, fmt.Sprintf("%s: %v", f.oldPath, err)
+			}
+			content = string(data)
+		}
+	}
+	if f.newPath != "" && f.newPath != f.oldPath && f.oldPath != "" && t.patchTargetExists(f.newPath, pending) {
+		return nil, fmt.Sprintf("%s: rename target already exists", f.newPath)
+	}
+
+	file := splitText(content)
+	results := applyHunks(file, f.hunks)
+
+	var conflicts []string
+	for i, r := range results {
+		if r.conflict != "" {
+			conflicts = append(conflicts, fmt.Sprintf("%s hunk %d (%s):\n%s", path, i+1, f.hunks[i].header, r.conflict))
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, strings.Join(conflicts, "\n\n")
+	}
+	if f.newPath == "" && len(f.hunks) > 0 && len(file.lines) > 0 {
+		return nil, fmt.Sprintf("%s: file still has %d lines after applying the deletion; the patch does not match the whole file", f.oldPath, len(file.lines))
+	}
+
+	change := &patchChange{patch: f, results: results, content: file.String()}
+	for _, h := range f.hunks {
+		for _, l := range h.lines {
+			switch l.op {
+			case '+':
+				change.added++
+			case '-':
+				change.removed++
+			}
+		}
+	}
+	if f.oldPath != "" && f.oldPath != f.newPath {
+		pending[f.oldPath] = nil
+	}
+	if f.newPath != "" {
+		pending[f.newPath] = &change.content
+	}
+	return change, ""
+}
+
+// patchTargetExists 判断文件是否已存在（包括本补丁中前面创建的文件）
+func (t *FileSystemTool) patchTargetExists(path string, pending map[string]*string) bool {
+	if c, ok := pending[path]; ok {
+		return c != nil
+	}
+	resolved, err := t.jail.Resolve(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Lstat(resolved)
+	return err == nil
+}
+
+// patchReport 生成每个文件的修改摘要，包括偏移和模糊匹配的块
+func patchReport(changes []*patchChange) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		f := c.patch
+		switch {
+		case f.oldPath == "":
+			fmt.Fprintf(&sb, "  A %s", f.newPath)
+		case f.newPath == "":
+			fmt.Fprintf(&sb, "  D %s", f.oldPath)
+		case f.oldPath != f.newPath:
+			fmt.Fprintf(&sb, "  R %s -> %s", f.oldPath, f.newPath)
+		default:
+			fmt.Fprintf(&sb, "  M %s", f.newPath)
+		}
+		fmt.Fprintf(&sb, " (+%d -%d)\n", c.added, c.removed)
+		for i, r := range c.results {
+			if r.offset == 0 && r.fuzz == "" {
+				continue
+			}
+			fmt.Fprintf(&sb, "    hunk %d applied at line %d", i+1, r.line)
+			if r.offset != 0 {
+				fmt.Fprintf(&sb, ", offset %+d", r.offset)
+			}
+			if r.fuzz != "" {
+				fmt.Fprintf(&sb, ", fuzz: %s", r.fuzz)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
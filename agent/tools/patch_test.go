@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	patch := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+
+diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+\ No newline at end of file
+--- /dev/null
++++ b/dir/added.txt
+@@ -0,0 +1,2 @@
++hello
++world
+`
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{{"main.go", "main.go"}, {"old.txt", "new.txt"}, {"gone.txt", ""}, {"", "dir/added.txt"}}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for i, f := range files {
+		if f.oldPath != want[i][0] || f.newPath != want[i][1] {
+			t.Errorf("file %d = %q -> %q, want %q -> %q", i, f.oldPath, f.newPath, want[i][0], want[i][1])
+		}
+	}
+	// 空行作为上下文，块头的行数被遵守
+	if got := len(files[0].hunks[0].lines); got != 4 {
+		t.Errorf("main.go hunk has %d lines, want 4", got)
+	}
+	if !files[2].hunks[0].lines[0].noNewline {
+		t.Error("no-newline marker not recorded")
+	}
+
+	if _, err := parsePatch("just some text"); err == nil {
+		t.Error("text without headers should fail to parse")
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{
+		"main.go":  "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n",
+		"old.txt":  "rename me\n",
+		"gone.txt": "bye\n",
+	})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+
+	// 行号偏了 3 行，缩进也不同
+	patch := `--- a/main.go
++++ b/main.go
+@@ -8,3 +8,3 @@
+ func main() {
+-    fmt.Println("hi")
++	fmt.Println("hello")
+ }
+--- a/old.txt
++++ b/renamed.txt
+@@ -1 +1 @@
+-rename me
++renamed
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+--- /dev/null
++++ b/new/file.txt
+@@ -0,0 +1 @@
++created
+`
+	out, err := fsTool.ApplyPatch(ctx, map[string]interface{}{"patch": patch, "dry_run": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Dry run") || !strings.Contains(out, "offset -3") || !strings.Contains(out, "fuzz: whitespace") {
+		t.Errorf("dry run report:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "new", "file.txt")); !os.IsNotExist(err) {
+		t.Error("dry run wrote a file")
+	}
+
+	out, err = fsTool.ApplyPatch(ctx, map[string]interface{}{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"M main.go", "R old.txt -> renamed.txt", "D gone.txt", "A new/file.txt"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "main.go")); !strings.Contains(string(data), "\tfmt.Println(\"hello\")\n}\n") {
+		t.Errorf("main.go = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "renamed.txt")); string(data) != "renamed\n" {
+		t.Errorf("renamed.txt = %q", data)
+	}
+	for _, name := range []string{"old.txt", "gone.txt"} {
+		if _, err := os.Stat(filepath.Join(ws, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", name)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "new", "file.txt")); string(data) != "created\n" {
+		t.Errorf("new/file.txt = %q", data)
+	}
+}
+
+func TestApplyPatchConflict(t *testing.T) {
+	ws := t.TempDir()
+	writeTree(t, ws, map[string]string{
+		"a.txt": "one\ntwo\nthree\nfour\n",
+		"b.txt": "alpha\nbeta\ngamma\n",
+	})
+	fsTool := NewFileSystemTool(nil, nil, ws)
+
+	// a.txt 可以应用，b.txt 的第二个块与文件内容不符
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+-one
++ONE
+ two
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+-alpha
++ALPHA
+ beta
+@@ -2,2 +2,2 @@
+ beta
+-delta
++DELTA
+`
+	_, err := fsTool.ApplyPatch(context.Background(), map[string]interface{}{"patch": patch})
+	if err == nil {
+		t.Fatal("expected a conflict")
+	}
+	msg := err.Error()
+	for _, want := range []string{"no files were changed", "b.txt hunk 2", "closest match at line 2 (1 of 2 lines match)", "2 | beta", "M a.txt"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("conflict report missing %q:\n%s", want, msg)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "a.txt")); string(data) != "one\ntwo\nthree\nfour\n" {
+		t.Errorf("a.txt changed despite the conflict: %q", data)
+	}
+
+	// 已应用过的块
+	if _, err := fsTool.ApplyPatch(context.Background(), map[string]interface{}{"patch": "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-zero\n+one\n two\n"}); err == nil || !strings.Contains(err.Error(), "already applied") {
+		t.Errorf("expected already-applied hint, got %v", err)
+	}
+}
+
+func TestApplyPatchOutsideJail(t *testing.T) {
+	root, ws := jailFixture(t)
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	patch := "--- a/../outside/passwd\n+++ b/../outside/passwd\n@@ -1 +1 @@\n-root:x:0:0\n+owned\n"
+	if _, err := fsTool.ApplyPatch(context.Background(), map[string]interface{}{"patch": patch}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected access error, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "outside", "passwd")); string(data) != "root:x:0:0" {
+		t.Errorf("outside file modified: %q", data)
+	}
+}
@@ -0,0 +1,360 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks 解析路径时最多跟随的符号链接数（与 Linux 的 ELOOP 上限一致）
+const maxSymlinks = 40
+
+// ErrPathNotAllowed 路径不在允许范围内
+var ErrPathNotAllowed = errors.New("path is not allowed")
+
+// ErrFileTooLarge 文件超过读取上限
+var ErrFileTooLarge = errors.New("file is too large")
+
+// PathJail 文件访问范围：路径先解析符号链接得到真实位置，再按路径段与允许/拒绝目录比较。
+// 没有允许列表时只允许工作区。文件工具和 Shell 工作目录共用同一个 PathJail
+type PathJail struct {
+	allowed   []string
+	denied    []string
+	workspace string
+}
+
+// NewPathJail 创建文件访问范围，相对路径以工作区为基准
+func NewPathJail(allowedPaths, deniedPaths []string, workspace string) *PathJail {
+	allowed := allowedPaths
+	if len(allowed) == 0 && workspace != "" {
+		allowed = []string{workspace}
+	}
+	return &PathJail{
+		allowed:   allowed,
+		denied:    deniedPaths,
+		workspace: workspace,
+	}
+}
+
+// Workspace 返回工作区路径
+func (j *PathJail) Workspace() string {
+	return j.workspace
+}
+
+// Resolve 返回路径解析符号链接后的真实位置，不在允许范围内时返回 ErrPathNotAllowed。
+// 文件读写应使用返回的路径，而不是原始路径
+func (j *PathJail) Resolve(path string) (string, error) {
+	resolved, err := j.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if !j.contains(resolved) {
+		return "", fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
+	return resolved, nil
+}
+
+// Allowed 判断路径是否允许访问
+func (j *PathJail) Allowed(path string) bool {
+	_, err := j.Resolve(path)
+	return err == nil
+}
+
+// contains 判断已解析的路径是否在允许范围内且不在拒绝范围内
+func (j *PathJail) contains(resolved string) bool {
+	for _, denied := range j.denied {
+		if root, err := resolvePath(denied); err == nil && withinDir(resolved, root) {
+			return false
+		}
+	}
+	for _, allowed := range j.allowed {
+		if root, err := resolvePath(allowed); err == nil && withinDir(resolved, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 将相对路径转换为相对工作区的绝对路径后解析
+func (j *PathJail) resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+	if !filepath.IsAbs(path) && j.workspace != "" {
+		path = j.workspace + string(filepath.Separator) + path
+	}
+	return resolvePath(path)
+}
+
+// ReadFile 读取允许范围内的文件
+func (j *PathJail) ReadFile(path string) ([]byte, error) {
+	return j.ReadFileLimit(path, -1)
+}
+
+// ReadFileLimit 读取允许范围内的文件，文件超过 limit 字节时返回 ErrFileTooLarge 而不读取内容（limit < 0 表示不限制）
+func (j *PathJail) ReadFileLimit(path string, limit int64) ([]byte, error) {
+	resolved, err := j.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := j.openResolved(path, resolved, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if limit < 0 {
+		return io.ReadAll(f)
+	}
+	if info.Size() > limit {
+		return nil, fmt.Errorf("%w: %s", ErrFileTooLarge, path)
+	}
+	// 文件可能在打开后继续增长
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s", ErrFileTooLarge, path)
+	}
+	return data, nil
+}
+
+// WriteFile 写入允许范围内的文件，必要时创建父目录；不会通过符号链接写到范围之外
+func (j *PathJail) WriteFile(path string, data []byte, perm os.FileMode) error {
+	resolved, err := j.Resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(resolved), 0755); err != nil {
+		return err
+	}
+
+	// 先不截断，确认打开的是检查过的文件后再截断
+	f, err := j.openResolved(path, resolved, os.O_WRONLY|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteFileAtomic 与 WriteFile 相同，但先写入同目录下的临时文件再重命名，
+// 写入失败或进程中途退出时原文件保持不变。已有文件的权限保持不变
+func (j *PathJail) WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	resolved, err := j.Resolve(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(resolved)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(resolved); err == nil && info.Mode().IsRegular() {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(resolved)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	// 重命名前确认目录和目标仍是检查过的位置（重命名不跟随目标处的符号链接）
+	if again, err := j.Resolve(path); err != nil || filepath.Dir(again) != dir {
+		return fmt.Errorf("%w: %s changed while writing", ErrPathNotAllowed, path)
+	}
+	if realDir, err := resolvePath(dir); err != nil || realDir != dir {
+		return fmt.Errorf("%w: %s changed while writing", ErrPathNotAllowed, path)
+	}
+	return os.Rename(tmpPath, resolved)
+}
+
+// Remove 删除允许范围内的文件。路径本身是符号链接时只删除链接
+func (j *PathJail) Remove(path string) error {
+	abs, err := j.resolve(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(abs, filepath.Base(path))
+	if !j.contains(target) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
+	return os.Remove(target)
+}
+
+// ReadDir 列出允许范围内的目录
+func (j *PathJail) ReadDir(path string) ([]os.DirEntry, error) {
+	resolved, err := j.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(resolved)
+}
+
+// Dir 返回允许范围内的目录的真实路径（用于命令的工作目录）
+func (j *PathJail) Dir(path string) (string, error) {
+	resolved, err := j.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+	return resolved, nil
+}
+
+// openResolved 打开已检查过的路径。最后一段不跟随符号链接，打开后重新解析并确认
+// 打开的仍是同一个文件，防止检查与打开之间路径被替换为指向范围外的符号链接（TOCTOU）
+func (j *PathJail) openResolved(path, resolved string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(resolved, flag|oNoFollow, perm)
+	if err != nil {
+		if isSymlinkLoop(err) {
+			return nil, fmt.Errorf("%w: %s changed to a symlink", ErrPathNotAllowed, path)
+		}
+		return nil, err
+	}
+
+	again, err := j.Resolve(path)
+	if err != nil || again != resolved {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s changed while opening", ErrPathNotAllowed, path)
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	current, err := os.Lstat(resolved)
+	if err != nil || !os.SameFile(opened, current) {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s changed while opening", ErrPathNotAllowed, path)
+	}
+	return f, nil
+}
+
+// resolvePath 将路径转换为绝对路径并逐段解析符号链接（与内核的解析顺序一致，
+// link/.. 指向链接目标的上级目录），不存在的部分按字面拼接，其后不允许出现 ..
+func resolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = wd + string(filepath.Separator) + path
+	}
+
+	volume := filepath.VolumeName(path)
+	root := volume + string(filepath.Separator)
+	resolved := root
+	parts := splitPath(path[len(volume):])
+	links := 0
+	missing := false
+
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		switch part {
+		case ".":
+			continue
+		case "..":
+			// 不存在的目录没有可解析的上级，按字面回退可能绕过后面的符号链接
+			if missing {
+				return "", fmt.Errorf("%w: %s has .. after a missing directory", ErrPathNotAllowed, path)
+			}
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// 尚不存在（例如要创建的文件），继续逐段检查其余部分
+				missing = true
+				resolved = next
+				continue
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks in %s", path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			volume = filepath.VolumeName(target)
+			resolved = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		parts = append(splitPath(target), parts[i+1:]...)
+		i = -1
+	}
+	return resolved, nil
+}
+
+// splitPath 按分隔符拆分路径，忽略空段
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// withinDir 按路径段判断 path 是否为 dir 或其子路径（/ws-evil 不属于 /ws）
+func withinDir(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+// jailFixture 创建工作区、同名前缀的兄弟目录和工作区外的目录
+//
+//	root/
+//	  workspace/notes.txt
+//	  workspace/secret/key.txt
+//	  workspace-evil/loot.txt
+//	  outside/passwd
+func jailFixture(t *testing.T) (root, workspace string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+	root = t.TempDir()
+	// macOS 的临时目录位于 /var -> /private/var 符号链接下
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace = filepath.Join(root, "workspace")
+	for path, content := range map[string]string{
+		"workspace/notes.txt":      "notes",
+		"workspace/secret/key.txt": "key",
+		"workspace-evil/loot.txt":  "loot",
+		"outside/passwd":           "root:x:0:0",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, workspace
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPathJailResolve(t *testing.T) {
+	root, ws := jailFixture(t)
+	outside := filepath.Join(root, "outside")
+
+	symlink(t, outside, filepath.Join(ws, "escape"))                          // 目录链接到工作区外
+	symlink(t, filepath.Join(outside, "passwd"), filepath.Join(ws, "passwd")) // 文件链接到工作区外
+	symlink(t, "notes.txt", filepath.Join(ws, "alias.txt"))                   // 工作区内的相对链接
+	symlink(t, filepath.Join(ws, "secret"), filepath.Join(ws, "public"))      // 指向拒绝目录的别名
+	symlink(t, "../outside/new.txt", filepath.Join(ws, "dangling"))           // 悬空链接指向工作区外
+	symlink(t, "loop", filepath.Join(ws, "loop"))
+	if err := os.MkdirAll(filepath.Join(outside, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, filepath.Join(outside, "sub"), filepath.Join(ws, "subdir")) // subdir/.. 是 outside
+
+	jail := NewPathJail(nil, []string{filepath.Join(ws, "secret")}, ws)
+
+	cases := []struct {
+		path    string
+		allowed bool
+	}{
+		{filepath.Join(ws, "notes.txt"), true},
+		{"notes.txt", true}, // 相对路径以工作区为基准
+		{filepath.Join(ws, "new", "file.txt"), true},
+		{ws, true},
+		{filepath.Join(ws, "alias.txt"), true},
+		{filepath.Join(root, "workspace-evil", "loot.txt"), false}, // 前缀相同的兄弟目录
+		{filepath.Join(ws, "..", "outside", "passwd"), false},
+		{"../outside/passwd", false},
+		{filepath.Join(ws, "escape", "passwd"), false},
+		{filepath.Join(ws, "passwd"), false},
+		{filepath.Join(ws, "secret", "key.txt"), false},
+		{filepath.Join(ws, "public", "key.txt"), false},
+		{filepath.Join(ws, "dangling"), false},
+		{filepath.Join(ws, "subdir", "..", "passwd"), false},
+		{filepath.Join(ws, "loop"), false},
+		{"nope/../escape/passwd", false}, // 不存在的目录后的 .. 不能跳过 escape 链接
+		{ws + "/nope/../escape/new", false},
+		{"nope/../notes.txt", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := jail.Allowed(c.path); got != c.allowed {
+			resolved, err := jail.resolve(c.path)
+			t.Errorf("Allowed(%q) = %v, want %v (resolved %q, %v)", c.path, got, c.allowed, resolved, err)
+		}
+	}
+}
+
+func TestPathJailAllowlist(t *testing.T) {
+	root, ws := jailFixture(t)
+	jail := NewPathJail([]string{filepath.Join(root, "outside")}, nil, ws)
+
+	if !jail.Allowed(filepath.Join(root, "outside", "passwd")) {
+		t.Error("allowlisted directory should be allowed")
+	}
+	if jail.Allowed(filepath.Join(ws, "notes.txt")) {
+		t.Error("an explicit allowlist replaces the workspace default")
+	}
+}
+
+func TestPathJailWriteThroughSymlink(t *testing.T) {
+	root, ws := jailFixture(t)
+	outside := filepath.Join(root, "outside")
+	symlink(t, outside, filepath.Join(ws, "escape"))
+	symlink(t, filepath.Join(outside, "passwd"), filepath.Join(ws, "passwd"))
+	symlink(t, "../outside/created.txt", filepath.Join(ws, "dangling"))
+
+	fsTool := NewFileSystemTool(nil, nil, ws)
+	ctx := context.Background()
+	for _, path := range []string{
+		filepath.Join(ws, "passwd"),
+		filepath.Join(ws, "escape", "passwd"),
+		filepath.Join(ws, "escape", "new.txt"),
+		filepath.Join(ws, "dangling"),
+		"nope/../escape/new.txt",
+	} {
+		if _, err := fsTool.WriteFile(ctx, map[string]interface{}{"path": path, "content": "owned"}); err == nil {
+			t.Errorf("write through %s should be blocked", path)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "passwd")); string(data) != "root:x:0:0" {
+		t.Errorf("outside file modified: %q", data)
+	}
+	for _, name := range []string{"new.txt", "created.txt"} {
+		if _, err := os.Stat(filepath.Join(outside, name)); !os.IsNotExist(err) {
+			t.Errorf("%s created outside the jail", name)
+		}
+	}
+
+	if _, err := fsTool.EditFile(ctx, map[string]interface{}{"path": filepath.Join(ws, "passwd"), "old_string": "root", "new_string": "evil"}); err == nil {
+		t.Error("edit through an escaping symlink should be blocked")
+	}
+	if _, err := fsTool.WriteFile(ctx, map[string]interface{}{"path": "sub/dir/file.txt", "content": "ok"}); err != nil {
+		t.Errorf("write inside the workspace: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "sub", "dir", "file.txt")); string(data) != "ok" {
+		t.Errorf("relative write landed elsewhere: %q", data)
+	}
+}
+
+func TestPathJailAtomicWriteAndReadLimit(t *testing.T) {
+	root, ws := jailFixture(t)
+	jail := NewPathJail(nil, nil, ws)
+	notes := filepath.Join(ws, "notes.txt")
+	if err := os.Chmod(notes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := jail.WriteFileAtomic(notes, []byte("updated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(notes)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode should be kept, got %v, %v", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(ws); len(entries) != 2 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+	symlink(t, filepath.Join(root, "outside", "passwd"), filepath.Join(ws, "passwd"))
+	if err := jail.WriteFileAtomic(filepath.Join(ws, "passwd"), []byte("owned"), 0644); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("atomic write through an escaping symlink should be blocked, got %v", err)
+	}
+
+	if data, err := jail.ReadFileLimit(notes, 7); err != nil || string(data) != "updated" {
+		t.Errorf("ReadFileLimit = %q, %v", data, err)
+	}
+	if _, err := jail.ReadFileLimit(notes, 6); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+}
+
+func TestPathJailConfigSymlink(t *testing.T) {
+	root, ws := jailFixture(t)
+	symlink(t, filepath.Join(root, "outside", "passwd"), filepath.Join(ws, "SOUL.md"))
+
+	// 即使允许列表包含工作区外的目录，配置文件也不能写到工作区外
+	fsTool := NewFileSystemTool([]string{root}, nil, ws)
+	if _, err := fsTool.UpdateConfig(context.Background(), map[string]interface{}{"file": "soul", "content": "owned"}); err == nil {
+		t.Error("update_config through an escaping symlink should be blocked")
+	}
+}
+
+// TOCTOU：检查之后、打开之前路径被替换为指向工作区外的符号链接
+func TestPathJailSwapAfterCheck(t *testing.T) {
+	root, ws := jailFixture(t)
+	outside := filepath.Join(root, "outside")
+	jail := NewPathJail(nil, nil, ws)
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(ws, "notes.txt")
+		resolved, err := jail.Resolve(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		symlink(t, filepath.Join(outside, "passwd"), path)
+
+		f, err := jail.openResolved(path, resolved, os.O_WRONLY|os.O_CREATE, 0644)
+		if err == nil {
+			f.Close()
+			t.Fatal("open should fail after the file was swapped for a symlink")
+		}
+		if !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+
+	t.Run("parent", func(t *testing.T) {
+		dir := filepath.Join(ws, "docs")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "passwd")
+		resolved, err := jail.Resolve(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		symlink(t, outside, dir)
+
+		f, err := jail.openResolved(path, resolved, os.O_WRONLY|os.O_CREATE, 0644)
+		if err == nil {
+			f.Close()
+			t.Fatal("open should fail after a parent directory was swapped for a symlink")
+		}
+	})
+
+	if data, _ := os.ReadFile(filepath.Join(outside, "passwd")); string(data) != "root:x:0:0" {
+		t.Errorf("outside file modified: %q", data)
+	}
+}
+
+func TestShellWorkingDirJail(t *testing.T) {
+	root, ws := jailFixture(t)
+	symlink(t, filepath.Join(root, "outside"), filepath.Join(ws, "escape"))
+	jail := NewPathJail(nil, nil, ws)
+
+	cases := []struct {
+		workingDir string
+		wantDir    string
+		wantErr    bool
+	}{
+		{"", ws, false},
+		{filepath.Join(ws, "secret"), filepath.Join(ws, "secret"), false},
+		{filepath.Join(root, "workspace-evil"), "", true},
+		{filepath.Join(ws, "escape"), "", true},
+	}
+	for _, c := range cases {
+		shell := NewShellTool(true, nil, nil, 10, c.workingDir, config.SandboxConfig{})
+		shell.SetPathJail(jail)
+		out, err := shell.Exec(context.Background(), map[string]interface{}{"command": "pwd -P"})
+		if c.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "not allowed") {
+				t.Errorf("working dir %q: err = %v, want not allowed", c.workingDir, err)
+			}
+			continue
+		}
+		if err != nil || strings.TrimSpace(out) != c.wantDir {
+			t.Errorf("working dir %q: pwd = %q, %v", c.workingDir, out, err)
+		}
+	}
+}
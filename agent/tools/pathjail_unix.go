@@ -0,0 +1,16 @@
+//go:build !windows
+
+package tools
+
+import (
+	"errors"
+	"syscall"
+)
+
+// oNoFollow 打开文件时不跟随最后一段的符号链接
+const oNoFollow = syscall.O_NOFOLLOW
+
+// isSymlinkLoop 判断打开失败是否因为最后一段是符号链接
+func isSymlinkLoop(err error) bool {
+	return errors.Is(err, syscall.ELOOP)
+}
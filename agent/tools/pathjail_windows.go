@@ -0,0 +1,11 @@
+//go:build windows
+
+package tools
+
+// oNoFollow Windows 没有 O_NOFOLLOW，依靠打开后的 SameFile 检查
+const oNoFollow = 0
+
+// isSymlinkLoop Windows 上不会因符号链接返回 ELOOP
+func isSymlinkLoop(err error) bool {
+	return false
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY 打开一对伪终端，返回主端和从端
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	var num uint32
+	if err := ioctlFile(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	if err := ioctlFile(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&num))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", num), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	// 较宽的终端减少自动换行
+	ws := struct{ rows, cols, x, y uint16 }{rows: ptyRows, cols: ptyCols}
+	_ = ioctlFile(slave, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+	return master, slave, nil
+}
+
+// ioctlFile 对文件执行 ioctl，不把文件切换为阻塞模式（避免 Close 无法打断读取）
+func ioctlFile(f *os.File, req uint, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
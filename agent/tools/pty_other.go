@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os"
+)
+
+// openPTY 当前平台不支持伪终端，会话使用管道
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -86,8 +86,9 @@ func (r *Registry) Execute(ctx context.Context, name string, params map[string]i
 		return "", fmt.Errorf("tool %s not found", name)
 	}
 
-	// 验证参数
-	if err := ValidateParameters(params, tool.Parameters()); err != nil {
+	// 验证参数（并修复常见的类型错误）
+	params, _, err := ValidateAndCoerce(params, tool.Parameters())
+	if err != nil {
 		return "", fmt.Errorf("parameter validation failed: %w", err)
 	}
 
@@ -0,0 +1,565 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+	"github.com/google/uuid"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// sandboxLabel 沙箱容器的标签，据此清理遗留的容器
+	sandboxLabel = "goclaw.sandbox"
+
+	// sandboxInstanceLabel 记录创建容器的沙箱管理器，区分同一主机上的多个 goclaw 进程
+	sandboxInstanceLabel = "goclaw.sandbox.instance"
+
+	// sandboxSessionLabel 记录容器所属会话的标签
+	sandboxSessionLabel = "goclaw.session"
+
+	// sandboxOutputLimit 每个输出流保留的字节数
+	sandboxOutputLimit = 1024 * 1024
+
+	// defaultSandboxIdleTimeout 默认空闲回收时间
+	defaultSandboxIdleTimeout = 10 * time.Minute
+)
+
+// sandboxDocker 沙箱使用的 Docker API（*client.Client 实现了它）
+type sandboxDocker interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+	Close() error
+}
+
+// SandboxManager 管理 Docker 沙箱容器：每个会话和镜像一个常驻容器，命令通过 docker exec 执行，
+// 容器空闲超时后回收。容器以非 root 用户运行，根文件系统只读，默认没有网络，并限制内存、CPU、进程数和磁盘
+type SandboxManager struct {
+	docker      sandboxDocker
+	cfg         config.SandboxConfig
+	memory      int64
+	disk        int64
+	idleTimeout time.Duration
+	instance    string // 本进程的实例标识，写入 sandboxInstanceLabel
+
+	mu         sync.Mutex
+	containers map[string]*sandboxContainer // sessionKey + "\x00" + image
+	cleaned    bool                         // 是否已清理遗留的容器（首次使用后定期清理）
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// sandboxContainer 一个会话的常驻容器
+type sandboxContainer struct {
+	id         string
+	name       string
+	image      string
+	sessionKey string
+	lastUsed   time.Time
+	busy       int // 正在执行的命令数，大于 0 时不回收
+}
+
+// SandboxResult 沙箱中命令的执行结果
+type SandboxResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+	TimedOut bool
+}
+
+// SandboxExitError 命令以非零状态退出或超时，错误信息包含完整的输出和退出码
+type SandboxExitError struct {
+	Result *SandboxResult
+}
+
+func (e *SandboxExitError) Error() string {
+	return e.Result.String()
+}
+
+// newDockerSandbox 连接本机 Docker 并创建沙箱管理器
+func newDockerSandbox(cfg config.SandboxConfig) (*SandboxManager, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Docker client: %w", err)
+	}
+	m, err := NewSandboxManager(cli, cfg)
+	if err != nil {
+		_ = cli.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewSandboxManager 创建沙箱管理器
+func NewSandboxManager(docker sandboxDocker, cfg config.SandboxConfig) (*SandboxManager, error) {
+	m := &SandboxManager{
+		docker:      docker,
+		cfg:         cfg,
+		idleTimeout: defaultSandboxIdleTimeout,
+		instance:    uuid.New().String(),
+		containers:  make(map[string]*sandboxContainer),
+		stop:        make(chan struct{}),
+	}
+	if cfg.Memory != "" {
+		memory, err := units.RAMInBytes(cfg.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sandbox memory %q: %w", cfg.Memory, err)
+		}
+		m.memory = memory
+	}
+	if cfg.Disk != "" {
+		disk, err := units.RAMInBytes(cfg.Disk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sandbox disk %q: %w", cfg.Disk, err)
+		}
+		m.disk = disk
+	}
+	switch cfg.WorkspaceAccess {
+	case "", "ro", "rw", "none":
+	default:
+		return nil, fmt.Errorf("invalid sandbox workspace_access %q (use ro, rw or none)", cfg.WorkspaceAccess)
+	}
+	if cfg.IdleTimeout > 0 {
+		m.idleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	}
+	if m.cfg.Image == "" {
+		m.cfg.Image = "goclaw/sandbox:latest"
+	}
+	if m.cfg.Workdir == "" {
+		m.cfg.Workdir = "/workspace"
+	}
+
+	go m.reapLoop()
+	return m, nil
+}
+
+// ImageFor 返回命令使用的镜像：最近加载的、配置了镜像的技能优先，否则为默认镜像
+func (m *SandboxManager) ImageFor(skills []string) string {
+	for i := len(skills) - 1; i >= 0; i-- {
+		if image := m.cfg.SkillImages[skills[i]]; image != "" {
+			return image
+		}
+	}
+	return m.cfg.Image
+}
+
+// Exec 在会话的容器中执行命令，容器不存在时创建并把 hostDir 挂载到工作目录。
+// 命令以非零状态退出或超时时返回 *SandboxExitError
+func (m *SandboxManager) Exec(ctx context.Context, sessionKey, image, hostDir, command string, timeout time.Duration) (*SandboxResult, error) {
+	c, err := m.acquire(ctx, sessionKey, image, hostDir)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release(c)
+
+	started := time.Now()
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	exec, err := m.docker.ContainerExecCreate(execCtx, c.id, container.ExecOptions{
+		User:         m.cfg.User,
+		WorkingDir:   m.cfg.Workdir,
+		Cmd:          []string{"sh", "-c", command},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec in sandbox: %w", err)
+	}
+	attach, err := m.docker.ContainerExecAttach(execCtx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to sandbox exec: %w", err)
+	}
+	defer attach.Close()
+
+	// 超时或取消时关闭连接，结束读取
+	copyDone := make(chan struct{})
+	go func() {
+		select {
+		case <-execCtx.Done():
+			attach.Close()
+		case <-copyDone:
+		}
+	}()
+	stdout := &sandboxOutput{ctx: ctx}
+	stderr := &sandboxOutput{ctx: ctx}
+	_, copyErr := stdcopy.StdCopy(stdout, stderr, attach.Reader)
+	close(copyDone)
+
+	result := &SandboxResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(started),
+	}
+	if execCtx.Err() != nil {
+		// 命令可能仍在容器中运行，重建容器以结束它
+		result.TimedOut = true
+		result.ExitCode = -1
+		m.discard(c)
+		return result, &SandboxExitError{Result: result}
+	}
+	if copyErr != nil && copyErr != io.EOF {
+		return nil, fmt.Errorf("failed to read sandbox output: %w", copyErr)
+	}
+
+	code, err := m.exitCode(ctx, exec.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.ExitCode = code
+	if code != 0 {
+		return result, &SandboxExitError{Result: result}
+	}
+	return result, nil
+}
+
+// exitCode 获取 exec 的退出码，输出读取完毕后进程可能还没有被标记为结束
+func (m *SandboxManager) exitCode(ctx context.Context, execID string) (int, error) {
+	for i := 0; ; i++ {
+		inspect, err := m.docker.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to inspect sandbox exec: %w", err)
+		}
+		if !inspect.Running || i >= 20 {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// acquire 返回会话的容器（不存在或已停止时创建）并标记为使用中。
+// Docker 调用期间不持有 m.mu，避免一个会话创建容器时阻塞其他会话
+func (m *SandboxManager) acquire(ctx context.Context, sessionKey, image, hostDir string) (*sandboxContainer, error) {
+	m.mu.Lock()
+	first := !m.cleaned
+	m.cleaned = true
+	key := sessionKey + "\x00" + image
+	c := m.containers[key]
+	if c != nil {
+		// 先标记为使用中，检查期间不会被回收
+		c.busy++
+		c.lastUsed = time.Now()
+	}
+	m.mu.Unlock()
+
+	if first {
+		m.removeStale(ctx)
+	}
+
+	if c != nil {
+		if inspect, err := m.docker.ContainerInspect(ctx, c.id); err == nil && inspect.State != nil && inspect.State.Running {
+			return c, nil
+		}
+		// 容器被外部停止或删除，重新创建
+		m.discard(c)
+		m.release(c)
+	}
+
+	c, err := m.create(ctx, sessionKey, image, hostDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if existing := m.containers[key]; existing != nil {
+		// 同一会话的另一次执行已经创建了容器，使用它并删除刚创建的容器
+		existing.busy++
+		existing.lastUsed = time.Now()
+		m.mu.Unlock()
+		m.removeContainer(c)
+		return existing, nil
+	}
+	c.busy++
+	m.containers[key] = c
+	m.mu.Unlock()
+	return c, nil
+}
+
+// release 结束一次使用
+func (m *SandboxManager) release(c *sandboxContainer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.busy--
+	c.lastUsed = time.Now()
+}
+
+// discard 删除容器，下一次执行时重新创建
+func (m *SandboxManager) discard(c *sandboxContainer) {
+	m.mu.Lock()
+	key := c.sessionKey + "\x00" + c.image
+	owned := m.containers[key] == c
+	if owned {
+		delete(m.containers, key)
+	}
+	m.mu.Unlock()
+	// 已经被其他调用移出的容器由那次调用回收
+	if owned {
+		m.removeContainer(c)
+	}
+}
+
+// create 创建并启动会话的容器
+func (m *SandboxManager) create(ctx context.Context, sessionKey, image, hostDir string) (*sandboxContainer, error) {
+	sum := sha256.Sum256([]byte(sessionKey + "\x00" + image))
+	name := fmt.Sprintf("goclaw-sandbox-%s-%d", hex.EncodeToString(sum[:6]), time.Now().UnixNano()%1_000_000)
+
+	cfg, hostCfg := m.containerConfig(sessionKey, image, hostDir)
+	resp, err := m.docker.ContainerCreate(ctx, cfg, hostCfg, nil, nil, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox container from %s: %w", image, err)
+	}
+	c := &sandboxContainer{id: resp.ID, name: name, image: image, sessionKey: sessionKey, lastUsed: time.Now()}
+	if err := m.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		m.removeContainer(c)
+		return nil, fmt.Errorf("failed to start sandbox container: %w", err)
+	}
+
+	logger.Info("Sandbox container started",
+		zap.String("container", name),
+		zap.String("image", image),
+		zap.String("session_key", sessionKey))
+	return c, nil
+}
+
+// containerConfig 返回加固后的容器配置
+func (m *SandboxManager) containerConfig(sessionKey, image, hostDir string) (*container.Config, *container.HostConfig) {
+	// 容器常驻，命令通过 exec 执行；不依赖 sleep infinity，busybox 镜像也可用
+	cfg := &container.Config{
+		Image:      image,
+		Entrypoint: []string{"sh", "-c", "while :; do sleep 3600; done"},
+		User:       m.cfg.User,
+		WorkingDir: m.cfg.Workdir,
+		Env:        []string{"HOME=/tmp", "TMPDIR=/tmp"},
+		Labels: map[string]string{
+			sandboxLabel:         "1",
+			sandboxInstanceLabel: m.instance,
+			sandboxSessionLabel:  sessionKey,
+		},
+		NetworkDisabled: m.cfg.Network == "" || m.cfg.Network == "none",
+	}
+
+	networkMode := m.cfg.Network
+	if networkMode == "" {
+		networkMode = "none"
+	}
+	tmpfs := "rw,nosuid,nodev"
+	if m.disk > 0 {
+		tmpfs += fmt.Sprintf(",size=%d", m.disk)
+	}
+	hostCfg := &container.HostConfig{
+		NetworkMode:    container.NetworkMode(networkMode),
+		Privileged:     m.cfg.Privileged,
+		ReadonlyRootfs: m.cfg.ReadOnlyRoot,
+		CapDrop:        []string{"ALL"},
+		SecurityOpt:    []string{"no-new-privileges"},
+		Tmpfs:          map[string]string{"/tmp": tmpfs},
+		Init:           boolPtr(true),
+	}
+	if m.memory > 0 {
+		hostCfg.Memory = m.memory
+		hostCfg.MemorySwap = m.memory // 不使用交换空间
+	}
+	if m.cfg.CPUs > 0 {
+		hostCfg.NanoCPUs = int64(m.cfg.CPUs * 1e9)
+	}
+	if m.cfg.PidsLimit > 0 {
+		pids := m.cfg.PidsLimit
+		hostCfg.PidsLimit = &pids
+	}
+
+	switch {
+	case hostDir == "" || m.cfg.WorkspaceAccess == "none":
+	case m.cfg.WorkspaceAccess == "rw":
+		hostCfg.Binds = []string{hostDir + ":" + m.cfg.Workdir + ":rw"}
+	default:
+		hostCfg.Binds = []string{hostDir + ":" + m.cfg.Workdir + ":ro"}
+	}
+	if hostCfg.Binds == nil && m.cfg.ReadOnlyRoot {
+		// 没有挂载工作区时工作目录也需要可写
+		hostCfg.Tmpfs[m.cfg.Workdir] = tmpfs
+	}
+	return cfg, hostCfg
+}
+
+// removeContainer 回收容器：删除（remove 为 false 时只停止）
+func (m *SandboxManager) removeContainer(c *sandboxContainer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var err error
+	if m.cfg.Remove {
+		err = m.docker.ContainerRemove(ctx, c.id, container.RemoveOptions{Force: true})
+	} else {
+		timeout := 0
+		err = m.docker.ContainerStop(ctx, c.id, container.StopOptions{Timeout: &timeout})
+	}
+	if err != nil {
+		logger.Warn("Failed to remove sandbox container", zap.String("container", c.name), zap.Error(err))
+	}
+}
+
+// removeStale 删除已停止且创建时间超过 idleTimeout 的沙箱容器：上次运行退出时停止的容器，
+// 以及 remove 为 false 时回收的容器。运行中的容器可能属于同一主机上的其他 goclaw 进程，不做处理
+func (m *SandboxManager) removeStale(ctx context.Context) {
+	list, err := m.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", sandboxLabel)),
+	})
+	if err != nil {
+		logger.Warn("Failed to list stale sandbox containers", zap.Error(err))
+		return
+	}
+	cutoff := time.Now().Add(-m.idleTimeout).Unix()
+	for _, stale := range list {
+		switch stale.State {
+		case "created", "exited", "dead":
+		default:
+			continue
+		}
+		if stale.Created > cutoff {
+			continue
+		}
+		name := strings.Join(stale.Names, ",")
+		rmCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := m.docker.ContainerRemove(rmCtx, stale.ID, container.RemoveOptions{Force: true}); err != nil {
+			logger.Warn("Failed to remove stale sandbox container", zap.String("container", name), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+// reapLoop 定期回收空闲的容器
+func (m *SandboxManager) reapLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.reapIdle(now)
+			m.mu.Lock()
+			used := m.cleaned
+			m.mu.Unlock()
+			if used {
+				m.removeStale(context.Background())
+			}
+		}
+	}
+}
+
+// reapIdle 回收空闲超过 idleTimeout 的容器，返回回收的数量
+func (m *SandboxManager) reapIdle(now time.Time) int {
+	idle := m.take(func(c *sandboxContainer) bool {
+		return c.busy == 0 && now.Sub(c.lastUsed) >= m.idleTimeout
+	})
+	for _, c := range idle {
+		m.removeContainer(c)
+	}
+	return len(idle)
+}
+
+// CleanupSession 回收会话的所有容器
+func (m *SandboxManager) CleanupSession(sessionKey string) {
+	for _, c := range m.take(func(c *sandboxContainer) bool { return c.sessionKey == sessionKey }) {
+		m.removeContainer(c)
+	}
+}
+
+// Close 回收所有容器并关闭 Docker 客户端
+func (m *SandboxManager) Close() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	for _, c := range m.take(func(*sandboxContainer) bool { return true }) {
+		m.removeContainer(c)
+	}
+	return m.docker.Close()
+}
+
+// take 在持有锁时移出满足条件的容器，调用方在锁外删除它们
+func (m *SandboxManager) take(match func(c *sandboxContainer) bool) []*sandboxContainer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var taken []*sandboxContainer
+	for key, c := range m.containers {
+		if match(c) {
+			taken = append(taken, c)
+			delete(m.containers, key)
+		}
+	}
+	return taken
+}
+
+// String 格式化执行结果：退出码、标准输出和标准错误
+func (r *SandboxResult) String() string {
+	var sb strings.Builder
+	if r.TimedOut {
+		fmt.Fprintf(&sb, "exit_code: -1 (timed out after %s, the sandbox container was reset)\n", r.Duration.Round(time.Second))
+	} else {
+		fmt.Fprintf(&sb, "exit_code: %d\n", r.ExitCode)
+	}
+	sb.WriteString("stdout:\n")
+	sb.WriteString(r.Stdout)
+	if r.Stdout != "" && !strings.HasSuffix(r.Stdout, "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("stderr:\n")
+	sb.WriteString(r.Stderr)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// sandboxOutput 收集一个输出流（超过 sandboxOutputLimit 的部分丢弃），同时作为进度报告
+type sandboxOutput struct {
+	ctx       context.Context
+	buf       strings.Builder
+	truncated bool
+}
+
+func (o *sandboxOutput) Write(p []byte) (int, error) {
+	ReportProgress(o.ctx, string(p))
+	if room := sandboxOutputLimit - o.buf.Len(); room < len(p) {
+		o.buf.Write(p[:max(room, 0)])
+		o.truncated = true
+		return len(p), nil
+	}
+	o.buf.Write(p)
+	return len(p), nil
+}
+
+func (o *sandboxOutput) String() string {
+	if o.truncated {
+		return o.buf.String() + fmt.Sprintf("\n[... output truncated at %d bytes ...]\n", sandboxOutputLimit)
+	}
+	return o.buf.String()
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
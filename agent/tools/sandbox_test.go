@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/smallnest/goclaw/config"
+)
+
+// fakeDocker 模拟 Docker API，命令的输出由 run 决定
+type fakeDocker struct {
+	mu      sync.Mutex
+	created []*container.HostConfig
+	images  []string
+	labels  []map[string]string
+	running map[string]bool
+	removed []string
+	execs   map[string]string // exec ID -> 命令
+	run     func(command string) (stdout, stderr string, code int, hang bool)
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		running: make(map[string]bool),
+		execs:   make(map[string]string),
+		run: func(command string) (string, string, int, bool) {
+			return "ok\n", "", 0, false
+		},
+	}
+}
+
+func (f *fakeDocker) ContainerCreate(ctx context.Context, cfg *container.Config, hostCfg *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, name string) (container.CreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, hostCfg)
+	f.images = append(f.images, cfg.Image)
+	f.labels = append(f.labels, cfg.Labels)
+	return container.CreateResponse{ID: fmt.Sprintf("c%d", len(f.created))}, nil
+}
+
+func (f *fakeDocker) ContainerStart(ctx context.Context, id string, _ container.StartOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[id] = true
+	return nil
+}
+
+func (f *fakeDocker) ContainerStop(ctx context.Context, id string, _ container.StopOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[id] = false
+	return nil
+}
+
+func (f *fakeDocker) ContainerRemove(ctx context.Context, id string, _ container.RemoveOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.running, id)
+	f.removed = append(f.removed, id)
+	return nil
+}
+
+func (f *fakeDocker) ContainerInspect(ctx context.Context, id string) (container.InspectResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := &container.State{Running: f.running[id]}
+	return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: id, State: state}}, nil
+}
+
+// ContainerList 返回其他进程留下的容器：只有已停止且足够旧的 stale 应被删除
+func (f *fakeDocker) ContainerList(ctx context.Context, _ container.ListOptions) ([]container.Summary, error) {
+	old := time.Now().Add(-time.Hour).Unix()
+	return []container.Summary{
+		{ID: "stale", Names: []string{"/goclaw-sandbox-old"}, State: "exited", Created: old},
+		{ID: "recent", Names: []string{"/goclaw-sandbox-recent"}, State: "exited", Created: time.Now().Unix()},
+		{ID: "other", Names: []string{"/goclaw-sandbox-other"}, State: "running", Created: old},
+	}, nil
+}
+
+func (f *fakeDocker) ContainerExecCreate(ctx context.Context, id string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	execID := fmt.Sprintf("e%d", len(f.execs)+1)
+	f.execs[execID] = opts.Cmd[len(opts.Cmd)-1]
+	return container.ExecCreateResponse{ID: execID}, nil
+}
+
+func (f *fakeDocker) ContainerExecAttach(ctx context.Context, execID string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	command := f.execs[execID]
+	f.mu.Unlock()
+	stdout, stderr, _, hang := f.run(command)
+
+	conn, peer := net.Pipe()
+	if hang {
+		// 不写入任何输出，直到连接被关闭
+		return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, nil
+	}
+	_ = peer.Close()
+	var buf bytes.Buffer
+	_, _ = stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(stdout))
+	if stderr != "" {
+		_, _ = stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(stderr))
+	}
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(&buf)}, nil
+}
+
+func (f *fakeDocker) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	f.mu.Lock()
+	command := f.execs[execID]
+	f.mu.Unlock()
+	_, _, code, _ := f.run(command)
+	return container.ExecInspect{ExecID: execID, ExitCode: code}, nil
+}
+
+func (f *fakeDocker) Close() error { return nil }
+
+func newTestSandbox(t *testing.T, docker *fakeDocker, cfg config.SandboxConfig) *SandboxManager {
+	t.Helper()
+	m, err := NewSandboxManager(docker, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+func TestSandboxReusesContainerPerSession(t *testing.T) {
+	docker := newFakeDocker()
+	m := newTestSandbox(t, docker, config.SandboxConfig{
+		Image:        "goclaw/sandbox:latest",
+		Remove:       true,
+		User:         "1000:1000",
+		Memory:       "512m",
+		CPUs:         1.5,
+		PidsLimit:    64,
+		Disk:         "100m",
+		ReadOnlyRoot: true,
+		SkillImages:  map[string]string{"pdf": "goclaw/pdf:latest"},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Exec(ctx, "chat:1", m.ImageFor(nil), "/data", "true", time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Exec(ctx, "chat:2", m.ImageFor(nil), "/data", "true", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker.created) != 2 {
+		t.Fatalf("expected one container per session, created %d", len(docker.created))
+	}
+	if len(docker.removed) != 1 || docker.removed[0] != "stale" {
+		t.Errorf("only old stopped containers should be removed on first use, removed %v", docker.removed)
+	}
+	if docker.labels[0][sandboxInstanceLabel] == "" || docker.labels[0][sandboxInstanceLabel] != docker.labels[1][sandboxInstanceLabel] {
+		t.Errorf("containers should carry the instance label, labels %v", docker.labels)
+	}
+
+	host := docker.created[0]
+	if host.Memory != 512*1024*1024 || host.MemorySwap != host.Memory || host.NanoCPUs != 1.5e9 {
+		t.Errorf("memory/cpu limits not applied: %+v", host.Resources)
+	}
+	if host.PidsLimit == nil || *host.PidsLimit != 64 || !host.ReadonlyRootfs || host.NetworkMode != "none" {
+		t.Errorf("hardening not applied: pids=%v readonly=%v network=%s", host.PidsLimit, host.ReadonlyRootfs, host.NetworkMode)
+	}
+	if !strings.Contains(host.Tmpfs["/tmp"], fmt.Sprintf("size=%d", 100*1024*1024)) || len(host.CapDrop) == 0 {
+		t.Errorf("tmpfs %v capdrop %v", host.Tmpfs, host.CapDrop)
+	}
+	if len(host.Binds) != 1 || host.Binds[0] != "/data:/workspace:ro" {
+		t.Errorf("workspace should be mounted read-only by default, binds %v", host.Binds)
+	}
+
+	// 技能配置的镜像使用单独的容器
+	if image := m.ImageFor([]string{"web", "pdf"}); image != "goclaw/pdf:latest" {
+		t.Errorf("ImageFor = %s", image)
+	}
+	if _, err := m.Exec(ctx, "chat:1", "goclaw/pdf:latest", "/data", "true", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker.images) != 3 || docker.images[2] != "goclaw/pdf:latest" {
+		t.Errorf("images = %v", docker.images)
+	}
+
+	// 会话结束时回收它的容器
+	m.CleanupSession("chat:1")
+	if len(docker.removed) != 3 {
+		t.Errorf("CleanupSession removed %v", docker.removed)
+	}
+}
+
+func TestSandboxStructuredResult(t *testing.T) {
+	docker := newFakeDocker()
+	docker.run = func(command string) (string, string, int, bool) {
+		switch command {
+		case "fail":
+			return "partial\n", "boom\n", 2, false
+		case "hang":
+			return "", "", 0, true
+		}
+		return "hello\n", "", 0, false
+	}
+	m := newTestSandbox(t, docker, config.SandboxConfig{Remove: true})
+	ctx := context.Background()
+
+	res, err := m.Exec(ctx, "chat:1", m.ImageFor(nil), "", "echo", time.Second)
+	if err != nil || res.Stdout != "hello\n" || res.ExitCode != 0 {
+		t.Fatalf("Exec = %+v, %v", res, err)
+	}
+
+	_, err = m.Exec(ctx, "chat:1", m.ImageFor(nil), "", "fail", time.Second)
+	var exitErr *SandboxExitError
+	if !errors.As(err, &exitErr) || exitErr.Result.ExitCode != 2 {
+		t.Fatalf("expected SandboxExitError with code 2, got %v", err)
+	}
+	for _, want := range []string{"exit_code: 2", "stdout:\npartial", "stderr:\nboom"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%s", want, err)
+		}
+	}
+
+	// 超时后容器被重建
+	_, err = m.Exec(ctx, "chat:1", m.ImageFor(nil), "", "hang", 100*time.Millisecond)
+	if !errors.As(err, &exitErr) || !exitErr.Result.TimedOut {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if _, err := m.Exec(ctx, "chat:1", m.ImageFor(nil), "", "echo", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker.created) != 2 {
+		t.Errorf("timed out container should be replaced, created %d", len(docker.created))
+	}
+	if binds := docker.created[0].Binds; binds != nil {
+		t.Errorf("no workspace should be mounted without a host dir, binds %v", binds)
+	}
+}
+
+func TestSandboxReapsIdleContainers(t *testing.T) {
+	docker := newFakeDocker()
+	m := newTestSandbox(t, docker, config.SandboxConfig{IdleTimeout: 60})
+	if _, err := m.Exec(context.Background(), "chat:1", m.ImageFor(nil), "", "true", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.reapIdle(time.Now()); n != 0 {
+		t.Errorf("recently used container reaped")
+	}
+	if n := m.reapIdle(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Errorf("idle container not reaped")
+	}
+	// remove 为 false 时只停止容器
+	if docker.running["c1"] || len(docker.removed) != 1 || docker.removed[0] != "stale" {
+		t.Errorf("running=%v removed=%v", docker.running, docker.removed)
+	}
+
+	if _, err := NewSandboxManager(docker, config.SandboxConfig{Memory: "lots"}); err == nil {
+		t.Error("invalid memory should be rejected")
+	}
+}
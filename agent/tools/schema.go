@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationErrors 多个参数验证错误
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// String 返回带字段路径的错误描述
+func (e *ValidationError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidateAndCoerce 按 JSON Schema 验证参数，并修复 LLM 常见的参数错误：
+// 数字/布尔值写成字符串、需要数组时只给了单个值、对象或数组被序列化成 JSON 字符串等。
+// 返回修复后的参数副本（不修改原参数）、修复说明，以及无法修复的错误（ValidationErrors）。
+func ValidateAndCoerce(params map[string]interface{}, schema map[string]interface{}) (map[string]interface{}, []string, error) {
+	v := &schemaValidator{}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	result := v.validateObject("", params, schema)
+	out, _ := result.(map[string]interface{})
+	if len(v.errors) > 0 {
+		return out, v.repairs, v.errors
+	}
+	return out, v.repairs, nil
+}
+
+// schemaValidator 递归验证器，收集错误和修复记录
+type schemaValidator struct {
+	errors  ValidationErrors
+	repairs []string
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{Field: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) repaired(path, format string, args ...interface{}) {
+	v.repairs = append(v.repairs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validate 验证单个值，返回（可能经过修复的）值
+func (v *schemaValidator) validate(path string, value interface{}, schema map[string]interface{}) interface{} {
+	if schema == nil {
+		return value
+	}
+
+	types := schemaTypes(schema["type"])
+	if len(types) > 0 && !matchesAnyType(value, types) {
+		coerced, ok := coerceValue(value, types)
+		if !ok {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))
+			return value
+		}
+		v.repaired(path, "converted %s to %s", jsonTypeName(value), jsonTypeName(coerced))
+		value = coerced
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		value = v.validateObject(path, val, schema)
+	case []interface{}:
+		value = v.validateArray(path, val, schema)
+	}
+
+	if schema["enum"] != nil && !enumContains(schema["enum"], value) {
+		v.fail(path, "must be one of %s, got %v", formatEnum(schema["enum"]), value)
+	}
+
+	return value
+}
+
+// validateObject 验证对象的必需字段和各属性
+func (v *schemaValidator) validateObject(path string, obj map[string]interface{}, schema map[string]interface{}) interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		out[key] = value
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := schemaStrings(schema["required"])
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		isRequired[name] = true
+	}
+
+	// 按字段名排序，保证错误顺序稳定
+	keys := make([]string, 0, len(out))
+	for key := range out {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propSchema, ok := properties[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, key)
+
+		// 可选字段传 null 视为未传
+		if out[key] == nil && !isRequired[key] {
+			delete(out, key)
+			v.repaired(fieldPath, "removed null value")
+			continue
+		}
+		out[key] = v.validate(fieldPath, out[key], propSchema)
+	}
+
+	for _, name := range required {
+		if value, ok := out[name]; !ok || value == nil {
+			v.fail(joinPath(path, name), "required field missing")
+		}
+	}
+
+	return out
+}
+
+// validateArray 验证数组元素
+func (v *schemaValidator) validateArray(path string, arr []interface{}, schema map[string]interface{}) interface{} {
+	itemSchema, ok := schema["items"].(map[string]interface{})
+	if !ok {
+		return arr
+	}
+
+	out := make([]interface{}, len(arr))
+	for i, item := range arr {
+		out[i] = v.validate(fmt.Sprintf("%s[%d]", path, i), item, itemSchema)
+	}
+	return out
+}
+
+// coerceValue 尝试将值转换为期望的类型之一
+func coerceValue(value interface{}, types []string) (interface{}, bool) {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			switch val := value.(type) {
+			case string:
+				if n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+					return float64(n), true
+				}
+				if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil && f == math.Trunc(f) {
+					return f, true
+				}
+			}
+		case "number":
+			if val, ok := value.(string); ok {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					return f, true
+				}
+			}
+		case "boolean":
+			if val, ok := value.(string); ok {
+				if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+					return b, true
+				}
+			}
+		case "string":
+			switch val := value.(type) {
+			case float64:
+				return strconv.FormatFloat(val, 'f', -1, 64), true
+			case bool:
+				return strconv.FormatBool(val), true
+			}
+		case "array":
+			if val, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(val), "[") {
+				var arr []interface{}
+				if err := json.Unmarshal([]byte(val), &arr); err == nil {
+					return arr, true
+				}
+			}
+			if value != nil {
+				if _, isMap := value.(map[string]interface{}); !isMap {
+					return []interface{}{value}, true
+				}
+			}
+		case "object":
+			if val, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(val), "{") {
+				var obj map[string]interface{}
+				if err := json.Unmarshal([]byte(val), &obj); err == nil {
+					return obj, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// matchesAnyType 检查值是否符合任一 JSON Schema 类型
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(value interface{}, t string) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		switch val := value.(type) {
+		case int, int32, int64:
+			return true
+		case float64:
+			return val == math.Trunc(val)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int32, int64, float32, float64:
+			return true
+		}
+		return false
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		switch value.(type) {
+		case []interface{}, []string:
+			return true
+		}
+		return false
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	// 未知类型不做限制
+	return true
+}
+
+// jsonTypeName 返回值对应的 JSON 类型名
+func jsonTypeName(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case int, int32, int64, float32:
+		return "number"
+	case []interface{}, []string:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaTypes 解析 type 字段（字符串或字符串数组）
+func schemaTypes(value interface{}) []string {
+	if t, ok := value.(string); ok {
+		return []string{t}
+	}
+	return schemaStrings(value)
+}
+
+// schemaStrings 解析 []string 或 []interface{} 形式的字符串列表
+func schemaStrings(value interface{}) []string {
+	switch val := value.(type) {
+	case []string:
+		return val
+	case []interface{}:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// enumContains 检查值是否在枚举列表中
+func enumContains(enum interface{}, value interface{}) bool {
+	switch list := enum.(type) {
+	case []string:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, item := range list {
+			if item == s {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, item := range list {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func formatEnum(enum interface{}) string {
+	data, err := json.Marshal(enum)
+	if err != nil {
+		return fmt.Sprint(enum)
+	}
+	return string(data)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":   map[string]interface{}{"type": "string"},
+		"limit":  map[string]interface{}{"type": "integer"},
+		"ratio":  map[string]interface{}{"type": "number"},
+		"force":  map[string]interface{}{"type": "boolean"},
+		"mode":   map[string]interface{}{"type": "string", "enum": []string{"read", "write"}},
+		"tags":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"option": map[string]interface{}{"type": "string"},
+		"target": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"width":  map[string]interface{}{"type": "integer"},
+				"height": map[string]interface{}{"type": "integer"},
+			},
+			"required": []string{"width", "height"},
+		},
+	},
+	"required": []string{"path"},
+}
+
+func TestValidateAndCoerceRepairs(t *testing.T) {
+	params := map[string]interface{}{
+		"path":   "/tmp/a",
+		"limit":  "10",
+		"ratio":  "0.5",
+		"force":  "true",
+		"tags":   "single",
+		"option": nil,
+		"target": `{"width": "640", "height": 480}`,
+	}
+
+	out, repairs, err := ValidateAndCoerce(params, testSchema)
+	if err != nil {
+		t.Fatalf("expected repairable params, got %v", err)
+	}
+
+	want := map[string]interface{}{
+		"path":   "/tmp/a",
+		"limit":  float64(10),
+		"ratio":  0.5,
+		"force":  true,
+		"tags":   []interface{}{"single"},
+		"target": map[string]interface{}{"width": float64(640), "height": float64(480)},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("unexpected repaired params:\n got %#v\nwant %#v", out, want)
+	}
+	if len(repairs) != 7 {
+		t.Errorf("expected 7 repairs, got %d: %v", len(repairs), repairs)
+	}
+	if params["limit"] != "10" {
+		t.Error("original params must not be modified")
+	}
+}
+
+func TestValidateAndCoerceErrors(t *testing.T) {
+	params := map[string]interface{}{
+		"limit":  "ten",
+		"mode":   "delete",
+		"tags":   []interface{}{"ok", map[string]interface{}{}},
+		"target": map[string]interface{}{"width": 1.5},
+	}
+
+	_, _, err := ValidateAndCoerce(params, testSchema)
+	verrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T (%v)", err, err)
+	}
+
+	fields := make([]string, 0, len(verrs))
+	for _, verr := range verrs {
+		fields = append(fields, verr.Field)
+	}
+	want := []string{"limit", "mode", "tags[1]", "target.width", "target.height", "path"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("unexpected error fields: got %v, want %v (%v)", fields, want, err)
+	}
+	if !strings.Contains(err.Error(), `must be one of ["read","write"]`) {
+		t.Errorf("enum error should list allowed values: %v", err)
+	}
+}
+
+func TestValidateParametersRequired(t *testing.T) {
+	if err := ValidateParameters(map[string]interface{}{}, testSchema); err == nil {
+		t.Error("expected error for missing required field")
+	}
+	if err := ValidateParameters(map[string]interface{}{"path": "a"}, testSchema); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
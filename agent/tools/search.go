@@ -0,0 +1,390 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSearchResults 默认返回的结果数
+	defaultSearchResults = 5
+
+	// maxSearchResults 单次搜索最多返回的结果数
+	maxSearchResults = 10
+
+	// defaultSearchCacheTTL 默认的结果缓存时间
+	defaultSearchCacheTTL = 10 * time.Minute
+
+	// maxSearchCacheEntries 缓存的查询数上限
+	maxSearchCacheEntries = 256
+)
+
+// ErrNoSearchBackend 没有可用的搜索后端
+var ErrNoSearchBackend = errors.New("no search backend is configured")
+
+// errSearchQuotaExhausted 后端今日的请求数已达上限
+var errSearchQuotaExhausted = errors.New("daily quota exhausted")
+
+// SearchResult 统一格式的搜索结果
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+	Date    string `json:"date,omitempty"` // 发布日期，能解析时为 2006-01-02 格式
+}
+
+// SearchBackend 搜索后端
+type SearchBackend interface {
+	// Name 后端名称，例如 brave、searxng
+	Name() string
+	// Search 搜索并返回至多 limit 条结果
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResponse 搜索链的结果
+type SearchResponse struct {
+	Query   string
+	Backend string // 返回结果的后端
+	Results []SearchResult
+	Cached  bool
+	Skipped []string // 失败或跳过的后端及原因，例如 "brave: daily quota exhausted"
+}
+
+// SearchChain 按顺序尝试搜索后端：后端出错、没有结果或超出每日配额时使用下一个。
+// 结果按查询缓存，配额计数按 UTC 日期保存在 usagePath 中（为空时只保存在内存），
+// 多个进程共用同一个文件，每次计数都在文件锁内重新读取
+type SearchChain struct {
+	backends []SearchBackend
+	quotas   map[string]int
+	cacheTTL time.Duration
+
+	mu        sync.Mutex
+	cache     map[string]searchCacheEntry
+	usage     searchUsage
+	usagePath string
+}
+
+// searchCacheEntry 缓存的结果
+type searchCacheEntry struct {
+	response SearchResponse
+	expires  time.Time
+}
+
+// searchUsage 每个后端当天的请求数
+type searchUsage struct {
+	Date   string         `json:"date"`
+	Counts map[string]int `json:"counts"`
+}
+
+// NewSearchChain 创建搜索链。quotas 为每个后端的每日请求上限（0 或缺省表示不限制），
+// cacheTTL 为 0 时使用默认缓存时间，小于 0 时不缓存
+func NewSearchChain(backends []SearchBackend, quotas map[string]int, cacheTTL time.Duration, usagePath string) *SearchChain {
+	if cacheTTL == 0 {
+		cacheTTL = defaultSearchCacheTTL
+	}
+	c := &SearchChain{
+		backends:  backends,
+		quotas:    quotas,
+		cacheTTL:  cacheTTL,
+		cache:     make(map[string]searchCacheEntry),
+		usagePath: usagePath,
+	}
+	if usagePath != "" {
+		c.usage = c.loadUsage()
+	}
+	return c
+}
+
+// Backends 返回后端名称，按回退顺序排列
+func (c *SearchChain) Backends() []string {
+	names := make([]string, len(c.backends))
+	for i, b := range c.backends {
+		names[i] = b.Name()
+	}
+	return names
+}
+
+// Search 依次尝试后端，返回第一个有结果的后端的结果。所有后端都没有结果时返回空结果，
+// 都失败时返回包含各后端原因的错误
+func (c *SearchChain) Search(ctx context.Context, query string, limit int) (*SearchResponse, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("query is empty")
+	}
+	if limit <= 0 {
+		limit = defaultSearchResults
+	}
+	limit = min(limit, maxSearchResults)
+	if len(c.backends) == 0 {
+		return nil, ErrNoSearchBackend
+	}
+
+	key := fmt.Sprintf("%d\x00%s", limit, strings.ToLower(strings.Join(strings.Fields(query), " ")))
+	if resp, ok := c.cached(key); ok {
+		return resp, nil
+	}
+
+	resp := &SearchResponse{Query: query}
+	failed := 0
+	for _, backend := range c.backends {
+		name := backend.Name()
+		if !c.takeQuota(name) {
+			resp.Skipped = append(resp.Skipped, fmt.Sprintf("%s: %v", name, errSearchQuotaExhausted))
+			failed++
+			continue
+		}
+		results, err := backend.Search(ctx, query, limit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			resp.Skipped = append(resp.Skipped, fmt.Sprintf("%s: %v", name, err))
+			failed++
+			continue
+		}
+		results = normalizeSearchResults(results, limit)
+		if len(results) == 0 {
+			resp.Skipped = append(resp.Skipped, name+": no results")
+			continue
+		}
+		resp.Backend = name
+		resp.Results = results
+		c.store(key, resp)
+		return resp, nil
+	}
+
+	if failed == len(c.backends) {
+		return nil, fmt.Errorf("all search backends failed: %s", strings.Join(resp.Skipped, "; "))
+	}
+	c.store(key, resp)
+	return resp, nil
+}
+
+// cached 返回未过期的缓存结果
+func (c *SearchChain) cached(key string) (*SearchResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.cache, key)
+		return nil, false
+	}
+	resp := entry.response
+	resp.Cached = true
+	return &resp, true
+}
+
+// store 缓存结果，超过上限时删除最早过期的条目
+func (c *SearchChain) store(key string, resp *SearchResponse) {
+	if c.cacheTTL < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= maxSearchCacheEntries {
+		var oldest string
+		for k, e := range c.cache {
+			if oldest == "" || e.expires.Before(c.cache[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(c.cache, oldest)
+	}
+	c.cache[key] = searchCacheEntry{response: *resp, expires: time.Now().Add(c.cacheTTL)}
+}
+
+// takeQuota 记录一次请求，今日已达上限时返回 false
+func (c *SearchChain) takeQuota(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 其他进程可能已经更新了计数，在文件锁内读取、检查并写回
+	if c.usagePath != "" {
+		unlock, err := c.lockUsage()
+		if err != nil {
+			logger.Warn("Failed to lock search usage file, counting in memory only", zap.Error(err))
+		} else {
+			defer unlock()
+			c.usage = c.loadUsage()
+		}
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if c.usage.Date != today || c.usage.Counts == nil {
+		c.usage = searchUsage{Date: today, Counts: make(map[string]int)}
+	}
+	if quota := c.quotas[name]; quota > 0 && c.usage.Counts[name] >= quota {
+		return false
+	}
+	c.usage.Counts[name]++
+	c.saveUsage()
+	return true
+}
+
+// lockUsage 锁定配额文件旁的锁文件（配额文件本身会被重命名替换，不能作为锁）
+func (c *SearchChain) lockUsage() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(c.usagePath), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(c.usagePath+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// loadUsage 读取配额文件，文件不存在或无法解析时返回空计数
+func (c *SearchChain) loadUsage() searchUsage {
+	var usage searchUsage
+	if data, err := os.ReadFile(c.usagePath); err == nil {
+		_ = json.Unmarshal(data, &usage)
+	}
+	return usage
+}
+
+// saveUsage 保存配额计数（调用方持有锁）
+func (c *SearchChain) saveUsage() {
+	if c.usagePath == "" {
+		return
+	}
+	data, err := json.Marshal(c.usage)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.usagePath), 0755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.usagePath), filepath.Base(c.usagePath)+".tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.usagePath)
+	}
+	if err != nil {
+		logger.Warn("Failed to save search usage", zap.String("path", c.usagePath), zap.Error(err))
+	}
+}
+
+// Usage 返回后端今日的请求数和每日上限（0 表示不限制）
+func (c *SearchChain) Usage(name string) (used, quota int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.usagePath != "" {
+		c.usage = c.loadUsage()
+	}
+	if c.usage.Date == time.Now().UTC().Format("2006-01-02") {
+		used = c.usage.Counts[name]
+	}
+	return used, c.quotas[name]
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanSearchText 去掉 HTML 标签和实体并合并空白
+func cleanSearchText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// searchDateLayouts 各后端返回的日期格式
+var searchDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.000000",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// normalizeSearchDate 将日期转换为 2006-01-02 格式，无法解析时原样返回
+func normalizeSearchDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range searchDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
+
+// normalizeSearchResults 清理标题和摘要、统一日期格式、去掉没有 URL 的和重复的结果
+func normalizeSearchResults(results []SearchResult, limit int) []SearchResult {
+	seen := make(map[string]bool)
+	normalized := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		r.URL = strings.TrimSpace(r.URL)
+		if r.URL == "" || seen[r.URL] {
+			continue
+		}
+		seen[r.URL] = true
+		r.Title = cleanSearchText(r.Title)
+		if r.Title == "" {
+			r.Title = r.URL
+		}
+		r.Snippet = cleanSearchText(r.Snippet)
+		if r.Date != "" {
+			r.Date = normalizeSearchDate(r.Date)
+		}
+		normalized = append(normalized, r)
+		if len(normalized) == limit {
+			break
+		}
+	}
+	return normalized
+}
+
+// FormatSearchResults 将搜索结果格式化为文本
+func FormatSearchResults(resp *SearchResponse) string {
+	var sb strings.Builder
+	source := resp.Backend
+	if resp.Cached {
+		source += ", cached"
+	}
+	if len(resp.Results) == 0 {
+		fmt.Fprintf(&sb, "No results found for: %s", resp.Query)
+	} else {
+		fmt.Fprintf(&sb, "Search results for: %s (via %s)\n", resp.Query, source)
+	}
+	for i, r := range resp.Results {
+		fmt.Fprintf(&sb, "\n%d. %s\n   URL: %s\n", i+1, r.Title, r.URL)
+		if r.Date != "" {
+			fmt.Fprintf(&sb, "   Date: %s\n", r.Date)
+		}
+		if r.Snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", r.Snippet)
+		}
+	}
+	if len(resp.Skipped) > 0 && !resp.Cached {
+		fmt.Fprintf(&sb, "\n(skipped: %s)", strings.Join(resp.Skipped, "; "))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
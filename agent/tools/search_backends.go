@@ -0,0 +1,529 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/net/html"
+)
+
+// searchUserAgent 抓取 HTML 结果页时使用的 User-Agent
+const searchUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// SearchBackendNames 支持的搜索后端
+var SearchBackendNames = []string{"google", "brave", "bing", "duckduckgo", "searxng", "tavily", "serper"}
+
+// NewSearchChainFromConfig 按配置创建搜索链。search_backends 为空时使用 search_engine（配置了
+// search_api_key 时）并以 duckduckgo 兜底。usagePath 为配额计数文件，为空时不保存
+func NewSearchChainFromConfig(cfg config.WebToolConfig, usagePath string) (*SearchChain, error) {
+	timeout := 10 * time.Second
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	legacy := searchBackendName(cfg.SearchEngine)
+
+	names := cfg.SearchBackends
+	explicit := len(names) > 0
+	if !explicit {
+		if cfg.SearchAPIKey != "" && legacy != "" {
+			names = append(names, legacy)
+		}
+		names = append(names, "duckduckgo")
+	}
+
+	var backends []SearchBackend
+	quotas := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, raw := range names {
+		name := searchBackendName(raw)
+		if name == "" {
+			return nil, fmt.Errorf("unknown search backend %q (supported: %s)", raw, strings.Join(SearchBackendNames, ", "))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		bc := cfg.Backends[name]
+		if bc.APIKey == "" && name == legacy {
+			bc.APIKey = cfg.SearchAPIKey
+		}
+		backend, err := newSearchBackend(name, bc, client)
+		if err != nil {
+			if explicit {
+				return nil, err
+			}
+			logger.Warn("Search backend skipped", zap.String("backend", name), zap.Error(err))
+			continue
+		}
+		backends = append(backends, backend)
+		if bc.DailyQuota > 0 {
+			quotas[name] = bc.DailyQuota
+		}
+	}
+
+	ttl := time.Duration(cfg.SearchCacheTTL) * time.Second
+	if cfg.SearchCacheTTL < 0 {
+		ttl = -1
+	}
+	return NewSearchChain(backends, quotas, ttl, usagePath), nil
+}
+
+// searchBackendName 返回后端的规范名称，不支持时返回空字符串
+func searchBackendName(name string) string {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "travily":
+		return "tavily"
+	case "ddg":
+		return "duckduckgo"
+	}
+	for _, known := range SearchBackendNames {
+		if name == known {
+			return name
+		}
+	}
+	return ""
+}
+
+// newSearchBackend 创建后端，检查必需的配置
+func newSearchBackend(name string, cfg config.SearchBackendConfig, client *http.Client) (SearchBackend, error) {
+	endpoint := strings.TrimRight(cfg.Endpoint, "/")
+	requireKey := func() error {
+		if cfg.APIKey == "" {
+			return fmt.Errorf("search backend %s requires api_key", name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "google":
+		if err := requireKey(); err != nil {
+			return nil, err
+		}
+		if cfg.CX == "" {
+			return nil, fmt.Errorf("search backend google requires cx (the Programmable Search Engine ID)")
+		}
+		return &googleSearch{client: client, apiKey: cfg.APIKey, cx: cfg.CX, endpoint: orDefault(endpoint, "https://www.googleapis.com/customsearch/v1")}, nil
+	case "brave":
+		if err := requireKey(); err != nil {
+			return nil, err
+		}
+		return &braveSearch{client: client, apiKey: cfg.APIKey, endpoint: orDefault(endpoint, "https://api.search.brave.com/res/v1/web/search")}, nil
+	case "bing":
+		if err := requireKey(); err != nil {
+			return nil, err
+		}
+		return &bingSearch{client: client, apiKey: cfg.APIKey, endpoint: orDefault(endpoint, "https://api.bing.microsoft.com/v7.0/search")}, nil
+	case "tavily":
+		if err := requireKey(); err != nil {
+			return nil, err
+		}
+		return &tavilySearch{client: client, apiKey: cfg.APIKey, endpoint: orDefault(endpoint, "https://api.tavily.com/search")}, nil
+	case "serper":
+		if err := requireKey(); err != nil {
+			return nil, err
+		}
+		return &serperSearch{client: client, apiKey: cfg.APIKey, endpoint: orDefault(endpoint, "https://google.serper.dev/search")}, nil
+	case "searxng":
+		if endpoint == "" {
+			return nil, fmt.Errorf("search backend searxng requires endpoint (the instance URL)")
+		}
+		return &searxngSearch{client: client, endpoint: endpoint}, nil
+	case "duckduckgo":
+		return &duckDuckGoSearch{client: client, endpoint: orDefault(endpoint, "https://html.duckduckgo.com/html/")}, nil
+	}
+	return nil, fmt.Errorf("unknown search backend %q", name)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// doSearchRequest 发送请求，状态码不是 200 时返回包含响应内容的错误
+func doSearchRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		// url.Error 包含完整的请求地址，其中可能有 API Key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		preview := strings.TrimSpace(string(body))
+		if len(preview) > 200 {
+			preview = preview[:200] + "..."
+		}
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, preview)
+	}
+	return body, nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func getJSON(ctx context.Context, client *http.Client, endpoint string, query url.Values, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	body, err := doSearchRequest(client, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// postJSON 发送 JSON 请求并解析 JSON 响应
+func postJSON(ctx context.Context, client *http.Client, endpoint string, payload any, headers map[string]string, out any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	body, err := doSearchRequest(client, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// googleSearch Google Programmable Search Engine（Custom Search JSON API）
+type googleSearch struct {
+	client   *http.Client
+	apiKey   string
+	cx       string
+	endpoint string
+}
+
+func (g *googleSearch) Name() string { return "google" }
+
+func (g *googleSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+			Pagemap struct {
+				Metatags []map[string]any `json:"metatags"`
+			} `json:"pagemap"`
+		} `json:"items"`
+	}
+	params := url.Values{"key": {g.apiKey}, "cx": {g.cx}, "q": {query}, "num": {strconv.Itoa(limit)}}
+	if err := getJSON(ctx, g.client, g.endpoint, params, nil, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		r := SearchResult{Title: item.Title, URL: item.Link, Snippet: item.Snippet}
+		for _, tags := range item.Pagemap.Metatags {
+			if date, ok := tags["article:published_time"].(string); ok {
+				r.Date = date
+				break
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// braveSearch Brave Search API
+type braveSearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (b *braveSearch) Name() string { return "brave" }
+
+func (b *braveSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				PageAge     string `json:"page_age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(limit)}}
+	if err := getJSON(ctx, b.client, b.endpoint, params, map[string]string{"X-Subscription-Token": b.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Web.Results))
+	for _, item := range resp.Web.Results {
+		results = append(results, SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Description, Date: item.PageAge})
+	}
+	return results, nil
+}
+
+// bingSearch Bing Web Search API
+type bingSearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (b *bingSearch) Name() string { return "bing" }
+
+func (b *bingSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name          string `json:"name"`
+				URL           string `json:"url"`
+				Snippet       string `json:"snippet"`
+				DatePublished string `json:"datePublished"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(limit)}}
+	if err := getJSON(ctx, b.client, b.endpoint, params, map[string]string{"Ocp-Apim-Subscription-Key": b.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.WebPages.Value))
+	for _, item := range resp.WebPages.Value {
+		results = append(results, SearchResult{Title: item.Name, URL: item.URL, Snippet: item.Snippet, Date: item.DatePublished})
+	}
+	return results, nil
+}
+
+// tavilySearch Tavily Search API
+type tavilySearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (t *tavilySearch) Name() string { return "tavily" }
+
+func (t *tavilySearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"published_date"`
+		} `json:"results"`
+	}
+	payload := map[string]any{"query": query, "search_depth": "basic", "max_results": limit}
+	if err := postJSON(ctx, t.client, t.endpoint, payload, map[string]string{"Authorization": "Bearer " + t.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Results))
+	for _, item := range resp.Results {
+		results = append(results, SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Content, Date: item.PublishedDate})
+	}
+	return results, nil
+}
+
+// serperSearch Serper（Google 搜索结果 API）
+type serperSearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (s *serperSearch) Name() string { return "serper" }
+
+func (s *serperSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Organic []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+			Date    string `json:"date"`
+		} `json:"organic"`
+	}
+	payload := map[string]any{"q": query, "num": limit}
+	if err := postJSON(ctx, s.client, s.endpoint, payload, map[string]string{"X-API-KEY": s.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Organic))
+	for _, item := range resp.Organic {
+		results = append(results, SearchResult{Title: item.Title, URL: item.Link, Snippet: item.Snippet, Date: item.Date})
+	}
+	return results, nil
+}
+
+// searxngSearch 自建的 SearXNG 实例（需要在 settings.yml 的 search.formats 中启用 json）
+type searxngSearch struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (s *searxngSearch) Name() string { return "searxng" }
+
+func (s *searxngSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var resp struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	params := url.Values{"q": {query}, "format": {"json"}}
+	if err := getJSON(ctx, s.client, s.endpoint+"/search", params, nil, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Results))
+	for _, item := range resp.Results {
+		results = append(results, SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Content, Date: item.PublishedDate})
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// duckDuckGoSearch 解析 DuckDuckGo 的 HTML 结果页，不需要 API Key
+type duckDuckGoSearch struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (d *duckDuckGoSearch) Name() string { return "duckduckgo" }
+
+func (d *duckDuckGoSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	form := url.Values{"q": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", searchUserAgent)
+	body, err := doSearchRequest(d.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := parseDuckDuckGoHTML(body, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 && bytes.Contains(body, []byte("anomaly")) {
+		return nil, fmt.Errorf("blocked by DuckDuckGo bot detection")
+	}
+	return results, nil
+}
+
+// parseDuckDuckGoHTML 从结果页中提取 result__a（标题和链接）和 result__snippet（摘要）
+func parseDuckDuckGoHTML(page []byte, limit int) ([]SearchResult, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse result page: %w", err)
+	}
+
+	var results []SearchResult
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case hasClass(n, "result__a"):
+				results = append(results, SearchResult{Title: nodeText(n), URL: duckDuckGoURL(attr(n, "href"))})
+				return
+			case hasClass(n, "result__snippet") && len(results) > 0 && results[len(results)-1].Snippet == "":
+				results[len(results)-1].Snippet = nodeText(n)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// duckDuckGoURL 解析跳转链接 //duckduckgo.com/l/?uddg=<目标地址>
+func duckDuckGoURL(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	if target := u.Query().Get("uddg"); target != "" {
+		return target
+	}
+	if u.Scheme == "" && strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	return href
+}
+
+// hasClass 判断元素是否有指定的 class
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// attr 返回元素的属性值
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText 返回元素内的全部文本
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+// stubBackend 返回固定结果的后端，记录调用次数
+type stubBackend struct {
+	name    string
+	results []SearchResult
+	err     error
+	calls   int
+}
+
+func (b *stubBackend) Name() string { return b.name }
+
+func (b *stubBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	b.calls++
+	return b.results, b.err
+}
+
+func TestSearchBackendsNormalizeResponses(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		cfg     config.SearchBackendConfig
+		header  string
+		wantURL string
+	}{
+		{"google", `{"items":[{"title":"Go <b>1.22</b>","link":"https://go.dev/a","snippet":"Release&nbsp;notes","pagemap":{"metatags":[{"article:published_time":"2024-02-06T10:00:00Z"}]}}]}`,
+			config.SearchBackendConfig{APIKey: "k", CX: "cx"}, "", "https://go.dev/a"},
+		{"brave", `{"web":{"results":[{"title":"Go <strong>1.22</strong>","url":"https://go.dev/b","description":"Release notes","page_age":"2024-02-06T10:00:00"}]}}`,
+			config.SearchBackendConfig{APIKey: "k"}, "X-Subscription-Token", "https://go.dev/b"},
+		{"bing", `{"webPages":{"value":[{"name":"Go 1.22","url":"https://go.dev/c","snippet":"Release notes","datePublished":"2024-02-06T10:00:00.0000000"}]}}`,
+			config.SearchBackendConfig{APIKey: "k"}, "Ocp-Apim-Subscription-Key", "https://go.dev/c"},
+		{"tavily", `{"results":[{"title":"Go 1.22","url":"https://go.dev/d","content":"Release notes","published_date":"Tue, 06 Feb 2024 10:00:00 GMT"}]}`,
+			config.SearchBackendConfig{APIKey: "k"}, "Authorization", "https://go.dev/d"},
+		{"serper", `{"organic":[{"title":"Go 1.22","link":"https://go.dev/e","snippet":"Release notes","date":"Feb 6, 2024"}]}`,
+			config.SearchBackendConfig{APIKey: "k"}, "X-API-KEY", "https://go.dev/e"},
+		{"searxng", `{"results":[{"title":"Go 1.22","url":"https://go.dev/f","content":"Release notes","publishedDate":"2024-02-06T10:00:00"},{"title":"dup","url":"https://go.dev/f"}]}`,
+			config.SearchBackendConfig{}, "", "https://go.dev/f"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.header != "" && r.Header.Get(c.header) == "" {
+					http.Error(w, "missing credentials", http.StatusUnauthorized)
+					return
+				}
+				if c.name == "searxng" && (r.URL.Path != "/search" || r.URL.Query().Get("format") != "json") {
+					http.Error(w, "bad request", http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			c.cfg.Endpoint = srv.URL
+			chain, err := NewSearchChainFromConfig(config.WebToolConfig{
+				SearchBackends: []string{c.name},
+				Backends:       map[string]config.SearchBackendConfig{c.name: c.cfg},
+			}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := chain.Search(context.Background(), "go 1.22", 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := SearchResult{Title: "Go 1.22", URL: c.wantURL, Snippet: "Release notes", Date: "2024-02-06"}
+			if len(resp.Results) != 1 || resp.Results[0] != want {
+				t.Errorf("results = %+v, want [%+v]", resp.Results, want)
+			}
+			if resp.Backend != c.name {
+				t.Errorf("backend = %q", resp.Backend)
+			}
+		})
+	}
+}
+
+func TestDuckDuckGoSearch(t *testing.T) {
+	page := `<html><body>
+<div class="result results_links web-result">
+  <h2 class="result__title"><a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc%2F&amp;rut=x">The <b>Go</b> Docs</a></h2>
+  <a class="result__snippet" href="#">Documentation for the <b>Go</b> language.</a>
+</div>
+<div class="result"><h2><a class="result__a" href="https://pkg.go.dev/">Packages</a></h2>
+  <a class="result__snippet">Go packages.</a></div>
+<div class="result"><h2><a class="result__a" href="https://example.com/3">Third</a></h2></div>
+</body></html>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("q") != "golang" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	backend := &duckDuckGoSearch{client: srv.Client(), endpoint: srv.URL}
+	results, err := backend.Search(context.Background(), "golang", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{
+		{Title: "The Go Docs", URL: "https://go.dev/doc/", Snippet: "Documentation for the Go language."},
+		{Title: "Packages", URL: "https://pkg.go.dev/", Snippet: "Go packages."},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v", results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><form id="challenge-form" class="anomaly-modal"></form></body></html>`))
+	}))
+	defer blocked.Close()
+	backend = &duckDuckGoSearch{client: blocked.Client(), endpoint: blocked.URL}
+	if _, err := backend.Search(context.Background(), "golang", 2); err == nil {
+		t.Error("expected an error for the bot detection page")
+	}
+}
+
+func TestSearchChainFallbackAndCache(t *testing.T) {
+	failing := &stubBackend{name: "brave", err: errors.New("status 500")}
+	empty := &stubBackend{name: "searxng"}
+	working := &stubBackend{name: "duckduckgo", results: []SearchResult{{Title: "A", URL: "https://a.example"}}}
+	chain := NewSearchChain([]SearchBackend{failing, empty, working}, nil, 0, "")
+
+	resp, err := chain.Search(context.Background(), "Hello  World", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Backend != "duckduckgo" || len(resp.Results) != 1 || len(resp.Skipped) != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if out := FormatSearchResults(resp); !strings.Contains(out, "https://a.example") || !strings.Contains(out, "brave: status 500") {
+		t.Errorf("formatted = %q", out)
+	}
+
+	resp, err = chain.Search(context.Background(), "hello world", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Cached || working.calls != 1 || failing.calls != 1 {
+		t.Errorf("expected a cache hit, cached=%v calls=%d/%d", resp.Cached, failing.calls, working.calls)
+	}
+
+	// 所有后端都失败时返回错误，只是没有结果时返回空结果
+	chain = NewSearchChain([]SearchBackend{failing}, nil, -1, "")
+	if _, err := chain.Search(context.Background(), "q", 5); err == nil || !strings.Contains(err.Error(), "brave") {
+		t.Errorf("expected all backends failed error, got %v", err)
+	}
+	chain = NewSearchChain([]SearchBackend{failing, empty}, nil, -1, "")
+	resp, err = chain.Search(context.Background(), "q", 5)
+	if err != nil || len(resp.Results) != 0 {
+		t.Errorf("expected empty results, got %+v, %v", resp, err)
+	}
+}
+
+func TestSearchChainQuota(t *testing.T) {
+	usagePath := filepath.Join(t.TempDir(), "search_usage.json")
+	limited := &stubBackend{name: "brave", results: []SearchResult{{Title: "B", URL: "https://b.example"}}}
+	fallback := &stubBackend{name: "duckduckgo", results: []SearchResult{{Title: "D", URL: "https://d.example"}}}
+	chain := NewSearchChain([]SearchBackend{limited, fallback}, map[string]int{"brave": 2}, -1, usagePath)
+
+	for i := 0; i < 3; i++ {
+		resp, err := chain.Search(context.Background(), "q", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "brave"
+		if i == 2 {
+			want = "duckduckgo"
+		}
+		if resp.Backend != want {
+			t.Errorf("search %d used %q, want %q", i, resp.Backend, want)
+		}
+	}
+	if limited.calls != 2 {
+		t.Errorf("brave called %d times, want 2", limited.calls)
+	}
+	if used, quota := chain.Usage("brave"); used != 2 || quota != 2 {
+		t.Errorf("usage = %d/%d", used, quota)
+	}
+
+	data, err := os.ReadFile(usagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usage searchUsage
+	if err := json.Unmarshal(data, &usage); err != nil || usage.Counts["brave"] != 2 {
+		t.Errorf("usage file = %s, %v", data, err)
+	}
+
+	// 重新创建的搜索链沿用今日计数
+	reloaded := NewSearchChain([]SearchBackend{limited, fallback}, map[string]int{"brave": 2}, -1, usagePath)
+	resp, err := reloaded.Search(context.Background(), "q", 5)
+	if err != nil || resp.Backend != "duckduckgo" {
+		t.Errorf("reloaded chain should skip exhausted brave, got %+v, %v", resp, err)
+	}
+}
+
+func TestSearchChainSharedUsageFile(t *testing.T) {
+	usagePath := filepath.Join(t.TempDir(), "search_usage.json")
+	quotas := map[string]int{"brave": 30}
+	newChain := func() *SearchChain {
+		backend := &stubBackend{name: "brave", results: []SearchResult{{Title: "B", URL: "https://b.example"}}}
+		return NewSearchChain([]SearchBackend{backend}, quotas, -1, usagePath)
+	}
+	// 两个搜索链模拟共用配额文件的两个进程
+	first, second := newChain(), newChain()
+
+	var wg sync.WaitGroup
+	allowed := make(chan bool, 40)
+	for i := 0; i < 20; i++ {
+		for _, chain := range []*SearchChain{first, second} {
+			wg.Add(1)
+			go func(chain *SearchChain) {
+				defer wg.Done()
+				allowed <- chain.takeQuota("brave")
+			}(chain)
+		}
+	}
+	wg.Wait()
+	close(allowed)
+
+	taken := 0
+	for ok := range allowed {
+		if ok {
+			taken++
+		}
+	}
+	if taken != 30 {
+		t.Errorf("quota of 30 allowed %d searches across both chains", taken)
+	}
+	if used, _ := first.Usage("brave"); used != 30 {
+		t.Errorf("first chain sees %d searches, want 30", used)
+	}
+	if matches, _ := filepath.Glob(usagePath + ".tmp*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestNewSearchChainFromConfig(t *testing.T) {
+	chain, err := NewSearchChainFromConfig(config.WebToolConfig{SearchAPIKey: "k", SearchEngine: "travily"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(chain.Backends(), ","); got != "tavily,duckduckgo" {
+		t.Errorf("default backends = %q", got)
+	}
+
+	chain, err = NewSearchChainFromConfig(config.WebToolConfig{SearchEngine: "google"}, "")
+	if err != nil || strings.Join(chain.Backends(), ",") != "duckduckgo" {
+		t.Errorf("without an api key only duckduckgo should be used, got %v, %v", chain.Backends(), err)
+	}
+
+	invalid := []config.WebToolConfig{
+		{SearchBackends: []string{"altavista"}},
+		{SearchBackends: []string{"brave"}},
+		{SearchBackends: []string{"google"}, Backends: map[string]config.SearchBackendConfig{"google": {APIKey: "k"}}},
+		{SearchBackends: []string{"searxng"}},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewSearchChainFromConfig(cfg, ""); err == nil {
+			t.Errorf("expected an error for %v", cfg.SearchBackends)
+		}
+	}
+}
@@ -1,16 +1,17 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
 	"go.uber.org/zap"
 )
 
@@ -22,7 +23,10 @@ type ShellTool struct {
 	timeout       time.Duration
 	workingDir    string
 	sandboxConfig config.SandboxConfig
-	dockerClient  *client.Client
+	sandbox       *SandboxManager // 沙箱启用时非 nil，除非初始化失败
+	sandboxErr    error           // 沙箱初始化失败的原因，此时拒绝执行命令而不是退回主机
+	jail          *PathJail       // 工作目录必须在文件访问范围内（nil 表示不限制）
+	sessions      *ShellSessions
 }
 
 // NewShellTool 创建 Shell 工具
@@ -47,21 +51,44 @@ func NewShellTool(
 		timeout:       t,
 		workingDir:    workingDir,
 		sandboxConfig: sandboxConfig,
+		sessions:      NewShellSessions(),
 	}
 
-	// 如果启用沙箱，初始化 Docker 客户端
+	// 如果启用沙箱，初始化沙箱管理器
 	if sandboxConfig.Enabled {
-		if cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation()); err == nil {
-			st.dockerClient = cli
-		} else {
-			zap.L().Warn("Failed to initialize Docker client, sandbox disabled", zap.Error(err))
-			st.sandboxConfig.Enabled = false
+		st.sandbox, st.sandboxErr = newDockerSandbox(sandboxConfig)
+		if st.sandboxErr != nil {
+			logger.Warn("Failed to initialize Docker sandbox, shell commands will be refused", zap.Error(st.sandboxErr))
 		}
 	}
 
 	return st
 }
 
+// SetPathJail 设置文件访问范围，工作目录必须位于其中，未配置工作目录时使用工作区
+func (t *ShellTool) SetPathJail(jail *PathJail) {
+	t.jail = jail
+}
+
+// workDir 返回解析符号链接后的工作目录
+func (t *ShellTool) workDir() (string, error) {
+	if t.jail == nil {
+		return t.workingDir, nil
+	}
+	dir := t.workingDir
+	if dir == "" {
+		dir = t.jail.Workspace()
+	}
+	if dir == "" {
+		return "", nil
+	}
+	resolved, err := t.jail.Dir(dir)
+	if err != nil {
+		return "", fmt.Errorf("working directory %s is not allowed: %w", dir, err)
+	}
+	return resolved, nil
+}
+
 // Exec 执行 Shell 命令
 func (t *ShellTool) Exec(ctx context.Context, params map[string]interface{}) (string, error) {
 	if !t.enabled {
@@ -73,141 +100,90 @@ func (t *ShellTool) Exec(ctx context.Context, params map[string]interface{}) (st
 		return "", fmt.Errorf("command parameter is required")
 	}
 
-	// 检查危险命令
-	if t.isDenied(command) {
-		return "", fmt.Errorf("command is not allowed: %s", command)
+	workdir, err := t.workDir()
+	if err != nil {
+		return "", err
+	}
+
+	// 检查命令中调用的每个程序和重定向
+	if _, err := t.policy().Check(command, workdir); err != nil {
+		return "", err
 	}
 
 	// 根据是否启用沙箱选择执行方式
-	if t.sandboxConfig.Enabled && t.dockerClient != nil {
-		return t.execInSandbox(ctx, command)
+	if t.sandboxConfig.Enabled {
+		return t.execInSandbox(ctx, command, workdir)
 	}
-	return t.execDirect(ctx, command)
+	return t.execDirect(ctx, command, workdir)
 }
 
 // execDirect 直接执行命令
-func (t *ShellTool) execDirect(ctx context.Context, command string) (string, error) {
+func (t *ShellTool) execDirect(ctx context.Context, command, workdir string) (string, error) {
 	// 创建带超时的上下文
 	cmdCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	// 执行命令
+	// 执行命令，输出通过进度回调流式报告
 	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
-	if t.workingDir != "" {
-		cmd.Dir = t.workingDir
+	if workdir != "" {
+		cmd.Dir = workdir
 	}
+	output := &progressWriter{ctx: ctx}
+	cmd.Stdout = output
+	cmd.Stderr = output
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("command failed: %w, output: %s", err, string(output))
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("command failed: %w, output: %s", err, output.String())
 	}
 
-	return string(output), nil
+	return output.String(), nil
 }
 
-// execInSandbox 在 Docker 容器中执行命令
-func (t *ShellTool) execInSandbox(ctx context.Context, command string) (string, error) {
-	containerName := fmt.Sprintf("goclaw-%d", time.Now().UnixNano())
+// progressWriter 收集命令输出，同时作为进度报告
+type progressWriter struct {
+	ctx context.Context
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
 
-	// 准备工作目录
-	workdir := t.workingDir
-	if workdir == "" {
-		workdir = "."
-	}
-
-	// 准备挂载点
-	binds := []string{
-		workdir + ":" + t.sandboxConfig.Workdir,
-	}
-
-	// 创建并运行容器
-	resp, err := t.dockerClient.ContainerCreate(ctx, &container.Config{
-		Image:      t.sandboxConfig.Image,
-		Cmd:        []string{"sh", "-c", command},
-		WorkingDir: t.sandboxConfig.Workdir,
-		Tty:        false,
-	}, &container.HostConfig{
-		Binds:       binds,
-		NetworkMode: container.NetworkMode(t.sandboxConfig.Network),
-		Privileged:  t.sandboxConfig.Privileged,
-		AutoRemove:  t.sandboxConfig.Remove,
-	}, nil, nil, containerName)
-	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
-	}
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ReportProgress(w.ctx, string(p))
+	return w.buf.Write(p)
+}
 
-	// 确保容器被清理
-	if !t.sandboxConfig.Remove {
-		defer func() {
-			_ = t.dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{
-				Force: true,
-			})
-		}()
-	}
+func (w *progressWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
 
-	// 启动容器
-	if err := t.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("failed to start container: %w", err)
+// execInSandbox 在会话的沙箱容器中执行命令，退出码非零时错误信息包含输出和退出码
+func (t *ShellTool) execInSandbox(ctx context.Context, command, workdir string) (string, error) {
+	if t.sandbox == nil {
+		return "", fmt.Errorf("sandbox is enabled but unavailable: %w", t.sandboxErr)
 	}
-
-	// 等待容器完成
-	statusCh, errCh := t.dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errCh:
-		return "", fmt.Errorf("container wait error: %w", err)
-	case status := <-statusCh:
-		if status.StatusCode != 0 {
-			return "", fmt.Errorf("container exited with code %d", status.StatusCode)
+	if workdir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			workdir = wd
 		}
-	case <-ctx.Done():
-		return "", ctx.Err()
-	}
-
-	// 获取日志
-	out, err := t.dockerClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to get container logs: %w", err)
 	}
-	defer out.Close()
-
-	// 读取输出
-	logs, err := io.ReadAll(out)
+	image := t.sandbox.ImageFor(LoadedSkillsFromContext(ctx))
+	result, err := t.sandbox.Exec(ctx, SessionKeyFromContext(ctx), image, workdir, command, t.timeout)
 	if err != nil {
-		return "", fmt.Errorf("failed to read logs: %w", err)
+		return "", err
 	}
-
-	return string(logs), nil
+	return result.String(), nil
 }
 
-// isDenied 检查命令是否被拒绝
-func (t *ShellTool) isDenied(command string) bool {
-	// 检查明确拒绝的命令
-	for _, denied := range t.deniedCmds {
-		if strings.Contains(command, denied) {
-			return true
-		}
-	}
-
-	// 如果有允许列表，检查是否在允许列表中
-	if len(t.allowedCmds) > 0 {
-		parts := strings.Fields(command)
-		if len(parts) == 0 {
-			return true
-		}
-		cmdName := parts[0]
-
-		for _, allowed := range t.allowedCmds {
-			if cmdName == allowed {
-				return false
-			}
-		}
-		return true
+// policy 返回命令策略。沙箱中的路径是容器内的路径，不检查重定向目标
+func (t *ShellTool) policy() *ShellPolicy {
+	jail := t.jail
+	if t.sandboxConfig.Enabled {
+		jail = nil
 	}
-
-	return false
+	return NewShellPolicy(t.allowedCmds, t.deniedCmds, jail)
 }
 
 // GetTools 获取所有 Shell 工具
@@ -216,14 +192,14 @@ func (t *ShellTool) GetTools() []Tool {
 	desc.WriteString("Execute a shell command")
 
 	if t.sandboxConfig.Enabled {
-		desc.WriteString(" inside a Docker sandbox container. Commands run in a containerized environment with network isolation.")
+		desc.WriteString(" inside this conversation's Docker sandbox container. The container persists between calls (files in /tmp survive), runs as a non-root user with a read-only root filesystem, limited memory, CPU and processes, and no network unless configured. The workspace is mounted at " + t.sandboxConfig.Workdir + ". The result reports exit_code, stdout and stderr")
 	} else {
 		desc.WriteString(" on the host system")
 	}
 
-	desc.WriteString(". Use this for file operations, running scripts (Python, Node.js, etc.), installing dependencies, HTTP requests (curl), system diagnostics and more. Commands run in a non-interactive shell.")
+	desc.WriteString(". Use this for file operations, running scripts (Python, Node.js, etc.), installing dependencies, HTTP requests (curl), system diagnostics and more. Commands run in a non-interactive shell and each call starts a fresh shell. For long-running servers or interactive programs use shell_start.")
 
-	return []Tool{
+	return append([]Tool{
 		NewBaseTool(
 			"exec",
 			desc.String(),
@@ -239,13 +215,22 @@ func (t *ShellTool) GetTools() []Tool {
 			},
 			t.Exec,
 		),
+	}, t.sessionTools()...)
+}
+
+// CleanupSession 结束聊天会话的 Shell 会话
+func (t *ShellTool) CleanupSession(sessionKey string) {
+	t.sessions.CleanupSession(sessionKey)
+	if t.sandbox != nil {
+		t.sandbox.CleanupSession(sessionKey)
 	}
 }
 
 // Close 关闭工具
 func (t *ShellTool) Close() error {
-	if t.dockerClient != nil {
-		return t.dockerClient.Close()
+	t.sessions.CloseAll()
+	if t.sandbox != nil {
+		return t.sandbox.Close()
 	}
 	return nil
 }
@@ -0,0 +1,856 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxShellNesting sh -c、eval 等嵌套脚本最多展开的层数
+const maxShellNesting = 4
+
+// shellBuiltins 设置了 allowed_cmds 时也始终允许的无害内建命令
+var shellBuiltins = map[string]bool{
+	"cd": true, "pwd": true, "echo": true, "printf": true, "true": true, "false": true,
+	"test": true, "[": true, ":": true, "exit": true,
+}
+
+// shellWrappers 把后续参数作为命令执行的包装命令
+var shellWrappers = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "nice": true, "nohup": true, "time": true,
+	"timeout": true, "command": true, "builtin": true, "exec": true, "xargs": true,
+	"stdbuf": true, "ionice": true, "setsid": true, "chroot": true, "watch": true,
+}
+
+// shellInterpreters 通过 -c 执行脚本字符串的 Shell
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true,
+}
+
+// readOnlyCommands 不修改文件和系统状态的命令（部分参数除外，见 readOnlyInvocation）
+var readOnlyCommands = map[string]bool{
+	"ls": true, "cat": true, "head": true, "tail": true, "grep": true, "egrep": true, "fgrep": true,
+	"rg": true, "find": true, "wc": true, "sort": true, "uniq": true, "cut": true, "tr": true,
+	"diff": true, "cmp": true, "file": true, "stat": true, "du": true, "df": true, "pwd": true,
+	"echo": true, "printf": true, "which": true, "whoami": true, "id": true, "date": true,
+	"uname": true, "hostname": true, "true": true, "false": true, "test": true, "[": true,
+	":": true, "cd": true, "basename": true, "dirname": true, "realpath": true, "readlink": true,
+	"tree": true, "jq": true, "nl": true, "column": true, "md5sum": true, "sha1sum": true,
+	"sha256sum": true, "ps": true, "uptime": true, "free": true, "printenv": true, "seq": true,
+	"type": true, "git": true, "env": true, "time": true, "nice": true, "xargs": true, "tac": true,
+}
+
+// readOnlyGitCommands 只读的 git 子命令
+var readOnlyGitCommands = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "ls-files": true, "rev-parse": true,
+	"blame": true, "grep": true, "describe": true, "shortlog": true, "branch": true, "tag": true,
+	"remote": true,
+}
+
+// flagSpec 命令允许的选项。shortValue 中的短选项带参数，其后的字符作为参数值
+type flagSpec struct {
+	short      string
+	shortValue string
+	long       []string
+}
+
+// allows 判断参数中的选项是否都在允许范围内，-- 之后的参数不再检查
+func (s flagSpec) allows(args []string) bool {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return true
+		case strings.HasPrefix(arg, "--"):
+			name, _, _ := strings.Cut(arg, "=")
+			if !slices.Contains(s.long, name) {
+				return false
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, c := range arg[1:] {
+				if strings.ContainsRune(s.shortValue, c) {
+					break
+				}
+				if !strings.ContainsRune(s.short, c) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// readOnlyFlags 只读命令中选项可以执行外部程序或写文件的，只允许下列选项
+var readOnlyFlags = map[string]flagSpec{
+	// sort 不允许 -o 和 --compress-program
+	"sort": {
+		short:      "bcCdfghiMmnRrsuVz",
+		shortValue: "ktST",
+		long: []string{
+			"--ignore-leading-blanks", "--dictionary-order", "--ignore-case", "--general-numeric-sort",
+			"--ignore-nonprinting", "--month-sort", "--human-numeric-sort", "--numeric-sort",
+			"--random-sort", "--random-source", "--reverse", "--version-sort", "--sort", "--check",
+			"--merge", "--key", "--field-separator", "--buffer-size", "--temporary-directory",
+			"--stable", "--unique", "--zero-terminated", "--parallel", "--batch-size", "--files0-from",
+			"--debug", "--help", "--version",
+		},
+	},
+	// rg 不允许 --pre、--pre-glob 和 --hostname-bin
+	"rg": {
+		short:      "abcFhHiIlLnNopqsSuUvVwxz.",
+		shortValue: "ABCdeEfgjmMrtT",
+		long: []string{
+			"--regexp", "--file", "--after-context", "--before-context", "--context", "--binary",
+			"--case-sensitive", "--color", "--colors", "--column", "--count", "--count-matches",
+			"--debug", "--max-depth", "--encoding", "--engine", "--files", "--files-with-matches",
+			"--files-without-match", "--fixed-strings", "--follow", "--glob", "--iglob", "--glob-case-insensitive",
+			"--heading", "--no-heading", "--hidden", "--no-hidden", "--ignore-case", "--ignore-file",
+			"--invert-match", "--json", "--line-number", "--no-line-number", "--line-regexp",
+			"--max-columns", "--max-columns-preview", "--max-count", "--max-filesize", "--multiline",
+			"--multiline-dotall", "--no-filename", "--with-filename", "--no-ignore", "--no-ignore-vcs",
+			"--no-ignore-parent", "--no-ignore-dot", "--no-messages", "--null", "--null-data",
+			"--only-matching", "--passthru", "--pcre2", "--quiet", "--replace", "--search-zip",
+			"--smart-case", "--sort", "--sortr", "--stats", "--text", "--threads", "--trim", "--type",
+			"--type-not", "--type-list", "--unrestricted", "--vimgrep", "--word-regexp", "--byte-offset",
+			"--help", "--version",
+		},
+	},
+}
+
+// gitGlobalFlags 允许的 git 全局选项。-c、--config-env、--exec-path 等可以让 git 执行任意程序
+var gitGlobalFlags = []string{
+	"-P", "--no-pager", "--no-optional-locks", "--literal-pathspecs", "--glob-pathspecs",
+	"--noglob-pathspecs", "--icase-pathspecs", "--no-replace-objects", "--version", "--help",
+}
+
+// gitListFlags branch、tag、remote 列出时允许的选项
+var gitListFlags = flagSpec{
+	short: "alrvn",
+	long: []string{
+		"--all", "--remotes", "--list", "--verbose", "--show-current", "--merged", "--no-merged",
+		"--contains", "--no-contains", "--points-at", "--sort", "--format", "--color", "--no-color",
+		"--column", "--no-column", "--ignore-case",
+	},
+}
+
+// unsafeEnvVars 改变后续命令解析或加载方式、或让命令执行其他程序的环境变量，
+// 另外所有 GIT_ 开头的变量（GIT_EXTERNAL_DIFF、GIT_PAGER、GIT_CONFIG_* 等）也视为不安全
+var unsafeEnvVars = map[string]bool{
+	"PATH": true, "LD_PRELOAD": true, "LD_LIBRARY_PATH": true, "DYLD_INSERT_LIBRARIES": true,
+	"DYLD_LIBRARY_PATH": true, "BASH_ENV": true, "ENV": true, "IFS": true, "PROMPT_COMMAND": true,
+	"PAGER": true, "MANPAGER": true, "LESSOPEN": true, "LESSCLOSE": true, "EDITOR": true,
+	"VISUAL": true, "SHELLOPTS": true, "BASHOPTS": true, "PERL5OPT": true, "PYTHONSTARTUP": true,
+	"NODE_OPTIONS": true,
+}
+
+// IsUnsafeEnvVar 判断设置环境变量是否可能改变命令的解析方式或让命令执行其他程序
+func IsUnsafeEnvVar(name string) bool {
+	return unsafeEnvVars[name] || strings.HasPrefix(name, "GIT_")
+}
+
+// fdNumber 形如 >&2 的文件描述符复制
+var fdNumber = regexp.MustCompile(`^[0-9]+-?$|^-$`)
+
+// ShellCommand 命令中调用的一个程序
+type ShellCommand struct {
+	Args        []string // 命令名和参数，无法静态确定的参数为空字符串
+	Dynamic     bool     // 命令名无法静态确定（变量、命令替换等）
+	DynamicArgs bool     // 部分参数无法静态确定
+	Wrapper     bool     // 包装命令（sudo、env、xargs 等），后续参数作为命令执行
+}
+
+// Name 返回命令名（不含路径）
+func (c ShellCommand) Name() string {
+	if c.Dynamic || len(c.Args) == 0 {
+		return ""
+	}
+	return path.Base(c.Args[0])
+}
+
+// ShellWrite 重定向写入的目标
+type ShellWrite struct {
+	Target     string // 重定向目标，无法静态确定时为空
+	Dir        string // 写入时 cd 之后的目录（相对起始目录或绝对路径），空为起始目录
+	DirUnknown bool   // 之前的 cd 目标无法确定
+}
+
+// ShellAnalysis 解析 Shell 命令得到的调用和写入，包括管道、子 Shell、命令替换、
+// 进程替换、函数体以及 sh -c、eval 中的脚本
+type ShellAnalysis struct {
+	Commands []ShellCommand
+	Writes   []ShellWrite
+	Unsafe   []string // 改变命令解析的赋值，例如 PATH=...
+	Env      []string // 所有赋值的变量名，包括 VAR=value cmd、export VAR=value 和 env VAR=value cmd
+	Dir      string   // 命令结束时 cd 之后的目录，含义同 ShellWrite.Dir
+	DirKnown bool     // Dir 是否可以确定
+}
+
+// shellAnalyzer 遍历语法树，按源码顺序跟踪 cd 改变的目录
+type shellAnalyzer struct {
+	analysis *ShellAnalysis
+	depth    int
+}
+
+// AnalyzeShellCommand 解析 Shell 命令（bash 语法），返回其中调用的全部命令和重定向写入
+func AnalyzeShellCommand(command string) (*ShellAnalysis, error) {
+	z := &shellAnalyzer{analysis: &ShellAnalysis{DirKnown: true}}
+	if err := z.parse(command); err != nil {
+		return nil, err
+	}
+	return z.analysis, nil
+}
+
+// parse 解析并遍历一段脚本
+func (z *shellAnalyzer) parse(script string) error {
+	if z.depth > maxShellNesting {
+		return fmt.Errorf("shell command is nested too deeply")
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return err
+	}
+
+	var walkErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if walkErr != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			z.assigns(n.Assigns)
+			if len(n.Args) > 0 {
+				walkErr = z.call(n.Args)
+			}
+		case *syntax.DeclClause:
+			z.assigns(n.Args)
+		case *syntax.Redirect:
+			z.redirect(n)
+		}
+		return true
+	})
+	return walkErr
+}
+
+// assigns 记录赋值
+func (z *shellAnalyzer) assigns(assigns []*syntax.Assign) {
+	for _, a := range assigns {
+		if a.Name != nil {
+			z.assign(a.Name.Value)
+		}
+	}
+}
+
+// assign 记录变量赋值，改变命令解析方式的同时记为不安全
+func (z *shellAnalyzer) assign(name string) {
+	z.analysis.Env = append(z.analysis.Env, name)
+	if IsUnsafeEnvVar(name) {
+		z.analysis.Unsafe = append(z.analysis.Unsafe, name)
+	}
+}
+
+// call 记录一次命令调用
+func (z *shellAnalyzer) call(words []*syntax.Word) error {
+	cmd := ShellCommand{Args: make([]string, len(words))}
+	for i, w := range words {
+		value, ok := wordValue(w)
+		if !ok {
+			if i == 0 {
+				cmd.Dynamic = true
+			}
+			cmd.DynamicArgs = true
+		}
+		cmd.Args[i] = value
+	}
+	return z.command(cmd)
+}
+
+// command 记录命令，并展开其中作为命令执行的部分（包装命令、sh -c、eval、find -exec）
+func (z *shellAnalyzer) command(cmd ShellCommand) error {
+	name := cmd.Name()
+	cmd.Wrapper = shellWrappers[name]
+	z.analysis.Commands = append(z.analysis.Commands, cmd)
+	if cmd.Dynamic {
+		return nil
+	}
+	args := cmd.Args
+
+	switch {
+	case name == "cd":
+		z.cd(cmd)
+	case shellInterpreters[name]:
+		for i := 1; i < len(args)-1; i++ {
+			if strings.HasPrefix(args[i], "-") && !strings.HasPrefix(args[i], "--") && strings.Contains(args[i], "c") {
+				return z.nested(args[i+1], cmd.DynamicArgs)
+			}
+		}
+	case name == "eval":
+		return z.nested(strings.Join(args[1:], " "), cmd.DynamicArgs)
+	case name == "env":
+		return z.env(cmd)
+	case name == "find":
+		for i := 1; i < len(args)-1; i++ {
+			switch args[i] {
+			case "-exec", "-execdir", "-ok", "-okdir":
+				end := i + 1
+				for end < len(args) && args[end] != ";" && args[end] != "+" {
+					end++
+				}
+				if end > i+1 {
+					inner := args[i+1 : end]
+					if err := z.command(ShellCommand{Args: inner, Dynamic: inner[0] == "", DynamicArgs: cmd.DynamicArgs}); err != nil {
+						return err
+					}
+				}
+				i = end
+			}
+		}
+	case cmd.Wrapper:
+		return z.wrapped(wrappedCommand(name, args), cmd.DynamicArgs)
+	}
+	return nil
+}
+
+// wrapped 记录包装命令实际执行的命令
+func (z *shellAnalyzer) wrapped(inner []string, dynamic bool) error {
+	if len(inner) == 0 {
+		return nil
+	}
+	return z.command(ShellCommand{Args: inner, Dynamic: inner[0] == "", DynamicArgs: dynamic})
+}
+
+// env 记录 env 设置的变量和执行的命令，-S/--split-string 的字符串按 Shell 单词拆分后检查
+func (z *shellAnalyzer) env(cmd ShellCommand) error {
+	args := cmd.Args
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return z.wrapped(args[i+1:], cmd.DynamicArgs)
+		case arg == "--split-string":
+			if i+1 < len(args) {
+				return z.splitString(args[i+1], args[i+2:], cmd.DynamicArgs)
+			}
+			return nil
+		case strings.HasPrefix(arg, "--split-string="):
+			return z.splitString(strings.TrimPrefix(arg, "--split-string="), args[i+1:], cmd.DynamicArgs)
+		case arg == "--unset" || arg == "--chdir":
+			i++
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if j := strings.IndexByte(arg, 'S'); j > 0 {
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						return nil
+					}
+					i++
+					value = args[i]
+				}
+				return z.splitString(value, args[i+1:], cmd.DynamicArgs)
+			}
+			// -u NAME、-C DIR 的参数在下一个单词中
+			if last := arg[len(arg)-1]; last == 'u' || last == 'C' {
+				i++
+			}
+		case strings.Contains(arg, "="):
+			z.assign(arg[:strings.IndexByte(arg, '=')])
+		default:
+			return z.wrapped(args[i:], cmd.DynamicArgs)
+		}
+	}
+	return nil
+}
+
+// splitString 把 env -S 的字符串和其后的参数作为一条命令解析
+func (z *shellAnalyzer) splitString(value string, rest []string, dynamic bool) error {
+	script := value
+	for _, arg := range rest {
+		quoted, err := syntax.Quote(arg, syntax.LangBash)
+		if err != nil {
+			return err
+		}
+		script += " " + quoted
+	}
+	return z.nested(script, dynamic || value == "")
+}
+
+// nested 解析 sh -c 或 eval 执行的脚本
+func (z *shellAnalyzer) nested(script string, dynamic bool) error {
+	if dynamic {
+		z.analysis.Commands = append(z.analysis.Commands, ShellCommand{Dynamic: true})
+		return nil
+	}
+	z.depth++
+	defer func() { z.depth-- }()
+	return z.parse(script)
+}
+
+// cd 跟踪工作目录的变化
+func (z *shellAnalyzer) cd(cmd ShellCommand) {
+	a := z.analysis
+	args := cmd.Args[1:]
+	for len(args) > 0 && (args[0] == "-P" || args[0] == "-L" || args[0] == "--") {
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0] == "" || args[0] == "-" || cmd.DynamicArgs {
+		a.DirKnown = false
+		return
+	}
+	target := expandHome(args[0])
+	if filepath.IsAbs(target) {
+		a.Dir = target
+		a.DirKnown = true
+	} else if a.DirKnown {
+		a.Dir = filepath.Join(a.Dir, target)
+	}
+}
+
+// redirect 记录写入文件的重定向
+func (z *shellAnalyzer) redirect(r *syntax.Redirect) {
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.RdrInOut:
+	case syntax.DplOut:
+		// >&2 复制文件描述符，>&file 等同于 &>file
+		if value, ok := wordValue(r.Word); ok && fdNumber.MatchString(value) {
+			return
+		}
+	default:
+		return
+	}
+	w := ShellWrite{Dir: z.analysis.Dir, DirUnknown: !z.analysis.DirKnown}
+	if value, ok := wordValue(r.Word); ok {
+		w.Target = expandHome(value)
+	}
+	z.analysis.Writes = append(z.analysis.Writes, w)
+}
+
+// ReadOnly 判断命令是否只读：只调用只读命令，不写文件（/dev/null 等除外），没有无法确定的部分
+func (a *ShellAnalysis) ReadOnly() bool {
+	// 环境变量可以改变只读命令的行为（例如 GIT_EXTERNAL_DIFF、PAGER）
+	if len(a.Unsafe) > 0 || len(a.Env) > 0 {
+		return false
+	}
+	for _, w := range a.Writes {
+		if !isSpecialDevice(w.Target) {
+			return false
+		}
+	}
+	for _, cmd := range a.Commands {
+		if !readOnlyInvocation(cmd) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReadOnlyCommand 判断 Shell 命令是否只读，无法解析时返回 false
+func IsReadOnlyCommand(command string) bool {
+	a, err := AnalyzeShellCommand(command)
+	return err == nil && len(a.Commands) > 0 && a.ReadOnly()
+}
+
+// readOnlyInvocation 判断一次调用是否只读
+func readOnlyInvocation(cmd ShellCommand) bool {
+	name := cmd.Name()
+	if name == "" || !readOnlyCommands[name] {
+		return false
+	}
+	args := cmd.Args[1:]
+	switch name {
+	case "find":
+		for _, arg := range args {
+			if arg == "-delete" || strings.HasPrefix(arg, "-fprint") || arg == "-fls" {
+				return false
+			}
+		}
+	case "date":
+		for _, arg := range args {
+			if arg == "-s" || strings.HasPrefix(arg, "--set") {
+				return false
+			}
+		}
+	case "tree":
+		for _, arg := range args {
+			if arg == "-o" {
+				return false
+			}
+		}
+	case "git":
+		sub, rest := gitSubcommand(args)
+		if !readOnlyGitCommands[sub] {
+			return false
+		}
+		for _, arg := range rest {
+			// --ext-diff 和 grep 的 -O 会执行外部程序
+			if strings.HasPrefix(arg, "--output") || arg == "--ext-diff" || strings.HasPrefix(arg, "--open-files-in-pager") {
+				return false
+			}
+			if sub == "grep" && strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "O") {
+				return false
+			}
+		}
+		// branch、tag、remote 只有列出时是只读的
+		if sub == "branch" || sub == "tag" || sub == "remote" {
+			for _, arg := range rest {
+				if !strings.HasPrefix(arg, "-") || !gitListFlags.allows([]string{arg}) {
+					return false
+				}
+			}
+		}
+	case "uniq":
+		// uniq IN OUT 会写入 OUT
+		positional := 0
+		for i := 0; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "-f" || arg == "-s" || arg == "-w" || arg == "--skip-fields" || arg == "--skip-chars" || arg == "--check-chars":
+				i++
+			case arg == "--":
+				positional += len(args) - i - 1
+				i = len(args)
+			case strings.HasPrefix(arg, "-") && arg != "-":
+			default:
+				positional++
+			}
+		}
+		if positional > 1 {
+			return false
+		}
+	case "hostname":
+		// 带参数时设置主机名
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				return false
+			}
+		}
+	default:
+		if spec, ok := readOnlyFlags[name]; ok && !spec.allows(args) {
+			return false
+		}
+	}
+	return true
+}
+
+// gitSubcommand 跳过 git 的全局选项，返回子命令及其参数。
+// 有不在 gitGlobalFlags 中的全局选项（-C 除外）时返回空字符串
+func gitSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C":
+			i++
+		case strings.HasPrefix(arg, "-"):
+			if !slices.Contains(gitGlobalFlags, arg) {
+				return "", nil
+			}
+		default:
+			return arg, args[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// wrappedCommand 返回包装命令实际执行的命令：跳过选项、环境变量赋值和 timeout 的时长
+func wrappedCommand(name string, args []string) []string {
+	skipValue := name == "timeout" || name == "watch" || name == "chroot"
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "-"):
+		case skipValue:
+			skipValue = false
+		default:
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+// wordValue 返回单词的静态值（处理引号和转义），包含变量、命令替换等时返回 false
+func wordValue(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value, ""))
+		case *syntax.SglQuoted:
+			if p.Dollar && strings.Contains(p.Value, `\`) {
+				return "", false
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range p.Parts {
+				lit, ok := inner.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(unescape(lit.Value, "$`\"\\\n"))
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// unescape 去掉反斜杠转义；special 非空时只有其中的字符可以被转义（双引号内的规则）
+func unescape(s, special string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (special == "" || strings.IndexByte(special, s[i+1]) >= 0) {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// expandHome 展开开头的 ~
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
+
+// isSpecialDevice 判断是否为写入无副作用的设备文件
+func isSpecialDevice(target string) bool {
+	switch target {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return true
+	}
+	return strings.HasPrefix(target, "/dev/fd/")
+}
+
+// ShellPolicy 基于解析后的 Shell 语法检查命令：每个被调用的程序（包括管道、子 Shell 和命令替换中的）
+// 都要通过允许/拒绝规则，配置了文件访问范围时重定向不能写到范围之外
+type ShellPolicy struct {
+	allowed [][]string
+	denied  []shellRule
+	jail    *PathJail
+}
+
+// shellRule 一条拒绝规则
+type shellRule struct {
+	text  string
+	words []string // 命令名和参数，规则不是单个简单命令时为空，此时按原文匹配
+}
+
+// NewShellPolicy 创建命令策略。allowed 为空时允许所有命令；规则可以带参数，例如 "git status"、"rm -rf"。
+// jail 为 nil 时不检查重定向目标
+func NewShellPolicy(allowed, denied []string, jail *PathJail) *ShellPolicy {
+	p := &ShellPolicy{jail: jail}
+	for _, rule := range allowed {
+		if words := strings.Fields(rule); len(words) > 0 {
+			p.allowed = append(p.allowed, words)
+		}
+	}
+	for _, rule := range denied {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		r := shellRule{text: rule}
+		if a, err := AnalyzeShellCommand(rule); err == nil && len(a.Commands) == 1 && !a.Commands[0].DynamicArgs && len(a.Writes) == 0 {
+			r.words = a.Commands[0].Args
+			r.words[0] = path.Base(r.words[0])
+		}
+		p.denied = append(p.denied, r)
+	}
+	return p
+}
+
+// Check 检查命令，dir 为命令的起始工作目录（为空表示未知）。
+// 返回命令结束时的工作目录（cd 之后，无法确定时为空）
+func (p *ShellPolicy) Check(command, dir string) (string, error) {
+	for _, rule := range p.denied {
+		if rule.words == nil && strings.Contains(stripSpace(command), stripSpace(rule.text)) {
+			return "", fmt.Errorf("command is not allowed: matches denied pattern %q", rule.text)
+		}
+	}
+
+	a, err := AnalyzeShellCommand(command)
+	if err != nil {
+		if len(p.allowed) > 0 {
+			return "", fmt.Errorf("command is not allowed: cannot parse it to check allowed_cmds: %w", err)
+		}
+		// 无法解析的命令由 Shell 报告语法错误，这里按原文匹配拒绝规则
+		for _, rule := range p.denied {
+			if strings.Contains(command, rule.text) {
+				return "", fmt.Errorf("command is not allowed: matches denied pattern %q", rule.text)
+			}
+		}
+		return "", nil
+	}
+
+	for _, cmd := range a.Commands {
+		if err := p.checkCommand(cmd); err != nil {
+			return "", err
+		}
+	}
+	if len(p.allowed) > 0 && len(a.Unsafe) > 0 {
+		return "", fmt.Errorf("command is not allowed: setting %s can run commands outside allowed_cmds", a.Unsafe[0])
+	}
+	if p.jail != nil {
+		for _, w := range a.Writes {
+			if err := p.checkWrite(w, dir); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if !a.DirKnown || dir == "" && !filepath.IsAbs(a.Dir) {
+		return "", nil
+	}
+	return joinDir(dir, a.Dir), nil
+}
+
+// checkCommand 检查一次调用
+func (p *ShellPolicy) checkCommand(cmd ShellCommand) error {
+	if cmd.Dynamic {
+		if len(p.allowed) > 0 {
+			return fmt.Errorf("command is not allowed: the program name is computed at run time and cannot be checked against allowed_cmds")
+		}
+		return nil
+	}
+
+	name := cmd.Name()
+	for _, rule := range p.denied {
+		if rule.words == nil {
+			continue
+		}
+		if cmd.DynamicArgs && rule.words[0] == name && len(rule.words) > 1 {
+			return fmt.Errorf("command is not allowed: %s has arguments computed at run time that may match denied rule %q", name, rule.text)
+		}
+		// 包装命令的每个位置都可能是被执行的命令
+		last := 0
+		if cmd.Wrapper {
+			last = len(cmd.Args) - 1
+		}
+		for i := 0; i <= last; i++ {
+			if rule.matches(cmd.Args[i:]) {
+				return fmt.Errorf("command is not allowed: %s (denied by rule %q)", strings.Join(cmd.Args, " "), rule.text)
+			}
+		}
+	}
+
+	if len(p.allowed) == 0 || shellBuiltins[name] {
+		return nil
+	}
+	for _, rule := range p.allowed {
+		if allowRuleMatches(rule, cmd.Args) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command is not allowed: %s is not in allowed_cmds", name)
+}
+
+// checkWrite 检查重定向目标是否在文件访问范围内
+func (p *ShellPolicy) checkWrite(w ShellWrite, dir string) error {
+	if w.Target == "" {
+		return fmt.Errorf("command is not allowed: the redirection target is computed at run time and cannot be checked against the allowed paths")
+	}
+	if isSpecialDevice(w.Target) {
+		return nil
+	}
+	target := w.Target
+	if !filepath.IsAbs(target) {
+		base := joinDir(dir, w.Dir)
+		if w.DirUnknown || !filepath.IsAbs(base) {
+			return fmt.Errorf("command is not allowed: cannot determine the directory %s is written in", w.Target)
+		}
+		target = filepath.Join(base, target)
+	}
+	if !p.jail.Allowed(target) {
+		return fmt.Errorf("command is not allowed: writes to %s, outside the allowed paths", w.Target)
+	}
+	return nil
+}
+
+// matches 判断调用是否匹配拒绝规则：命令名相同，规则中的参数都出现在调用中。
+// 短选项按字母比较，"-rf" 也匹配 "-fr" 和 "-r -f"
+func (r shellRule) matches(args []string) bool {
+	if len(args) == 0 || path.Base(args[0]) != r.words[0] {
+		return false
+	}
+	flags := make(map[rune]bool)
+	present := make(map[string]bool)
+	for _, arg := range args[1:] {
+		present[arg] = true
+		if strings.HasPrefix(arg, "--") {
+			if name, _, ok := strings.Cut(arg, "="); ok {
+				present[name] = true
+			}
+		} else if strings.HasPrefix(arg, "-") {
+			for _, c := range arg[1:] {
+				flags[c] = true
+			}
+		}
+	}
+	for _, word := range r.words[1:] {
+		if strings.HasPrefix(word, "-") && !strings.HasPrefix(word, "--") && len(word) > 1 {
+			for _, c := range word[1:] {
+				if !flags[c] {
+					return false
+				}
+			}
+		} else if !present[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// allowRuleMatches 判断调用是否匹配允许规则：命令名相同（规则含路径时比较完整路径），规则中的参数为调用参数的前缀
+func allowRuleMatches(rule, args []string) bool {
+	if len(args) < len(rule) {
+		return false
+	}
+	name := args[0]
+	if !strings.Contains(rule[0], "/") {
+		name = path.Base(name)
+	}
+	if name != rule[0] {
+		return false
+	}
+	for i := 1; i < len(rule); i++ {
+		if args[i] != rule[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// joinDir 将 cd 之后的目录与起始目录合并
+func joinDir(start, dir string) string {
+	if filepath.IsAbs(dir) || start == "" {
+		return dir
+	}
+	return filepath.Join(start, dir)
+}
+
+// stripSpace 去掉所有空白字符
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
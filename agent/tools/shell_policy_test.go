@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellPolicyAllowAndDeny(t *testing.T) {
+	policy := NewShellPolicy([]string{"ls", "cat", "grep", "git status"}, []string{"rm -rf", "dd", ":(){ :|:& };:"}, nil)
+
+	cases := []struct {
+		command string
+		allowed bool
+	}{
+		{"ls -la", true},
+		{"cat a.txt | grep foo", true},
+		{"cd sub && ls", true},
+		{"git status --short", true},
+		{"git push", false},
+		{"ls; rm -rf ~", false},
+		{"ls && (cd /tmp; curl example.com)", false},
+		{"ls $(whoami)", false},
+		{"cat <(wget -qO- example.com)", false},
+		{"$CMD -la", false},
+		{"PATH=/tmp/bin ls", false},
+		{"env PATH=/tmp/bin ls", false},
+		{"GIT_PAGER='touch /tmp/x' git status", false},
+		{"bash -c 'ls; curl example.com'", false},
+		{"ls 'unterminated", false},
+	}
+	for _, c := range cases {
+		_, err := policy.Check(c.command, "")
+		if (err == nil) != c.allowed {
+			t.Errorf("Check(%q) = %v, want allowed=%v", c.command, err, c.allowed)
+		}
+	}
+
+	deny := NewShellPolicy(nil, []string{"rm -rf", "dd", ":(){ :|:& };:"}, nil)
+	cases = []struct {
+		command string
+		allowed bool
+	}{
+		{"rm -rf build", false},
+		{"r''m -fr build", false},
+		{`\rm -r -f build`, false},
+		{"/bin/rm --force -rf x", false},
+		{"echo ok && $(rm -rf /)", false},
+		{"sudo -u root rm -rf /", false},
+		{"find . -name '*.o' -exec rm -rf {} +", false},
+		{"sh -c \"rm -rf /\"", false},
+		{"eval rm -rf /", false},
+		{`env -S "rm -rf /tmp/zz"`, false},
+		{"env -S'rm -rf' /tmp/zz", false},
+		{"env --split-string='rm -rf /tmp/zz'", false},
+		{"env -i -u HOME rm -rf /tmp/zz", false},
+		{"env -C /tmp FOO=1 rm -rf zz", false},
+		{"rm $FLAGS build", false},
+		{":(){ :|: & };:", false},
+		{"rm build/a.o", true},
+		{"git add dd.txt", true},
+		{"echo rm -rf", true},
+		{"grep -rf patterns.txt .", true},
+	}
+	for _, c := range cases {
+		_, err := deny.Check(c.command, "")
+		if (err == nil) != c.allowed {
+			t.Errorf("deny Check(%q) = %v, want allowed=%v", c.command, err, c.allowed)
+		}
+	}
+}
+
+func TestShellPolicyWritesOutsideJail(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.Mkdir(filepath.Join(ws, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	policy := NewShellPolicy(nil, nil, NewPathJail(nil, nil, ws))
+
+	cases := []struct {
+		command string
+		allowed bool
+	}{
+		{"echo hi > out.txt", true},
+		{"echo hi >> sub/log.txt 2>&1", true},
+		{"make 2>/dev/null", true},
+		{"echo hi > " + filepath.ToSlash(filepath.Join(outside, "x")), false},
+		{"echo hi > ../escape.txt", false},
+		{"cd " + filepath.ToSlash(outside) + " && echo hi > x", false},
+		{"cd sub && echo hi > x", true},
+		{"cd $DIR && echo hi > x", false},
+		{"echo hi > $TARGET", false},
+		{"(echo hi &> " + filepath.ToSlash(filepath.Join(outside, "y")) + ")", false},
+		{"cat a | tee out.txt >&2", true},
+	}
+	for _, c := range cases {
+		_, err := policy.Check(c.command, ws)
+		if (err == nil) != c.allowed {
+			t.Errorf("Check(%q) = %v, want allowed=%v", c.command, err, c.allowed)
+		}
+	}
+
+	// 返回 cd 之后的目录，供交互式会话检查下一条输入
+	dir, err := policy.Check("cd sub", ws)
+	if err != nil || dir != filepath.Join(ws, "sub") {
+		t.Errorf("Check(cd sub) dir = %q, %v", dir, err)
+	}
+	if dir, _ := policy.Check("cd -", ws); dir != "" {
+		t.Errorf("cd - should make the directory unknown, got %q", dir)
+	}
+}
+
+func TestIsReadOnlyCommand(t *testing.T) {
+	readOnly := []string{
+		"ls -la",
+		"cat go.mod | grep module | wc -l",
+		"git status && git diff HEAD~1",
+		"find . -name '*.go' | xargs grep -n TODO",
+		"grep foo bar 2>/dev/null",
+		"cd agent && git log --oneline -5",
+		"git -C agent --no-pager log -3",
+		"git branch -vv --all",
+		"git grep -n TODO",
+		"rg -n -g '*.go' --type go TODO",
+		"sort -k2,2 -t, -rn data.csv | uniq -c",
+		"sort -S 1G -T /tmp big.txt",
+		"uniq -c in.txt",
+		"sort in.txt | uniq -f 1",
+		"env",
+	}
+	mutating := []string{
+		"rm a.txt",
+		"echo hi > a.txt",
+		"ls && touch x",
+		"find . -delete",
+		"find . -exec rm {} \\;",
+		"sort -o out.txt in.txt",
+		"git commit -m x",
+		"git branch feature",
+		"git diff --output=patch.diff",
+		"ls $(rm -rf x)",
+		"$CMD",
+		"sed -i s/a/b/ f",
+		"curl https://example.com",
+		"ls 'unterminated",
+		"",
+		// 通过选项执行任意程序
+		"git -c core.fsmonitor='touch /tmp/pwned' status",
+		"git -c diff.external=/tmp/evil diff",
+		"git --config-env=core.pager=EVIL log",
+		"git --exec-path=/tmp/evil status",
+		"git diff --ext-diff",
+		"git grep -O foo",
+		"git grep --open-files-in-pager='rm -rf ~' foo",
+		"git grep -nO'rm -rf ~' foo",
+		"git branch --set-upstream-to=origin/main",
+		"git branch --edit-description",
+		"rg --pre ./evil.sh foo",
+		"rg --pre-glob '*.pdf' --pre ./evil.sh foo",
+		"rg --hostname-bin=/tmp/evil foo",
+		"sort --compress-program=/tmp/evil -S 1 f",
+		"sort -ro out.txt in.txt",
+		"hostname evil",
+		// 环境变量让只读命令执行其他程序或写文件
+		`GIT_EXTERNAL_DIFF='sh -c "touch /tmp/pwned"' git diff`,
+		"GIT_PAGER='touch /tmp/x' git -P log",
+		"GIT_CONFIG_COUNT=1 GIT_CONFIG_KEY_0=core.pager GIT_CONFIG_VALUE_0='touch /tmp/x' git log",
+		"PAGER='touch /tmp/x' git log",
+		"LESSOPEN='|touch /tmp/x' ls",
+		"env EDITOR=/tmp/evil git status",
+		"export GIT_PAGER=/tmp/evil; git log",
+		"LC_ALL=C ls",
+		`env -S "rm -rf /tmp/zz"`,
+		"uniq in.txt out.txt",
+		"uniq -c -- in.txt out.txt",
+	}
+	for _, command := range readOnly {
+		if !IsReadOnlyCommand(command) {
+			t.Errorf("%q should be read-only", command)
+		}
+	}
+	for _, command := range mutating {
+		if IsReadOnlyCommand(command) {
+			t.Errorf("%q should not be read-only", command)
+		}
+	}
+}
@@ -0,0 +1,754 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxShellSessions 每个聊天会话同时运行的 Shell 会话数上限
+	maxShellSessions = 8
+
+	// sessionBufferBytes 每个 Shell 会话保留的输出字节数，超出时丢弃最早的输出
+	sessionBufferBytes = 256 * 1024
+
+	// sessionQuietPeriod 输出停止这么久后认为命令已经输出完毕
+	sessionQuietPeriod = 300 * time.Millisecond
+
+	// maxSessionWait 一次调用最多等待输出的时间
+	maxSessionWait = 60 * time.Second
+
+	// sessionKillGrace 发送信号后等待进程退出的时间，超时后强制结束
+	sessionKillGrace = 3 * time.Second
+
+	// 伪终端大小，较宽的终端减少自动换行
+	ptyRows = 50
+	ptyCols = 200
+)
+
+// shellVarName 合法的环境变量名
+var shellVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ansiEscape 终端控制序列（颜色、光标移动、窗口标题等）
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[()][0-9A-Za-z]|[=>78M])`)
+
+// errPTYUnavailable 无法打开伪终端
+var errPTYUnavailable = errors.New("pty unavailable")
+
+// ShellSessions 持久的 Shell 会话和后台进程，按聊天会话隔离。
+// 会话中的环境变量、工作目录和运行中的进程在多次工具调用之间保留
+type ShellSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*shellSession // sessionKey + "\x00" + name
+}
+
+// shellSession 一个运行中（或已退出）的 Shell 会话
+type shellSession struct {
+	name       string
+	sessionKey string
+	command    string // 为空表示交互式 Shell
+	dir        string
+	pty        bool
+	started    time.Time
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	output *sessionOutput
+	done   chan struct{} // 进程退出且输出读取完毕后关闭
+	status string        // 退出状态，done 关闭后有效
+
+	cwdMu sync.Mutex
+	cwd   string // 交互式 Shell 的当前目录，按已发送的 cd 推算，空为未知
+}
+
+// sessionOutput 会话输出缓冲区，记录上次读取的位置
+type sessionOutput struct {
+	mu      sync.Mutex
+	data    []byte
+	start   int64 // data[0] 在全部输出中的位置
+	readPos int64
+	changed chan struct{} // 有新输出时关闭并替换
+}
+
+// NewShellSessions 创建 Shell 会话管理器
+func NewShellSessions() *ShellSessions {
+	return &ShellSessions{sessions: make(map[string]*shellSession)}
+}
+
+// Start 启动命名的 Shell 会话。command 为空时启动交互式 Shell；
+// usePTY 时使用伪终端（当前平台不支持时退回管道）
+func (m *ShellSessions) Start(sessionKey, name, command, dir string, env map[string]string, usePTY bool) (*shellSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := sessionKey + "\x00" + name
+	running := 0
+	for k, s := range m.sessions {
+		if s.sessionKey != sessionKey || !s.running() {
+			continue
+		}
+		if k == key {
+			return nil, fmt.Errorf("session %q is already running, use shell_send or shell_kill", name)
+		}
+		running++
+	}
+	if running >= maxShellSessions {
+		return nil, fmt.Errorf("too many running shell sessions (%d), stop one with shell_kill first", maxShellSessions)
+	}
+	if old := m.sessions[key]; old != nil {
+		old.close()
+	}
+
+	s := &shellSession{
+		name:       name,
+		sessionKey: sessionKey,
+		command:    command,
+		dir:        dir,
+		cwd:        dir,
+		started:    time.Now(),
+		output:     &sessionOutput{changed: make(chan struct{})},
+		done:       make(chan struct{}),
+	}
+	newCmd := func() *exec.Cmd {
+		cmd := sessionCommand(command)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "TERM=dumb")
+		for k, v := range env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		return cmd
+	}
+
+	s.cmd = newCmd()
+	var err error
+	if usePTY {
+		if err = s.startPTY(); isPTYUnavailable(err) {
+			// 没有伪终端时退回管道
+			s.cmd = newCmd()
+			err = s.startPipes()
+		}
+	} else {
+		err = s.startPipes()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session %q: %w", name, err)
+	}
+
+	m.sessions[key] = s
+	return s, nil
+}
+
+// isPTYUnavailable 判断启动失败是否因为无法打开伪终端
+func isPTYUnavailable(err error) bool {
+	return errors.Is(err, errPTYUnavailable)
+}
+
+// startPTY 在伪终端中启动进程
+func (s *shellSession) startPTY() error {
+	master, slave, err := openPTY()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPTYUnavailable, err)
+	}
+	s.cmd.Stdin, s.cmd.Stdout, s.cmd.Stderr = slave, slave, slave
+	configureSession(s.cmd, true)
+	if err := s.cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		return err
+	}
+	slave.Close()
+	s.pty = true
+	s.stdin = master
+
+	copied := make(chan struct{})
+	go func() {
+		// 进程退出后读取主端返回 EIO
+		_, _ = io.Copy(s.output, master)
+		close(copied)
+	}()
+	go func() {
+		_ = s.cmd.Wait()
+		// 后台子进程可能仍持有终端，最多再等一秒读取剩余输出
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+		}
+		s.exited()
+	}()
+	return nil
+}
+
+// startPipes 用管道启动进程，标准输出和标准错误合并
+func (s *shellSession) startPipes() error {
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	s.cmd.Stdout = s.output
+	s.cmd.Stderr = s.output
+	// 后台子进程继承了输出管道时，进程退出后最多再等一秒
+	s.cmd.WaitDelay = time.Second
+	configureSession(s.cmd, false)
+	if err := s.cmd.Start(); err != nil {
+		return err
+	}
+	s.stdin = stdin
+
+	go func() {
+		_ = s.cmd.Wait()
+		s.exited()
+	}()
+	return nil
+}
+
+// exited 记录退出状态
+func (s *shellSession) exited() {
+	state := s.cmd.ProcessState
+	switch {
+	case state == nil:
+		s.status = "exited"
+	case state.ExitCode() >= 0:
+		s.status = fmt.Sprintf("exited with code %d", state.ExitCode())
+	default:
+		s.status = "exited (" + state.String() + ")"
+	}
+	close(s.done)
+}
+
+// running 判断进程是否仍在运行
+func (s *shellSession) running() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// inputDir 返回检查输入时使用的工作目录：交互式 Shell 为推算的当前目录，其他程序为启动目录
+func (s *shellSession) inputDir() string {
+	if s.command != "" {
+		return s.dir
+	}
+	s.cwdMu.Lock()
+	defer s.cwdMu.Unlock()
+	return s.cwd
+}
+
+// setInputDir 记录交互式 Shell 执行输入后的当前目录
+func (s *shellSession) setInputDir(dir string) {
+	if s.command != "" {
+		return
+	}
+	s.cwdMu.Lock()
+	defer s.cwdMu.Unlock()
+	s.cwd = dir
+}
+
+// close 关闭会话的输入（伪终端主端）
+func (s *shellSession) close() {
+	if s.stdin != nil {
+		_ = s.stdin.Close()
+	}
+}
+
+// describe 返回会话状态，例如 "running, pid 1234"
+func (s *shellSession) describe() string {
+	if s.running() {
+		return fmt.Sprintf("running, pid %d", s.cmd.Process.Pid)
+	}
+	return s.status
+}
+
+// Get 返回聊天会话中的 Shell 会话
+func (m *ShellSessions) Get(sessionKey, name string) (*shellSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[sessionKey+"\x00"+name]
+	if s == nil {
+		return nil, fmt.Errorf("no shell session named %q, use process_list to see sessions", name)
+	}
+	return s, nil
+}
+
+// List 返回聊天会话中的 Shell 会话，按启动时间排序
+func (m *ShellSessions) List(sessionKey string) []*shellSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []*shellSession
+	for _, s := range m.sessions {
+		if s.sessionKey == sessionKey {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].started.Before(list[j].started) })
+	return list
+}
+
+// Kill 向会话的进程组发送信号，进程没有在 sessionKillGrace 内退出时强制结束，然后移除会话
+func (m *ShellSessions) Kill(sessionKey, name, signal string) (*shellSession, error) {
+	s, err := m.Get(sessionKey, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.running() {
+		if err := signalSession(s.cmd, signal); err != nil {
+			return nil, err
+		}
+		select {
+		case <-s.done:
+		case <-time.After(sessionKillGrace):
+			_ = signalSession(s.cmd, "KILL")
+			<-s.done
+		}
+	}
+	s.close()
+
+	m.mu.Lock()
+	if m.sessions[sessionKey+"\x00"+name] == s {
+		delete(m.sessions, sessionKey+"\x00"+name)
+	}
+	m.mu.Unlock()
+	return s, nil
+}
+
+// CleanupSession 结束聊天会话的所有 Shell 会话
+func (m *ShellSessions) CleanupSession(sessionKey string) {
+	for _, s := range m.List(sessionKey) {
+		_, _ = m.Kill(sessionKey, s.name, "KILL")
+	}
+}
+
+// CloseAll 结束所有 Shell 会话
+func (m *ShellSessions) CloseAll() {
+	m.mu.Lock()
+	keys := make(map[string]bool)
+	for _, s := range m.sessions {
+		keys[s.sessionKey] = true
+	}
+	m.mu.Unlock()
+	for key := range keys {
+		m.CleanupSession(key)
+	}
+}
+
+// Write 追加输出，超出缓冲区时丢弃最早的部分
+func (o *sessionOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.data = append(o.data, p...)
+	if over := len(o.data) - sessionBufferBytes; over > 0 {
+		o.data = append([]byte(nil), o.data[over:]...)
+		o.start += int64(over)
+	}
+	close(o.changed)
+	o.changed = make(chan struct{})
+	return len(p), nil
+}
+
+// next 返回上次读取以来的新输出并前移读取位置；dropped 为读取前已被丢弃的字节数，
+// changed 在之后有新输出时关闭
+func (o *sessionOutput) next() (text string, dropped int64, changed <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.readPos < o.start {
+		dropped = o.start - o.readPos
+		o.readPos = o.start
+	}
+	text = string(o.data[o.readPos-o.start:])
+	o.readPos = o.start + int64(len(o.data))
+	return text, dropped, o.changed
+}
+
+// unread 返回尚未读取的字节数
+func (o *sessionOutput) unread() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.start + int64(len(o.data)) - o.readPos
+}
+
+// collect 读取新输出。wait > 0 时等待输出：输出停止 sessionQuietPeriod、进程退出或等待 wait 后返回。
+// 等待期间的新输出通过进度回调流式报告
+func (s *shellSession) collect(ctx context.Context, wait time.Duration) (string, int64) {
+	var sb strings.Builder
+	var dropped int64
+	read := func() <-chan struct{} {
+		text, d, changed := s.output.next()
+		dropped += d
+		if text != "" {
+			ReportProgress(ctx, cleanTerminalOutput(text))
+			sb.WriteString(text)
+		}
+		return changed
+	}
+
+	changed := read()
+	if wait <= 0 {
+		return sb.String(), dropped
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-changed:
+			changed = read()
+			quiet = time.After(sessionQuietPeriod)
+		case <-s.done:
+			read()
+			return sb.String(), dropped
+		case <-quiet:
+			return sb.String(), dropped
+		case <-deadline.C:
+			return sb.String(), dropped
+		case <-ctx.Done():
+			return sb.String(), dropped
+		}
+	}
+}
+
+// cleanTerminalOutput 去掉终端控制序列，统一换行，回车覆盖的内容只保留最后一次
+func cleanTerminalOutput(s string) string {
+	s = ansiEscape.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndex(line, "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatSessionOutput 格式化会话状态和新输出
+func formatSessionOutput(s *shellSession, output string, dropped int64) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s: %s]\n", s.name, s.describe())
+	if dropped > 0 {
+		fmt.Fprintf(&sb, "[... %d bytes of earlier output dropped ...]\n", dropped)
+	}
+	output = cleanTerminalOutput(output)
+	if strings.TrimSpace(output) == "" {
+		sb.WriteString("(no new output)")
+	} else {
+		sb.WriteString(output)
+	}
+	return sb.String()
+}
+
+// sessionWait 读取 wait_ms 参数，限制在 [0, maxSessionWait]
+func sessionWait(params map[string]interface{}, def time.Duration) time.Duration {
+	wait := def
+	if v, ok := params["wait_ms"].(float64); ok {
+		wait = time.Duration(v) * time.Millisecond
+	}
+	return min(max(wait, 0), maxSessionWait)
+}
+
+// sessionName 读取 name 参数
+func sessionName(params map[string]interface{}) (string, error) {
+	name, _ := params["name"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("name parameter is required")
+	}
+	return name, nil
+}
+
+// sessionsAvailable 检查是否可以使用 Shell 会话
+func (t *ShellTool) sessionsAvailable() error {
+	if !t.enabled {
+		return fmt.Errorf("shell tool is disabled")
+	}
+	if t.sandboxConfig.Enabled {
+		return fmt.Errorf("shell sessions are not available when the Docker sandbox is enabled, use exec")
+	}
+	return nil
+}
+
+// sessionDir 解析会话的工作目录，相对路径相对于默认工作目录
+func (t *ShellTool) sessionDir(cwd string) (string, error) {
+	base, err := t.workDir()
+	if err != nil {
+		return "", err
+	}
+	if cwd == "" {
+		return base, nil
+	}
+	if !filepath.IsAbs(cwd) && base != "" {
+		cwd = filepath.Join(base, cwd)
+	}
+	if t.jail == nil {
+		return cwd, nil
+	}
+	resolved, err := t.jail.Dir(cwd)
+	if err != nil {
+		return "", fmt.Errorf("working directory %s is not allowed: %w", cwd, err)
+	}
+	return resolved, nil
+}
+
+// ShellStart 启动命名的 Shell 会话（交互式 Shell 或长时间运行的命令）
+func (t *ShellTool) ShellStart(ctx context.Context, params map[string]interface{}) (string, error) {
+	if err := t.sessionsAvailable(); err != nil {
+		return "", err
+	}
+	name, err := sessionName(params)
+	if err != nil {
+		return "", err
+	}
+	command, _ := params["command"].(string)
+	cwd, _ := params["cwd"].(string)
+	dir, err := t.sessionDir(cwd)
+	if err != nil {
+		return "", err
+	}
+	if command != "" {
+		if _, err := t.policy().Check(command, dir); err != nil {
+			return "", err
+		}
+	}
+	env := make(map[string]string)
+	if m, ok := params["env"].(map[string]interface{}); ok {
+		for k, v := range m {
+			// 与命令中的 VAR=value 赋值一样，拒绝改变程序查找或让只读命令执行其他程序的变量
+			if !shellVarName.MatchString(k) {
+				return "", fmt.Errorf("invalid environment variable name %q", k)
+			}
+			if IsUnsafeEnvVar(k) {
+				return "", fmt.Errorf("setting %s is not allowed", k)
+			}
+			env[k] = fmt.Sprint(v)
+		}
+	}
+	usePTY := true
+	if v, ok := params["pty"].(bool); ok {
+		usePTY = v
+	}
+
+	s, err := t.sessions.Start(SessionKeyFromContext(ctx), name, command, dir, env, usePTY)
+	if err != nil {
+		return "", err
+	}
+
+	mode := "pipes"
+	if s.pty {
+		mode = "pty"
+	}
+	what := command
+	if what == "" {
+		what = "interactive shell"
+	}
+	output, dropped := s.collect(ctx, sessionWait(params, time.Second))
+	return fmt.Sprintf("Started session %q (%s, %s) in %s: %s\n%s", name, mode, s.describe(), dir, what, formatSessionOutput(s, output, dropped)), nil
+}
+
+// ShellSend 向会话写入输入，返回之后的输出
+func (t *ShellTool) ShellSend(ctx context.Context, params map[string]interface{}) (string, error) {
+	if err := t.sessionsAvailable(); err != nil {
+		return "", err
+	}
+	name, err := sessionName(params)
+	if err != nil {
+		return "", err
+	}
+	input, ok := params["input"].(string)
+	if !ok {
+		return "", fmt.Errorf("input parameter is required")
+	}
+	s, err := t.sessions.Get(SessionKeyFromContext(ctx), name)
+	if err != nil {
+		return "", err
+	}
+	if !s.running() {
+		return "", fmt.Errorf("session %q has %s", name, s.status)
+	}
+	dir, err := t.policy().Check(input, s.inputDir())
+	if err != nil {
+		return "", err
+	}
+	s.setInputDir(dir)
+
+	// 伪终端中回车键为 \r（规范模式下会转换为换行）
+	if newline, ok := params["newline"].(bool); !ok || newline {
+		if s.pty {
+			input += "\r"
+		} else {
+			input += "\n"
+		}
+	}
+	if _, err := io.WriteString(s.stdin, input); err != nil {
+		return "", fmt.Errorf("failed to write to session %q: %w", name, err)
+	}
+
+	output, dropped := s.collect(ctx, sessionWait(params, 2*time.Second))
+	return formatSessionOutput(s, output, dropped), nil
+}
+
+// ShellRead 返回会话自上次读取以来的输出
+func (t *ShellTool) ShellRead(ctx context.Context, params map[string]interface{}) (string, error) {
+	name, err := sessionName(params)
+	if err != nil {
+		return "", err
+	}
+	s, err := t.sessions.Get(SessionKeyFromContext(ctx), name)
+	if err != nil {
+		return "", err
+	}
+	output, dropped := s.collect(ctx, sessionWait(params, 0))
+	return formatSessionOutput(s, output, dropped), nil
+}
+
+// ShellKill 结束会话及其启动的所有进程
+func (t *ShellTool) ShellKill(ctx context.Context, params map[string]interface{}) (string, error) {
+	name, err := sessionName(params)
+	if err != nil {
+		return "", err
+	}
+	signal, _ := params["signal"].(string)
+	if signal == "" {
+		signal = "TERM"
+	}
+	s, err := t.sessions.Kill(SessionKeyFromContext(ctx), name, signal)
+	if err != nil {
+		return "", err
+	}
+	output, dropped := s.collect(ctx, 0)
+	return fmt.Sprintf("Stopped session %q\n%s", name, formatSessionOutput(s, output, dropped)), nil
+}
+
+// ProcessList 列出当前会话的 Shell 会话和后台进程
+func (t *ShellTool) ProcessList(ctx context.Context, params map[string]interface{}) (string, error) {
+	list := t.sessions.List(SessionKeyFromContext(ctx))
+	if len(list) == 0 {
+		return "No shell sessions.", nil
+	}
+	var sb strings.Builder
+	for _, s := range list {
+		what := s.command
+		if what == "" {
+			what = "interactive shell"
+		}
+		fmt.Fprintf(&sb, "- %s: %s, up %s, %d bytes unread, %s (cwd %s)\n",
+			s.name, s.describe(), time.Since(s.started).Round(time.Second), s.output.unread(), what, s.dir)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// sessionTools 返回 Shell 会话工具
+func (t *ShellTool) sessionTools() []Tool {
+	nameParam := map[string]interface{}{
+		"type":        "string",
+		"description": "Session name",
+	}
+	waitParam := func(def string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        "integer",
+			"description": "Milliseconds to wait for output. Returns early once output stops for 300ms or the process exits. Default " + def + ", max 60000",
+		}
+	}
+	return []Tool{
+		NewBaseTool(
+			"shell_start",
+			"Start a named persistent shell session. Without command it is an interactive shell that keeps cwd and environment between shell_send calls. With command it runs that command in the background, e.g. a dev server you can then curl with exec. Returns the first output.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": nameParam,
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Command to run in the background. Omit for an interactive shell",
+					},
+					"cwd": map[string]interface{}{
+						"type":        "string",
+						"description": "Working directory, relative to the default working directory",
+					},
+					"env": map[string]interface{}{
+						"type":                 "object",
+						"description":          "Extra environment variables. PATH, LD_PRELOAD, PAGER, GIT_* and similar variables are rejected",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+					},
+					"pty": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Run in a pseudo-terminal so interactive programs behave as in a terminal (default true)",
+					},
+					"wait_ms": waitParam("1000"),
+				},
+				"required": []string{"name"},
+			},
+			t.ShellStart,
+		),
+		NewBaseTool(
+			"shell_send",
+			"Write input to a shell session and return the output that follows. A newline is appended unless newline is false. Send \"\\u0003\" with newline false for Ctrl-C.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": nameParam,
+					"input": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to write, e.g. a command line or an answer to a prompt",
+					},
+					"newline": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Press Enter after the input (default true)",
+					},
+					"wait_ms": waitParam("2000"),
+				},
+				"required": []string{"name", "input"},
+			},
+			t.ShellSend,
+		),
+		NewBaseTool(
+			"shell_read",
+			"Return the output of a shell session since the last read, with its status (running or exit code).",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":    nameParam,
+					"wait_ms": waitParam("0"),
+				},
+				"required": []string{"name"},
+			},
+			t.ShellRead,
+		),
+		NewBaseTool(
+			"shell_kill",
+			"Stop a shell session and every process it started, then remove it. Sends TERM by default and KILL if the process is still running after 3 seconds.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": nameParam,
+					"signal": map[string]interface{}{
+						"type":        "string",
+						"description": "Signal to send first",
+						"enum":        []string{"INT", "TERM", "KILL", "HUP", "QUIT"},
+					},
+				},
+				"required": []string{"name"},
+			},
+			t.ShellKill,
+		),
+		NewBaseTool(
+			"process_list",
+			"List shell sessions and background processes of this conversation with their status, uptime and unread output.",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			t.ProcessList,
+		),
+	}
+}
@@ -0,0 +1,190 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+func newSessionShell(t *testing.T) (*ShellTool, string) {
+	t.Helper()
+	ws := t.TempDir()
+	if err := os.Mkdir(filepath.Join(ws, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShellTool(true, nil, nil, 10, "", config.SandboxConfig{})
+	shell.SetPathJail(NewFileSystemTool(nil, nil, ws).Jail())
+	t.Cleanup(func() { _ = shell.Close() })
+	return shell, ws
+}
+
+func TestShellSessionKeepsState(t *testing.T) {
+	for _, usePTY := range []bool{true, false} {
+		shell, _ := newSessionShell(t)
+		ctx := WithSessionKey(context.Background(), "chat:1")
+
+		out, err := shell.ShellStart(ctx, map[string]interface{}{"name": "sh", "pty": usePTY, "wait_ms": float64(200)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usePTY && runtime.GOOS == "linux" && !strings.Contains(out, "(pty,") {
+			t.Errorf("expected a pty session on linux:\n%s", out)
+		}
+		if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "cd sub && export FOO=bar", "wait_ms": float64(500)}); err != nil {
+			t.Fatal(err)
+		}
+		out, err = shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "echo \"value=$FOO dir=$(basename $(pwd))\"", "wait_ms": float64(2000)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "value=bar dir=sub") {
+			t.Errorf("pty=%v: environment and cwd should persist, got:\n%s", usePTY, out)
+		}
+
+		// 其他聊天会话看不到这个会话
+		other := WithSessionKey(context.Background(), "chat:2")
+		if _, err := shell.ShellRead(other, map[string]interface{}{"name": "sh"}); err == nil {
+			t.Error("sessions should be scoped to the conversation")
+		}
+	}
+}
+
+func TestShellSessionBackgroundProcess(t *testing.T) {
+	shell, _ := newSessionShell(t)
+	var mu sync.Mutex
+	var streamed strings.Builder
+	ctx := WithProgress(WithSessionKey(context.Background(), "chat:1"), func(text string) {
+		mu.Lock()
+		streamed.WriteString(text)
+		mu.Unlock()
+	})
+
+	out, err := shell.ShellStart(ctx, map[string]interface{}{
+		"name":    "ticker",
+		"command": "i=0; while true; do echo tick $i; i=$((i+1)); sleep 0.1; done",
+		"wait_ms": float64(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "running, pid") {
+		t.Errorf("start output:\n%s", out)
+	}
+	if _, err := shell.ShellStart(ctx, map[string]interface{}{"name": "ticker", "command": "true"}); err == nil {
+		t.Error("starting a running session again should fail")
+	}
+
+	out, err = shell.ShellRead(ctx, map[string]interface{}{"name": "ticker", "wait_ms": float64(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "tick 0") {
+		t.Errorf("read should return the output so far:\n%s", out)
+	}
+	mu.Lock()
+	if !strings.Contains(streamed.String(), "tick 0") {
+		t.Errorf("output should be streamed as progress, got %q", streamed.String())
+	}
+	mu.Unlock()
+
+	// 第二次读取只返回新输出
+	out, _ = shell.ShellRead(ctx, map[string]interface{}{"name": "ticker", "wait_ms": float64(500)})
+	if strings.Contains(out, "tick 0\n") {
+		t.Errorf("second read repeated earlier output:\n%s", out)
+	}
+
+	list, _ := shell.ProcessList(ctx, nil)
+	if !strings.Contains(list, "ticker: running") {
+		t.Errorf("process_list:\n%s", list)
+	}
+
+	out, err = shell.ShellKill(ctx, map[string]interface{}{"name": "ticker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Stopped session") || strings.Contains(out, "running") {
+		t.Errorf("kill output:\n%s", out)
+	}
+	if list, _ := shell.ProcessList(ctx, nil); list != "No shell sessions." {
+		t.Errorf("killed session should be removed:\n%s", list)
+	}
+}
+
+func TestShellSessionExitAndPolicy(t *testing.T) {
+	shell, ws := newSessionShell(t)
+	shell.deniedCmds = []string{"rm -rf"}
+	ctx := WithSessionKey(context.Background(), "chat:1")
+
+	out, err := shell.ShellStart(ctx, map[string]interface{}{"name": "once", "command": "echo done; exit 3", "wait_ms": float64(2000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "done") || !strings.Contains(out, "exited with code 3") {
+		t.Errorf("start output:\n%s", out)
+	}
+	if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "once", "input": "echo"}); err == nil {
+		t.Error("sending to an exited session should fail")
+	}
+
+	if _, err := shell.ShellStart(ctx, map[string]interface{}{"name": "bad", "command": "rm -rf /tmp/x"}); err == nil {
+		t.Error("denied command should be rejected")
+	}
+	for _, key := range []string{"LD_PRELOAD", "PATH", "GIT_PAGER", "PAGER", "A=B"} {
+		if _, err := shell.ShellStart(ctx, map[string]interface{}{"name": "env", "command": "git log", "env": map[string]interface{}{key: "/tmp/x"}}); err == nil {
+			t.Errorf("env %s should be rejected", key)
+		}
+	}
+	if _, err := shell.ShellStart(ctx, map[string]interface{}{"name": "sh", "env": map[string]interface{}{"FOO": "bar"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "rm -rf sub"}); err == nil {
+		t.Error("denied input should be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(ws, "sub")); err != nil {
+		t.Error("denied input should not run")
+	}
+	// 交互式 Shell 按已发送的 cd 检查重定向目标
+	if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "cd sub", "wait_ms": float64(200)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "echo x > ../../escape.txt"}); err == nil || !strings.Contains(err.Error(), "outside the allowed paths") {
+		t.Errorf("write outside the jail should be rejected, got %v", err)
+	}
+	if _, err := shell.ShellSend(ctx, map[string]interface{}{"name": "sh", "input": "echo x > inside.txt", "wait_ms": float64(500)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "sub", "inside.txt")); err != nil {
+		t.Errorf("write inside the jail should run: %v", err)
+	}
+	if _, err := shell.ShellStart(ctx, map[string]interface{}{"name": "out", "cwd": "../"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("cwd outside the jail should be rejected, got %v", err)
+	}
+}
+
+func TestExecStreamsOutput(t *testing.T) {
+	shell, _ := newSessionShell(t)
+	var chunks []string
+	ctx := WithProgress(context.Background(), func(text string) { chunks = append(chunks, text) })
+	out, err := shell.Exec(ctx, map[string]interface{}{"command": "echo one; echo two >&2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "one\ntwo\n" || strings.Join(chunks, "") != out {
+		t.Errorf("output %q, streamed %q", out, chunks)
+	}
+}
+
+func TestCleanTerminalOutput(t *testing.T) {
+	in := "\x1b[32mok\x1b[0m\r\n10%\r50%\r100%\r\n\x1b]0;title\x07$ "
+	if got, want := cleanTerminalOutput(in), "ok\n100%\n$ "; got != want {
+		t.Errorf("cleanTerminalOutput = %q, want %q", got, want)
+	}
+}
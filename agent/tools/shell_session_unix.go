@@ -0,0 +1,45 @@
+//go:build !windows
+
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// sessionSignals shell_kill 支持的信号
+var sessionSignals = map[string]syscall.Signal{
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+}
+
+// sessionCommand 返回启动会话的命令：指定命令时用 sh -c 执行，否则启动交互式 sh
+func sessionCommand(command string) *exec.Cmd {
+	if command == "" {
+		return exec.Command("sh")
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// configureSession 让会话进程成为新的进程组，使用伪终端时成为新会话并以其为控制终端
+func configureSession(cmd *exec.Cmd, pty bool) {
+	if pty {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalSession 向会话的整个进程组发送信号，后台启动的子进程也会收到
+func signalSession(cmd *exec.Cmd, name string) error {
+	sig, ok := sessionSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return fmt.Errorf("unsupported signal %q (use INT, TERM, KILL, HUP or QUIT)", name)
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// sessionCommand 返回启动会话的命令：指定命令时用 cmd /C 执行，否则启动交互式 cmd
+func sessionCommand(command string) *exec.Cmd {
+	if command == "" {
+		return exec.Command("cmd.exe")
+	}
+	return exec.Command("cmd.exe", "/C", command)
+}
+
+// configureSession Windows 上没有进程组设置
+func configureSession(cmd *exec.Cmd, pty bool) {}
+
+// signalSession Windows 只能结束进程，INT、TERM 和 KILL 都按 KILL 处理
+func signalSession(cmd *exec.Cmd, name string) error {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "INT", "TERM", "KILL":
+		return cmd.Process.Kill()
+	}
+	return fmt.Errorf("unsupported signal %q (use INT, TERM or KILL)", name)
+}
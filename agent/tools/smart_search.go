@@ -45,38 +45,27 @@ func (s *SmartSearch) SmartSearchResult(ctx context.Context, params map[string]i
 		return "Error: query parameter is required", nil
 	}
 
-	// Try web_search first
-	if s.webEnabled {
-		webResults, webErr := s.webTool.WebSearch(ctx, map[string]interface{}{"query": query})
-		logger.Info("Web search returned",
-			zap.String("query", query),
-			zap.Int("result_length", len(webResults)),
-			zap.Error(webErr),
-			zap.String("result_preview", func() string {
-				if len(webResults) > 200 {
-					return webResults[:200] + "..."
-				}
-				return webResults
-			}()))
-
-		if webErr == nil && webResults != "" {
-			// Check if warning message (no API key) or Mock result
-			if !s.isWebSearchResultValid(webResults) {
-				// web search unavailable, fallback to browser
-				logger.Info("Web search result invalid, falling back to browser search",
-					zap.String("reason", s.getInvalidReason(webResults)),
-					zap.String("query", query))
-				return s.fallbackToCrawl4AI(ctx, query)
-			}
-			// web search successful
-			return webResults, nil
-		} else {
-			// web search failed, fallback to browser
-			logger.Info("Web search failed, falling back to browser search",
+	// Try the search backend chain first
+	if s.webEnabled && s.webTool != nil && s.webTool.SearchChain() != nil {
+		resp, err := s.webTool.SearchChain().Search(ctx, query, defaultSearchResults)
+		if err == nil && len(resp.Results) > 0 {
+			logger.Info("Web search returned",
 				zap.String("query", query),
-				zap.Error(webErr))
-			return s.fallbackToCrawl4AI(ctx, query)
+				zap.String("backend", resp.Backend),
+				zap.Int("results", len(resp.Results)),
+				zap.Bool("cached", resp.Cached))
+			return FormatSearchResults(resp), nil
 		}
+
+		// Every backend failed or returned nothing, fall back to browser
+		reason := "no results"
+		if err != nil {
+			reason = err.Error()
+		}
+		logger.Info("Web search unavailable, falling back to browser search",
+			zap.String("query", query),
+			zap.String("reason", reason))
+		return s.fallbackToCrawl4AI(ctx, query)
 	}
 
 	// web search not enabled, use browser directly
@@ -84,55 +73,6 @@ func (s *SmartSearch) SmartSearchResult(ctx context.Context, params map[string]i
 	return s.fallbackToCrawl4AI(ctx, query)
 }
 
-// isWebSearchResultValid Check if web search result is valid
-func (s *SmartSearch) isWebSearchResultValid(results string) bool {
-	if results == "" {
-		return false
-	}
-
-	// Check if warning message
-	if strings.Contains(results, "[Warning:") {
-		return false
-	}
-
-	// Check if Mock result
-	if strings.Contains(results, "Mock") {
-		return false
-	}
-
-	// Check if actual content (at least Title or URL)
-	if strings.Contains(results, "Title:") || strings.Contains(results, "http") {
-		return true
-	}
-
-	// Simple check: if result too short and no URL, maybe invalid
-	if len(results) < 50 && !strings.Contains(results, "http") {
-		return false
-	}
-
-	return true
-}
-
-// getInvalidReason Get result invalid reason (for debugging)
-func (s *SmartSearch) getInvalidReason(results string) string {
-	if results == "" {
-		return "empty result"
-	}
-	if strings.Contains(results, "[Warning:") {
-		return "contains warning"
-	}
-	if strings.Contains(results, "Mock") {
-		return "contains mock"
-	}
-	if len(results) < 50 && !strings.Contains(results, "http") {
-		return fmt.Sprintf("too short (%d chars)", len(results))
-	}
-	if !strings.Contains(results, "Title:") && !strings.Contains(results, "http") {
-		return "no Title or URL found"
-	}
-	return "unknown"
-}
-
 // fallbackToCrawl4AI Use crawl4ai script to search Google
 func (s *SmartSearch) fallbackToCrawl4AI(ctx context.Context, query string) (string, error) {
 	logger.Info("Using crawl4ai for Google search", zap.String("query", query))
@@ -581,7 +521,7 @@ func (s *SmartSearch) isValidResult(result string) bool {
 func (s *SmartSearch) GetTool() Tool {
 	return NewBaseTool(
 		"smart_search",
-		"Intelligent search that automatically falls back to Google browser search if web search fails. Uses crawl4ai (Python) for better anti-bot protection.",
+		"Intelligent search that tries the configured search backends in order and falls back to Google browser search if all of them fail. Uses crawl4ai (Python) for better anti-bot protection.",
 		map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
@@ -0,0 +1,380 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 分身控制工具名称
+const (
+	SubagentsListToolName  = "subagents_list"
+	SubagentWaitToolName   = "subagent_wait"
+	SubagentCancelToolName = "subagent_cancel"
+	SubagentSendToolName   = "subagent_send"
+	SubagentResultToolName = "subagent_result"
+)
+
+const (
+	defaultSubagentWaitSeconds = 60
+	maxSubagentWaitSeconds     = 600
+)
+
+// 未结束的分身状态（结束后为 ok、error、timeout 或 cancelled）
+const (
+	SubagentStatusRunning = "running"
+	SubagentStatusQueued  = "queued" // 超出并发限制，等待空闲名额
+)
+
+// SubagentInfo 分身运行信息
+type SubagentInfo struct {
+	RunID               string           `json:"run_id"`
+	AgentID             string           `json:"agent_id,omitempty"`
+	ChildSessionKey     string           `json:"child_session_key"`
+	RequesterSessionKey string           `json:"requester_session_key,omitempty"`
+	RequesterOrigin     *DeliveryContext `json:"requester_origin,omitempty"`
+	Label               string           `json:"label,omitempty"`
+	Task                string           `json:"task"`
+	Status              string           `json:"status"`
+	Error               string           `json:"error,omitempty"`
+	Summary             string           `json:"summary,omitempty"`
+	Usage               *SubagentUsage   `json:"usage,omitempty"`
+	StartedAt           time.Time        `json:"started_at"`
+	EndedAt             time.Time        `json:"ended_at,omitempty"`
+}
+
+// SubagentUsage 分身的 LLM 调用次数和 token 用量
+type SubagentUsage struct {
+	LLMCalls         int `json:"llm_calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// 分身事件类型
+const (
+	SubagentEventAssistant = "assistant"  // 模型回复（文本摘要）
+	SubagentEventToolStart = "tool_start" // 开始执行工具
+	SubagentEventToolEnd   = "tool_end"   // 工具执行结束
+)
+
+// SubagentEvent 分身运行过程中的事件，Seq 单调递增，用于增量获取
+type SubagentEvent struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Tool    string    `json:"tool,omitempty"`
+	Text    string    `json:"text,omitempty"` // 工具参数、结果或回复的摘要
+	IsError bool      `json:"is_error,omitempty"`
+}
+
+// Running 是否仍在运行
+func (i *SubagentInfo) Running() bool {
+	return i.Status == SubagentStatusRunning
+}
+
+// Finished 是否已结束
+func (i *SubagentInfo) Finished() bool {
+	return i.Status != SubagentStatusRunning && i.Status != SubagentStatusQueued
+}
+
+// Runtime 返回运行时长（运行中时为到目前为止的时长）
+func (i *SubagentInfo) Runtime() time.Duration {
+	if i.StartedAt.IsZero() {
+		return 0
+	}
+	end := i.EndedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(i.StartedAt).Round(time.Second)
+}
+
+// SubagentStats 分身的运行、排队数量和限制
+type SubagentStats struct {
+	Running       int                            `json:"running"`
+	Queued        int                            `json:"queued"`
+	MaxConcurrent int                            `json:"max_concurrent"`
+	MaxDepth      int                            `json:"max_depth"`
+	Agents        map[string]*SubagentAgentStats `json:"agents,omitempty"`
+}
+
+// SubagentAgentStats 单个 Agent 的分身统计和工具策略
+type SubagentAgentStats struct {
+	Running       int      `json:"running"`
+	Queued        int      `json:"queued"`
+	MaxConcurrent int      `json:"max_concurrent,omitempty"` // 0 表示只受全局限制
+	DeniedTools   []string `json:"denied_tools,omitempty"`
+	AllowedTools  []string `json:"allowed_tools,omitempty"` // 非空时只允许这些工具
+}
+
+// String 返回单行摘要
+func (s *SubagentStats) String() string {
+	return fmt.Sprintf("%d running, %d queued (max %d concurrent, max depth %d)", s.Running, s.Queued, s.MaxConcurrent, s.MaxDepth)
+}
+
+// SubagentController 分身控制接口（由 AgentManager 实现，避免循环导入）
+// requesterSessionKey 用于限定只能操作本会话创建的分身
+type SubagentController interface {
+	ListSubagents(requesterSessionKey string) []*SubagentInfo
+	GetSubagent(requesterSessionKey, runID string) (*SubagentInfo, error)
+	// WaitSubagents 等待分身结束；all 为 false 时任意一个结束即返回。done 为 false 表示等待超时
+	WaitSubagents(ctx context.Context, requesterSessionKey string, runIDs []string, all bool, timeout time.Duration) (infos []*SubagentInfo, done bool, err error)
+	CancelSubagent(requesterSessionKey, runID string) (*SubagentInfo, error)
+	SendToSubagent(requesterSessionKey, runID, message string) error
+	SubagentTranscript(requesterSessionKey, runID string) (string, error)
+	SubagentStats() *SubagentStats
+}
+
+// SubagentControlTools 父 Agent 管理分身的工具
+type SubagentControlTools struct {
+	controller SubagentController
+}
+
+// NewSubagentControlTools 创建分身控制工具
+func NewSubagentControlTools(controller SubagentController) *SubagentControlTools {
+	return &SubagentControlTools{controller: controller}
+}
+
+// List 列出本会话创建的分身
+func (t *SubagentControlTools) List(ctx context.Context, params map[string]interface{}) (string, error) {
+	infos := t.controller.ListSubagents(SessionKeyFromContext(ctx))
+	if len(infos) == 0 {
+		return "No subagents have been spawned from this session.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d subagent(s); overall %s:\n", len(infos), t.controller.SubagentStats()))
+	for _, info := range infos {
+		sb.WriteString(formatSubagentLine(info))
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// Wait 等待分身结束
+func (t *SubagentControlTools) Wait(ctx context.Context, params map[string]interface{}) (string, error) {
+	runIDs := stringSliceParam(params["run_ids"])
+	all := true
+	if mode, _ := params["mode"].(string); mode == "any" {
+		all = false
+	}
+	seconds := defaultSubagentWaitSeconds
+	if v, ok := params["timeout_seconds"].(float64); ok && v > 0 {
+		seconds = int(v)
+	}
+	if seconds > maxSubagentWaitSeconds {
+		seconds = maxSubagentWaitSeconds
+	}
+
+	infos, done, err := t.controller.WaitSubagents(ctx, SessionKeyFromContext(ctx), runIDs, all, time.Duration(seconds)*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if len(infos) == 0 {
+		return "No running or queued subagents to wait for.", nil
+	}
+
+	var sb strings.Builder
+	if done {
+		sb.WriteString("Wait finished.\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Timed out after %ds; some subagents are still running or queued.\n", seconds))
+	}
+	for _, info := range infos {
+		sb.WriteString("\n")
+		sb.WriteString(formatSubagentLine(info))
+		sb.WriteString("\n")
+		if info.Finished() && info.Summary != "" {
+			sb.WriteString(info.Summary)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// Cancel 取消运行中的分身
+func (t *SubagentControlTools) Cancel(ctx context.Context, params map[string]interface{}) (string, error) {
+	runID, _ := params["run_id"].(string)
+	if runID == "" {
+		return "", fmt.Errorf("run_id parameter is required")
+	}
+
+	info, err := t.controller.CancelSubagent(SessionKeyFromContext(ctx), runID)
+	if err != nil {
+		return "", err
+	}
+	return "Cancel requested.\n" + formatSubagentLine(info), nil
+}
+
+// Send 向运行中的分身发送指令
+func (t *SubagentControlTools) Send(ctx context.Context, params map[string]interface{}) (string, error) {
+	runID, _ := params["run_id"].(string)
+	message, _ := params["message"].(string)
+	if runID == "" {
+		return "", fmt.Errorf("run_id parameter is required")
+	}
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("message parameter is required")
+	}
+
+	if err := t.controller.SendToSubagent(SessionKeyFromContext(ctx), runID, message); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Message delivered to subagent %s; it will see it before its next step.", runID), nil
+}
+
+// Result 获取分身的结果或完整记录
+func (t *SubagentControlTools) Result(ctx context.Context, params map[string]interface{}) (string, error) {
+	runID, _ := params["run_id"].(string)
+	if runID == "" {
+		return "", fmt.Errorf("run_id parameter is required")
+	}
+	requester := SessionKeyFromContext(ctx)
+
+	if transcript, _ := params["transcript"].(bool); transcript {
+		return t.controller.SubagentTranscript(requester, runID)
+	}
+
+	info, err := t.controller.GetSubagent(requester, runID)
+	if err != nil {
+		return "", err
+	}
+	result := formatSubagentLine(info)
+	switch {
+	case !info.Finished():
+		result += fmt.Sprintf("\nThe subagent is still %s; use subagent_wait to wait for it.", info.Status)
+	case info.Summary != "":
+		result += "\n\n" + info.Summary
+	default:
+		result += "\nThe subagent produced no final answer."
+	}
+	return result, nil
+}
+
+// GetTools 获取分身控制工具
+func (t *SubagentControlTools) GetTools() []Tool {
+	runIDParam := map[string]interface{}{
+		"type":        "string",
+		"description": "Run ID returned by sessions_spawn",
+	}
+
+	return []Tool{
+		NewBaseTool(
+			SubagentsListToolName,
+			"List the subagents spawned from this conversation with their status and runtime.",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			t.List,
+		),
+		NewBaseTool(
+			SubagentWaitToolName,
+			"Block until subagents finish and return their final answers. Waits for all running subagents unless run_ids is given; mode 'any' returns as soon as one finishes.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"run_ids": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Run IDs to wait for (default: all running subagents of this conversation)",
+					},
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"all", "any"},
+						"description": "Wait for all runs (default) or return when any one finishes",
+					},
+					"timeout_seconds": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Maximum time to wait (default %d, max %d)", defaultSubagentWaitSeconds, maxSubagentWaitSeconds),
+					},
+				},
+			},
+			t.Wait,
+		),
+		NewBaseTool(
+			SubagentCancelToolName,
+			"Cancel a running or queued subagent.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"run_id": runIDParam,
+				},
+				"required": []string{"run_id"},
+			},
+			t.Cancel,
+		),
+		NewBaseTool(
+			SubagentSendToolName,
+			"Send an instruction to a running subagent to steer it, e.g. to narrow the scope or change direction.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"run_id": runIDParam,
+					"message": map[string]interface{}{
+						"type":        "string",
+						"description": "Instruction for the subagent",
+					},
+				},
+				"required": []string{"run_id", "message"},
+			},
+			t.Send,
+		),
+		NewBaseTool(
+			SubagentResultToolName,
+			"Get the final answer of a subagent, or its full transcript including tool calls.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"run_id": runIDParam,
+					"transcript": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return the full transcript instead of the final answer",
+					},
+				},
+				"required": []string{"run_id"},
+			},
+			t.Result,
+		),
+	}
+}
+
+// formatSubagentLine 格式化分身的单行摘要
+func formatSubagentLine(info *SubagentInfo) string {
+	name := info.Label
+	if name == "" {
+		name = truncateText(info.Task, 80)
+	}
+	line := fmt.Sprintf("- %s [%s] %s (%s)", info.RunID, info.Status, name, info.Runtime())
+	if info.Error != "" {
+		line += ": " + info.Error
+	}
+	return line
+}
+
+// stringSliceParam 解析字符串数组参数
+func stringSliceParam(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case []string:
+		result = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// truncateText 截断文本
+func truncateText(s string, maxLen int) string {
+	s = strings.TrimSpace(normalizeText(s))
+	if len([]rune(s)) <= maxLen {
+		return s
+	}
+	return string([]rune(s)[:maxLen]) + "..."
+}
@@ -163,6 +163,8 @@ type SubagentSpawnResult struct {
 	Error           string `json:"error,omitempty"`
 	ModelApplied    bool   `json:"model_applied,omitempty"`
 	Warning         string `json:"warning,omitempty"`
+	// RunTimeoutSeconds 分身运行超时（0 表示使用配置的默认值）
+	RunTimeoutSeconds int `json:"run_timeout_seconds,omitempty"`
 }
 
 // SubagentRegistryInterface 分身注册表接口
@@ -176,7 +178,7 @@ type SubagentSpawnTool struct {
 	getAgentConfig   func(agentID string) *config.AgentConfig
 	getDefaultConfig func() *config.AgentDefaults
 	getAgentID       func(sessionKey string) string
-	onSpawn          func(spawnParams *SubagentSpawnResult) error
+	onSpawn          func(ctx context.Context, spawnParams *SubagentSpawnResult) error
 }
 
 // NewSubagentSpawnTool 创建分身生成工具
@@ -202,7 +204,8 @@ func (t *SubagentSpawnTool) SetAgentIDGetter(getter func(sessionKey string) stri
 }
 
 // SetOnSpawn 设置分身生成回调
-func (t *SubagentSpawnTool) SetOnSpawn(fn func(spawnParams *SubagentSpawnResult) error) {
+// 回调返回错误时本次生成失败，错误信息返回给模型
+func (t *SubagentSpawnTool) SetOnSpawn(fn func(ctx context.Context, spawnParams *SubagentSpawnResult) error) {
 	t.onSpawn = fn
 }
 
@@ -282,9 +285,11 @@ func (t *SubagentSpawnTool) Execute(ctx context.Context, params map[string]inter
 	}
 
 	// 获取请求者会话信息（从上下文获取）
-	// TODO: 从 context 中获取请求者会话密钥、agent ID 等
-	requesterSessionKey := "main" // 默认值
-	requesterAgentID := t.getAgentID(requesterSessionKey)
+	requesterSessionKey := SessionKeyFromContext(ctx)
+	requesterAgentID := AgentIDFromContext(ctx)
+	if requesterAgentID == "" && t.getAgentID != nil {
+		requesterAgentID = t.getAgentID(requesterSessionKey)
+	}
 	if requesterAgentID == "" {
 		requesterAgentID = "default"
 	}
@@ -307,9 +312,12 @@ func (t *SubagentSpawnTool) Execute(ctx context.Context, params map[string]inter
 	}
 
 	// 解析请求者来源
-	requesterOrigin := &DeliveryContext{
-		Channel:   "cli", // 默认值
-		AccountID: "default",
+	requesterOrigin := DeliveryContextFromContext(ctx)
+	if requesterOrigin == nil {
+		requesterOrigin = &DeliveryContext{
+			Channel:   "cli", // 默认值
+			AccountID: "default",
+		}
 	}
 
 	// 生成子会话密钥
@@ -318,16 +326,6 @@ func (t *SubagentSpawnTool) Execute(ctx context.Context, params map[string]inter
 	// 生成运行 ID
 	runID := GenerateRunID()
 
-	// 构建分身系统提示词
-	childSystemPrompt := BuildSubagentSystemPrompt(&SubagentSystemPromptParams{
-		RequesterSessionKey: requesterSessionKey,
-		RequesterOrigin:     requesterOrigin,
-		ChildSessionKey:     childSessionKey,
-		Label:               spawnParams.Label,
-		Task:                spawnParams.Task,
-	})
-	_ = childSystemPrompt // TODO: 传递给分身实例使用
-
 	// 获取归档时间
 	archiveAfterMinutes := 60 // 默认值
 	if defCfg := t.getDefaultConfig(); defCfg != nil && defCfg.Subagents != nil {
@@ -355,27 +353,28 @@ func (t *SubagentSpawnTool) Execute(ctx context.Context, params map[string]inter
 		return t.marshalResult(result), nil
 	}
 
-	// 调用生成回调
+	// 构建结果
+	result := &SubagentSpawnResult{
+		Status:            "accepted",
+		ChildSessionKey:   childSessionKey,
+		RunID:             runID,
+		RunTimeoutSeconds: spawnParams.RunTimeoutSeconds,
+	}
+
+	// 调用生成回调（启动分身运行，超出并发限制时回调会设置 Warning）
 	if t.onSpawn != nil {
-		spawnResult := &SubagentSpawnResult{
-			Status:          "accepted",
-			ChildSessionKey: childSessionKey,
-			RunID:           runID,
-		}
-		if err := t.onSpawn(spawnResult); err != nil {
+		if err := t.onSpawn(ctx, result); err != nil {
 			logger.Error("Failed to handle subagent spawn",
 				zap.String("run_id", runID),
 				zap.Error(err))
+			result := &SubagentSpawnResult{
+				Status: "error",
+				Error:  fmt.Sprintf("failed to start subagent: %v", err),
+			}
+			return t.marshalResult(result), nil
 		}
 	}
 
-	// 构建结果
-	result := &SubagentSpawnResult{
-		Status:          "accepted",
-		ChildSessionKey: childSessionKey,
-		RunID:           runID,
-	}
-
 	logger.Info("Subagent spawned",
 		zap.String("run_id", runID),
 		zap.String("task", spawnParams.Task),
@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smallnest/goclaw/session"
+)
+
+// TodoToolName todo 工具名称
+const TodoToolName = "todo"
+
+// TodoTool 维护当前会话的任务清单，清单保存在会话元数据中
+type TodoTool struct {
+	sessionMgr *session.Manager
+}
+
+// NewTodoTool 创建任务清单工具
+func NewTodoTool(sessionMgr *session.Manager) *TodoTool {
+	return &TodoTool{sessionMgr: sessionMgr}
+}
+
+// Name 返回工具名称
+func (t *TodoTool) Name() string {
+	return TodoToolName
+}
+
+// Description 返回工具描述
+func (t *TodoTool) Description() string {
+	return "Track a task list for multi-step jobs in this conversation. " +
+		"Add the steps before starting, mark each step in_progress while working on it and done when finished. " +
+		"The current list is shown in your system prompt on every turn and is kept across restarts. " +
+		"Actions: add (items), update (id, status and/or content), list, clear (removes done items)."
+}
+
+// Parameters 返回工具参数定义
+func (t *TodoTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"add", "update", "list", "clear"},
+				"description": "Operation to perform.",
+			},
+			"items": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Tasks to append (add).",
+			},
+			"id": map[string]interface{}{
+				"type":        "integer",
+				"description": "ID of the task to change (update).",
+			},
+			"status": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{session.TodoStatusPending, session.TodoStatusInProgress, session.TodoStatusDone},
+				"description": "New status of the task (update).",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "New description of the task (update, optional).",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// Execute 执行工具
+func (t *TodoTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	sess, err := t.sessionMgr.GetOrCreate(SessionKeyFromContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to load session: %w", err)
+	}
+
+	action, _ := params["action"].(string)
+	items := sess.GetTodos()
+	now := time.Now()
+
+	switch action {
+	case "list":
+		return FormatTodoList(items), nil
+
+	case "add":
+		raw, _ := params["items"].([]interface{})
+		added := 0
+		for _, value := range raw {
+			content, _ := value.(string)
+			content = strings.TrimSpace(content)
+			if content == "" {
+				continue
+			}
+			items = append(items, session.TodoItem{
+				ID:        nextTodoID(items),
+				Content:   content,
+				Status:    session.TodoStatusPending,
+				UpdatedAt: now,
+			})
+			added++
+		}
+		if added == 0 {
+			return "", fmt.Errorf("items parameter must contain at least one task")
+		}
+
+	case "update":
+		id, ok := params["id"].(float64)
+		if !ok {
+			return "", fmt.Errorf("id parameter is required")
+		}
+		status, _ := params["status"].(string)
+		content, _ := params["content"].(string)
+		content = strings.TrimSpace(content)
+		if status == "" && content == "" {
+			return "", fmt.Errorf("status or content parameter is required")
+		}
+		if status != "" && !session.ValidTodoStatus(status) {
+			return "", fmt.Errorf("invalid status: %s", status)
+		}
+
+		found := false
+		for i := range items {
+			if items[i].ID != int(id) {
+				continue
+			}
+			if status != "" {
+				items[i].Status = status
+			}
+			if content != "" {
+				items[i].Content = content
+			}
+			items[i].UpdatedAt = now
+			found = true
+			break
+		}
+		if !found {
+			return "", fmt.Errorf("task not found: %d", int(id))
+		}
+
+	case "clear":
+		kept := items[:0]
+		for _, item := range items {
+			if item.Status != session.TodoStatusDone {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+
+	default:
+		return "", fmt.Errorf("unknown action: %s (expected add, update, list or clear)", action)
+	}
+
+	sess.SetTodos(items)
+	if err := t.sessionMgr.Save(sess); err != nil {
+		return "", fmt.Errorf("failed to save task list: %w", err)
+	}
+	return FormatTodoList(items), nil
+}
+
+// nextTodoID 返回下一个任务 ID
+func nextTodoID(items []session.TodoItem) int {
+	maxID := 0
+	for _, item := range items {
+		if item.ID > maxID {
+			maxID = item.ID
+		}
+	}
+	return maxID + 1
+}
+
+// FormatTodoList 将任务清单格式化为文本
+func FormatTodoList(items []session.TodoItem) string {
+	if len(items) == 0 {
+		return "The task list is empty."
+	}
+
+	done := 0
+	var sb strings.Builder
+	for _, item := range items {
+		marker := "[ ]"
+		switch item.Status {
+		case session.TodoStatusDone:
+			marker = "[x]"
+			done++
+		case session.TodoStatusInProgress:
+			marker = "[~]"
+		}
+		sb.WriteString(fmt.Sprintf("%s %d. %s\n", marker, item.ID, item.Content))
+	}
+	sb.WriteString(fmt.Sprintf("(%d/%d done)", done, len(items)))
+	return sb.String()
+}
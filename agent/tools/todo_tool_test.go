@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/smallnest/goclaw/session"
+)
+
+func TestTodoTool(t *testing.T) {
+	dir := t.TempDir()
+	sessionMgr, err := session.NewManager(dir)
+	if err != nil {
+		t.Fatalf("failed to create session manager: %v", err)
+	}
+	tool := NewTodoTool(sessionMgr)
+	ctx := WithSessionKey(context.Background(), "telegram:bot:42")
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"action": "archive"}); err == nil {
+		t.Error("expected error for unknown action")
+	}
+
+	out, err := tool.Execute(ctx, map[string]interface{}{
+		"action": "add",
+		"items":  []interface{}{"collect data", "write report", " "},
+	})
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if !strings.Contains(out, "[ ] 1. collect data") || !strings.Contains(out, "[ ] 2. write report") {
+		t.Errorf("unexpected list after add:\n%s", out)
+	}
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"action": "update", "id": float64(1), "status": "done"}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	out, err = tool.Execute(ctx, map[string]interface{}{"action": "update", "id": float64(2), "status": "in_progress"})
+	if err != nil || !strings.Contains(out, "[x] 1.") || !strings.Contains(out, "[~] 2.") || !strings.Contains(out, "(1/2 done)") {
+		t.Fatalf("unexpected list after update (err=%v):\n%s", err, out)
+	}
+	if _, err := tool.Execute(ctx, map[string]interface{}{"action": "update", "id": float64(9), "status": "done"}); err == nil {
+		t.Error("expected error for unknown task")
+	}
+	if _, err := tool.Execute(ctx, map[string]interface{}{"action": "update", "id": float64(2), "status": "blocked"}); err == nil {
+		t.Error("expected error for invalid status")
+	}
+
+	// 清单随会话持久化，重新加载后仍可读取
+	reloaded, err := session.NewManager(dir)
+	if err != nil {
+		t.Fatalf("failed to reload session manager: %v", err)
+	}
+	sess, err := reloaded.GetOrCreate("telegram:bot:42")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	todos := sess.GetTodos()
+	if len(todos) != 2 || todos[0].Status != session.TodoStatusDone || todos[1].Content != "write report" {
+		t.Fatalf("unexpected persisted todos: %+v", todos)
+	}
+
+	// clear 只移除已完成的任务，新任务的 ID 不会重复
+	out, err = tool.Execute(ctx, map[string]interface{}{"action": "clear"})
+	if err != nil || strings.Contains(out, "collect data") || !strings.Contains(out, "write report") {
+		t.Fatalf("unexpected list after clear (err=%v):\n%s", err, out)
+	}
+	out, _ = tool.Execute(ctx, map[string]interface{}{"action": "add", "items": []interface{}{"send report"}})
+	if !strings.Contains(out, "[ ] 3. send report") {
+		t.Errorf("expected new task to get id 3:\n%s", out)
+	}
+}
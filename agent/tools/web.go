@@ -1,26 +1,26 @@
 package tools
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/smallnest/goclaw/config"
 )
 
 // WebTool Web 工具
 type WebTool struct {
-	searchAPIKey string
-	searchEngine string
-	timeout      time.Duration
-	client       *http.Client
+	timeout time.Duration
+	client  *http.Client
+	search  *SearchChain
 }
 
-// NewWebTool 创建 Web 工具
+// NewWebTool 创建 Web 工具，默认使用 searchEngine（配置了 searchAPIKey 时）并以 DuckDuckGo 兜底，
+// 可通过 SetSearchChain 替换
 func NewWebTool(searchAPIKey, searchEngine string, timeout int) *WebTool {
 	var t time.Duration
 	if timeout > 0 {
@@ -29,146 +29,50 @@ func NewWebTool(searchAPIKey, searchEngine string, timeout int) *WebTool {
 		t = 10 * time.Second
 	}
 
+	search, err := NewSearchChainFromConfig(config.WebToolConfig{
+		SearchAPIKey: searchAPIKey,
+		SearchEngine: searchEngine,
+		Timeout:      timeout,
+	}, "")
+	if err != nil {
+		search = NewSearchChain(nil, nil, 0, "")
+	}
+
 	return &WebTool{
-		searchAPIKey: searchAPIKey,
-		searchEngine: searchEngine,
-		timeout:      t,
+		timeout: t,
 		client: &http.Client{
 			Timeout: t,
 		},
+		search: search,
 	}
 }
 
-// WebSearch 网络搜索
-func (t *WebTool) WebSearch(ctx context.Context, params map[string]interface{}) (string, error) {
-	query, ok := params["query"].(string)
-	if !ok {
-		return "", fmt.Errorf("query parameter is required")
-	}
-
-	if t.searchAPIKey == "" {
-		return fmt.Sprintf("Search results for: %s\n\n[Warning: Search API Key not configured. Please set search_api_key in config to get actual results.]", query), nil
-	}
-
-	// 默认使用 travily
-	if t.searchEngine == "travily" || t.searchEngine == "tavily" || t.searchEngine == "" {
-		return t.searchTavily(ctx, query)
-	}
-
-	if t.searchEngine == "serper" {
-		return t.searchSerper(ctx, query)
-	}
-
-	if t.searchEngine == "google" {
-		return t.searchGoogle(ctx, query)
-	}
-
-	return fmt.Sprintf("Search results for: %s\n\n[Warning: Search engine '%s' is not fully implemented. Using mock results.]", query, t.searchEngine), nil
-}
-
-func (t *WebTool) searchTavily(ctx context.Context, query string) (string, error) {
-	apiURL := "https://api.tavily.com/search"
-	maxResults := 5 // Default limit
-
-	requestBody, err := json.Marshal(map[string]any{
-		"query":          query,
-		"search_depth":   "basic",
-		"max_results":    maxResults,
-		"include_images": true,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+t.searchAPIKey)
-
-	resp, err := t.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to perform Tavily search: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Tavily API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Results []struct {
-			Title   string `json:"title"`
-			URL     string `json:"url"`
-			Content string `json:"content"`
-		} `json:"results"`
-		Images []string `json:"images"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode Tavily response: %w", err)
-	}
-
-	var sb bytes.Buffer
-	for _, item := range result.Results {
-		sb.WriteString(fmt.Sprintf("Title: %s\nURL: %s\nContent: %s\n\n", item.Title, item.URL, item.Content))
-	}
-
-	if len(result.Images) > 0 {
-		sb.WriteString("\nRelevant Images:\n")
-		for _, imgURL := range result.Images {
-			sb.WriteString(fmt.Sprintf("- Image URL: %s\n", imgURL))
-		}
-		sb.WriteString("\n")
-	}
-
-	if sb.Len() == 0 {
-		return "No results found.", nil
-	}
-
-	return sb.String(), nil
+// SetSearchChain 设置搜索后端链
+func (t *WebTool) SetSearchChain(search *SearchChain) {
+	t.search = search
 }
 
-func (t *WebTool) searchGoogle(ctx context.Context, query string) (string, error) {
-	// TODO: 实现 Google Custom Search API 调用
-	if t.searchAPIKey == "" {
-		return "", fmt.Errorf("google search api key is required")
-	}
-	return fmt.Sprintf("Google Search results for: %s\n\n1. Example Result (Mock)\n2. Another Result (Mock)", query), nil
+// SearchChain 返回搜索后端链
+func (t *WebTool) SearchChain() *SearchChain {
+	return t.search
 }
 
-func (t *WebTool) searchSerper(ctx context.Context, query string) (string, error) {
-	url := "https://google.serper.dev/search"
-	payload := strings.NewReader(fmt.Sprintf(`{"q": "%s"}`, query))
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, payload)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Add("X-API-KEY", t.searchAPIKey)
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := t.client.Do(req)
-	if err != nil {
-		return "", err
+// WebSearch 网络搜索，按配置的顺序尝试搜索后端
+func (t *WebTool) WebSearch(ctx context.Context, params map[string]interface{}) (string, error) {
+	query, ok := params["query"].(string)
+	if !ok {
+		return "", fmt.Errorf("query parameter is required")
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("search api returned status: %s", res.Status)
+	limit := defaultSearchResults
+	if n, ok := params["max_results"].(float64); ok && n > 0 {
+		limit = int(n)
 	}
 
-	body, err := io.ReadAll(res.Body)
+	resp, err := t.search.Search(ctx, query, limit)
 	if err != nil {
 		return "", err
 	}
-
-	// 这里简单返回 JSON，实际应该解析并格式化
-	return string(body), nil
+	return FormatSearchResults(resp), nil
 }
 
 // WebFetch 抓取网页
@@ -273,7 +177,7 @@ func (t *WebTool) GetTools() []Tool {
 	return []Tool{
 		NewBaseTool(
 			"web_search",
-			"Search the web for information",
+			"Search the web for information. Returns numbered results with title, URL, date (when known) and snippet.",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -281,6 +185,10 @@ func (t *WebTool) GetTools() []Tool {
 						"type":        "string",
 						"description": "Search query",
 					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of results to return (1-10, default 5)",
+					},
 				},
 				"required": []string{"query"},
 			},
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/internal/metrics"
+	"github.com/smallnest/goclaw/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consumeInboundSpan 开始处理入站消息的 span，父节点是发布消息时的链路
+func consumeInboundSpan(ctx context.Context, msg *bus.InboundMessage) (context.Context, trace.Span) {
+	return telemetry.StartConsumer(ctx, "bus.consume inbound", msg.TraceContext,
+		telemetry.AttrChannel.String(msg.Channel),
+		telemetry.AttrAccountID.String(msg.AccountID),
+		telemetry.AttrChatID.String(msg.ChatID),
+		telemetry.AttrMessageID.String(msg.ID),
+	)
+}
+
+// startRunSpan 开始 Agent 运行的 span
+func (o *Orchestrator) startRunSpan(ctx context.Context) (context.Context, trace.Span) {
+	return telemetry.Tracer().Start(ctx, "agent.run",
+		trace.WithAttributes(
+			telemetry.AttrSessionKey.String(tools.SessionKeyFromContext(ctx)),
+			telemetry.AttrAgentID.String(tools.AgentIDFromContext(ctx)),
+			telemetry.AttrModel.String(o.config.Model),
+		))
+}
+
+// recordRunUsage 在运行的 span 上记录本次运行的迭代次数和 token 用量
+func recordRunUsage(ctx context.Context, tracker *BudgetTracker) {
+	report := tracker.Report()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		telemetry.AttrPromptTokens.Int(report.PromptTokens),
+		telemetry.AttrCompletionTokens.Int(report.CompletionTokens),
+		telemetry.AttrTotalTokens.Int(report.TotalTokens),
+		attribute.Int("goclaw.run.iterations", report.Iterations),
+	)
+	if report.Reason != "" {
+		span.SetAttributes(attribute.String("goclaw.run.budget_exceeded", report.Reason))
+	}
+}
+
+// startToolSpan 开始工具执行的 span
+func startToolSpan(ctx context.Context, tc ToolCallContent) (context.Context, trace.Span) {
+	return telemetry.Tracer().Start(ctx, "tool.execute",
+		trace.WithAttributes(
+			telemetry.AttrToolName.String(tc.Name),
+			telemetry.AttrToolCallID.String(tc.ID),
+			telemetry.AttrSessionKey.String(tools.SessionKeyFromContext(ctx)),
+		))
+}
+
+// metricsAgentID 返回指标使用的 Agent 标签，未指定时为 default
+func metricsAgentID(ctx context.Context) string {
+	if agentID := tools.AgentIDFromContext(ctx); agentID != "" {
+		return agentID
+	}
+	return "default"
+}
+
+// recordToolCall 记录工具调用次数和失败次数
+func recordToolCall(name string, err error) {
+	if err != nil {
+		metrics.RecordToolCall(name, metrics.StatusError)
+		return
+	}
+	metrics.RecordToolCall(name, metrics.StatusOK)
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/internal/audit"
+	"github.com/smallnest/goclaw/internal/redact"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 )
@@ -142,6 +145,7 @@ type Event struct {
 	// Turn end fields
 	StopReason    string         `json:"stop_reason,omitempty"`
 	FinalMessages []AgentMessage `json:"final_messages,omitempty"`
+	Budget        *BudgetReport  `json:"budget,omitempty"`
 }
 
 // LoopConfig contains configuration for the agent loop
@@ -152,6 +156,22 @@ type LoopConfig struct {
 	MaxIterations int
 	SessionID     string
 
+	// Budget limits each run; a budget attached with WithRunBudget takes precedence
+	Budget *RunBudget
+
+	// OutputSpool moves oversized tool output to spool files (nil keeps output inline)
+	OutputSpool *tools.OutputSpool
+
+	// AuditLog records every tool call to the hash-chained audit log (nil disables auditing)
+	AuditLog *audit.Log
+
+	// Redactor masks secrets in tool results before the model sees them (nil leaves results as is)
+	Redactor *redact.Redactor
+
+	// Untrusted wraps results of web, browser, search and file tools in an untrusted-content envelope
+	// and holds side-effecting tools until the user confirms (nil disables the policy)
+	Untrusted *UntrustedContentPolicy
+
 	// Hooks for message transformation
 	ConvertToLLM     func([]AgentMessage) ([]providers.Message, error)
 	TransformContext func([]AgentMessage) ([]AgentMessage, error)
@@ -323,6 +343,12 @@ func (e *Event) WithStopReason(reason string) *Event {
 	return e
 }
 
+// WithBudget adds the run budget report to the event
+func (e *Event) WithBudget(report *BudgetReport) *Event {
+	e.Budget = report
+	return e
+}
+
 // WithFinalMessages adds final messages to the event
 func (e *Event) WithFinalMessages(msgs []AgentMessage) *Event {
 	e.FinalMessages = msgs
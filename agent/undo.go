@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/session"
+	"go.uber.org/zap"
+)
+
+// UndoCommand 撤销上一次运行中文件修改的命令
+const UndoCommand = "/undo"
+
+// undoNoteKey 会话元数据中的撤销说明，下一次运行时告知 Agent 文件已恢复
+const undoNoteKey = "undo_note"
+
+// isUndoCommand 判断消息是否为 /undo 命令
+func isUndoCommand(content string) bool {
+	return isChatCommand(content, UndoCommand)
+}
+
+// undoLastRun 处理 /undo：把会话中最近一次修改过文件的运行恢复到修改之前。
+// 文件在运行结束后又被修改过时需要 /undo force
+func (m *AgentManager) undoLastRun(sess *session.Session, content string) string {
+	if m.fileCheckpoints == nil {
+		return "File checkpoints are disabled, there is nothing to undo."
+	}
+	force := false
+	for _, arg := range strings.Fields(content)[1:] {
+		if arg == "force" {
+			force = true
+		}
+	}
+
+	cp, err := m.fileCheckpoints.Latest(sess.Key)
+	if err != nil {
+		logger.Error("Failed to load file checkpoints", zap.String("session_key", sess.Key), zap.Error(err))
+		return fmt.Sprintf("Failed to load file checkpoints: %v", err)
+	}
+	if cp == nil {
+		return "There are no file changes to undo."
+	}
+
+	restored, err := m.fileCheckpoints.Restore(cp, force)
+	if errors.Is(err, tools.ErrFilesChangedSinceRun) {
+		return fmt.Sprintf("⚠️ These files were changed after the run and undoing would overwrite those changes:\n\n%s\n\nReply %s force to undo anyway.",
+			m.displayPaths(cp.Changed()), UndoCommand)
+	}
+	if err != nil {
+		logger.Error("Failed to restore file checkpoint",
+			zap.String("session_key", sess.Key),
+			zap.String("run_id", cp.RunID),
+			zap.Error(err))
+		return fmt.Sprintf("Undo failed after restoring %d file(s): %v", len(restored), err)
+	}
+
+	logger.Info("Restored file checkpoint",
+		zap.String("session_key", sess.Key),
+		zap.String("run_id", cp.RunID),
+		zap.Int("files", len(restored)))
+
+	sess.SetMetadata(undoNoteKey, fmt.Sprintf("The user ran %s. These files were restored to their state before the request %q:\n\n%s\n\nChanges you made to them in that run no longer exist. Read them again before editing.",
+		UndoCommand, truncateString(cp.Prompt, 200), m.displayPaths(restored)))
+	if err := m.sessionMgr.Save(sess); err != nil {
+		logger.Error("Failed to save session", zap.Error(err))
+	}
+
+	return fmt.Sprintf("↩️ Reverted %d file(s) changed by %q:\n\n%s", len(restored), truncateString(cp.Prompt, 80), m.displayPaths(restored))
+}
+
+// undoContext 返回待告知 Agent 的撤销说明并清除（随会话在运行结束后保存）
+func undoContext(sess *session.Session) string {
+	note, _ := sess.GetMetadata(undoNoteKey).(string)
+	if note == "" {
+		return ""
+	}
+	sess.SetMetadata(undoNoteKey, nil)
+	return "## Files reverted\n\n" + note
+}
+
+// displayPaths 将路径列表格式化为相对工作区的列表
+func (m *AgentManager) displayPaths(paths []string) string {
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if rel, err := filepath.Rel(m.dataDir, path); err == nil && m.dataDir != "" && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		lines = append(lines, "- "+path)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// finishFileChanges 结束运行的文件检查点，返回本次修改的文件列表；没有修改时返回空字符串
+func finishFileChanges(checkpoints *tools.FileCheckpoints, sessionKey, runID, prompt, workspace string) string {
+	if checkpoints == nil {
+		return ""
+	}
+	cp, err := checkpoints.Finish(sessionKey, runID, prompt)
+	if err != nil {
+		logger.Warn("Failed to finish file checkpoint",
+			zap.String("session_key", sessionKey),
+			zap.String("run_id", runID),
+			zap.Error(err))
+		return ""
+	}
+	if cp == nil {
+		return ""
+	}
+	return cp.Summary(workspace)
+}
+
+// appendText 在消息的文本后追加内容（用于在最终回复后附加修改的文件列表）
+func appendText(msg AgentMessage, text string) AgentMessage {
+	result := msg
+	result.Content = make([]ContentBlock, 0, len(msg.Content)+1)
+	appended := false
+	for _, block := range msg.Content {
+		if t, ok := block.(TextContent); ok && !appended {
+			block = TextContent{Text: t.Text + text}
+			appended = true
+		}
+		result.Content = append(result.Content, block)
+	}
+	if !appended {
+		result.Content = append([]ContentBlock{TextContent{Text: strings.TrimLeft(text, "\n")}}, result.Content...)
+	}
+	return result
+}
+
+// withFileChanges 在运行的最终回复后附加本次修改的文件列表和撤销方法
+func (m *AgentManager) withFileChanges(msg AgentMessage, sessionKey, runID, prompt string) AgentMessage {
+	summary := finishFileChanges(m.fileCheckpoints, sessionKey, runID, prompt, m.dataDir)
+	if summary == "" {
+		return msg
+	}
+	return appendText(msg, fmt.Sprintf("\n\n---\nFiles changed:\n%s\n\nReply %s to revert these changes.", summary, UndoCommand))
+}
+
+// shortRunID 返回运行 ID 的前 8 位，goclaw checkpoints 接受 ID 前缀
+func shortRunID(runID string) string {
+	if len(runID) > 8 {
+		return runID[:8]
+	}
+	return runID
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/session"
+)
+
+func TestUndoLastRun(t *testing.T) {
+	sessionMgr, err := session.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create session manager: %v", err)
+	}
+	ws := t.TempDir()
+	m := &AgentManager{
+		sessionMgr:      sessionMgr,
+		dataDir:         ws,
+		fileCheckpoints: tools.NewFileCheckpoints(t.TempDir(), 0),
+	}
+	sess, _ := sessionMgr.GetOrCreate("telegram:bot:42")
+	path := filepath.Join(ws, "notes.md")
+	if err := os.WriteFile(path, []byte("draft\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := m.undoLastRun(sess, "/undo"); !strings.Contains(reply, "no file changes") {
+		t.Errorf("undo without checkpoints = %q", reply)
+	}
+
+	// 一次运行修改了文件，最终回复附带修改列表
+	if err := m.fileCheckpoints.Snapshot(sess.Key, "run-1", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("final\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reply := AgentMessage{Role: RoleAssistant, Content: []ContentBlock{TextContent{Text: "Done."}}}
+	text := extractTextContent(m.withFileChanges(reply, sess.Key, "run-1", "polish the notes"))
+	if !strings.HasPrefix(text, "Done.") || !strings.Contains(text, "M notes.md (+1 -1)") || !strings.Contains(text, UndoCommand) {
+		t.Errorf("final message should list changed files:\n%s", text)
+	}
+
+	// 运行后文件又被修改，需要 force
+	if err := os.WriteFile(path, []byte("edited by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if reply := m.undoLastRun(sess, "/undo"); !strings.Contains(reply, "/undo force") {
+		t.Errorf("undo of changed files should ask for force, got %q", reply)
+	}
+	if reply := m.undoLastRun(sess, "/undo force"); !strings.Contains(reply, "Reverted 1 file(s)") {
+		t.Errorf("forced undo = %q", reply)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "draft\n" {
+		t.Errorf("notes.md = %q after undo", data)
+	}
+
+	// 下一次运行告知 Agent 文件已恢复，只告知一次
+	if note := undoContext(sess); !strings.Contains(note, "notes.md") || !strings.Contains(note, "polish the notes") {
+		t.Errorf("undo note = %q", note)
+	}
+	if note := undoContext(sess); note != "" {
+		t.Errorf("undo note should be cleared, got %q", note)
+	}
+	if reply := m.undoLastRun(sess, "/undo"); !strings.Contains(reply, "no file changes") {
+		t.Errorf("second undo = %q", reply)
+	}
+}
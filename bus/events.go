@@ -15,6 +15,8 @@ type InboundMessage struct {
 	Media     []Media                `json:"media"`      // 媒体文件
 	Metadata  map[string]interface{} `json:"metadata"`   // 元数据
 	Timestamp time.Time              `json:"timestamp"`
+	// TraceContext 追踪上下文（W3C traceparent），把入站消息、Agent 运行和回复串成一条链路
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 // Media 媒体文件
@@ -40,6 +42,8 @@ type OutboundMessage struct {
 	ReplyTo   string                 `json:"reply_to"` // 回复的消息ID
 	Metadata  map[string]interface{} `json:"metadata"` // 元数据
 	Timestamp time.Time              `json:"timestamp"`
+	// TraceContext 追踪上下文（W3C traceparent），由 PublishOutbound 写入
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 // SystemMessage 系统消息（用于子代理结果通知）
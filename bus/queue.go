@@ -7,6 +7,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/metrics"
+	"github.com/smallnest/goclaw/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -35,7 +38,7 @@ func NewMessageBus(bufferSize int) *MessageBus {
 }
 
 // PublishInbound 发布入站消息
-func (b *MessageBus) PublishInbound(ctx context.Context, msg *InboundMessage) error {
+func (b *MessageBus) PublishInbound(ctx context.Context, msg *InboundMessage) (err error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -51,8 +54,21 @@ func (b *MessageBus) PublishInbound(ctx context.Context, msg *InboundMessage) er
 		msg.Timestamp = time.Now()
 	}
 
+	// 入站消息是链路的起点，追踪上下文随消息传给消费方
+	ctx, span := telemetry.Tracer().Start(ctx, "bus.publish inbound",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			telemetry.AttrChannel.String(msg.Channel),
+			telemetry.AttrAccountID.String(msg.AccountID),
+			telemetry.AttrChatID.String(msg.ChatID),
+			telemetry.AttrMessageID.String(msg.ID),
+		))
+	defer func() { telemetry.End(span, err) }()
+	msg.TraceContext = telemetry.Inject(ctx)
+
 	select {
 	case b.inbound <- msg:
+		metrics.RecordInbound(msg.Channel)
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
@@ -77,7 +93,7 @@ func (b *MessageBus) ConsumeInbound(ctx context.Context) (*InboundMessage, error
 }
 
 // PublishOutbound 发布出站消息
-func (b *MessageBus) PublishOutbound(ctx context.Context, msg *OutboundMessage) error {
+func (b *MessageBus) PublishOutbound(ctx context.Context, msg *OutboundMessage) (err error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -94,6 +110,17 @@ func (b *MessageBus) PublishOutbound(ctx context.Context, msg *OutboundMessage)
 		msg.Timestamp = time.Now()
 	}
 
+	// 回复继承发布方（通常是 Agent 运行）的追踪上下文
+	ctx, span := telemetry.Tracer().Start(ctx, "bus.publish outbound",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			telemetry.AttrChannel.String(msg.Channel),
+			telemetry.AttrChatID.String(msg.ChatID),
+			telemetry.AttrMessageID.String(msg.ID),
+		))
+	defer func() { telemetry.End(span, err) }()
+	msg.TraceContext = telemetry.Inject(ctx)
+
 	logger.Info("Publishing outbound message to bus",
 		zap.String("id", msg.ID),
 		zap.String("channel", msg.Channel),
@@ -103,6 +130,7 @@ func (b *MessageBus) PublishOutbound(ctx context.Context, msg *OutboundMessage)
 
 	select {
 	case b.outbound <- msg:
+		metrics.RecordOutbound(msg.Channel)
 		logger.Info("Outbound message published successfully",
 			zap.String("id", msg.ID),
 			zap.Int("outbound_queue_size", len(b.outbound)))
@@ -152,3 +152,20 @@ func (c *BaseChannelImpl) SendStream(chatID string, stream <-chan *bus.StreamMes
 
 	return nil
 }
+
+// quickReplies 从出站消息元数据中读取快捷回复选项
+func quickReplies(metadata map[string]interface{}) []string {
+	switch v := metadata["quick_replies"].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		replies := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				replies = append(replies, s)
+			}
+		}
+		return replies
+	}
+	return nil
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/config"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -175,8 +178,8 @@ func (m *Manager) DispatchOutbound(ctx context.Context) error {
 				continue
 			}
 
-			// 发送消息
-			if err := channel.Send(msg); err != nil {
+			// 发送消息（接在回复所属的链路上）
+			if err := m.send(ctx, channel, msg); err != nil {
 				logger.Error("Failed to send message via channel",
 					zap.String("channel", msg.Channel),
 					zap.Error(err),
@@ -190,6 +193,27 @@ func (m *Manager) DispatchOutbound(ctx context.Context) error {
 	}
 }
 
+// send 通过通道发送出站消息，并记录消费和发送的 span
+func (m *Manager) send(ctx context.Context, channel BaseChannel, msg *bus.OutboundMessage) (err error) {
+	ctx, consumeSpan := telemetry.StartConsumer(ctx, "bus.consume outbound", msg.TraceContext,
+		telemetry.AttrChannel.String(msg.Channel),
+		telemetry.AttrChatID.String(msg.ChatID),
+		telemetry.AttrMessageID.String(msg.ID),
+	)
+	defer func() { telemetry.End(consumeSpan, err) }()
+
+	_, sendSpan := telemetry.Tracer().Start(ctx, "channel.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			telemetry.AttrChannel.String(msg.Channel),
+			telemetry.AttrChatID.String(msg.ChatID),
+			attribute.Int("goclaw.content_length", len(msg.Content)),
+		))
+	err = channel.Send(msg)
+	telemetry.End(sendSpan, err)
+	return err
+}
+
 // SetupFromConfig 从配置设置通道
 func (m *Manager) SetupFromConfig(cfg *config.Config) error {
 	// 1. 优先使用新的多账号配置格式
@@ -120,9 +120,15 @@ func (c *TelegramChannel) handleUpdate(ctx context.Context, update *telegrambot.
 		content = message.Caption
 	}
 
-	// 处理命令
-	if strings.HasPrefix(content, "/") {
-		return c.handleCommand(ctx, message, content)
+	// 处理命令（Agent 命令如 /retry 转发给 Agent，其他命令由通道处理）
+	if command, ok := telegramCommand(content, c.bot.Self.UserName); command != "" {
+		if !ok {
+			// 群组中发给其他 Bot 的命令
+			return nil
+		}
+		if !telegramAgentCommands[command] {
+			return c.handleCommand(ctx, message, command)
+		}
 	}
 
 	// 构建入站消息
@@ -146,6 +152,27 @@ func (c *TelegramChannel) handleUpdate(ctx context.Context, update *telegrambot.
 	return c.PublishInbound(ctx, msg)
 }
 
+// telegramAgentCommands 转发给 Agent 处理的命令
+var telegramAgentCommands = map[string]bool{
+	"/status": true,
+	"/retry":  true,
+	"/undo":   true,
+}
+
+// telegramCommand 返回消息开头的命令（去掉 /command@botname 中的 Bot 名称），不是命令时返回空字符串。
+// 命令指定了其他 Bot 时 ok 为 false
+func telegramCommand(content, botName string) (command string, ok bool) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", false
+	}
+	command, target, found := strings.Cut(fields[0], "@")
+	if found && !strings.EqualFold(target, botName) {
+		return command, false
+	}
+	return command, true
+}
+
 // handleCommand 处理命令
 func (c *TelegramChannel) handleCommand(ctx context.Context, message *telegrambot.Message, command string) error {
 	chatID := message.Chat.ID
@@ -161,15 +188,17 @@ func (c *TelegramChannel) handleCommand(ctx context.Context, message *telegrambo
 
 /start - 开始使用
 /help - 显示帮助
+/status - 查看会话状态和任务清单
+/retry - 重新执行被中断的请求
+/undo - 撤销上一次请求对文件的修改
 
 你可以直接与我对话，我会尽力帮助你！`
 		msg := telegrambot.NewMessage(chatID, helpText)
 		if _, err := c.bot.Send(msg); err != nil {
 			return err
 		}
-	case "/status":
-		statusText := fmt.Sprintf("✅ goclaw 运行中\n\n通道状态: %s", map[bool]string{true: "🟢 在线", false: "🔴 离线"}[c.IsRunning()])
-		msg := telegrambot.NewMessage(chatID, statusText)
+	default:
+		msg := telegrambot.NewMessage(chatID, "未知命令："+command+"\n\n发送 /help 查看可用命令。")
 		if _, err := c.bot.Send(msg); err != nil {
 			return err
 		}
@@ -240,6 +269,15 @@ func (c *TelegramChannel) Send(msg *bus.OutboundMessage) error {
 		}
 	}
 
+	// 快捷回复按钮（例如中断运行后的 /retry）
+	if replies := quickReplies(msg.Metadata); len(replies) > 0 {
+		row := make([]telegrambot.KeyboardButton, 0, len(replies))
+		for _, reply := range replies {
+			row = append(row, telegrambot.NewKeyboardButton(reply))
+		}
+		tgMsg.ReplyMarkup = telegrambot.NewOneTimeReplyKeyboard(row)
+	}
+
 	// 发送消息
 	_, err = c.bot.Send(tgMsg)
 	if err != nil {
@@ -0,0 +1,26 @@
+package channels
+
+import "testing"
+
+func TestTelegramCommand(t *testing.T) {
+	cases := []struct {
+		content string
+		command string
+		ok      bool
+	}{
+		{"/help", "/help", true},
+		{"/help@goclaw_bot", "/help", true},
+		{"/Help@GoClaw_Bot extra", "/Help", true},
+		{"  /retry@goclaw_bot  ", "/retry", true},
+		{"/status@other_bot", "/status", false},
+		{"/unknown", "/unknown", true},
+		{"hello /help", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		command, ok := telegramCommand(c.content, "goclaw_bot")
+		if command != c.command || ok != c.ok {
+			t.Errorf("telegramCommand(%q) = %q, %v, want %q, %v", c.content, command, ok, c.command, c.ok)
+		}
+	}
+}
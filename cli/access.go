@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/access"
+	"github.com/spf13/cobra"
+)
+
+var accessCmd = &cobra.Command{
+	Use:   "access",
+	Short: "Manage per-sender roles (owner, member, guest)",
+	Long: `Assign roles to sender IDs across channels. Roles control which agents a sender can reach,
+which tools are available and the rate and budget limits of their runs.
+
+Use "*" as channel or sender ID to match any value, e.g. "goclaw access assign websocket '*' owner".
+Changes apply to a running gateway without a restart. Set access.enabled to true to enforce roles.`,
+}
+
+var accessListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List role assignments",
+	Run:   runAccessList,
+}
+
+var accessAssignCmd = &cobra.Command{
+	Use:   "assign <channel> <sender-id> <role>",
+	Short: "Assign a role to a sender",
+	Args:  cobra.ExactArgs(3),
+	Run:   runAccessAssign,
+}
+
+var accessRevokeCmd = &cobra.Command{
+	Use:   "revoke <channel> <sender-id>",
+	Short: "Remove a sender's role assignment",
+	Args:  cobra.ExactArgs(2),
+	Run:   runAccessRevoke,
+}
+
+var accessCheckCmd = &cobra.Command{
+	Use:   "check <channel> <sender-id>",
+	Short: "Show the effective role and policy of a sender",
+	Args:  cobra.ExactArgs(2),
+	Run:   runAccessCheck,
+}
+
+var accessRolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "Show role policies",
+	Run:   runAccessRoles,
+}
+
+// Flags for access commands
+var accessJSON bool
+
+func init() {
+	accessListCmd.Flags().BoolVar(&accessJSON, "json", false, "Output in JSON format")
+	accessRolesCmd.Flags().BoolVar(&accessJSON, "json", false, "Output in JSON format")
+
+	accessCmd.AddCommand(accessListCmd)
+	accessCmd.AddCommand(accessAssignCmd)
+	accessCmd.AddCommand(accessRevokeCmd)
+	accessCmd.AddCommand(accessCheckCmd)
+	accessCmd.AddCommand(accessRolesCmd)
+	rootCmd.AddCommand(accessCmd)
+}
+
+// runAccessList prints all role assignments
+func runAccessList(cmd *cobra.Command, args []string) {
+	_, store := loadAccess()
+
+	assignments, err := store.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading assignments: %v\n", err)
+		os.Exit(1)
+	}
+
+	if accessJSON {
+		printAccessJSON(assignments)
+		return
+	}
+
+	if len(assignments) == 0 {
+		fmt.Println("No role assignments. Unassigned senders get the default role.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CHANNEL\tSENDER\tROLE\tUPDATED\n")
+	for _, a := range assignments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Channel, a.SenderID, a.Role, a.Updated.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+// runAccessAssign assigns a role to a sender
+func runAccessAssign(cmd *cobra.Command, args []string) {
+	cfg, store := loadAccess()
+	channel, senderID, role := args[0], args[1], args[2]
+
+	roles := access.Roles(&cfg.Access)
+	if _, ok := roles[role]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown role %q (available: %s)\n", role, strings.Join(sortedRoleNames(roles), ", "))
+		os.Exit(1)
+	}
+
+	if err := store.Assign(channel, senderID, role); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving assignment: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Assigned role %s to %s:%s\n", role, channel, senderID)
+	if !cfg.Access.Enabled {
+		fmt.Println("Note: access control is disabled; set access.enabled to true to enforce roles.")
+	}
+}
+
+// runAccessRevoke removes a role assignment
+func runAccessRevoke(cmd *cobra.Command, args []string) {
+	_, store := loadAccess()
+
+	removed, err := store.Revoke(args[0], args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving assignments: %v\n", err)
+		os.Exit(1)
+	}
+	if !removed {
+		fmt.Fprintf(os.Stderr, "No assignment for %s:%s\n", args[0], args[1])
+		os.Exit(1)
+	}
+	fmt.Printf("Removed role assignment for %s:%s\n", args[0], args[1])
+}
+
+// runAccessCheck shows the role and policy that apply to a sender
+func runAccessCheck(cmd *cobra.Command, args []string) {
+	cfg, store := loadAccess()
+
+	enabled := cfg.Access
+	enabled.Enabled = true
+	ctl, err := access.New(&enabled, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid access config: %v\n", err)
+		os.Exit(1)
+	}
+
+	role := ctl.Role(args[0], args[1])
+	fmt.Printf("Sender:  %s:%s\n", args[0], args[1])
+	fmt.Printf("Role:    %s\n", role)
+	printRolePolicy(ctl.Policy(role))
+	if !cfg.Access.Enabled {
+		fmt.Println("\nNote: access control is disabled; all senders currently have full access.")
+	}
+}
+
+// runAccessRoles prints all role policies
+func runAccessRoles(cmd *cobra.Command, args []string) {
+	cfg, _ := loadAccess()
+	roles := access.Roles(&cfg.Access)
+
+	if accessJSON {
+		printAccessJSON(roles)
+		return
+	}
+
+	defaultRole := cfg.Access.DefaultRole
+	if defaultRole == "" {
+		defaultRole = access.RoleGuest
+	}
+	for i, name := range sortedRoleNames(roles) {
+		if i > 0 {
+			fmt.Println()
+		}
+		if name == defaultRole {
+			fmt.Printf("%s (default)\n", name)
+		} else {
+			fmt.Println(name)
+		}
+		printRolePolicy(roles[name])
+	}
+}
+
+// printRolePolicy prints the limits of a role
+func printRolePolicy(policy config.AccessRoleConfig) {
+	fmt.Printf("  Agents:        %s\n", listOrAll(policy.Agents))
+	fmt.Printf("  Allowed tools: %s\n", listOrAll(policy.AllowedTools))
+	if len(policy.DeniedTools) > 0 {
+		fmt.Printf("  Denied tools:  %s\n", strings.Join(policy.DeniedTools, ", "))
+	}
+	if policy.RateLimit > 0 {
+		fmt.Printf("  Rate limit:    %d messages/minute\n", policy.RateLimit)
+	} else {
+		fmt.Printf("  Rate limit:    none\n")
+	}
+	if b := policy.Budget; b != nil {
+		var limits []string
+		if b.MaxTotalTokens > 0 {
+			limits = append(limits, fmt.Sprintf("%d tokens", b.MaxTotalTokens))
+		}
+		if b.MaxCost > 0 {
+			limits = append(limits, fmt.Sprintf("$%.2f", b.MaxCost))
+		}
+		if b.MaxWallTimeSeconds > 0 {
+			limits = append(limits, fmt.Sprintf("%ds", b.MaxWallTimeSeconds))
+		}
+		for tool, n := range b.MaxToolCalls {
+			limits = append(limits, fmt.Sprintf("%d calls of %s", n, tool))
+		}
+		fmt.Printf("  Run budget:    %s\n", strings.Join(limits, ", "))
+	}
+}
+
+// listOrAll formats a name list where empty means everything
+func listOrAll(names []string) string {
+	if len(names) == 0 {
+		return "all"
+	}
+	return strings.Join(names, ", ")
+}
+
+// loadAccess loads the config and the role assignment store
+func loadAccess() (*config.Config, *access.Store) {
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	path, err := config.GetAccessPath(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving access file path: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := access.OpenStore(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening access file: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, store
+}
+
+// sortedRoleNames returns role names in a stable order, built-in roles first
+func sortedRoleNames(roles map[string]config.AccessRoleConfig) []string {
+	names := []string{access.RoleOwner, access.RoleMember, access.RoleGuest}
+	var custom []string
+	for name := range roles {
+		if name != access.RoleOwner && name != access.RoleMember && name != access.RoleGuest {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// printAccessJSON prints a value as indented JSON
+func printAccessJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
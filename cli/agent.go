@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/smallnest/goclaw/agent"
 	"github.com/smallnest/goclaw/agent/tools"
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
+	"github.com/smallnest/goclaw/mcp"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"github.com/spf13/cobra"
@@ -78,6 +82,22 @@ func runAgent(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Set up secret redaction for logs, tool results and (optionally) sessions
+	redactor, err := redact.Setup(cfg)
+	if err != nil {
+		if agentVerbose {
+			fmt.Fprintf(os.Stderr, "Warning: Invalid redaction config: %v\n", err)
+		}
+		redactor = redact.Default()
+	}
+	if redactor != nil {
+		logger.SetRedactor(redactor)
+	}
+	var toolRedactor *redact.Redactor
+	if cfg.Redaction.ToolResults {
+		toolRedactor = redactor
+	}
+
 	// Create workspace
 	workspace := os.Getenv("HOME") + "/.goclaw/workspace"
 	if err := os.MkdirAll(workspace, 0755); err != nil {
@@ -96,6 +116,9 @@ func runAgent(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Failed to create session manager: %v\n", err)
 		os.Exit(1)
 	}
+	if redactor != nil && cfg.Redaction.Sessions {
+		sessionMgr.SetRedactor(redactor)
+	}
 
 	// Create memory store
 	memoryStore := agent.NewMemoryStore(workspace)
@@ -113,6 +136,11 @@ func runAgent(cmd *cobra.Command, args []string) {
 
 	// Register file system tool
 	fsTool := tools.NewFileSystemTool(cfg.Tools.FileSystem.AllowedPaths, cfg.Tools.FileSystem.DeniedPaths, workspace)
+	// Save file contents before edits so the run can be reverted with goclaw checkpoints
+	fileCheckpoints := newFileCheckpoints(cfg)
+	if fileCheckpoints != nil {
+		fsTool.SetCheckpoints(fileCheckpoints)
+	}
 	for _, tool := range fsTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil && agentVerbose {
 			fmt.Fprintf(os.Stderr, "Warning: Failed to register tool %s: %v\n", tool.Name(), err)
@@ -128,6 +156,8 @@ func runAgent(cmd *cobra.Command, args []string) {
 		cfg.Tools.Shell.WorkingDir,
 		cfg.Tools.Shell.Sandbox,
 	)
+	shellTool.SetPathJail(fsTool.Jail()) // Same jail as the file tools for the working dir
+	defer shellTool.Close()              // Stop shell sessions and background processes
 	for _, tool := range shellTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil && agentVerbose {
 			fmt.Fprintf(os.Stderr, "Warning: Failed to register tool %s: %v\n", tool.Name(), err)
@@ -135,11 +165,10 @@ func runAgent(cmd *cobra.Command, args []string) {
 	}
 
 	// Register web tool
-	webTool := tools.NewWebTool(
-		cfg.Tools.Web.SearchAPIKey,
-		cfg.Tools.Web.SearchEngine,
-		cfg.Tools.Web.Timeout,
-	)
+	webTool, err := newWebTool(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Invalid web search backends, using defaults: %v\n", err)
+	}
 	for _, tool := range webTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil && agentVerbose {
 			fmt.Fprintf(os.Stderr, "Warning: Failed to register tool %s: %v\n", tool.Name(), err)
@@ -173,12 +202,40 @@ func runAgent(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to register use_skill: %v\n", err)
 	}
 
+	// Register read_output for oversized tool output
+	outputSpool := tools.NewOutputSpool(
+		filepath.Join(workspace, ".spool"),
+		cfg.Tools.Output.MaxChars,
+		cfg.Tools.Output.ToolLimits,
+		cfg.Tools.Output.HeadChars,
+		cfg.Tools.Output.TailChars,
+	)
+	for _, tool := range outputSpool.GetTools() {
+		if err := toolRegistry.RegisterExisting(tool); err != nil && agentVerbose {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to register tool %s: %v\n", tool.Name(), err)
+		}
+	}
+
+	// Register todo tool (the task list lives in the session metadata)
+	if err := toolRegistry.RegisterExisting(tools.NewTodoTool(sessionMgr)); err != nil && agentVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to register todo tool: %v\n", err)
+	}
+
 	// Create skills loader
 	skillsLoader := agent.NewSkillsLoader(workspace, []string{})
 	if err := skillsLoader.Discover(); err != nil && agentVerbose {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to discover skills: %v\n", err)
 	}
 
+	// Open audit log for tool calls
+	auditLog, err := audit.OpenFromConfig(cfg)
+	if err != nil && agentVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to open audit log: %v\n", err)
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
 	// Create LLM provider
 	provider, err := providers.NewProvider(cfg)
 	if err != nil {
@@ -187,10 +244,29 @@ func runAgent(cmd *cobra.Command, args []string) {
 	}
 	defer provider.Close()
 
+	// Mark web, browser, search and file content as untrusted
+	untrustedPolicy, err := agent.NewUntrustedContentPolicy(&cfg.Untrusted, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid untrusted_content config: %v\n", err)
+		os.Exit(1)
+	}
+	contextBuilder.SetUntrustedContentPolicy(untrustedPolicy)
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(agentTimeout)*time.Second)
 	defer cancel()
 
+	// Connect MCP servers before the agent snapshots its tools
+	mcpManager, err := mcp.NewManager(&cfg.MCP, toolRegistry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid mcp config: %v\n", err)
+		os.Exit(1)
+	}
+	if mcpManager != nil {
+		mcpManager.Start(ctx, 30*time.Second)
+		defer mcpManager.Stop()
+	}
+
 	// Determine session key
 	sessionKey := agentSessionID
 	if sessionKey == "" {
@@ -213,13 +289,17 @@ func runAgent(cmd *cobra.Command, args []string) {
 
 	// Create new agent
 	agentInstance, err := agent.NewAgent(&agent.NewAgentConfig{
-		Bus:          messageBus,
-		Provider:     provider,
-		SessionMgr:   sessionMgr,
-		Tools:        toolRegistry,
-		Context:      contextBuilder,
-		Workspace:    workspace,
-		MaxIteration: cfg.Agents.Defaults.MaxIterations,
+		Bus:             messageBus,
+		Provider:        provider,
+		SessionMgr:      sessionMgr,
+		Tools:           toolRegistry,
+		Context:         contextBuilder,
+		Workspace:       workspace,
+		MaxIteration:    cfg.Agents.Defaults.MaxIterations,
+		OutputSpool:     outputSpool,
+		AuditLog:        auditLog,
+		Redactor:        toolRedactor,
+		FileCheckpoints: fileCheckpoints,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create agent: %v\n", err)
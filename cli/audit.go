@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the tool call audit log",
+	Long:  `Query and verify the hash-chained audit log of tool calls and config changes.`,
+}
+
+var auditQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Query audit entries",
+	Run:   runAuditQuery,
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the audit log hash chain",
+	Run:   runAuditVerify,
+}
+
+// Flags for audit commands
+var (
+	auditPath   string
+	auditSince  string
+	auditTool   string
+	auditSender string
+	auditType   string
+	auditLimit  int
+	auditJSON   bool
+)
+
+func init() {
+	auditCmd.PersistentFlags().StringVar(&auditPath, "path", "", "Path to the audit log (default from config)")
+
+	auditQueryCmd.Flags().StringVar(&auditSince, "since", "", "Only entries after this time (duration like 24h or 7d, date 2006-01-02 or RFC3339)")
+	auditQueryCmd.Flags().StringVar(&auditTool, "tool", "", "Only entries for this tool")
+	auditQueryCmd.Flags().StringVar(&auditSender, "sender", "", "Only entries triggered by this sender ID")
+	auditQueryCmd.Flags().StringVar(&auditType, "type", "", "Only entries of this type (tool_call, config_change)")
+	auditQueryCmd.Flags().IntVarP(&auditLimit, "limit", "n", 50, "Maximum number of entries (most recent)")
+	auditQueryCmd.Flags().BoolVar(&auditJSON, "json", false, "Output in JSON format")
+
+	auditCmd.AddCommand(auditQueryCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+// runAuditQuery prints audit entries matching the filters
+func runAuditQuery(cmd *cobra.Command, args []string) {
+	path := resolveAuditPath()
+
+	filter := audit.Filter{
+		Tool:   auditTool,
+		Sender: auditSender,
+		Type:   auditType,
+		Limit:  auditLimit,
+	}
+	if auditSince != "" {
+		since, err := parseSince(auditSince, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		filter.Since = since
+	}
+
+	entries, err := audit.Query(path, filter)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Println("No audit entries found.")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error reading audit log: %v\n", err)
+		os.Exit(1)
+	}
+
+	if auditJSON {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No audit entries found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SEQ\tTIME\tTYPE\tTOOL\tSENDER\tAGENT\tSTATUS\tDURATION\tARGUMENTS\n")
+	for _, entry := range entries {
+		sender := entry.SenderID
+		if entry.Channel != "" {
+			sender = entry.Channel + ":" + sender
+		}
+		status := entry.Status
+		if entry.Error != "" {
+			status += " (" + truncateString(entry.Error, 40) + ")"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			entry.Seq,
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Type,
+			entry.Tool,
+			sender,
+			entry.AgentID,
+			status,
+			entry.DurationMs,
+			truncateString(string(entry.Arguments), 60),
+		)
+	}
+	w.Flush()
+}
+
+// runAuditVerify checks the hash chain of the audit log
+func runAuditVerify(cmd *cobra.Command, args []string) {
+	path := resolveAuditPath()
+
+	count, err := audit.Verify(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Audit log %s does not exist yet.\n", path)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Audit log verification FAILED after %d valid entries: %v\n", count, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Audit log OK: %d entries verified (%s)\n", count, path)
+}
+
+// resolveAuditPath returns the audit log path from the flag or config
+func resolveAuditPath() string {
+	if auditPath != "" {
+		return auditPath
+	}
+
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	path, err := config.GetAuditPath(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving audit log path: %v\n", err)
+		os.Exit(1)
+	}
+	return path
+}
+
+// parseSince parses a relative duration (24h, 30m), a date or an RFC3339 timestamp
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "d") {
+		var days int
+		if _, err := fmt.Sscanf(value, "%dd", &days); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q (expected duration like 24h or 7d, date 2006-01-02 or RFC3339)", value)
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/spf13/cobra"
+)
+
+var checkpointsCmd = &cobra.Command{
+	Use:   "checkpoints",
+	Short: "Inspect and restore files changed by agent runs",
+	Long: `Every agent run that edits files saves the original contents first.
+List those runs, show what they changed, or restore the files to their state before the run.
+Changes made through the shell tool are not tracked.`,
+}
+
+var checkpointsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List runs that changed files",
+	Args:  cobra.NoArgs,
+	Run:   runCheckpointsList,
+}
+
+var checkpointsDiffCmd = &cobra.Command{
+	Use:   "diff <run>",
+	Short: "Show the changes a run made (run ID or unique prefix)",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCheckpointsDiff,
+}
+
+var checkpointsRestoreCmd = &cobra.Command{
+	Use:   "restore <run>",
+	Short: "Restore the files a run changed (run ID or unique prefix)",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCheckpointsRestore,
+}
+
+// Flags for checkpoints commands
+var (
+	checkpointsSession string
+	checkpointsForce   bool
+)
+
+func init() {
+	checkpointsListCmd.Flags().StringVar(&checkpointsSession, "session", "", "Only runs of this session key")
+	checkpointsRestoreCmd.Flags().BoolVar(&checkpointsForce, "force", false, "Restore even if the files were changed after the run")
+
+	checkpointsCmd.AddCommand(checkpointsListCmd)
+	checkpointsCmd.AddCommand(checkpointsDiffCmd)
+	checkpointsCmd.AddCommand(checkpointsRestoreCmd)
+	rootCmd.AddCommand(checkpointsCmd)
+}
+
+// checkpointsDir returns the directory holding file checkpoints
+func checkpointsDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".goclaw", "checkpoints")
+}
+
+// newFileCheckpoints returns the checkpoint store, or nil when checkpoints are disabled
+func newFileCheckpoints(cfg *config.Config) *tools.FileCheckpoints {
+	if !cfg.Tools.FileSystem.Checkpoints.Enabled {
+		return nil
+	}
+	return tools.NewFileCheckpoints(checkpointsDir(), cfg.Tools.FileSystem.Checkpoints.KeepRuns)
+}
+
+// openFileCheckpoints opens the checkpoint store for the checkpoints commands
+func openFileCheckpoints() *tools.FileCheckpoints {
+	keepRuns := 0
+	if cfg, err := config.Load(""); err == nil {
+		keepRuns = cfg.Tools.FileSystem.Checkpoints.KeepRuns
+	}
+	return tools.NewFileCheckpoints(checkpointsDir(), keepRuns)
+}
+
+// findCheckpoint resolves a run ID or prefix, exiting on error
+func findCheckpoint(store *tools.FileCheckpoints, runID string) *tools.FileCheckpoint {
+	cp, err := store.Find(runID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return cp
+}
+
+// runCheckpointsList prints the runs that changed files, newest first
+func runCheckpointsList(cmd *cobra.Command, args []string) {
+	checkpoints, err := openFileCheckpoints().List(checkpointsSession)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading checkpoints: %v\n", err)
+		os.Exit(1)
+	}
+	if len(checkpoints) == 0 {
+		fmt.Println("No file checkpoints found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "RUN\tTIME\tSESSION\tFILES\tSTATUS\tPROMPT\n")
+	for _, cp := range checkpoints {
+		status := "done"
+		switch {
+		case cp.RestoredAt != nil:
+			status = "restored"
+		case cp.FinishedAt == nil:
+			status = "running"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			cp.RunID,
+			cp.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			cp.SessionKey,
+			len(cp.Files),
+			status,
+			truncateString(cp.Prompt, 50),
+		)
+	}
+	w.Flush()
+}
+
+// runCheckpointsDiff prints a unified diff from the saved contents to the current files
+func runCheckpointsDiff(cmd *cobra.Command, args []string) {
+	cp := findCheckpoint(openFileCheckpoints(), args[0])
+	diff, err := cp.Diff()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading checkpoint: %v\n", err)
+		os.Exit(1)
+	}
+	if diff == "" {
+		fmt.Println("The files match their state before the run.")
+		return
+	}
+	fmt.Print(diff)
+}
+
+// runCheckpointsRestore restores the files of a run to their state before it
+func runCheckpointsRestore(cmd *cobra.Command, args []string) {
+	store := openFileCheckpoints()
+	cp := findCheckpoint(store, args[0])
+
+	restored, err := store.Restore(cp, checkpointsForce)
+	if errors.Is(err, tools.ErrFilesChangedSinceRun) {
+		fmt.Fprintln(os.Stderr, "These files were changed after the run, restoring would overwrite those changes:")
+		for _, path := range cp.Changed() {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
+		}
+		fmt.Fprintln(os.Stderr, "Use --force to restore anyway.")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored %d file(s) from run %s:\n", len(restored), cp.RunID)
+	for _, path := range restored {
+		fmt.Printf("  %s\n", path)
+	}
+}
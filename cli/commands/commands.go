@@ -495,6 +495,15 @@ func (r *CommandRegistry) handleStatus(args []string) string {
 			if r.sessionMgr != nil {
 				if sess, err := r.sessionMgr.GetOrCreate(key); err == nil {
 					sb.WriteString(fmt.Sprintf("      Messages: %d\n", len(sess.Messages)))
+					if todos := sess.GetTodos(); len(todos) > 0 {
+						done := 0
+						for _, item := range todos {
+							if item.Status == session.TodoStatusDone {
+								done++
+							}
+						}
+						sb.WriteString(fmt.Sprintf("      Tasks:    %d/%d done\n", done, len(todos)))
+					}
 					sb.WriteString(fmt.Sprintf("      Created:  %s\n", sess.CreatedAt.Format("2006-01-02 15:04")))
 					updatedAt := time.Since(sess.UpdatedAt)
 					if updatedAt < time.Minute {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,7 +18,9 @@ import (
 	"github.com/smallnest/goclaw/cli/input"
 	"github.com/smallnest/goclaw/config"
 	"github.com/smallnest/goclaw/internal"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"github.com/spf13/cobra"
@@ -43,6 +46,9 @@ func NewTUIAgent(
 	workspace string,
 	maxIterations int,
 	skillsLoader *agent.SkillsLoader,
+	auditLog *audit.Log,
+	redactor *redact.Redactor,
+	outputCfg config.OutputToolConfig,
 ) (*TUIAgent, error) {
 	toolRegistry := agent.NewToolRegistry()
 
@@ -64,6 +70,7 @@ func NewTUIAgent(
 		workspace,              // workingDir
 		config.SandboxConfig{}, // sandbox
 	)
+	shellTool.SetPathJail(fsTool.Jail())
 	for _, tool := range shellTool.GetTools() {
 		_ = toolRegistry.RegisterExisting(tool)
 	}
@@ -83,6 +90,21 @@ func NewTUIAgent(
 		_ = toolRegistry.RegisterExisting(tool)
 	}
 
+	// Register read_output for oversized tool output
+	outputSpool := tools.NewOutputSpool(
+		filepath.Join(workspace, ".spool"),
+		outputCfg.MaxChars,
+		outputCfg.ToolLimits,
+		outputCfg.HeadChars,
+		outputCfg.TailChars,
+	)
+	for _, tool := range outputSpool.GetTools() {
+		_ = toolRegistry.RegisterExisting(tool)
+	}
+
+	// Register todo tool
+	_ = toolRegistry.RegisterExisting(tools.NewTodoTool(sessionMgr))
+
 	// Create Agent
 	newAgent, err := agent.NewAgent(&agent.NewAgentConfig{
 		Bus:          messageBus,
@@ -93,6 +115,9 @@ func NewTUIAgent(
 		Workspace:    workspace,
 		MaxIteration: maxIterations,
 		SkillsLoader: skillsLoader,
+		OutputSpool:  outputSpool,
+		AuditLog:     auditLog,
+		Redactor:     redactor,
 	})
 	if err != nil {
 		return nil, err
@@ -167,6 +192,20 @@ func runTUI(cmd *cobra.Command, args []string) {
 	}
 	defer logger.Sync() // nolint:errcheck
 
+	// Set up secret redaction for logs, tool results and (optionally) sessions
+	redactor, err := redact.Setup(cfg)
+	if err != nil {
+		logger.Warn("Invalid redaction config, using built-in detectors", zap.Error(err))
+		redactor = redact.Default()
+	}
+	if redactor != nil {
+		logger.SetRedactor(redactor)
+	}
+	var toolRedactor *redact.Redactor
+	if cfg.Redaction.ToolResults {
+		toolRedactor = redactor
+	}
+
 	fmt.Println("🐾 goclaw Terminal UI")
 	fmt.Println()
 
@@ -184,6 +223,9 @@ func runTUI(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Failed to create session manager: %v\n", err)
 		os.Exit(1)
 	}
+	if redactor != nil && cfg.Redaction.Sessions {
+		sessionMgr.SetRedactor(redactor)
+	}
 
 	// Create memory store
 	memoryStore := agent.NewMemoryStore(workspace)
@@ -200,6 +242,14 @@ func runTUI(cmd *cobra.Command, args []string) {
 	}
 	defer provider.Close()
 
+	// Mark web, browser, search and file content as untrusted
+	untrustedPolicy, err := agent.NewUntrustedContentPolicy(&cfg.Untrusted, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid untrusted_content config: %v\n", err)
+		os.Exit(1)
+	}
+	contextBuilder.SetUntrustedContentPolicy(untrustedPolicy)
+
 	// Create skills loader
 	goclawDir := os.Getenv("HOME") + "/.goclaw"
 	skillsDir := goclawDir + "/skills"
@@ -219,7 +269,16 @@ func runTUI(cmd *cobra.Command, args []string) {
 		maxIterations = 15
 	}
 
-	tuiAgent, err := NewTUIAgent(messageBus, sessionMgr, provider, contextBuilder, workspace, maxIterations, skillsLoader)
+	// Open audit log for tool calls
+	auditLog, err := audit.OpenFromConfig(cfg)
+	if err != nil {
+		logger.Warn("Failed to open audit log, auditing disabled", zap.Error(err))
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
+	tuiAgent, err := NewTUIAgent(messageBus, sessionMgr, provider, contextBuilder, workspace, maxIterations, skillsLoader, auditLog, toolRedactor, cfg.Tools.Output)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create TUI agent: %v\n", err)
 		os.Exit(1)
@@ -380,7 +439,7 @@ func processTUIDialogue(
 	agentMsgs := sessionMessagesToAgentMessages(history)
 
 	// Run orchestrator
-	finalMessages, err := orchestrator.Run(ctx, agentMsgs)
+	finalMessages, err := orchestrator.Run(tools.WithSessionKey(ctx, sess.Key), agentMsgs)
 	if err != nil {
 		return fmt.Sprintf("Error: %v", err)
 	}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/gateway"
+)
+
+// gatewayClient 通过 WebSocket 调用运行中网关的 JSON-RPC 方法
+type gatewayClient struct {
+	conn    *websocket.Conn
+	timeout time.Duration
+	nextID  int
+}
+
+// gatewayURL 根据配置确定网关地址，override 非空时优先使用
+func gatewayURL(cfg *config.Config, override string) string {
+	if override != "" {
+		return override
+	}
+
+	host := "localhost"
+	port := 28789
+	path := "/ws"
+	if cfg != nil {
+		ws := cfg.Gateway.WebSocket
+		if ws.Host != "" && ws.Host != "0.0.0.0" {
+			host = ws.Host
+		}
+		if ws.Port != 0 {
+			port = ws.Port
+		}
+		if ws.Path != "" {
+			path = ws.Path
+		}
+	}
+	return fmt.Sprintf("ws://%s:%d%s", host, port, path)
+}
+
+// dialGateway 连接网关；token 为空且配置启用认证时使用配置中的令牌
+func dialGateway(cfg *config.Config, urlOverride, token string, timeout time.Duration) (*gatewayClient, error) {
+	if token == "" && cfg != nil && cfg.Gateway.WebSocket.EnableAuth {
+		token = cfg.Gateway.WebSocket.AuthToken
+	}
+
+	target := gatewayURL(cfg, urlOverride)
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	conn, resp, err := dialer.Dial(target, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("gateway at %s rejected the auth token", target)
+		}
+		return nil, fmt.Errorf("gateway is not reachable at %s (is `goclaw start` running?): %w", target, err)
+	}
+
+	return &gatewayClient{conn: conn, timeout: timeout}, nil
+}
+
+// Call 调用网关方法并把结果解码到 result
+func (c *gatewayClient) Call(method string, params map[string]interface{}, result interface{}) error {
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err := c.conn.WriteJSON(gateway.JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	}); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	// 跳过欢迎消息和广播通知，直到收到对应 ID 的响应
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("failed to read %s response: %w", method, err)
+		}
+
+		var resp struct {
+			ID     string            `json:"id"`
+			Method string            `json:"method"`
+			Result json.RawMessage   `json:"result"`
+			Error  *gateway.RPCError `json:"error"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil || resp.Method != "" || resp.ID != id {
+			continue
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s", resp.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// Close 关闭连接
+func (c *gatewayClient) Close() error {
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smallnest/goclaw/agent"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Manage Model Context Protocol (MCP) servers",
+	Long: `Connect MCP servers configured under mcp.servers. Their tools are registered as
+"<server>__<tool>", resources are readable through "<server>__read_resource" and prompts
+through "<server>__get_prompt". Use agents.list[].mcp to limit which agents see them.
+
+"goclaw mcp serve" does the reverse and exposes goclaw itself as an MCP server.`,
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Connect all configured servers and list their tools",
+	Run:   runMCPList,
+}
+
+var mcpTestCmd = &cobra.Command{
+	Use:   "test <server>",
+	Short: "Connect a server, show its tools, resources and prompts, optionally call a tool",
+	Args:  cobra.ExactArgs(1),
+	Run:   runMCPTest,
+}
+
+// Flags for mcp commands
+var (
+	mcpJSON    bool
+	mcpTool    string
+	mcpArgs    string
+	mcpTimeout int
+	mcpShowAll bool
+)
+
+func init() {
+	mcpListCmd.Flags().BoolVar(&mcpJSON, "json", false, "Output in JSON format")
+	mcpListCmd.Flags().BoolVarP(&mcpShowAll, "tools", "t", false, "Show the registered tool names of each server")
+	mcpListCmd.Flags().IntVar(&mcpTimeout, "timeout", 30, "Connect timeout in seconds")
+	mcpTestCmd.Flags().StringVar(&mcpTool, "tool", "", "Call this tool (server-side name)")
+	mcpTestCmd.Flags().StringVar(&mcpArgs, "args", "{}", "Tool arguments as a JSON object")
+	mcpTestCmd.Flags().IntVar(&mcpTimeout, "timeout", 30, "Connect and call timeout in seconds")
+
+	mcpCmd.AddCommand(mcpListCmd)
+	mcpCmd.AddCommand(mcpTestCmd)
+	rootCmd.AddCommand(mcpCmd)
+}
+
+// runMCPList connects every enabled server and prints its status
+func runMCPList(cmd *cobra.Command, args []string) {
+	cfg := loadMCPConfig()
+	if len(cfg.MCP.Servers) == 0 {
+		fmt.Println("No MCP servers configured. Add them under mcp.servers in the config.")
+		return
+	}
+
+	manager, err := mcp.NewManager(&cfg.MCP, agent.NewToolRegistry())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid mcp config: %v\n", err)
+		os.Exit(1)
+	}
+
+	statuses := make(map[string]mcp.ServerStatus)
+	if manager != nil {
+		manager.Start(context.Background(), time.Duration(mcpTimeout)*time.Second)
+		for _, status := range manager.Status() {
+			statuses[status.Name] = status
+		}
+		manager.Stop()
+	}
+
+	if mcpJSON {
+		result := make([]mcp.ServerStatus, 0, len(cfg.MCP.Servers))
+		for _, sc := range cfg.MCP.Servers {
+			status, ok := statuses[sc.Name]
+			if !ok {
+				status = mcp.ServerStatus{Name: sc.Name, Error: "disabled"}
+			}
+			result = append(result, status)
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tTRANSPORT\tSTATUS\tTOOLS\tRESOURCES\tPROMPTS\n")
+	for _, sc := range cfg.MCP.Servers {
+		status, ok := statuses[sc.Name]
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "%s\t%s\tdisabled\t-\t-\t-\n", sc.Name, mcpTransport(sc))
+		case status.Connected:
+			fmt.Fprintf(w, "%s\t%s\tconnected\t%d\t%d\t%d\n", sc.Name, status.Transport, len(status.Tools), status.Resources, status.Prompts)
+		default:
+			fmt.Fprintf(w, "%s\t%s\terror: %s\t-\t-\t-\n", sc.Name, status.Transport, status.Error)
+		}
+	}
+	w.Flush()
+
+	if mcpShowAll {
+		for _, sc := range cfg.MCP.Servers {
+			if status, ok := statuses[sc.Name]; ok && len(status.Tools) > 0 {
+				fmt.Printf("\n%s:\n  %s\n", sc.Name, strings.Join(status.Tools, "\n  "))
+			}
+		}
+	}
+}
+
+// runMCPTest connects a single server and exercises it
+func runMCPTest(cmd *cobra.Command, args []string) {
+	cfg := loadMCPConfig()
+	var server *config.MCPServerConfig
+	for i := range cfg.MCP.Servers {
+		if cfg.MCP.Servers[i].Name == args[0] {
+			server = &cfg.MCP.Servers[i]
+		}
+	}
+	if server == nil {
+		fmt.Fprintf(os.Stderr, "MCP server %q is not configured\n", args[0])
+		os.Exit(1)
+	}
+
+	timeout := time.Duration(mcpTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	client, err := mcp.Connect(ctx, *server, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Connect failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	info := client.ServerInfo()
+	fmt.Printf("Server:    %s %s (%s, connected in %s)\n", info.Name, info.Version, mcpTransport(*server), time.Since(start).Round(time.Millisecond))
+	if instructions := client.Instructions(); instructions != "" {
+		fmt.Printf("Notes:     %s\n", instructions)
+	}
+
+	caps := client.Capabilities()
+	if caps.Tools != nil {
+		remote, err := client.ListTools(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tools/list failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nTools (%d):\n", len(remote))
+		for _, t := range remote {
+			note := ""
+			if !mcp.AllowTool(server.AllowTools, server.DenyTools, t.Name) {
+				note = " [filtered out]"
+			}
+			fmt.Printf("  %s%s\n", mcp.ToolName(server.Name, t.Name), note)
+			if t.Description != "" {
+				fmt.Printf("      %s\n", firstLine(t.Description))
+			}
+		}
+	}
+	if caps.Resources != nil {
+		resources, err := client.ListResources(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "resources/list failed: %v\n", err)
+		}
+		fmt.Printf("\nResources (%d):\n", len(resources))
+		for _, r := range resources {
+			fmt.Printf("  %s  %s\n", r.URI, r.Name)
+		}
+	}
+	if caps.Prompts != nil {
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prompts/list failed: %v\n", err)
+		}
+		fmt.Printf("\nPrompts (%d):\n", len(prompts))
+		for _, p := range prompts {
+			fmt.Printf("  %s  %s\n", p.Name, firstLine(p.Description))
+		}
+	}
+
+	if mcpTool == "" {
+		return
+	}
+	var toolArgs map[string]any
+	if err := json.Unmarshal([]byte(mcpArgs), &toolArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --args: %v\n", err)
+		os.Exit(1)
+	}
+	result, err := client.CallTool(ctx, mcpTool, toolArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nCall %s failed: %v\n", mcpTool, err)
+		os.Exit(1)
+	}
+	output, err := mcp.FormatToolResult(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s returned an error: %v\n", mcpTool, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n%s result:\n%s\n", mcpTool, output)
+}
+
+// loadMCPConfig loads the config or exits
+func loadMCPConfig() *config.Config {
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// mcpTransport names the transport a server config uses
+func mcpTransport(sc config.MCPServerConfig) string {
+	if sc.Command != "" {
+		return "stdio"
+	}
+	return "http"
+}
+
+// firstLine returns the first line of a description
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/smallnest/goclaw/agent"
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/audit"
+	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
+	"github.com/smallnest/goclaw/mcp"
+	"github.com/smallnest/goclaw/memory"
+	"github.com/smallnest/goclaw/providers"
+	"github.com/smallnest/goclaw/session"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Expose goclaw tools, sessions, workspace files and skills as an MCP server",
+	Long: `Serve goclaw over the Model Context Protocol so IDE assistants and other agents can use it.
+
+Tools run with the same filesystem, shell, redaction, audit and untrusted-content
+approval rules as the goclaw agent. Session transcripts (session:<key>) and workspace
+files (file://...) are exposed as resources and skills as prompts.
+
+By default the server speaks stdio. Use --http to listen for Streamable HTTP clients;
+mcp.serve.token (or --token) is then required unless the address is loopback.`,
+	Run: runMCPServe,
+}
+
+// Flags for mcp serve
+var (
+	mcpServeHTTP  bool
+	mcpServeAddr  string
+	mcpServeToken string
+)
+
+func init() {
+	mcpServeCmd.Flags().BoolVar(&mcpServeHTTP, "http", false, "Serve Streamable HTTP instead of stdio")
+	mcpServeCmd.Flags().StringVar(&mcpServeAddr, "addr", "", "HTTP listen address (default mcp.serve.addr)")
+	mcpServeCmd.Flags().StringVar(&mcpServeToken, "token", "", "Bearer token HTTP clients must send (default mcp.serve.token)")
+
+	mcpCmd.AddCommand(mcpServeCmd)
+}
+
+// runMCPServe builds the goclaw tool set and serves it over MCP
+func runMCPServe(cmd *cobra.Command, args []string) {
+	// stdout carries the protocol in stdio mode, so logs go to stderr
+	if err := logger.InitStderr("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	cfg := loadMCPConfig()
+
+	redactor, err := redact.Setup(cfg)
+	if err != nil {
+		logger.Warn("Invalid redaction config, using built-in detectors", zap.Error(err))
+		redactor = redact.Default()
+	}
+	if redactor != nil {
+		logger.SetRedactor(redactor)
+	}
+	var toolRedactor *redact.Redactor
+	if cfg.Redaction.ToolResults {
+		toolRedactor = redactor
+	}
+
+	workspaceDir, err := config.GetWorkspacePath(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get workspace path: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessionMgr, err := session.NewManager(os.Getenv("HOME") + "/.goclaw/sessions")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create session manager: %v\n", err)
+		os.Exit(1)
+	}
+	if redactor != nil && cfg.Redaction.Sessions {
+		sessionMgr.SetRedactor(redactor)
+	}
+
+	fsTool := tools.NewFileSystemTool(cfg.Tools.FileSystem.AllowedPaths, cfg.Tools.FileSystem.DeniedPaths, workspaceDir)
+	registry, outputSpool, closeTools := buildServeTools(cfg, workspaceDir, fsTool, sessionMgr)
+	defer closeTools()
+
+	exposed := make([]tools.Tool, 0, registry.Count())
+	for _, tool := range registry.ListExisting() {
+		if mcp.AllowTool(cfg.MCP.Serve.Tools, cfg.MCP.Serve.DenyTools, tool.Name()) {
+			exposed = append(exposed, tool)
+		}
+	}
+
+	auditLog, err := audit.OpenFromConfig(cfg)
+	if err != nil {
+		logger.Warn("Failed to open audit log, auditing disabled", zap.Error(err))
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
+	// Only the LLM classifier needs a provider; the heuristic one works without credentials
+	var provider providers.Provider
+	if cfg.Untrusted.Enabled && cfg.Untrusted.Classifier == agent.ClassifierLLM {
+		provider, err = providers.NewProvider(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create LLM provider for the untrusted content classifier: %v\n", err)
+			os.Exit(1)
+		}
+		defer provider.Close()
+	}
+	untrustedPolicy, err := agent.NewUntrustedContentPolicy(&cfg.Untrusted, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid untrusted_content config: %v\n", err)
+		os.Exit(1)
+	}
+
+	skillsLoader := agent.NewSkillsLoader(os.Getenv("HOME")+"/.goclaw", []string{os.Getenv("HOME") + "/.goclaw/skills"})
+	if err := skillsLoader.Discover(); err != nil {
+		logger.Warn("Failed to discover skills", zap.Error(err))
+	}
+
+	runner := agent.NewToolRunner(&agent.LoopConfig{
+		OutputSpool: outputSpool,
+		AuditLog:    auditLog,
+		Redactor:    toolRedactor,
+		Untrusted:   untrustedPolicy,
+	}, agent.ToAgentTools(exposed))
+
+	server := mcp.NewGoclawServer(Version, mcp.GoclawOptions{
+		Runner:     runner,
+		Sessions:   sessionMgr,
+		FileSystem: fsTool,
+		Workspace:  workspaceDir,
+		Skills:     skillsLoader,
+		Redactor:   redactor,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if !mcpServeHTTP {
+		if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "MCP server stopped: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	addr := firstNonEmpty(mcpServeAddr, cfg.MCP.Serve.Addr, "127.0.0.1:18790")
+	token := firstNonEmpty(mcpServeToken, cfg.MCP.Serve.Token)
+	if token == "" && !isLoopbackAddr(addr) {
+		fmt.Fprintf(os.Stderr, "Refusing to serve %s without a token: set mcp.serve.token or --token, or listen on 127.0.0.1\n", addr)
+		os.Exit(1)
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           requireBearer(token, server),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "goclaw MCP server listening on http://%s (%d tools)\n", addr, len(exposed))
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "MCP server stopped: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildServeTools registers the tools the agent would have, minus agent-only ones
+// (use_skill, subagents, messaging) that make no sense outside an agent run
+func buildServeTools(cfg *config.Config, workspaceDir string, fsTool *tools.FileSystemTool, sessionMgr *session.Manager) (*agent.ToolRegistry, *tools.OutputSpool, func()) {
+	registry := agent.NewToolRegistry()
+	register := func(list ...tools.Tool) {
+		for _, tool := range list {
+			if err := registry.RegisterExisting(tool); err != nil {
+				logger.Warn("Failed to register tool", zap.String("tool", tool.Name()), zap.Error(err))
+			}
+		}
+	}
+
+	register(fsTool.GetTools()...)
+
+	shellTool := tools.NewShellTool(
+		cfg.Tools.Shell.Enabled,
+		cfg.Tools.Shell.AllowedCmds,
+		cfg.Tools.Shell.DeniedCmds,
+		cfg.Tools.Shell.Timeout,
+		cfg.Tools.Shell.WorkingDir,
+		cfg.Tools.Shell.Sandbox,
+	)
+	shellTool.SetPathJail(fsTool.Jail())
+	register(shellTool.GetTools()...)
+
+	webTool, err := newWebTool(cfg)
+	if err != nil {
+		logger.Warn("Invalid web search backends, using defaults", zap.Error(err))
+	}
+	register(webTool.GetTools()...)
+
+	browserTimeout := 30
+	if cfg.Tools.Browser.Timeout > 0 {
+		browserTimeout = cfg.Tools.Browser.Timeout
+	}
+	register(tools.NewSmartSearch(webTool, true, browserTimeout).GetTool())
+
+	if cfg.Tools.Browser.Enabled {
+		browserTool := tools.NewBrowserTool(
+			cfg.Tools.Browser.Headless,
+			cfg.Tools.Browser.Timeout,
+		)
+		register(browserTool.GetTools()...)
+	}
+
+	closeTools := func() { _ = shellTool.Close() }
+	searchMgr, err := memory.GetMemorySearchManager(cfg.Memory, workspaceDir)
+	if err != nil {
+		logger.Warn("Memory search unavailable, memory_search not exposed", zap.Error(err))
+	} else {
+		register(tools.NewMemoryTool(searchMgr))
+		closeTools = func() {
+			_ = shellTool.Close()
+			_ = searchMgr.Close()
+		}
+	}
+
+	outputSpool := tools.NewOutputSpool(
+		filepath.Join(workspaceDir, ".spool"),
+		cfg.Tools.Output.MaxChars,
+		cfg.Tools.Output.ToolLimits,
+		cfg.Tools.Output.HeadChars,
+		cfg.Tools.Output.TailChars,
+	)
+	register(outputSpool.GetTools()...)
+	register(tools.NewTodoTool(sessionMgr))
+
+	return registry, outputSpool, closeTools
+}
+
+// requireBearer rejects requests without the token (no-op when token is empty)
+func requireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/smallnest/goclaw/agent"
 	"github.com/smallnest/goclaw/agent/tools"
@@ -16,8 +18,13 @@ import (
 	"github.com/smallnest/goclaw/cron"
 	"github.com/smallnest/goclaw/gateway"
 	"github.com/smallnest/goclaw/internal"
+	"github.com/smallnest/goclaw/internal/access"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
+	"github.com/smallnest/goclaw/internal/telemetry"
 	"github.com/smallnest/goclaw/internal/workspace"
+	"github.com/smallnest/goclaw/mcp"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"github.com/spf13/cobra"
@@ -81,6 +88,7 @@ func init() {
 	rootCmd.AddCommand(agentsCmd)
 	rootCmd.AddCommand(agentCmd)
 	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(subagentsCmd)
 	rootCmd.AddCommand(onboardCmd)
 
 	// Register memory and logs commands from commands package
@@ -104,6 +112,7 @@ func init() {
 func SetVersion(v string) {
 	Version = v
 	rootCmd.Version = v
+	mcp.Version = v
 }
 
 // Execute 执行 CLI
@@ -150,6 +159,35 @@ func runStart(cmd *cobra.Command, args []string) {
 		logger.Fatal("Invalid configuration", zap.Error(err))
 	}
 
+	// 初始化脱敏（日志、审计日志、网关 logs.get，以及可选的会话文件和工具结果）
+	redactor, err := redact.Setup(cfg)
+	if err != nil {
+		logger.Warn("Invalid redaction config, using built-in detectors", zap.Error(err))
+		redactor = redact.Default()
+	}
+	if redactor != nil {
+		logger.SetRedactor(redactor)
+	}
+	var toolRedactor *redact.Redactor
+	if cfg.Redaction.ToolResults {
+		toolRedactor = redactor
+	}
+
+	// 初始化链路追踪（未启用时为空实现）
+	shutdownTelemetry, err := telemetry.Setup(cfg.Telemetry)
+	if err != nil {
+		logger.Warn("Failed to set up telemetry, tracing disabled", zap.Error(err))
+	} else if cfg.Telemetry.Enabled {
+		logger.Info("Telemetry enabled", zap.String("exporter", cfg.Telemetry.Exporter))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			logger.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	// 获取 workspace 目录
 	workspaceDir, err := config.GetWorkspacePath(cfg)
 	if err != nil {
@@ -174,6 +212,9 @@ func runStart(cmd *cobra.Command, args []string) {
 	if err != nil {
 		logger.Fatal("Failed to create session manager", zap.Error(err))
 	}
+	if redactor != nil && cfg.Redaction.Sessions {
+		sessionMgr.SetRedactor(redactor)
+	}
 
 	// 创建记忆存储
 	memoryStore := agent.NewMemoryStore(workspaceDir)
@@ -199,6 +240,11 @@ func runStart(cmd *cobra.Command, args []string) {
 
 	// 注册文件系统工具
 	fsTool := tools.NewFileSystemTool(cfg.Tools.FileSystem.AllowedPaths, cfg.Tools.FileSystem.DeniedPaths, workspaceDir)
+	// 文件检查点（修改文件前保存原始内容，用于 /undo 和 goclaw checkpoints）
+	fileCheckpoints := newFileCheckpoints(cfg)
+	if fileCheckpoints != nil {
+		fsTool.SetCheckpoints(fileCheckpoints)
+	}
 	for _, tool := range fsTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil {
 			logger.Warn("Failed to register tool", zap.String("tool", tool.Name()))
@@ -219,6 +265,8 @@ func runStart(cmd *cobra.Command, args []string) {
 		cfg.Tools.Shell.WorkingDir,
 		cfg.Tools.Shell.Sandbox,
 	)
+	shellTool.SetPathJail(fsTool.Jail()) // 工作目录与文件工具共用访问范围
+	defer shellTool.Close()              // 结束 Shell 会话和后台进程
 	for _, tool := range shellTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil {
 			logger.Warn("Failed to register tool", zap.String("tool", tool.Name()))
@@ -226,11 +274,10 @@ func runStart(cmd *cobra.Command, args []string) {
 	}
 
 	// 注册 Web 工具
-	webTool := tools.NewWebTool(
-		cfg.Tools.Web.SearchAPIKey,
-		cfg.Tools.Web.SearchEngine,
-		cfg.Tools.Web.Timeout,
-	)
+	webTool, err := newWebTool(cfg)
+	if err != nil {
+		logger.Warn("Invalid web search backends, using defaults", zap.Error(err))
+	}
 	for _, tool := range webTool.GetTools() {
 		if err := toolRegistry.RegisterExisting(tool); err != nil {
 			logger.Warn("Failed to register tool", zap.String("tool", tool.Name()))
@@ -260,6 +307,45 @@ func runStart(cmd *cobra.Command, args []string) {
 		logger.Info("Browser tools registered")
 	}
 
+	// 注册工具输出缓存（超长输出写入 workspace/.spool，通过 read_output 分页读取）
+	outputSpool := tools.NewOutputSpool(
+		filepath.Join(workspaceDir, ".spool"),
+		cfg.Tools.Output.MaxChars,
+		cfg.Tools.Output.ToolLimits,
+		cfg.Tools.Output.HeadChars,
+		cfg.Tools.Output.TailChars,
+	)
+	for _, tool := range outputSpool.GetTools() {
+		if err := toolRegistry.RegisterExisting(tool); err != nil {
+			logger.Warn("Failed to register tool", zap.String("tool", tool.Name()))
+		}
+	}
+	// 注册任务清单工具（清单保存在会话元数据中）
+	if err := toolRegistry.RegisterExisting(tools.NewTodoTool(sessionMgr)); err != nil {
+		logger.Warn("Failed to register todo tool", zap.Error(err))
+	}
+
+	sessionMgr.OnDelete(func(key string) {
+		if err := outputSpool.CleanupSession(key); err != nil {
+			logger.Warn("Failed to clean up spooled output", zap.String("session_key", key), zap.Error(err))
+		}
+		shellTool.CleanupSession(key)
+		if fileCheckpoints != nil {
+			if err := fileCheckpoints.CleanupSession(key); err != nil {
+				logger.Warn("Failed to clean up file checkpoints", zap.String("session_key", key), zap.Error(err))
+			}
+		}
+	})
+
+	// 打开审计日志（工具调用和配置修改）
+	auditLog, err := audit.OpenFromConfig(cfg)
+	if err != nil {
+		logger.Warn("Failed to open audit log, auditing disabled", zap.Error(err))
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
 	// 创建 LLM 提供商
 	provider, err := providers.NewProvider(cfg)
 	if err != nil {
@@ -267,6 +353,13 @@ func runStart(cmd *cobra.Command, args []string) {
 	}
 	defer provider.Close()
 
+	// 不可信内容防护（网页、浏览器、搜索结果、文件内容加来源标记，副作用工具需确认）
+	untrustedPolicy, err := agent.NewUntrustedContentPolicy(&cfg.Untrusted, provider)
+	if err != nil {
+		logger.Fatal("Invalid untrusted_content config", zap.Error(err))
+	}
+	contextBuilder.SetUntrustedContentPolicy(untrustedPolicy)
+
 	// 创建上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -287,15 +380,26 @@ func runStart(cmd *cobra.Command, args []string) {
 	// 创建调度器
 	scheduler := cron.NewScheduler(messageBus, provider, sessionMgr)
 
+	// 按发送者角色的访问控制（未启用时为 nil）
+	accessCtl, err := access.NewFromConfig(cfg)
+	if err != nil {
+		logger.Fatal("Invalid access config", zap.Error(err))
+	}
+
 	// 创建 AgentManager
 	agentManager := agent.NewAgentManager(&agent.NewAgentManagerConfig{
-		Bus:            messageBus,
-		Provider:       provider,
-		SessionMgr:     sessionMgr,
-		Tools:          toolRegistry,
-		DataDir:        workspaceDir, // 使用 workspace 作为数据目录
-		ContextBuilder: contextBuilder,
-		SkillsLoader:   skillsLoader,
+		Bus:             messageBus,
+		Provider:        provider,
+		SessionMgr:      sessionMgr,
+		Tools:           toolRegistry,
+		DataDir:         workspaceDir, // 使用 workspace 作为数据目录
+		ContextBuilder:  contextBuilder,
+		SkillsLoader:    skillsLoader,
+		OutputSpool:     outputSpool,
+		AuditLog:        auditLog,
+		Redactor:        toolRedactor,
+		Access:          accessCtl,
+		FileCheckpoints: fileCheckpoints,
 	})
 
 	// 从配置设置 Agent 和绑定
@@ -303,6 +407,21 @@ func runStart(cmd *cobra.Command, args []string) {
 		logger.Fatal("Failed to setup agent manager", zap.Error(err))
 	}
 
+	// 连接 MCP 服务器（工具注册为 server__tool，连接或工具列表变化后刷新 Agent 的工具）
+	mcpManager, err := mcp.NewManager(&cfg.MCP, toolRegistry)
+	if err != nil {
+		logger.Fatal("Invalid mcp config", zap.Error(err))
+	}
+	if mcpManager != nil {
+		mcpManager.SetOnChange(agentManager.RefreshTools)
+		mcpManager.Start(ctx, 10*time.Second)
+		defer mcpManager.Stop()
+	}
+
+	// 网关提供分身看板（subagents.* 方法）
+	gatewayServer.SetSubagentDashboard(agentManager)
+	gatewayServer.SetAuditLog(auditLog)
+
 	// 处理信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+)
+
+// searchUsagePath returns the file holding per-backend daily search counts
+func searchUsagePath() string {
+	return filepath.Join(os.Getenv("HOME"), ".goclaw", "search_usage.json")
+}
+
+// newWebTool creates the web tool with the configured search backend chain.
+// If the chain cannot be built, the tool keeps its default chain and the error is returned.
+func newWebTool(cfg *config.Config) (*tools.WebTool, error) {
+	webTool := tools.NewWebTool(
+		cfg.Tools.Web.SearchAPIKey,
+		cfg.Tools.Web.SearchEngine,
+		cfg.Tools.Web.Timeout,
+	)
+	chain, err := tools.NewSearchChainFromConfig(cfg.Tools.Web, searchUsagePath())
+	if err != nil {
+		return webTool, err
+	}
+	webTool.SetSearchChain(chain)
+	return webTool, nil
+}
@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/spf13/cobra"
+)
+
+var subagentsCmd = &cobra.Command{
+	Use:   "subagents",
+	Short: "Inspect and control subagent runs",
+	Long:  `Inspect and control subagent runs of a running goclaw instance through the gateway.`,
+}
+
+var subagentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List subagent runs",
+	Run:   runSubagentsList,
+}
+
+var subagentsShowCmd = &cobra.Command{
+	Use:   "show <run_id>",
+	Short: "Show details of a subagent run",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSubagentsShow,
+}
+
+var subagentsCancelCmd = &cobra.Command{
+	Use:   "cancel <run_id>",
+	Short: "Cancel a running or queued subagent",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSubagentsCancel,
+}
+
+var subagentsLogsCmd = &cobra.Command{
+	Use:   "logs <run_id>",
+	Short: "Show the transcript of a subagent run",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSubagentsLogs,
+}
+
+// Flags for subagents commands
+var (
+	subagentsURL       string
+	subagentsToken     string
+	subagentsJSON      bool
+	subagentsRequester string
+	subagentsFollow    bool
+	subagentsInterval  time.Duration
+)
+
+func init() {
+	subagentsCmd.PersistentFlags().StringVar(&subagentsURL, "url", "", "Gateway WebSocket URL (default: from config, ws://localhost:28789/ws)")
+	subagentsCmd.PersistentFlags().StringVar(&subagentsToken, "token", "", "Gateway auth token (default: from config)")
+
+	subagentsListCmd.Flags().BoolVar(&subagentsJSON, "json", false, "Output in JSON format")
+	subagentsListCmd.Flags().StringVar(&subagentsRequester, "requester", "", "Only show runs spawned from this session key")
+	subagentsShowCmd.Flags().BoolVar(&subagentsJSON, "json", false, "Output in JSON format")
+	subagentsLogsCmd.Flags().BoolVarP(&subagentsFollow, "follow", "f", false, "Stream tool events live until the run finishes")
+	subagentsLogsCmd.Flags().DurationVar(&subagentsInterval, "interval", time.Second, "Polling interval for --follow")
+
+	subagentsCmd.AddCommand(subagentsListCmd)
+	subagentsCmd.AddCommand(subagentsShowCmd)
+	subagentsCmd.AddCommand(subagentsCancelCmd)
+	subagentsCmd.AddCommand(subagentsLogsCmd)
+}
+
+// subagentDetail subagents.get 的结果
+type subagentDetail struct {
+	Subagent   *tools.SubagentInfo   `json:"subagent"`
+	Transcript string                `json:"transcript,omitempty"`
+	Events     []tools.SubagentEvent `json:"events,omitempty"`
+}
+
+// connectSubagentsGateway 连接网关，失败时退出
+func connectSubagentsGateway() *gatewayClient {
+	cfg, err := config.Load("")
+	if err != nil {
+		cfg = nil
+	}
+
+	client, err := dialGateway(cfg, subagentsURL, subagentsToken, 10*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// getSubagent 调用 subagents.get
+func getSubagent(client *gatewayClient, runID string, transcript bool, eventsSince int) (*subagentDetail, error) {
+	params := map[string]interface{}{
+		"run_id":     runID,
+		"transcript": transcript,
+	}
+	if eventsSince >= 0 {
+		params["events_since"] = eventsSince
+	}
+
+	var detail subagentDetail
+	if err := client.Call("subagents.get", params, &detail); err != nil {
+		return nil, err
+	}
+	if detail.Subagent == nil {
+		return nil, fmt.Errorf("subagent run not found: %s", runID)
+	}
+	return &detail, nil
+}
+
+// runSubagentsList lists subagent runs
+func runSubagentsList(cmd *cobra.Command, args []string) {
+	client := connectSubagentsGateway()
+	defer client.Close()
+
+	var result struct {
+		Subagents []*tools.SubagentInfo `json:"subagents"`
+		Stats     *tools.SubagentStats  `json:"stats"`
+	}
+	if err := client.Call("subagents.list", map[string]interface{}{"requester": subagentsRequester}, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing subagents: %v\n", err)
+		os.Exit(1)
+	}
+
+	if subagentsJSON {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if result.Stats != nil {
+		fmt.Printf("Subagents: %s\n\n", result.Stats)
+	}
+	if len(result.Subagents) == 0 {
+		fmt.Println("No subagent runs found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tAGENT\tSTATUS\tELAPSED\tTOKENS\tORIGIN\tTASK")
+	for _, info := range result.Subagents {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.RunID,
+			info.AgentID,
+			info.Status,
+			info.Runtime(),
+			formatSubagentTokens(info.Usage),
+			formatSubagentOrigin(info.RequesterOrigin),
+			truncateRunes(subagentTitle(info), 50))
+	}
+	w.Flush()
+}
+
+// runSubagentsShow shows details of a subagent run
+func runSubagentsShow(cmd *cobra.Command, args []string) {
+	client := connectSubagentsGateway()
+	defer client.Close()
+
+	detail, err := getSubagent(client, args[0], false, -1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if subagentsJSON {
+		data, _ := json.MarshalIndent(detail.Subagent, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	info := detail.Subagent
+	fmt.Printf("Run ID:        %s\n", info.RunID)
+	fmt.Printf("Agent:         %s\n", info.AgentID)
+	fmt.Printf("Status:        %s\n", info.Status)
+	if info.Error != "" {
+		fmt.Printf("Error:         %s\n", info.Error)
+	}
+	if info.Label != "" {
+		fmt.Printf("Label:         %s\n", info.Label)
+	}
+	fmt.Printf("Task:          %s\n", info.Task)
+	fmt.Printf("Requester:     %s\n", info.RequesterSessionKey)
+	fmt.Printf("Origin:        %s\n", formatSubagentOrigin(info.RequesterOrigin))
+	fmt.Printf("Child session: %s\n", info.ChildSessionKey)
+	fmt.Printf("Started:       %s\n", info.StartedAt.Format(time.RFC3339))
+	if !info.EndedAt.IsZero() {
+		fmt.Printf("Ended:         %s\n", info.EndedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Elapsed:       %s\n", info.Runtime())
+	if info.Usage != nil {
+		fmt.Printf("Usage:         %d LLM calls, %d tokens (%d prompt, %d completion)\n",
+			info.Usage.LLMCalls, info.Usage.TotalTokens, info.Usage.PromptTokens, info.Usage.CompletionTokens)
+	}
+	if info.Summary != "" {
+		fmt.Printf("\nResult:\n%s\n", info.Summary)
+	}
+}
+
+// runSubagentsCancel cancels a subagent run
+func runSubagentsCancel(cmd *cobra.Command, args []string) {
+	client := connectSubagentsGateway()
+	defer client.Close()
+
+	var result struct {
+		Subagent *tools.SubagentInfo `json:"subagent"`
+	}
+	if err := client.Call("subagents.cancel", map[string]interface{}{"run_id": args[0]}, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error cancelling subagent: %v\n", err)
+		os.Exit(1)
+	}
+
+	status := "cancel requested"
+	if result.Subagent != nil {
+		status = result.Subagent.Status
+	}
+	fmt.Printf("Subagent %s: %s\n", args[0], status)
+}
+
+// runSubagentsLogs prints the transcript of a subagent run, optionally following its events
+func runSubagentsLogs(cmd *cobra.Command, args []string) {
+	client := connectSubagentsGateway()
+	defer client.Close()
+
+	runID := args[0]
+	detail, err := getSubagent(client, runID, true, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(detail.Transcript)
+	if !subagentsFollow || detail.Subagent.Finished() {
+		return
+	}
+
+	// 跟随模式：只输出记录之后的新事件
+	lastSeq := 0
+	for _, event := range detail.Events {
+		lastSeq = event.Seq
+	}
+	fmt.Printf("\n--- following %s (Ctrl+C to stop) ---\n", runID)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(subagentsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sigChan:
+			return
+		case <-ticker.C:
+		}
+
+		detail, err := getSubagent(client, runID, false, lastSeq)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, event := range detail.Events {
+			fmt.Println(formatSubagentEvent(event))
+			lastSeq = event.Seq
+		}
+
+		if info := detail.Subagent; info.Finished() {
+			fmt.Printf("--- %s %s after %s", runID, info.Status, info.Runtime())
+			if info.Error != "" {
+				fmt.Printf(": %s", info.Error)
+			}
+			fmt.Println(" ---")
+			if info.Summary != "" {
+				fmt.Printf("\n%s\n", info.Summary)
+			}
+			return
+		}
+	}
+}
+
+// formatSubagentEvent 格式化单条分身事件
+func formatSubagentEvent(event tools.SubagentEvent) string {
+	prefix := event.Time.Local().Format("15:04:05")
+	text := strings.ReplaceAll(event.Text, "\n", " ")
+	switch event.Type {
+	case tools.SubagentEventToolStart:
+		return fmt.Sprintf("%s → %s %s", prefix, event.Tool, truncateRunes(text, 160))
+	case tools.SubagentEventToolEnd:
+		marker := "✓"
+		if event.IsError {
+			marker = "✗"
+		}
+		return fmt.Sprintf("%s %s %s %s", prefix, marker, event.Tool, truncateRunes(text, 160))
+	default:
+		return fmt.Sprintf("%s 💬 %s", prefix, truncateRunes(text, 200))
+	}
+}
+
+// formatSubagentTokens 格式化 token 用量
+func formatSubagentTokens(usage *tools.SubagentUsage) string {
+	if usage == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", usage.TotalTokens)
+}
+
+// formatSubagentOrigin 格式化请求来源
+func formatSubagentOrigin(origin *tools.DeliveryContext) string {
+	if origin == nil || origin.Channel == "" {
+		return "-"
+	}
+	if origin.To != "" {
+		return origin.Channel + ":" + origin.To
+	}
+	return origin.Channel
+}
+
+// subagentTitle 返回分身的标签或任务
+func subagentTitle(info *tools.SubagentInfo) string {
+	if info.Label != "" {
+		return info.Label
+	}
+	return strings.Join(strings.Fields(info.Task), " ")
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen]) + "..."
+}
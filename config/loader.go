@@ -67,6 +67,7 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("agents.defaults.max_iterations", 15)
 	v.SetDefault("agents.defaults.temperature", 0.7)
 	v.SetDefault("agents.defaults.max_tokens", 4096)
+	v.SetDefault("agents.defaults.recovery.mode", "notify")
 
 	// Gateway 默认配置
 	v.SetDefault("gateway.host", "localhost")
@@ -75,6 +76,8 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("gateway.write_timeout", 30)
 
 	// 工具默认配置
+	v.SetDefault("tools.filesystem.checkpoints.enabled", true)
+	v.SetDefault("tools.filesystem.checkpoints.keep_runs", 20)
 	v.SetDefault("tools.shell.enabled", true)
 	v.SetDefault("tools.shell.timeout", 120)
 	v.SetDefault("tools.shell.sandbox.enabled", false)
@@ -83,11 +86,37 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("tools.shell.sandbox.remove", true)
 	v.SetDefault("tools.shell.sandbox.network", "none")
 	v.SetDefault("tools.shell.sandbox.privileged", false)
+	v.SetDefault("tools.shell.sandbox.user", "1000:1000")
+	v.SetDefault("tools.shell.sandbox.memory", "512m")
+	v.SetDefault("tools.shell.sandbox.cpus", 1.0)
+	v.SetDefault("tools.shell.sandbox.pids_limit", 256)
+	v.SetDefault("tools.shell.sandbox.disk", "256m")
+	v.SetDefault("tools.shell.sandbox.read_only_root", true)
+	v.SetDefault("tools.shell.sandbox.workspace_access", "ro")
+	v.SetDefault("tools.shell.sandbox.idle_timeout", 600)
 	v.SetDefault("tools.web.search_engine", "travily")
 	v.SetDefault("tools.web.timeout", 10)
+	v.SetDefault("tools.web.search_cache_ttl", 600)
 	v.SetDefault("tools.browser.enabled", false)
+	v.SetDefault("tools.output.max_chars", 20000)
 	v.SetDefault("browser.headless", true)
 	v.SetDefault("browser.timeout", 30)
+
+	// 审计日志默认启用
+	v.SetDefault("audit.enabled", true)
+
+	// 脱敏默认启用，会话文件默认保存原文
+	v.SetDefault("redaction.enabled", true)
+	v.SetDefault("redaction.tool_results", true)
+
+	// 不可信内容防护默认启用（启发式分类器，副作用工具需确认）
+	v.SetDefault("untrusted_content.enabled", true)
+	v.SetDefault("untrusted_content.classifier", "heuristic")
+	v.SetDefault("untrusted_content.require_approval", true)
+	v.SetDefault("untrusted_content.auto_approve_read_only", true)
+
+	// goclaw mcp serve --http 默认只监听本机
+	v.SetDefault("mcp.serve.addr", "127.0.0.1:18790")
 }
 
 // Save 保存配置到文件
@@ -140,6 +169,30 @@ func GetWorkspacePath(cfg *Config) (string, error) {
 	return filepath.Join(home, ".goclaw", "workspace"), nil
 }
 
+// GetAuditPath 获取审计日志路径
+func GetAuditPath(cfg *Config) (string, error) {
+	if cfg.Audit.Path != "" {
+		return cfg.Audit.Path, nil
+	}
+	workspace, err := GetWorkspacePath(cfg)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(workspace, ".audit", "audit.jsonl"), nil
+}
+
+// GetAccessPath 获取角色分配文件路径
+func GetAccessPath(cfg *Config) (string, error) {
+	if cfg.Access.Path != "" {
+		return cfg.Access.Path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".goclaw", "access.json"), nil
+}
+
 // Validate 验证配置
 func Validate(cfg *Config) error {
 	if err := validateAgents(cfg); err != nil {
@@ -318,6 +371,12 @@ func validateTools(cfg *Config) error {
 		return fmt.Errorf("web timeout must be positive")
 	}
 
+	for name, backend := range cfg.Tools.Web.Backends {
+		if backend.DailyQuota < 0 {
+			return fmt.Errorf("web backends.%s.daily_quota must not be negative", name)
+		}
+	}
+
 	// 浏览器工具配置验证
 	if cfg.Tools.Browser.Enabled {
 		if cfg.Tools.Browser.Timeout <= 0 {
@@ -6,14 +6,20 @@ import (
 
 // Config 是主配置结构
 type Config struct {
-	Workspace WorkspaceConfig `mapstructure:"workspace" json:"workspace"`
-	Agents    AgentsConfig    `mapstructure:"agents" json:"agents"`
-	Channels  ChannelsConfig  `mapstructure:"channels" json:"channels"`
-	Providers ProvidersConfig `mapstructure:"providers" json:"providers"`
-	Gateway   GatewayConfig   `mapstructure:"gateway" json:"gateway"`
-	Tools     ToolsConfig     `mapstructure:"tools" json:"tools"`
-	Approvals ApprovalsConfig `mapstructure:"approvals" json:"approvals"`
-	Memory    MemoryConfig    `mapstructure:"memory" json:"memory"`
+	Workspace WorkspaceConfig        `mapstructure:"workspace" json:"workspace"`
+	Agents    AgentsConfig           `mapstructure:"agents" json:"agents"`
+	Channels  ChannelsConfig         `mapstructure:"channels" json:"channels"`
+	Providers ProvidersConfig        `mapstructure:"providers" json:"providers"`
+	Gateway   GatewayConfig          `mapstructure:"gateway" json:"gateway"`
+	Tools     ToolsConfig            `mapstructure:"tools" json:"tools"`
+	Approvals ApprovalsConfig        `mapstructure:"approvals" json:"approvals"`
+	Memory    MemoryConfig           `mapstructure:"memory" json:"memory"`
+	Telemetry TelemetryConfig        `mapstructure:"telemetry" json:"telemetry"`
+	Audit     AuditConfig            `mapstructure:"audit" json:"audit"`
+	Redaction RedactionConfig        `mapstructure:"redaction" json:"redaction"`
+	Untrusted UntrustedContentConfig `mapstructure:"untrusted_content" json:"untrusted_content"`
+	Access    AccessConfig           `mapstructure:"access" json:"access"`
+	MCP       MCPConfig              `mapstructure:"mcp" json:"mcp"`
 	// Skills configuration (map[string]interface{} to be parsed by skills package)
 	Skills map[string]interface{} `mapstructure:"skills" json:"skills"`
 	// Agent 绑定配置
@@ -38,6 +44,28 @@ type AgentDefaults struct {
 	Temperature   float64          `mapstructure:"temperature" json:"temperature"`
 	MaxTokens     int              `mapstructure:"max_tokens" json:"max_tokens"`
 	Subagents     *SubagentsConfig `mapstructure:"subagents" json:"subagents"`
+	Budget        *RunBudgetConfig `mapstructure:"budget" json:"budget"`
+	// 按通道覆盖的运行预算（key 为通道名，如 telegram）
+	ChannelBudgets map[string]RunBudgetConfig `mapstructure:"channel_budgets" json:"channel_budgets"`
+	Recovery       RecoveryConfig             `mapstructure:"recovery" json:"recovery"`
+}
+
+// RecoveryConfig 中断运行的恢复配置
+type RecoveryConfig struct {
+	// Mode 启动时对中断运行的处理方式：notify（通知用户并提供 /retry）、resume（自动续跑）、off
+	Mode string `mapstructure:"mode" json:"mode"`
+	// ReadOnlyTools 续跑时允许重新执行的无副作用工具（为空时使用内置列表）
+	ReadOnlyTools []string `mapstructure:"read_only_tools" json:"read_only_tools"`
+}
+
+// RunBudgetConfig 单次 Agent 运行的预算配置（0 表示不限制）
+type RunBudgetConfig struct {
+	MaxWallTimeSeconds   int            `mapstructure:"max_wall_time_seconds" json:"max_wall_time_seconds"`     // 最大运行时长
+	MaxTotalTokens       int            `mapstructure:"max_total_tokens" json:"max_total_tokens"`               // 最大 token 总量
+	MaxCost              float64        `mapstructure:"max_cost" json:"max_cost"`                               // 最大费用（美元）
+	MaxToolCalls         map[string]int `mapstructure:"max_tool_calls" json:"max_tool_calls"`                   // 每个工具的最大调用次数，"*" 表示所有工具的默认值
+	InputCostPerMillion  float64        `mapstructure:"input_cost_per_million" json:"input_cost_per_million"`   // 每百万输入 token 的价格
+	OutputCostPerMillion float64        `mapstructure:"output_cost_per_million" json:"output_cost_per_million"` // 每百万输出 token 的价格
 }
 
 // SubagentsConfig 分身配置
@@ -47,6 +75,7 @@ type SubagentsConfig struct {
 	Model               string `mapstructure:"model" json:"model"`
 	Thinking            string `mapstructure:"thinking" json:"thinking"`
 	TimeoutSeconds      int    `mapstructure:"timeout_seconds" json:"timeout_seconds"`
+	MaxDepth            int    `mapstructure:"max_depth" json:"max_depth"` // 最大嵌套深度（1 表示分身不能再创建分身）
 }
 
 // AgentSubagentConfig 单 Agent 分身配置
@@ -55,6 +84,7 @@ type AgentSubagentConfig struct {
 	Model          string   `mapstructure:"model" json:"model"`
 	Thinking       string   `mapstructure:"thinking" json:"thinking"`
 	TimeoutSeconds int      `mapstructure:"timeout_seconds" json:"timeout_seconds"`
+	MaxConcurrent  int      `mapstructure:"max_concurrent" json:"max_concurrent"` // 该 Agent 的最大并发分身数
 	DenyTools      []string `mapstructure:"deny_tools" json:"deny_tools"`
 	AllowTools     []string `mapstructure:"allow_tools" json:"allow_tools"`
 }
@@ -70,6 +100,8 @@ type AgentConfig struct {
 	SystemPrompt string                 `mapstructure:"system_prompt" json:"system_prompt"` // 系统提示词
 	Metadata     map[string]interface{} `mapstructure:"metadata" json:"metadata"`           // 额外元数据
 	Subagents    *AgentSubagentConfig   `mapstructure:"subagents" json:"subagents"`         // 分身配置
+	Budget       *RunBudgetConfig       `mapstructure:"budget" json:"budget"`               // 运行预算
+	MCP          *AgentMCPConfig        `mapstructure:"mcp" json:"mcp"`                     // 可用的 MCP 工具
 }
 
 // AgentIdentity Agent 身份配置
@@ -297,12 +329,28 @@ type ToolsConfig struct {
 	Shell      ShellToolConfig      `mapstructure:"shell" json:"shell"`
 	Web        WebToolConfig        `mapstructure:"web" json:"web"`
 	Browser    BrowserToolConfig    `mapstructure:"browser" json:"browser"`
+	Output     OutputToolConfig     `mapstructure:"output" json:"output"`
+}
+
+// OutputToolConfig 工具输出缓存配置
+type OutputToolConfig struct {
+	MaxChars   int            `mapstructure:"max_chars" json:"max_chars"`     // 默认输出上限，超出部分写入缓存文件
+	ToolLimits map[string]int `mapstructure:"tool_limits" json:"tool_limits"` // 按工具名覆盖的上限（0 表示不限制）
+	HeadChars  int            `mapstructure:"head_chars" json:"head_chars"`   // 预览保留的开头字符数
+	TailChars  int            `mapstructure:"tail_chars" json:"tail_chars"`   // 预览保留的结尾字符数
 }
 
 // FileSystemToolConfig 文件系统工具配置
 type FileSystemToolConfig struct {
-	AllowedPaths []string `mapstructure:"allowed_paths" json:"allowed_paths"`
-	DeniedPaths  []string `mapstructure:"denied_paths" json:"denied_paths"`
+	AllowedPaths []string             `mapstructure:"allowed_paths" json:"allowed_paths"`
+	DeniedPaths  []string             `mapstructure:"denied_paths" json:"denied_paths"`
+	Checkpoints  FileCheckpointConfig `mapstructure:"checkpoints" json:"checkpoints"`
+}
+
+// FileCheckpointConfig 文件检查点配置（Agent 修改文件前保存原始内容，用于 /undo）
+type FileCheckpointConfig struct {
+	Enabled  bool `mapstructure:"enabled" json:"enabled"`
+	KeepRuns int  `mapstructure:"keep_runs" json:"keep_runs"` // 每个会话保留的运行数
 }
 
 // ShellToolConfig Shell 工具配置
@@ -315,21 +363,41 @@ type ShellToolConfig struct {
 	Sandbox     SandboxConfig `mapstructure:"sandbox" json:"sandbox"`
 }
 
-// SandboxConfig Docker 沙箱配置
+// SandboxConfig Docker 沙箱配置（每个会话一个常驻容器）
 type SandboxConfig struct {
-	Enabled    bool   `mapstructure:"enabled" json:"enabled"`
-	Image      string `mapstructure:"image" json:"image"`
-	Workdir    string `mapstructure:"workdir" json:"workdir"`
-	Remove     bool   `mapstructure:"remove" json:"remove"`
-	Network    string `mapstructure:"network" json:"network"`
-	Privileged bool   `mapstructure:"privileged" json:"privileged"`
+	Enabled         bool              `mapstructure:"enabled" json:"enabled"`
+	Image           string            `mapstructure:"image" json:"image"`
+	Workdir         string            `mapstructure:"workdir" json:"workdir"`
+	Remove          bool              `mapstructure:"remove" json:"remove"`                     // 回收时删除容器，false 时只停止
+	Network         string            `mapstructure:"network" json:"network"`                   // none（默认）、bridge 或自定义网络
+	Privileged      bool              `mapstructure:"privileged" json:"privileged"`
+	User            string            `mapstructure:"user" json:"user"`                         // 容器内执行命令的用户，默认 1000:1000
+	Memory          string            `mapstructure:"memory" json:"memory"`                     // 内存上限，例如 512m
+	CPUs            float64           `mapstructure:"cpus" json:"cpus"`                         // CPU 上限（核数）
+	PidsLimit       int64             `mapstructure:"pids_limit" json:"pids_limit"`             // 进程数上限
+	Disk            string            `mapstructure:"disk" json:"disk"`                         // 可写的 /tmp 大小上限，例如 256m
+	ReadOnlyRoot    bool              `mapstructure:"read_only_root" json:"read_only_root"`     // 只读根文件系统
+	WorkspaceAccess string            `mapstructure:"workspace_access" json:"workspace_access"` // 工作区挂载方式：ro（默认）、rw、none
+	IdleTimeout     int               `mapstructure:"idle_timeout" json:"idle_timeout"`         // 容器空闲多少秒后回收
+	SkillImages     map[string]string `mapstructure:"skill_images" json:"skill_images"`         // 技能名 -> 加载该技能后使用的镜像
 }
 
 // WebToolConfig Web 工具配置
 type WebToolConfig struct {
-	SearchAPIKey string `mapstructure:"search_api_key" json:"search_api_key"`
-	SearchEngine string `mapstructure:"search_engine" json:"search_engine"`
-	Timeout      int    `mapstructure:"timeout" json:"timeout"`
+	SearchAPIKey   string                         `mapstructure:"search_api_key" json:"search_api_key"`
+	SearchEngine   string                         `mapstructure:"search_engine" json:"search_engine"`
+	Timeout        int                            `mapstructure:"timeout" json:"timeout"`
+	SearchBackends []string                       `mapstructure:"search_backends" json:"search_backends"`   // 搜索后端的回退顺序，空则使用 search_engine 并以 duckduckgo 兜底
+	Backends       map[string]SearchBackendConfig `mapstructure:"backends" json:"backends"`                 // 按后端名称的配置
+	SearchCacheTTL int                            `mapstructure:"search_cache_ttl" json:"search_cache_ttl"` // 结果缓存秒数，默认 600，-1 不缓存
+}
+
+// SearchBackendConfig 搜索后端配置
+type SearchBackendConfig struct {
+	APIKey     string `mapstructure:"api_key" json:"api_key"`
+	Endpoint   string `mapstructure:"endpoint" json:"endpoint"`       // SearXNG 实例地址；其他后端可用来替换 API 地址
+	CX         string `mapstructure:"cx" json:"cx"`                   // Google Programmable Search Engine ID
+	DailyQuota int    `mapstructure:"daily_quota" json:"daily_quota"` // 每日请求上限（UTC），0 不限制
 }
 
 // BrowserToolConfig 浏览器工具配置
@@ -399,3 +467,104 @@ type QMDLimits struct {
 	MaxSnippetChars int `mapstructure:"max_snippet_chars" json:"max_snippet_chars"` // 默认 700
 	TimeoutMs       int `mapstructure:"timeout_ms" json:"timeout_ms"`               // 默认 4000
 }
+
+// TelemetryConfig OpenTelemetry 链路追踪配置
+type TelemetryConfig struct {
+	Enabled     bool              `mapstructure:"enabled" json:"enabled"`
+	ServiceName string            `mapstructure:"service_name" json:"service_name"` // 默认 goclaw
+	Exporter    string            `mapstructure:"exporter" json:"exporter"`         // "otlp"（默认）| "file"
+	Endpoint    string            `mapstructure:"endpoint" json:"endpoint"`         // OTLP/HTTP 地址，如 localhost:4318；空则使用 OTEL_EXPORTER_OTLP_* 环境变量
+	Insecure    bool              `mapstructure:"insecure" json:"insecure"`         // 使用 HTTP 而不是 HTTPS
+	Headers     map[string]string `mapstructure:"headers" json:"headers"`           // OTLP 请求头（如认证信息）
+	FilePath    string            `mapstructure:"file_path" json:"file_path"`       // file 导出器的输出文件，默认 ~/.goclaw/traces.jsonl
+	SampleRatio float64           `mapstructure:"sample_ratio" json:"sample_ratio"` // 采样比例 0-1，默认 1
+}
+
+// AuditConfig 工具调用审计日志配置
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled" json:"enabled"` // 默认启用
+	Path    string `mapstructure:"path" json:"path"`       // 审计日志路径，默认 workspace/.audit/audit.jsonl
+}
+
+// RedactionConfig 敏感信息脱敏配置
+type RedactionConfig struct {
+	Enabled     bool               `mapstructure:"enabled" json:"enabled"`           // 默认启用，作用于日志、审计日志和网关 logs.get
+	Detectors   []string           `mapstructure:"detectors" json:"detectors"`       // 启用的内置检测器，空则使用默认（不含 email、phone）
+	Patterns    []RedactionPattern `mapstructure:"patterns" json:"patterns"`         // 自定义正则
+	Secrets     []string           `mapstructure:"secrets" json:"secrets"`           // 额外的已知密钥值
+	EnvVars     []string           `mapstructure:"env_vars" json:"env_vars"`         // 额外视为密钥的环境变量名
+	Sessions    bool               `mapstructure:"sessions" json:"sessions"`         // 保存会话文件时脱敏，默认关闭
+	ToolResults bool               `mapstructure:"tool_results" json:"tool_results"` // 工具结果发给模型前脱敏，默认启用
+}
+
+// UntrustedContentConfig 不可信内容（网页、浏览器、搜索结果、文件）的提示词注入防护配置
+type UntrustedContentConfig struct {
+	Enabled             bool     `mapstructure:"enabled" json:"enabled"`                               // 默认启用，结果加来源标记并在系统提示词中说明
+	Tools               []string `mapstructure:"tools" json:"tools"`                                   // 结果视为不可信的工具，空则使用默认列表，支持 github__* 通配
+	Classifier          string   `mapstructure:"classifier" json:"classifier"`                         // off, heuristic（默认）, llm
+	ClassifierModel     string   `mapstructure:"classifier_model" json:"classifier_model"`             // llm 分类器使用的模型，空则使用默认模型
+	RequireApproval     bool     `mapstructure:"require_approval" json:"require_approval"`             // 读取不可信内容后，副作用工具需用户确认，默认启用
+	ApprovalTools       []string `mapstructure:"approval_tools" json:"approval_tools"`                 // 需要确认的工具，空则使用默认列表，支持通配
+	AutoApproveReadOnly bool     `mapstructure:"auto_approve_read_only" json:"auto_approve_read_only"` // 只读的 exec 命令（ls、cat、git status 等）无需确认，默认启用
+}
+
+// AccessConfig 按发送者角色（owner、member、guest）的访问控制
+// 角色分配保存在 Path 指向的文件中，由 goclaw access 命令管理
+type AccessConfig struct {
+	Enabled     bool                        `mapstructure:"enabled" json:"enabled"`           // 默认关闭，只使用通道的 allowed_ids
+	DefaultRole string                      `mapstructure:"default_role" json:"default_role"` // 未分配角色的发送者，默认 guest
+	Path        string                      `mapstructure:"path" json:"path"`                 // 角色分配文件，默认 ~/.goclaw/access.json
+	Roles       map[string]AccessRoleConfig `mapstructure:"roles" json:"roles"`               // 角色策略，同名时整体替换内置策略
+}
+
+// AccessRoleConfig 角色策略
+type AccessRoleConfig struct {
+	Agents       []string         `mapstructure:"agents" json:"agents"`               // 可访问的 Agent，空表示全部
+	AllowedTools []string         `mapstructure:"allowed_tools" json:"allowed_tools"` // 可用工具，空表示全部，支持 browser_* 通配
+	DeniedTools  []string         `mapstructure:"denied_tools" json:"denied_tools"`   // 禁用工具，优先于 allowed_tools
+	RateLimit    int              `mapstructure:"rate_limit" json:"rate_limit"`       // 每个发送者每分钟最多消息数，0 表示不限制
+	Budget       *RunBudgetConfig `mapstructure:"budget" json:"budget,omitempty"`     // 每次运行的预算，只会收紧 Agent 预算
+}
+
+// RedactionPattern 自定义脱敏规则
+type RedactionPattern struct {
+	Name        string `mapstructure:"name" json:"name"`
+	Pattern     string `mapstructure:"pattern" json:"pattern"`
+	Replacement string `mapstructure:"replacement" json:"replacement"` // 默认 [REDACTED]，支持 $1 分组引用
+}
+
+// MCPConfig MCP（Model Context Protocol）客户端配置
+type MCPConfig struct {
+	Servers []MCPServerConfig `mapstructure:"servers" json:"servers"`
+	Serve   MCPServeConfig    `mapstructure:"serve" json:"serve"` // goclaw mcp serve
+}
+
+// MCPServerConfig MCP 服务器，设置 command 时使用 stdio 传输，设置 url 时使用 Streamable HTTP 传输
+type MCPServerConfig struct {
+	Name       string            `mapstructure:"name" json:"name"`               // 服务器名称，作为工具名前缀（name__tool）
+	Disabled   bool              `mapstructure:"disabled" json:"disabled"`       // 暂时停用
+	Command    string            `mapstructure:"command" json:"command"`         // stdio：启动命令
+	Args       []string          `mapstructure:"args" json:"args"`               // stdio：命令参数
+	Env        []string          `mapstructure:"env" json:"env"`                 // stdio：额外环境变量（KEY=VALUE）
+	WorkingDir string            `mapstructure:"working_dir" json:"working_dir"` // stdio：工作目录
+	URL        string            `mapstructure:"url" json:"url"`                 // HTTP：服务器地址
+	Headers    map[string]string `mapstructure:"headers" json:"headers"`         // HTTP：额外请求头（如 Authorization）
+	Timeout    int               `mapstructure:"timeout" json:"timeout"`         // 单次请求超时（秒），默认 60
+	AllowTools []string          `mapstructure:"allow_tools" json:"allow_tools"` // 只注册这些工具（服务器端名称，支持 * 通配）
+	DenyTools  []string          `mapstructure:"deny_tools" json:"deny_tools"`   // 不注册这些工具，优先于 allow_tools
+}
+
+// MCPServeConfig goclaw 作为 MCP 服务器时的配置
+type MCPServeConfig struct {
+	Addr      string   `mapstructure:"addr" json:"addr"`             // HTTP 监听地址，默认 127.0.0.1:18790
+	Token     string   `mapstructure:"token" json:"token"`           // HTTP 客户端需携带的 Bearer token
+	Tools     []string `mapstructure:"tools" json:"tools"`           // 暴露的工具（支持 * 通配），空表示全部
+	DenyTools []string `mapstructure:"deny_tools" json:"deny_tools"` // 不暴露的工具，优先于 tools
+}
+
+// AgentMCPConfig Agent 可用的 MCP 工具，未配置时可使用全部 MCP 工具
+type AgentMCPConfig struct {
+	Servers    []string `mapstructure:"servers" json:"servers"`         // 可用的服务器，空表示全部
+	AllowTools []string `mapstructure:"allow_tools" json:"allow_tools"` // 可用的工具（带前缀的完整名称，如 github__*）
+	DenyTools  []string `mapstructure:"deny_tools" json:"deny_tools"`   // 禁用的工具，优先于 allow_tools
+}
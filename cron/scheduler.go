@@ -8,6 +8,7 @@ import (
 
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/metrics"
 	"github.com/smallnest/goclaw/providers"
 	"github.com/smallnest/goclaw/session"
 	"go.uber.org/zap"
@@ -139,12 +140,14 @@ func (s *Scheduler) createJobFunc(job *Job) func() {
 		)
 
 		// 执行任务
-		if err := s.executeJob(job); err != nil {
+		err := s.executeJob(job)
+		if err != nil {
 			logger.Error("Cron job execution failed",
 				zap.String("job_id", job.ID),
 				zap.Error(err),
 			)
 		}
+		metrics.RecordCronRun(job.ID, err)
 
 		// 更新任务状态
 		job.LastRun = time.Now()
@@ -1,14 +1,20 @@
 package gateway
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/channels"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/redact"
 	"github.com/smallnest/goclaw/session"
 	"go.uber.org/zap"
 )
@@ -19,6 +25,10 @@ type Handler struct {
 	bus        *bus.MessageBus
 	sessionMgr *session.Manager
 	channelMgr *channels.Manager
+
+	mu        sync.RWMutex
+	subagents SubagentDashboard
+	auditLog  *audit.Log
 }
 
 // NewHandler 创建处理器
@@ -42,9 +52,42 @@ func NewHandler(messageBus *bus.MessageBus, sessionMgr *session.Manager, channel
 	// 注册 Browser 方法
 	h.registerBrowserMethods()
 
+	// 注册分身方法
+	h.registerSubagentMethods()
+
 	return h
 }
 
+// SetAuditLog 设置审计日志，配置修改会写入审计日志
+func (h *Handler) SetAuditLog(log *audit.Log) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.auditLog = log
+}
+
+// auditConfigChange 记录通过网关进行的配置修改
+func (h *Handler) auditConfigChange(sessionID, method string, params map[string]interface{}) {
+	h.mu.RLock()
+	log := h.auditLog
+	h.mu.RUnlock()
+	if log == nil {
+		return
+	}
+
+	err := log.Append(&audit.Entry{
+		Type:      audit.TypeConfigChange,
+		Channel:   "gateway",
+		SenderID:  sessionID,
+		Tool:      method,
+		Arguments: audit.RedactArguments(params),
+		Status:    audit.StatusOK,
+		Approval:  audit.ApprovalAuto,
+	})
+	if err != nil {
+		logger.Error("Failed to write audit entry", zap.String("method", method), zap.Error(err))
+	}
+}
+
 // HandleRequest 处理请求
 func (h *Handler) HandleRequest(sessionID string, req *JSONRPCRequest) *JSONRPCResponse {
 	result, err := h.registry.Call(req.Method, sessionID, req.Params)
@@ -79,6 +122,7 @@ func (h *Handler) registerSystemMethods() {
 	h.registry.Register("config.set", func(sessionID string, params map[string]interface{}) (interface{}, error) {
 		key, _ := params["key"].(string)
 		value := params["value"]
+		h.auditConfigChange(sessionID, "config.set", params)
 		// 这里应该更新配置
 		return map[string]interface{}{
 			"key":   key,
@@ -101,14 +145,59 @@ func (h *Handler) registerSystemMethods() {
 		if l, ok := params["lines"].(float64); ok {
 			lines = int(l)
 		}
-		// 这里应该从日志中读取
+		logs, err := readLogTail(defaultLogPath(), lines)
+		if err != nil {
+			return nil, err
+		}
+		// 日志文件可能来自未启用脱敏的进程，返回前再脱敏一次
+		for i, line := range logs {
+			logs[i] = redact.String(line)
+		}
 		return map[string]interface{}{
 			"lines": lines,
-			"logs":  []string{}, // 实际应该返回日志
+			"logs":  logs,
 		}, nil
 	})
 }
 
+// defaultLogPath 返回默认日志文件路径（与 goclaw logs 相同）
+func defaultLogPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".goclaw", "logs", "goclaw.log")
+}
+
+// readLogTail 读取日志文件最后 n 行，文件不存在时返回空列表
+func readLogTail(path string, n int) ([]string, error) {
+	logs := []string{}
+	if path == "" || n <= 0 {
+		return logs, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return logs, nil
+		}
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		logs = append(logs, scanner.Text())
+		if len(logs) > n {
+			logs = logs[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return logs, nil
+}
+
 // registerAgentMethods 注册 Agent 方法
 func (h *Handler) registerAgentMethods() {
 	// agent - 发送消息给 Agent
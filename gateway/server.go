@@ -15,7 +15,9 @@ import (
 	"github.com/smallnest/goclaw/bus"
 	"github.com/smallnest/goclaw/channels"
 	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/audit"
 	"github.com/smallnest/goclaw/internal/logger"
+	"github.com/smallnest/goclaw/internal/metrics"
 	"github.com/smallnest/goclaw/session"
 	"go.uber.org/zap"
 )
@@ -97,7 +99,7 @@ func NewServer(cfg *config.GatewayConfig, messageBus *bus.MessageBus, channelMgr
 		writeTimeout = 10 * time.Second
 	}
 
-	return &Server{
+	s := &Server{
 		config: cfg,
 		wsConfig: &WebSocketConfig{
 			Host:           wsHost,
@@ -117,6 +119,33 @@ func NewServer(cfg *config.GatewayConfig, messageBus *bus.MessageBus, channelMgr
 		handler:     NewHandler(messageBus, sessionMgr, channelMgr),
 		connections: make(map[string]*Connection),
 	}
+	s.registerMetrics()
+	return s
+}
+
+// registerMetrics 注册在抓取 /metrics 时取值的仪表
+func (s *Server) registerMetrics() {
+	metrics.RegisterGauge("bus_inbound_queue_depth", "Inbound messages waiting in the bus queue.", func() float64 {
+		return float64(s.bus.InboundCount())
+	})
+	metrics.RegisterGauge("bus_outbound_queue_depth", "Outbound messages waiting in the bus queue.", func() float64 {
+		return float64(s.bus.OutboundCount())
+	})
+	metrics.RegisterGauge("websocket_connections", "Active WebSocket connections.", func() float64 {
+		s.connectionsMu.RLock()
+		defer s.connectionsMu.RUnlock()
+		return float64(len(s.connections))
+	})
+}
+
+// SetSubagentDashboard 设置分身看板（subagents.* 方法）
+func (s *Server) SetSubagentDashboard(dashboard SubagentDashboard) {
+	s.handler.SetSubagentDashboard(dashboard)
+}
+
+// SetAuditLog 设置审计日志（记录 config.set 等配置修改）
+func (s *Server) SetAuditLog(log *audit.Log) {
+	s.handler.SetAuditLog(log)
 }
 
 // SetWebSocketConfig 设置 WebSocket 配置
@@ -171,6 +200,9 @@ func (s *Server) startHTTPServer(ctx context.Context) error {
 	// Channels API 端点
 	mux.HandleFunc("/api/channels", s.handleChannelsAPI)
 
+	// Prometheus 指标端点
+	mux.Handle("/metrics", metrics.Handler())
+
 	// 飞书 webhook 端点
 	mux.HandleFunc("/webhook/feishu", s.handleFeishuWebhook)
 
@@ -213,6 +245,9 @@ func (s *Server) startWebSocketServer(ctx context.Context) error {
 	// Channels API 端点
 	mux.HandleFunc("/api/channels", s.handleChannelsAPI)
 
+	// Prometheus 指标端点
+	mux.Handle("/metrics", metrics.Handler())
+
 	// 创建 WebSocket 服务器
 	s.wsServer = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", s.wsConfig.Host, s.wsConfig.Port),
@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/smallnest/goclaw/agent/tools"
+)
+
+// SubagentDashboard 网关查看和管理分身所需的接口（由 AgentManager 实现）
+// requesterSessionKey 为空表示不限制请求者
+type SubagentDashboard interface {
+	ListSubagents(requesterSessionKey string) []*tools.SubagentInfo
+	GetSubagent(requesterSessionKey, runID string) (*tools.SubagentInfo, error)
+	CancelSubagent(requesterSessionKey, runID string) (*tools.SubagentInfo, error)
+	SubagentTranscript(requesterSessionKey, runID string) (string, error)
+	SubagentEvents(requesterSessionKey, runID string, since int) ([]tools.SubagentEvent, error)
+	SubagentStats() *tools.SubagentStats
+}
+
+// SetSubagentDashboard 设置分身看板，未设置时 subagents.* 方法返回错误
+func (h *Handler) SetSubagentDashboard(dashboard SubagentDashboard) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subagents = dashboard
+}
+
+// subagentDashboard 获取分身看板
+func (h *Handler) subagentDashboard() (SubagentDashboard, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.subagents == nil {
+		return nil, fmt.Errorf("subagents are not available on this gateway")
+	}
+	return h.subagents, nil
+}
+
+// registerSubagentMethods 注册分身方法
+func (h *Handler) registerSubagentMethods() {
+	// subagents.list - 列出分身及并发统计
+	h.registry.Register("subagents.list", func(sessionID string, params map[string]interface{}) (interface{}, error) {
+		dashboard, err := h.subagentDashboard()
+		if err != nil {
+			return nil, err
+		}
+
+		requester, _ := params["requester"].(string)
+		return map[string]interface{}{
+			"subagents": dashboard.ListSubagents(requester),
+			"stats":     dashboard.SubagentStats(),
+		}, nil
+	})
+
+	// subagents.get - 获取分身详情，可选包含完整记录和增量事件
+	h.registry.Register("subagents.get", func(sessionID string, params map[string]interface{}) (interface{}, error) {
+		dashboard, err := h.subagentDashboard()
+		if err != nil {
+			return nil, err
+		}
+
+		runID, ok := params["run_id"].(string)
+		if !ok || runID == "" {
+			return nil, fmt.Errorf("run_id parameter is required")
+		}
+
+		info, err := dashboard.GetSubagent("", runID)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{
+			"subagent": info,
+		}
+
+		if transcript, _ := params["transcript"].(bool); transcript {
+			text, err := dashboard.SubagentTranscript("", runID)
+			if err != nil {
+				return nil, err
+			}
+			result["transcript"] = text
+		}
+
+		if since, ok := params["events_since"].(float64); ok {
+			events, err := dashboard.SubagentEvents("", runID, int(since))
+			if err != nil {
+				return nil, err
+			}
+			if events == nil {
+				events = []tools.SubagentEvent{}
+			}
+			result["events"] = events
+		}
+
+		return result, nil
+	})
+
+	// subagents.cancel - 取消运行中或排队中的分身
+	h.registry.Register("subagents.cancel", func(sessionID string, params map[string]interface{}) (interface{}, error) {
+		dashboard, err := h.subagentDashboard()
+		if err != nil {
+			return nil, err
+		}
+
+		runID, ok := params["run_id"].(string)
+		if !ok || runID == "" {
+			return nil, fmt.Errorf("run_id parameter is required")
+		}
+
+		info, err := dashboard.CancelSubagent("", runID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"subagent": info,
+		}, nil
+	})
+}
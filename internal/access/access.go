@@ -0,0 +1,375 @@
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+// 内置角色
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+// Wildcard 匹配任意通道或发送者
+const Wildcard = "*"
+
+// internalChannels 内部通道（定时任务、系统事件）的消息视为 owner，除非显式分配了其他角色
+var internalChannels = map[string]bool{
+	"cron":   true,
+	"system": true,
+}
+
+// DefaultRoles 返回内置角色策略
+func DefaultRoles() map[string]config.AccessRoleConfig {
+	return map[string]config.AccessRoleConfig{
+		RoleOwner: {},
+		RoleMember: {
+			DeniedTools: []string{"update_config"},
+			RateLimit:   30,
+		},
+		RoleGuest: {
+			DeniedTools: []string{
+				"exec", "shell_*", "write_file", "edit_file", "multi_edit", "apply_patch", "update_config", "browser_*",
+				"spawn", "sessions_spawn", "handoff",
+			},
+			RateLimit: 10,
+			Budget: &config.RunBudgetConfig{
+				MaxTotalTokens: 50000,
+				MaxToolCalls:   map[string]int{"*": 20},
+			},
+		},
+	}
+}
+
+// Roles 返回内置角色与配置角色合并后的策略，同名时配置整体替换内置策略
+func Roles(cfg *config.AccessConfig) map[string]config.AccessRoleConfig {
+	roles := DefaultRoles()
+	for name, policy := range cfg.Roles {
+		roles[name] = policy
+	}
+	return roles
+}
+
+// Assignment 一条角色分配
+type Assignment struct {
+	Channel  string    `json:"channel"`
+	SenderID string    `json:"sender_id"`
+	Role     string    `json:"role"`
+	Updated  time.Time `json:"updated"`
+}
+
+// key 返回分配的查找键
+func (a Assignment) key() string {
+	return assignmentKey(a.Channel, a.SenderID)
+}
+
+// assignmentKey 构建查找键
+func assignmentKey(channel, senderID string) string {
+	return channel + ":" + senderID
+}
+
+// Store 角色分配文件，文件被 goclaw access 修改后自动重新加载
+type Store struct {
+	path        string
+	modTime     time.Time
+	assignments map[string]Assignment
+	mu          sync.Mutex
+}
+
+// OpenStore 打开角色分配文件，文件不存在时视为没有分配
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, assignments: make(map[string]Assignment)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path 返回文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// reload 文件修改时间变化时重新读取（调用方需持有锁或在初始化时调用）
+func (s *Store) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.assignments = make(map[string]Assignment)
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat access file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read access file: %w", err)
+	}
+	var file struct {
+		Assignments []Assignment `json:"assignments"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid access file %s: %w", s.path, err)
+	}
+
+	assignments := make(map[string]Assignment, len(file.Assignments))
+	for _, a := range file.Assignments {
+		assignments[a.key()] = a
+	}
+	s.assignments = assignments
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// save 写回文件（调用方需持有锁）
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create access directory: %w", err)
+	}
+	data, err := json.MarshalIndent(struct {
+		Assignments []Assignment `json:"assignments"`
+	}{Assignments: s.list()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write access file: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Assign 为发送者分配角色，channel 或 senderID 为 * 时匹配任意值
+func (s *Store) Assign(channel, senderID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+	a := Assignment{Channel: channel, SenderID: senderID, Role: role, Updated: time.Now().UTC()}
+	s.assignments[a.key()] = a
+	return s.save()
+}
+
+// Revoke 删除分配，返回是否存在
+func (s *Store) Revoke(channel, senderID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+	key := assignmentKey(channel, senderID)
+	if _, ok := s.assignments[key]; !ok {
+		return false, nil
+	}
+	delete(s.assignments, key)
+	return true, s.save()
+}
+
+// List 返回所有分配，按通道和发送者排序
+func (s *Store) List() ([]Assignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s.list(), nil
+}
+
+// list 排序后的分配（调用方需持有锁）
+func (s *Store) list() []Assignment {
+	result := make([]Assignment, 0, len(s.assignments))
+	for _, a := range s.assignments {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Channel != result[j].Channel {
+			return result[i].Channel < result[j].Channel
+		}
+		return result[i].SenderID < result[j].SenderID
+	})
+	return result
+}
+
+// lookup 按 通道:发送者、*:发送者、通道:* 的顺序查找角色
+func (s *Store) lookup(channel, senderID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 读取失败时继续使用上次加载的分配
+	_ = s.reload()
+	for _, key := range []string{
+		assignmentKey(channel, senderID),
+		assignmentKey(Wildcard, senderID),
+		assignmentKey(channel, Wildcard),
+	} {
+		if a, ok := s.assignments[key]; ok {
+			return a.Role, true
+		}
+	}
+	return "", false
+}
+
+// Controller 解析发送者角色并执行角色策略
+type Controller struct {
+	defaultRole string
+	roles       map[string]config.AccessRoleConfig
+	store       *Store
+	limiter     *rateLimiter
+}
+
+// New 创建访问控制器，未启用时返回 nil
+func New(cfg *config.AccessConfig, store *Store) (*Controller, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	roles := Roles(cfg)
+	defaultRole := cfg.DefaultRole
+	if defaultRole == "" {
+		defaultRole = RoleGuest
+	}
+	if _, ok := roles[defaultRole]; !ok {
+		return nil, fmt.Errorf("unknown access.default_role: %s", defaultRole)
+	}
+
+	return &Controller{
+		defaultRole: defaultRole,
+		roles:       roles,
+		store:       store,
+		limiter:     newRateLimiter(time.Minute),
+	}, nil
+}
+
+// NewFromConfig 按配置打开角色分配文件并创建访问控制器，未启用时返回 nil
+func NewFromConfig(cfg *config.Config) (*Controller, error) {
+	if !cfg.Access.Enabled {
+		return nil, nil
+	}
+	path, err := config.GetAccessPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store, err := OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(&cfg.Access, store)
+}
+
+// Role 返回发送者的角色
+func (c *Controller) Role(channel, senderID string) string {
+	if c.store != nil {
+		if role, ok := c.store.lookup(channel, senderID); ok {
+			if _, known := c.roles[role]; known {
+				return role
+			}
+		}
+	}
+	if internalChannels[channel] {
+		return RoleOwner
+	}
+	return c.defaultRole
+}
+
+// Policy 返回角色策略，未知角色使用默认角色的策略
+func (c *Controller) Policy(role string) config.AccessRoleConfig {
+	if policy, ok := c.roles[role]; ok {
+		return policy
+	}
+	return c.roles[c.defaultRole]
+}
+
+// CanReachAgent 判断角色是否可以访问 Agent
+func (c *Controller) CanReachAgent(role, agentID string) bool {
+	agents := c.Policy(role).Agents
+	if len(agents) == 0 {
+		return true
+	}
+	for _, id := range agents {
+		if id == Wildcard || id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMessage 按角色的速率限制记录一条消息，超出限制时返回 false
+func (c *Controller) AllowMessage(channel, senderID, role string) bool {
+	limit := c.Policy(role).RateLimit
+	if limit <= 0 {
+		return true
+	}
+	return c.limiter.allow(assignmentKey(channel, senderID), limit, time.Now())
+}
+
+// MatchTool 判断工具名是否匹配模式（支持 * 通配）
+func MatchTool(pattern, toolName string) bool {
+	if pattern == toolName || pattern == Wildcard {
+		return true
+	}
+	if strings.Contains(pattern, "*") {
+		matched, err := filepath.Match(pattern, toolName)
+		return err == nil && matched
+	}
+	return false
+}
+
+// rateLimiter 滑动窗口限流
+type rateLimiter struct {
+	window time.Duration
+	hits   map[string][]time.Time
+	mu     sync.Mutex
+}
+
+// newRateLimiter 创建限流器
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{window: window, hits: make(map[string][]time.Time)}
+}
+
+// allow 窗口内的请求数未达到 limit 时记录本次请求并返回 true
+func (l *rateLimiter) allow(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) >= limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+
+	// 清理长时间没有请求的发送者
+	for k, h := range l.hits {
+		if len(h) == 0 || !h[len(h)-1].After(cutoff) {
+			delete(l.hits, k)
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package access
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+func newTestController(t *testing.T) (*Controller, *Store) {
+	t.Helper()
+	store, err := OpenStore(filepath.Join(t.TempDir(), "access.json"))
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	ctl, err := New(&config.AccessConfig{
+		Enabled: true,
+		Roles: map[string]config.AccessRoleConfig{
+			"support": {Agents: []string{"helpdesk"}, AllowedTools: []string{"read_file", "web_*"}},
+		},
+	}, store)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return ctl, store
+}
+
+func TestRoleResolution(t *testing.T) {
+	ctl, store := newTestController(t)
+
+	if err := store.Assign("telegram", "42", RoleOwner); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if err := store.Assign("*", "U123", RoleMember); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if err := store.Assign("websocket", "*", "support"); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+
+	tests := []struct {
+		channel, sender, want string
+	}{
+		{"telegram", "42", RoleOwner},
+		{"slack", "U123", RoleMember},
+		{"websocket", "session-1", "support"},
+		{"telegram", "99", RoleGuest},
+		{"cron", "job-1", RoleOwner},
+	}
+	for _, tt := range tests {
+		if got := ctl.Role(tt.channel, tt.sender); got != tt.want {
+			t.Errorf("Role(%s, %s) = %s, want %s", tt.channel, tt.sender, got, tt.want)
+		}
+	}
+
+	// Changes made by another process (goclaw access) are picked up
+	other, err := OpenStore(store.Path())
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := other.Revoke("telegram", "42"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if got := ctl.Role("telegram", "42"); got != RoleGuest {
+		t.Errorf("expected revoked sender to fall back to guest, got %s", got)
+	}
+
+	if !ctl.CanReachAgent("support", "helpdesk") || ctl.CanReachAgent("support", "default") {
+		t.Error("support role should only reach the helpdesk agent")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := newRateLimiter(time.Minute)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !limiter.allow("telegram:1", 3, now) {
+			t.Fatalf("message %d should be allowed", i+1)
+		}
+	}
+	if limiter.allow("telegram:1", 3, now) {
+		t.Error("4th message within a minute should be rejected")
+	}
+	if !limiter.allow("telegram:2", 3, now) {
+		t.Error("other senders have their own limit")
+	}
+	if !limiter.allow("telegram:1", 3, now.Add(61*time.Second)) {
+		t.Error("limit should reset after the window")
+	}
+}
+
+func TestMatchTool(t *testing.T) {
+	if !MatchTool("browser_*", "browser_click") || MatchTool("browser_*", "web_fetch") {
+		t.Error("wildcard pattern mismatch")
+	}
+	if !MatchTool("exec", "exec") || MatchTool("exec", "exec2") {
+		t.Error("exact pattern mismatch")
+	}
+}
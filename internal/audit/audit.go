@@ -0,0 +1,359 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+// 记录类型
+const (
+	TypeToolCall     = "tool_call"
+	TypeConfigChange = "config_change"
+)
+
+// 执行结果
+const (
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// 审批状态
+const (
+	ApprovalAuto     = "auto"     // 未经人工审批直接执行
+	ApprovalRequired = "required" // 需要用户确认，未执行
+)
+
+// Entry 一条审计记录
+// Hash 覆盖除 Hash 以外的所有字段（包括上一条的 Hash），任何修改、删除或重排都会使链断开
+type Entry struct {
+	Seq          int64           `json:"seq"`
+	Time         time.Time       `json:"time"`
+	Type         string          `json:"type"`
+	Channel      string          `json:"channel,omitempty"`
+	AccountID    string          `json:"account_id,omitempty"`
+	ChatID       string          `json:"chat_id,omitempty"`
+	SenderID     string          `json:"sender_id,omitempty"`
+	AgentID      string          `json:"agent_id,omitempty"`
+	SessionKey   string          `json:"session_key,omitempty"`
+	Tool         string          `json:"tool"`
+	ToolCallID   string          `json:"tool_call_id,omitempty"`
+	Arguments    json.RawMessage `json:"arguments,omitempty"`
+	ResultDigest string          `json:"result_digest,omitempty"`
+	ResultSize   int             `json:"result_size,omitempty"`
+	Status       string          `json:"status"`
+	Error        string          `json:"error,omitempty"`
+	Approval     string          `json:"approval,omitempty"`
+	DurationMs   int64           `json:"duration_ms"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// computeHash 计算记录的哈希
+func (e *Entry) computeHash() (string, error) {
+	unsigned := *e
+	unsigned.Hash = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Digest 返回结果内容的摘要，审计日志只保存摘要而不保存原文
+func Digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Log 只追加的哈希链审计日志
+// 网关、goclaw agent、TUI 和 goclaw mcp serve 可能同时写同一个文件，
+// 每次追加都持有文件锁，并在文件被其他进程追加过时重新读取最后一条记录
+type Log struct {
+	path     string
+	file     *os.File
+	seq      int64
+	lastHash string
+	size     int64 // 上次追加后的文件大小，-1 表示未知
+	mu       sync.Mutex
+}
+
+// Open 打开（或创建）审计日志，从最后一条记录继续哈希链
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	// 打开时读到的最后一条记录可能已被其他进程追加的记录取代，第一次追加时重新读取
+	log := &Log{path: path, size: -1}
+	err := scan(path, func(entry *Entry) error {
+		log.seq = entry.Seq
+		log.lastHash = entry.Hash
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	log.file = file
+	return log, nil
+}
+
+// OpenFromConfig 按配置打开审计日志，未启用时返回 nil
+func OpenFromConfig(cfg *config.Config) (*Log, error) {
+	if !cfg.Audit.Enabled {
+		return nil, nil
+	}
+	path, err := config.GetAuditPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return Open(path)
+}
+
+// Path 返回审计日志路径
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append 追加一条记录，填充序号、时间和哈希
+func (l *Log) Append(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+
+	if err := lockFile(l.file); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlockFile(l.file)
+	if err := l.sync(); err != nil {
+		return err
+	}
+
+	entry.Seq = l.seq + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.PrevHash = l.lastHash
+	hash, err := entry.computeHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit entry: %w", err)
+	}
+	entry.Hash = hash
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := l.file.Write(data); err != nil {
+		l.size = -1
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	l.seq = entry.Seq
+	l.lastHash = entry.Hash
+	l.size += int64(len(data))
+	return nil
+}
+
+// sync 文件大小与上次追加后不同时（其他进程追加过），从最后一条记录继续哈希链（调用方持有文件锁）
+func (l *Log) sync() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	if info.Size() == l.size {
+		return nil
+	}
+
+	last, err := lastEntry(l.file, info.Size())
+	if err != nil {
+		return err
+	}
+	l.seq, l.lastHash = 0, ""
+	if last != nil {
+		l.seq, l.lastHash = last.Seq, last.Hash
+	}
+	l.size = info.Size()
+	return nil
+}
+
+// lastEntry 从文件末尾向前读取最后一条记录，文件为空时返回 nil
+func lastEntry(f *os.File, size int64) (*Entry, error) {
+	const chunk = 64 << 10
+	var buf []byte
+	for off := size; off > 0; {
+		n := min(chunk, off)
+		off -= n
+		block := make([]byte, n)
+		if _, err := f.ReadAt(block, off); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		buf = append(block, buf...)
+
+		data := bytes.TrimRight(buf, "\n")
+		i := bytes.LastIndexByte(data, '\n')
+		if i < 0 && off > 0 {
+			continue
+		}
+		line := data[i+1:]
+		if len(line) == 0 {
+			return nil, nil
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse last audit entry: %w", err)
+		}
+		return &entry, nil
+	}
+	return nil, nil
+}
+
+// Close 关闭审计日志
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Filter 查询条件，零值字段不参与过滤
+type Filter struct {
+	Since  time.Time
+	Tool   string
+	Sender string
+	Type   string
+	Limit  int // 只返回最近的 Limit 条
+}
+
+// match 判断记录是否满足条件
+func (f Filter) match(entry *Entry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if f.Tool != "" && entry.Tool != f.Tool {
+		return false
+	}
+	if f.Sender != "" && entry.SenderID != f.Sender {
+		return false
+	}
+	if f.Type != "" && entry.Type != f.Type {
+		return false
+	}
+	return true
+}
+
+// Query 按条件读取审计记录，按时间顺序返回
+func Query(path string, filter Filter) ([]*Entry, error) {
+	var entries []*Entry
+	err := scan(path, func(entry *Entry) error {
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// ChainError 哈希链校验失败
+type ChainError struct {
+	Line   int
+	Seq    int64
+	Reason string
+}
+
+// Error 实现 error 接口
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Verify 校验整条哈希链，返回已校验的记录数；链断开时返回 *ChainError
+func Verify(path string) (int, error) {
+	count := 0
+	var prevHash string
+	var prevSeq int64
+	err := scan(path, func(entry *Entry) error {
+		line := count + 1
+		if entry.Seq != prevSeq+1 {
+			return &ChainError{Line: line, Seq: entry.Seq, Reason: fmt.Sprintf("expected seq %d", prevSeq+1)}
+		}
+		if entry.PrevHash != prevHash {
+			return &ChainError{Line: line, Seq: entry.Seq, Reason: "prev_hash does not match the previous entry"}
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return &ChainError{Line: line, Seq: entry.Seq, Reason: "entry hash mismatch (entry was modified)"}
+		}
+		prevHash = entry.Hash
+		prevSeq = entry.Seq
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// scan 逐行读取审计日志
+func scan(path string, fn func(entry *Entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	lineNo := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			lineNo++
+			var entry Entry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				return &ChainError{Line: lineNo, Reason: fmt.Sprintf("invalid JSON: %v", jsonErr)}
+			}
+			if fnErr := fn(&entry); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package audit
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile 对文件加排他锁，其他进程的 Append 会等待
+func lockFile(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
+
+// unlockFile 释放文件锁
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/smallnest/goclaw/internal/redact"
+)
+
+// RedactArguments 使用全局脱敏引擎处理工具参数并编码为 JSON
+func RedactArguments(args map[string]any) json.RawMessage {
+	if len(args) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(redact.Default().Map(args))
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -21,13 +21,22 @@ var (
 func Init(level string, development bool) error {
 	var initErr error
 	once.Do(func() {
-		initErr = doInit(level, development)
+		initErr = doInit(level, development, "stdout")
+	})
+	return initErr
+}
+
+// InitStderr 初始化日志并输出到 stderr，用于 stdout 承载协议数据的场景（如 goclaw mcp serve）
+func InitStderr(level string) error {
+	var initErr error
+	once.Do(func() {
+		initErr = doInit(level, false, "stderr")
 	})
 	return initErr
 }
 
 // doInit 执行实际的日志初始化
-func doInit(level string, development bool) error {
+func doInit(level string, development bool, output string) error {
 	// 解析日志级别
 	var zapLevel zapcore.Level
 	switch level {
@@ -62,12 +71,14 @@ func doInit(level string, development bool) error {
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 		},
-		OutputPaths:      []string{"stdout"},
+		OutputPaths:      []string{output},
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
-	// 创建 logger
-	newLog, err := config.Build(zap.AddCallerSkip(1))
+	// 创建 logger（写出前经过脱敏，见 SetRedactor）
+	newLog, err := config.Build(zap.AddCallerSkip(1), zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactCore{Core: core}
+	}))
 	if err != nil {
 		return err
 	}
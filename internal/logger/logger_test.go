@@ -1,10 +1,14 @@
 package logger
 
 import (
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestConcurrentInit(t *testing.T) {
@@ -55,3 +59,84 @@ func TestConcurrentAccess(t *testing.T) {
 	wg.Wait()
 	_ = Sync()
 }
+
+// maskRedactor 把 secret 替换为 ***，字段名为 token 时整个值替换
+type maskRedactor struct{}
+
+func (maskRedactor) String(s string) string {
+	return strings.ReplaceAll(s, "secret", "***")
+}
+
+func (m maskRedactor) Value(key string, value interface{}) interface{} {
+	if key == "token" {
+		return "***"
+	}
+	if s, ok := value.(string); ok {
+		return m.String(s)
+	}
+	if args, ok := value.(map[string]interface{}); ok {
+		out := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			out[k] = m.Value(k, v)
+		}
+		return out
+	}
+	if items, ok := value.([]interface{}); ok {
+		out := make([]interface{}, len(items))
+		for i, v := range items {
+			out[i] = m.Value("", v)
+		}
+		return out
+	}
+	return value
+}
+
+// secretObject 带有密钥字段的 ObjectMarshaler
+type secretObject struct{}
+
+func (secretObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("url", "https://secret.example")
+	enc.AddString("token", "abc")
+	return nil
+}
+
+func TestRedactCore(t *testing.T) {
+	SetRedactor(maskRedactor{})
+	defer SetRedactor(nil)
+
+	core, logs := observer.New(zap.DebugLevel)
+	l := zap.New(&redactCore{Core: core}).With(zap.String("session", "secret-session"))
+	l.Info("got secret",
+		zap.String("token", "abc"),
+		zap.Error(errors.New("bad secret")),
+		zap.Any("arguments", map[string]interface{}{"cmd": "echo secret", "token": "abc"}))
+
+	entry := logs.All()[0]
+	if entry.Message != "got ***" {
+		t.Errorf("message not redacted: %q", entry.Message)
+	}
+	fields := entry.ContextMap()
+	if fields["session"] != "***-session" || fields["token"] != "***" || fields["error"] != "bad ***" {
+		t.Errorf("fields not redacted: %v", fields)
+	}
+	args := fields["arguments"].(map[string]interface{})
+	if args["cmd"] != "echo ***" || args["token"] != "***" {
+		t.Errorf("arguments not redacted: %v", args)
+	}
+
+	l.Info("marshalers",
+		zap.Strings("argv", []string{"curl", "secret"}),
+		zap.Object("request", secretObject{}),
+		zap.Inline(secretObject{}),
+		zap.ByteString("body", []byte("a secret")))
+	fields = logs.All()[1].ContextMap()
+	if argv := fields["argv"].([]interface{}); argv[1] != "***" {
+		t.Errorf("zap.Strings not redacted: %v", argv)
+	}
+	if req := fields["request"].(map[string]interface{}); req["url"] != "https://***.example" || req["token"] != "***" {
+		t.Errorf("zap.Object not redacted: %v", req)
+	}
+	if fields["url"] != "https://***.example" || fields["token"] != "***" || fields["body"] != "a ***" {
+		t.Errorf("inline and byte string fields not redacted: %v", fields)
+	}
+}
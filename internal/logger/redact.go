@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redactor 日志脱敏，由 internal/redact 实现
+type Redactor interface {
+	String(s string) string
+	Value(key string, value interface{}) interface{}
+}
+
+// redactorHolder 包装接口以便原子替换
+type redactorHolder struct {
+	r Redactor
+}
+
+var currentRedactor atomic.Pointer[redactorHolder]
+
+// SetRedactor 设置日志脱敏器，之后写出的日志消息和字段都会先脱敏；传 nil 关闭
+func SetRedactor(r Redactor) {
+	if r == nil {
+		currentRedactor.Store(nil)
+		return
+	}
+	currentRedactor.Store(&redactorHolder{r: r})
+}
+
+// redactor 返回当前的脱敏器
+func redactor() Redactor {
+	if h := currentRedactor.Load(); h != nil {
+		return h.r
+	}
+	return nil
+}
+
+// redactCore 在写出前脱敏日志消息和字段
+type redactCore struct {
+	zapcore.Core
+}
+
+// With 实现 zapcore.Core
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	if r := redactor(); r != nil {
+		fields = redactFields(r, fields)
+	}
+	return &redactCore{Core: c.Core.With(fields)}
+}
+
+// Check 实现 zapcore.Core
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write 实现 zapcore.Core
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if r := redactor(); r != nil {
+		ent.Message = r.String(ent.Message)
+		fields = redactFields(r, fields)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// redactFields 脱敏字符串、错误、数组、对象和任意值字段
+func redactFields(r Redactor, fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			if s, ok := r.Value(f.Key, f.String).(string); ok {
+				f.String = s
+			}
+		case zapcore.ByteStringType:
+			if b, ok := f.Interface.([]byte); ok {
+				f = zap.String(f.Key, r.String(string(b)))
+			}
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = zap.String(f.Key, r.String(err.Error()))
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+				f = zap.String(f.Key, r.String(s.String()))
+			}
+		case zapcore.ReflectType:
+			f.Interface = r.Value(f.Key, f.Interface)
+		case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+			// zap.Strings、zap.Object 等先编码为切片或 map 再脱敏
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			f = zap.Reflect(f.Key, r.Value(f.Key, enc.Fields[f.Key]))
+		case zapcore.InlineMarshalerType:
+			// zap.Inline 的字段展开到上一层
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			keys := make([]string, 0, len(enc.Fields))
+			for k := range enc.Fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				out = append(out, zap.Reflect(k, r.Value(k, enc.Fields[k])))
+			}
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
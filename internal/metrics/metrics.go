@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace 指标名前缀
+const namespace = "goclaw"
+
+// 工具调用和定时任务的结果
+const (
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// registry 独立的注册表，避免与依赖库注册到默认注册表的指标混在一起
+var registry = prometheus.NewRegistry()
+
+var (
+	inboundMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inbound_messages_total",
+		Help:      "Inbound messages published to the bus, by channel.",
+	}, []string{"channel"})
+
+	outboundMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbound_messages_total",
+		Help:      "Outbound messages published to the bus, by channel.",
+	}, []string{"channel"})
+
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "agent_run_duration_seconds",
+		Help:      "Duration of agent runs.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"agent", "status"})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM requests, by model and provider profile.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"model", "profile", "status"})
+
+	llmTokens = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens",
+		Help:      "Tokens used per LLM request, by model, provider profile and type (prompt or completion).",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"model", "profile", "type"})
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Tool calls, by tool and status (ok, error or skipped).",
+	}, []string{"tool", "status"})
+
+	cronRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cron_runs_total",
+		Help:      "Cron job runs, by job and status.",
+	}, []string{"job", "status"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		inboundMessages,
+		outboundMessages,
+		runDuration,
+		llmDuration,
+		llmTokens,
+		toolCalls,
+		cronRuns,
+	)
+}
+
+// Handler 返回 /metrics 的 HTTP 处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Registry 返回 goclaw 指标所在的注册表
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// RecordInbound 记录一条入站消息
+func RecordInbound(channel string) {
+	inboundMessages.WithLabelValues(channel).Inc()
+}
+
+// RecordOutbound 记录一条出站消息
+func RecordOutbound(channel string) {
+	outboundMessages.WithLabelValues(channel).Inc()
+}
+
+// ObserveRun 记录一次 Agent 运行的耗时
+func ObserveRun(agentID string, duration time.Duration, err error) {
+	runDuration.WithLabelValues(agentID, status(err)).Observe(duration.Seconds())
+}
+
+// ObserveLLM 记录一次 LLM 请求的耗时和 token 用量
+func ObserveLLM(model, profile string, duration time.Duration, promptTokens, completionTokens int, err error) {
+	llmDuration.WithLabelValues(model, profile, status(err)).Observe(duration.Seconds())
+	if err != nil {
+		return
+	}
+	llmTokens.WithLabelValues(model, profile, "prompt").Observe(float64(promptTokens))
+	llmTokens.WithLabelValues(model, profile, "completion").Observe(float64(completionTokens))
+}
+
+// RecordToolCall 记录一次工具调用，status 为 StatusOK、StatusError 或 StatusSkipped
+func RecordToolCall(tool, status string) {
+	toolCalls.WithLabelValues(tool, status).Inc()
+}
+
+// RecordCronRun 记录一次定时任务执行结果
+func RecordCronRun(job string, err error) {
+	cronRuns.WithLabelValues(job, status(err)).Inc()
+}
+
+// status 根据错误返回结果标签
+func status(err error) string {
+	if err != nil {
+		return StatusError
+	}
+	return StatusOK
+}
+
+// RegisterGauge 注册在抓取时取值的仪表，同名仪表会被替换
+func RegisterGauge(name, help string, fn func() float64) {
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// RegisterLabeledGauge 注册带一个标签的仪表，fn 返回标签值到取值的映射，同名仪表会被替换
+func RegisterLabeledGauge(name, help, label string, fn func() map[string]float64) {
+	register(&labeledGauge{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, []string{label}, nil),
+		fn:   fn,
+	})
+}
+
+// registerMu 保证替换同名仪表时的注销和注册是原子的
+var registerMu sync.Mutex
+
+// register 注册采集器，已存在同名采集器时先注销旧的
+// 网关或提供商重新创建时（如测试、配置重载）会再次注册
+func register(c prometheus.Collector) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	err := registry.Register(c)
+	var exists prometheus.AlreadyRegisteredError
+	if errors.As(err, &exists) {
+		registry.Unregister(exists.ExistingCollector)
+		err = registry.Register(c)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// labeledGauge 在抓取时调用 fn 生成一组带标签的仪表值
+type labeledGauge struct {
+	desc *prometheus.Desc
+	fn   func() map[string]float64
+}
+
+// Describe 实现 prometheus.Collector
+func (g *labeledGauge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect 实现 prometheus.Collector
+func (g *labeledGauge) Collect(ch chan<- prometheus.Metric) {
+	for label, value := range g.fn() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, label)
+	}
+}
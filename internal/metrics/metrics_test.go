@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/internal/metrics"
+)
+
+// scrape 抓取 /metrics 的文本输出
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	return string(body)
+}
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	messageBus := bus.NewMessageBus(10)
+	defer messageBus.Close()
+	if err := messageBus.PublishInbound(context.Background(), &bus.InboundMessage{Channel: "telegram", Content: "hi"}); err != nil {
+		t.Fatalf("publish inbound failed: %v", err)
+	}
+
+	metrics.ObserveRun("main", 2*time.Second, nil)
+	metrics.ObserveLLM("gpt-4o", "primary", time.Second, 120, 30, nil)
+	metrics.RecordToolCall("read_file", metrics.StatusOK)
+	metrics.RecordToolCall("read_file", metrics.StatusError)
+	metrics.RecordCronRun("daily", errors.New("bus closed"))
+
+	body := scrape(t)
+	for _, want := range []string{
+		`goclaw_inbound_messages_total{channel="telegram"} 1`,
+		`goclaw_agent_run_duration_seconds_count{agent="main",status="ok"} 1`,
+		`goclaw_llm_request_duration_seconds_count{model="gpt-4o",profile="primary",status="ok"} 1`,
+		`goclaw_llm_tokens_sum{model="gpt-4o",profile="primary",type="prompt"} 120`,
+		`goclaw_tool_calls_total{status="error",tool="read_file"} 1`,
+		`goclaw_cron_runs_total{job="daily",status="error"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in metrics output", want)
+		}
+	}
+}
+
+func TestRegisterGaugeReplacesExisting(t *testing.T) {
+	metrics.RegisterGauge("test_queue_depth", "Test gauge.", func() float64 { return 1 })
+	metrics.RegisterGauge("test_queue_depth", "Test gauge.", func() float64 { return 7 })
+	metrics.RegisterLabeledGauge("test_circuit_open", "Test labeled gauge.", "profile", func() map[string]float64 {
+		return map[string]float64{"primary": 1, "backup": 0}
+	})
+	metrics.RegisterLabeledGauge("test_circuit_open", "Test labeled gauge.", "profile", func() map[string]float64 {
+		return map[string]float64{"primary": 0}
+	})
+
+	body := scrape(t)
+	for _, want := range []string{
+		"goclaw_test_queue_depth 7",
+		`goclaw_test_circuit_open{profile="primary"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in metrics output:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `profile="backup"`) {
+		t.Error("expected replaced gauge to drop old values")
+	}
+}
@@ -0,0 +1,322 @@
+package redact
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/smallnest/goclaw/config"
+)
+
+// Placeholder 替换已知密钥和敏感字段值的占位符
+const Placeholder = "[REDACTED]"
+
+// minSecretLength 短于此长度的已知值不参与替换，避免误伤普通文本
+const minSecretLength = 8
+
+// Detector 内置或自定义的敏感信息检测器
+type Detector struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string // 支持 $1 等分组引用
+}
+
+// builtinDetectors 内置检测器，顺序即应用顺序
+var builtinDetectors = []Detector{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[REDACTED_PRIVATE_KEY]"},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`), "[REDACTED_JWT]"},
+	{"bearer", regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9._~+/\-]{16,}=*`), "$1 " + Placeholder},
+	{"openai_key", regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`), "[REDACTED_API_KEY]"},
+	{"github_token", regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr|github_pat)_[A-Za-z0-9_]{20,}`), "[REDACTED_API_KEY]"},
+	{"slack_token", regexp.MustCompile(`\bxox[abpors]-[A-Za-z0-9\-]{10,}`), "[REDACTED_API_KEY]"},
+	{"aws_access_key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), "[REDACTED_API_KEY]"},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`), "[REDACTED_API_KEY]"},
+	{"telegram_bot_token", regexp.MustCompile(`\b\d{8,10}:[A-Za-z0-9_\-]{35,}`), "[REDACTED_API_KEY]"},
+	{"url_credentials", regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "$1" + Placeholder + "@"},
+	{"api_key", regexp.MustCompile(`(?i)(api[_-]?key|apikey|secret|token)(["'\s:=]+)([A-Za-z0-9_\-]{16,})`), "$1$2[REDACTED_API_KEY]"},
+	{"password", regexp.MustCompile(`(?i)(password|passwd|pwd)("?\s*[:=]\s*"?)([^\s"',}]+)`), "$1$2[REDACTED_PASSWORD]"},
+	{"email", regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`), "[REDACTED_EMAIL]"},
+	{"phone", regexp.MustCompile(`\b1[3-9]\d{9}\b`), "[REDACTED_PHONE]"},
+}
+
+// PIIDetectors 个人信息检测器，默认不启用（日志中的邮箱、手机号通常是排查问题需要的）
+var PIIDetectors = []string{"email", "phone"}
+
+// sensitiveKeyPattern 字段名以这些词结尾时整个值都会被替换（max_tokens 之类不受影响）
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(api[_-]?key|apikey|token|secret|passw(or)?d|authorization|credentials?|private[_-]?key|cookie)$`)
+
+// Redactor 脱敏引擎：检测器 + 已知密钥值 + 敏感字段名
+type Redactor struct {
+	detectors []Detector
+	secrets   []string // 按长度降序，先替换长的
+}
+
+// DefaultDetectors 返回默认启用的内置检测器名称
+func DefaultDetectors() []string {
+	names := make([]string, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		if !isPII(d.Name) {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// isPII 判断是否为个人信息检测器
+func isPII(name string) bool {
+	for _, pii := range PIIDetectors {
+		if pii == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWithDetectors 使用指定的内置检测器创建脱敏引擎
+func NewWithDetectors(names ...string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, name := range names {
+		found := false
+		for _, d := range builtinDetectors {
+			if d.Name == name {
+				r.detectors = append(r.detectors, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown redaction detector: %s", name)
+		}
+	}
+	return r, nil
+}
+
+// New 按配置创建脱敏引擎，已知密钥取自配置中的敏感字段、配置的 secrets 和敏感环境变量
+func New(cfg *config.Config) (*Redactor, error) {
+	rc := cfg.Redaction
+	names := rc.Detectors
+	if len(names) == 0 {
+		names = DefaultDetectors()
+	}
+	r, err := NewWithDetectors(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range rc.Patterns {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p.Name, err)
+		}
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = Placeholder
+		}
+		r.detectors = append(r.detectors, Detector{Name: p.Name, Pattern: pattern, Replacement: replacement})
+	}
+
+	r.AddSecrets(rc.Secrets...)
+	r.AddSecrets(SecretsFromConfig(cfg)...)
+	r.AddSecrets(SecretsFromEnv(rc.EnvVars...)...)
+	return r, nil
+}
+
+// AddSecrets 添加需要替换的已知密钥值
+func (r *Redactor) AddSecrets(values ...string) {
+	seen := make(map[string]bool, len(r.secrets))
+	for _, s := range r.secrets {
+		seen[s] = true
+	}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength || seen[v] {
+			continue
+		}
+		seen[v] = true
+		r.secrets = append(r.secrets, v)
+	}
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// String 脱敏文本
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, secret := range r.secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Placeholder)
+		}
+	}
+	for _, d := range r.detectors {
+		s = d.Pattern.ReplaceAllString(s, d.Replacement)
+	}
+	return s
+}
+
+// Value 脱敏字段值：字段名敏感时整个值被替换，否则递归脱敏其中的字符串
+func (r *Redactor) Value(key string, value interface{}) interface{} {
+	if r == nil {
+		return value
+	}
+	if key != "" && IsSensitiveKey(key) {
+		if s, ok := value.(string); ok && s == "" {
+			return s
+		}
+		return Placeholder
+	}
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case map[string]interface{}:
+		return r.Map(v)
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for k, item := range v {
+			out[k] = r.Value(k, item).(string)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.Value("", item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = r.String(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// Map 脱敏 map 的副本
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = r.Value(k, v)
+	}
+	return out
+}
+
+// IsSensitiveKey 判断字段名是否表示凭据
+func IsSensitiveKey(key string) bool {
+	return sensitiveKeyPattern.MatchString(key)
+}
+
+// SecretsFromConfig 收集配置中所有敏感字段（api_key、token、secret 等）的值
+func SecretsFromConfig(cfg *config.Config) []string {
+	var secrets []string
+	collectSecrets(reflect.ValueOf(cfg), "", &secrets)
+	return secrets
+}
+
+// collectSecrets 递归遍历配置结构体
+func collectSecrets(v reflect.Value, name string, secrets *[]string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collectSecrets(v.Elem(), name, secrets)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			fieldName := t.Field(i).Name
+			if tag := t.Field(i).Tag.Get("json"); tag != "" && tag != "-" {
+				fieldName = strings.Split(tag, ",")[0]
+			}
+			collectSecrets(v.Field(i), fieldName, secrets)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectSecrets(v.Index(i), name, secrets)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			key := name
+			if iter.Key().Kind() == reflect.String {
+				key = iter.Key().String()
+			}
+			collectSecrets(iter.Value(), key, secrets)
+		}
+	case reflect.String:
+		if IsSensitiveKey(name) && v.String() != "" {
+			*secrets = append(*secrets, v.String())
+		}
+	}
+}
+
+// SecretsFromEnv 收集名称敏感（如 OPENAI_API_KEY、GITHUB_TOKEN）或被显式列出的环境变量的值
+func SecretsFromEnv(extra ...string) []string {
+	var secrets []string
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && IsSensitiveKey(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	for _, name := range extra {
+		if value := os.Getenv(name); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+var (
+	defaultRedactor *Redactor
+	defaultMu       sync.RWMutex
+)
+
+// builtin 未调用 SetDefault 时使用的默认引擎（只有默认检测器）
+var builtin, _ = NewWithDetectors(DefaultDetectors()...)
+
+// SetDefault 设置全局脱敏引擎
+func SetDefault(r *Redactor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRedactor = r
+}
+
+// Default 返回全局脱敏引擎，未设置时返回只有默认检测器的引擎
+func Default() *Redactor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultRedactor != nil {
+		return defaultRedactor
+	}
+	return builtin
+}
+
+// Setup 按配置创建并设置全局脱敏引擎，未启用时返回 nil 且全局引擎不做任何替换
+func Setup(cfg *config.Config) (*Redactor, error) {
+	if !cfg.Redaction.Enabled {
+		SetDefault(&Redactor{})
+		return nil, nil
+	}
+	r, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	SetDefault(r)
+	return r, nil
+}
+
+// String 使用全局引擎脱敏文本
+func String(s string) string {
+	return Default().String(s)
+}
@@ -0,0 +1,213 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 追踪器名称
+const instrumentationName = "github.com/smallnest/goclaw"
+
+// 导出器类型
+const (
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Span 属性键
+const (
+	AttrSessionKey       = attribute.Key("goclaw.session_key")
+	AttrAgentID          = attribute.Key("goclaw.agent_id")
+	AttrChannel          = attribute.Key("goclaw.channel")
+	AttrAccountID        = attribute.Key("goclaw.account_id")
+	AttrChatID           = attribute.Key("goclaw.chat_id")
+	AttrMessageID        = attribute.Key("messaging.message.id")
+	AttrModel            = attribute.Key("gen_ai.request.model")
+	AttrFinishReason     = attribute.Key("gen_ai.response.finish_reason")
+	AttrPromptTokens     = attribute.Key("gen_ai.usage.input_tokens")
+	AttrCompletionTokens = attribute.Key("gen_ai.usage.output_tokens")
+	AttrTotalTokens      = attribute.Key("goclaw.usage.total_tokens")
+	AttrToolName         = attribute.Key("gen_ai.tool.name")
+	AttrToolCallID       = attribute.Key("gen_ai.tool.call.id")
+	AttrErrorClass       = attribute.Key("error.type")
+)
+
+// propagator 在消息总线上传递追踪上下文（与是否启用导出无关）
+var propagator = propagation.TraceContext{}
+
+var errorClassifier = types.NewSimpleErrorClassifier()
+
+// Setup 按配置初始化全局 TracerProvider，返回刷新并关闭导出器的函数
+// 未启用时不做任何事，span 由默认的空实现丢弃
+func Setup(cfg config.TelemetryConfig) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !cfg.Enabled {
+		return noop, nil
+	}
+
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return noop, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "goclaw"
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return noop, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// newExporter 创建 span 导出器
+func newExporter(cfg config.TelemetryConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			if strings.Contains(cfg.Endpoint, "://") {
+				opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+			} else {
+				opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+			}
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		return exporter, nil
+
+	case ExporterFile:
+		path := cfg.FilePath
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get home directory: %w", err)
+			}
+			path = filepath.Join(home, ".goclaw", "traces.jsonl")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create trace directory: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		return &fileExporter{SpanExporter: exporter, file: file}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown telemetry exporter: %s (expected otlp or file)", cfg.Exporter)
+	}
+}
+
+// fileExporter 关闭导出器时同时关闭文件
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+// Shutdown 关闭导出器和文件
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Tracer 返回 goclaw 的追踪器
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End 结束 span，有错误时记录错误及其分类
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(AttrErrorClass.String(ErrorClass(err)))
+	}
+	span.End()
+}
+
+// ErrorClass 返回错误分类（canceled、timeout、auth、rate_limit 等）
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return string(errorClassifier.ClassifyError(err))
+}
+
+// Inject 将上下文中的 span 编码为可随消息传递的载体，没有 span 时返回 nil
+func Inject(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract 从消息携带的载体中恢复追踪上下文
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// StartConsumer 以消息携带的追踪上下文为父节点开始处理消息的 span
+func StartConsumer(ctx context.Context, name string, carrier map[string]string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(Extract(ctx, carrier), name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,116 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smallnest/goclaw/bus"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracePropagatesThroughBus(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx := context.Background()
+	messageBus := bus.NewMessageBus(10)
+	defer messageBus.Close()
+
+	if err := messageBus.PublishInbound(ctx, &bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "hi"}); err != nil {
+		t.Fatalf("publish inbound failed: %v", err)
+	}
+	inbound, err := messageBus.ConsumeInbound(ctx)
+	if err != nil {
+		t.Fatalf("consume inbound failed: %v", err)
+	}
+	if len(inbound.TraceContext) == 0 {
+		t.Fatal("expected inbound message to carry trace context")
+	}
+
+	// 消费方接着入站链路处理并发布回复
+	runCtx, span := telemetry.StartConsumer(ctx, "bus.consume inbound", inbound.TraceContext)
+	outbound := &bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "hello"}
+	if err := messageBus.PublishOutbound(runCtx, outbound); err != nil {
+		t.Fatalf("publish outbound failed: %v", err)
+	}
+	telemetry.End(span, errors.New("429 too many requests"))
+
+	_, send := telemetry.StartConsumer(ctx, "channel.send", outbound.TraceContext)
+	send.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	traceID := spans[0].SpanContext().TraceID()
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != traceID {
+			t.Errorf("span %s is not part of the inbound trace", s.Name())
+		}
+		if s.Name() == "bus.consume inbound" {
+			found := false
+			for _, attr := range s.Attributes() {
+				if attr.Key == telemetry.AttrErrorClass && attr.Value.AsString() == "rate_limit" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected error class on failed span, got %v", s.Attributes())
+			}
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := map[error]string{
+		context.Canceled:                    "canceled",
+		context.DeadlineExceeded:            "timeout",
+		errors.New("401 invalid api key"):   "auth",
+		errors.New("something else failed"): "unknown",
+	}
+	for err, want := range cases {
+		if got := telemetry.ErrorClass(err); got != want {
+			t.Errorf("ErrorClass(%q) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := telemetry.Setup(config.TelemetryConfig{Enabled: true, Exporter: telemetry.ExporterFile, FilePath: path})
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	_, span := telemetry.Tracer().Start(context.Background(), "agent.run")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+	if !strings.Contains(string(data), `"Name":"agent.run"`) {
+		t.Errorf("expected span in trace file, got:\n%s", data)
+	}
+
+	if _, err := telemetry.Setup(config.TelemetryConfig{Enabled: true, Exporter: "zipkin"}); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+}
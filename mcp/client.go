@@ -0,0 +1,345 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ClientName 初始化时上报的客户端名称
+const ClientName = "goclaw"
+
+// Version 初始化时上报的版本，由 CLI 设置
+var Version = "dev"
+
+// notifyTimeout 发送通知和回复服务器请求的超时
+const notifyTimeout = 5 * time.Second
+
+// NotificationHandler 处理服务器通知，在读取循环中同步调用，不能在其中发起请求
+type NotificationHandler func(method string, params json.RawMessage)
+
+// Client MCP 客户端，一个客户端对应一个服务器连接
+type Client struct {
+	name      string
+	transport Transport
+	onNotify  NotificationHandler
+
+	nextID  atomic.Int64
+	pending map[string]chan *Message
+	mu      sync.Mutex
+
+	done chan struct{}
+	err  error
+
+	initResult InitializeResult
+}
+
+// Connect 连接服务器并完成初始化握手
+func Connect(ctx context.Context, cfg config.MCPServerConfig, onNotify NotificationHandler) (*Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(cfg.Name, transport, onNotify)
+	if err := c.Initialize(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient 基于已建立的传输创建客户端（需调用 Initialize）
+func NewClient(name string, transport Transport, onNotify NotificationHandler) *Client {
+	c := &Client{
+		name:      name,
+		transport: transport,
+		onNotify:  onNotify,
+		pending:   make(map[string]chan *Message),
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Name 返回服务器名称
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Initialize 执行 initialize 握手并发送 initialized 通知
+func (c *Client) Initialize(ctx context.Context) error {
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: ClientName, Version: Version},
+	}
+	if err := c.call(ctx, "initialize", params, &c.initResult); err != nil {
+		return fmt.Errorf("mcp server %s: initialize failed: %w", c.name, err)
+	}
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+// ServerInfo 返回服务器信息
+func (c *Client) ServerInfo() Implementation {
+	return c.initResult.ServerInfo
+}
+
+// Capabilities 返回服务器能力
+func (c *Client) Capabilities() ServerCapabilities {
+	return c.initResult.Capabilities
+}
+
+// Instructions 返回服务器提供的使用说明
+func (c *Client) Instructions() string {
+	return c.initResult.Instructions
+}
+
+// Done 连接断开后关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 返回连接断开的原因
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// ListTools 列出全部工具
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		var result ListToolsResult
+		if err := c.call(ctx, "tools/list", paginatedParams{Cursor: cursor}, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool 调用工具
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources 列出全部资源
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var result ListResourcesResult
+		if err := c.call(ctx, "resources/list", paginatedParams{Cursor: cursor}, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Resources...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// ReadResource 读取资源
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var result ReadResourceResult
+	if err := c.call(ctx, "resources/read", ReadResourceParams{URI: uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListPrompts 列出全部提示词
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	cursor := ""
+	for {
+		var result ListPromptsResult
+		if err := c.call(ctx, "prompts/list", paginatedParams{Cursor: cursor}, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Prompts...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// GetPrompt 获取填充参数后的提示词
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	if err := c.call(ctx, "prompts/get", GetPromptParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ping 检查连接
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, "ping", nil, nil)
+}
+
+// call 发送请求并等待响应
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	msg.ID = json.RawMessage(id)
+
+	ch := make(chan *Message, 1)
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.transport.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return ErrClosed
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.cancelRequest(id, ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// notify 发送通知
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	return c.transport.Send(ctx, msg)
+}
+
+// cancelRequest 通知服务器放弃已超时或取消的请求
+func (c *Client) cancelRequest(id string, reason error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		_ = c.notify(ctx, "notifications/cancelled", map[string]any{
+			"requestId": json.RawMessage(id),
+			"reason":    reason.Error(),
+		})
+	}()
+}
+
+// readLoop 分发响应、通知和服务器请求，传输断开后结束所有等待中的请求
+func (c *Client) readLoop() {
+	for msg := range c.transport.Messages() {
+		switch {
+		case msg.IsResponse():
+			c.mu.Lock()
+			ch, ok := c.pending[string(msg.ID)]
+			c.mu.Unlock()
+			if ok {
+				// 重复的响应直接丢弃
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		case msg.IsRequest():
+			c.handleRequest(msg)
+		case msg.IsNotification():
+			if c.onNotify != nil {
+				c.onNotify(msg.Method, msg.Params)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.err = ErrClosed
+	close(c.done)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// handleRequest 回复服务器请求，只支持 ping
+func (c *Client) handleRequest(msg *Message) {
+	resp := &Message{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := c.transport.Send(ctx, resp); err != nil {
+			logger.Debug("Failed to reply to MCP server request",
+				zap.String("server", c.name),
+				zap.String("method", msg.Method),
+				zap.Error(err))
+		}
+	}()
+}
+
+// newMessage 创建请求或通知消息
+func newMessage(method string, params any) (*Message, error) {
+	msg := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = data
+	}
+	return msg, nil
+}
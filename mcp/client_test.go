@@ -0,0 +1,374 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+)
+
+// serverEnv 设置后测试二进制作为 stdio MCP 服务器运行
+const serverEnv = "GOCLAW_MCP_TEST_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(serverEnv) == "1" {
+		serveStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testServer 测试用的最小 MCP 服务器
+type testServer struct {
+	mu    sync.Mutex
+	extra bool
+	// notify 向客户端推送通知
+	notify func(*Message)
+}
+
+func (s *testServer) tools() []Tool {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+	}
+	list := []Tool{
+		{Name: "echo", Description: "Echo the text back", InputSchema: schema},
+		{Name: "fail", Description: "Always fails", InputSchema: schema},
+		{Name: "crash", Description: "Exit the server process", InputSchema: schema},
+		{Name: "add_tool", Description: "Add the extra tool", InputSchema: schema},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.extra {
+		list = append(list, Tool{Name: "extra", Description: "Added later", InputSchema: schema})
+	}
+	return list
+}
+
+// handle 处理一条消息，通知返回 nil
+func (s *testServer) handle(msg *Message) *Message {
+	if !msg.IsRequest() {
+		return nil
+	}
+	result, rpcErr := s.dispatch(msg)
+	resp := &Message{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result, _ = json.Marshal(result)
+	}
+	return resp
+}
+
+func (s *testServer) dispatch(msg *Message) (any, *RPCError) {
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities: ServerCapabilities{
+				Tools:     &ListChangedCapability{ListChanged: true},
+				Resources: &ResourcesCapability{},
+				Prompts:   &ListChangedCapability{},
+			},
+			ServerInfo: Implementation{Name: "test-server", Version: "1.0"},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return ListToolsResult{Tools: s.tools()}, nil
+	case "tools/call":
+		var params CallToolParams
+		_ = json.Unmarshal(msg.Params, &params)
+		text, _ := params.Arguments["text"].(string)
+		switch params.Name {
+		case "echo", "extra":
+			return CallToolResult{Content: []Content{TextContent(params.Name + ": " + text)}}, nil
+		case "fail":
+			return CallToolResult{Content: []Content{TextContent("something broke")}, IsError: true}, nil
+		case "crash":
+			os.Exit(1)
+		case "add_tool":
+			s.mu.Lock()
+			s.extra = true
+			s.mu.Unlock()
+			s.notify(&Message{JSONRPC: "2.0", Method: NotifyToolsListChanged})
+			return CallToolResult{Content: []Content{TextContent("added")}}, nil
+		}
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool " + params.Name}
+	case "resources/list":
+		return ListResourcesResult{Resources: []Resource{{URI: "test://readme", Name: "README"}}}, nil
+	case "resources/read":
+		var params ReadResourceParams
+		_ = json.Unmarshal(msg.Params, &params)
+		return ReadResourceResult{Contents: []ResourceContents{{URI: params.URI, Text: "hello resource"}}}, nil
+	case "prompts/list":
+		return ListPromptsResult{Prompts: []Prompt{{Name: "greet", Arguments: []PromptArgument{{Name: "name", Required: true}}}}}, nil
+	case "prompts/get":
+		var params GetPromptParams
+		_ = json.Unmarshal(msg.Params, &params)
+		return GetPromptResult{Messages: []PromptMessage{{Role: "user", Content: TextContent("Hello, " + params.Arguments["name"])}}}, nil
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found"}
+}
+
+// serveStdio 通过 stdin/stdout 运行测试服务器
+func serveStdio(in io.Reader, out io.Writer) {
+	var mu sync.Mutex
+	write := func(msg *Message) {
+		data, _ := json.Marshal(msg)
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = out.Write(append(data, '\n'))
+	}
+	s := &testServer{notify: write}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if resp := s.handle(&msg); resp != nil {
+			write(resp)
+		}
+	}
+}
+
+// mapRegistrar 记录注册的工具
+type mapRegistrar struct {
+	mu    sync.Mutex
+	tools map[string]tools.Tool
+}
+
+func newMapRegistrar() *mapRegistrar {
+	return &mapRegistrar{tools: make(map[string]tools.Tool)}
+}
+
+func (r *mapRegistrar) RegisterExisting(tool tools.Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name()]; ok {
+		return fmt.Errorf("tool %s already registered", tool.Name())
+	}
+	r.tools[tool.Name()] = tool
+	return nil
+}
+
+func (r *mapRegistrar) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
+func (r *mapRegistrar) get(name string) tools.Tool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tools[name]
+}
+
+// waitFor 轮询直到条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func stdioServerConfig(t *testing.T) config.MCPServerConfig {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.MCPServerConfig{
+		Name:      "test",
+		Command:   exe,
+		Env:       []string{serverEnv + "=1"},
+		DenyTools: []string{"fail"},
+	}
+}
+
+func startManager(t *testing.T, sc config.MCPServerConfig, registrar Registrar, onChange func()) *Manager {
+	t.Helper()
+	m, err := NewManager(&config.MCPConfig{Servers: []config.MCPServerConfig{sc}}, registrar)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	m.SetOnChange(onChange)
+	m.Start(context.Background(), 10*time.Second)
+	t.Cleanup(m.Stop)
+	return m
+}
+
+func TestStdioServerTools(t *testing.T) {
+	registrar := newMapRegistrar()
+	m := startManager(t, stdioServerConfig(t), registrar, nil)
+
+	status := m.Status()[0]
+	if !status.Connected || status.ServerInfo.Name != "test-server" {
+		t.Fatalf("server not connected: %+v", status)
+	}
+
+	echo := registrar.get("test__echo")
+	if echo == nil {
+		t.Fatal("test__echo not registered")
+	}
+	if registrar.get("test__fail") != nil {
+		t.Error("denied tool test__fail was registered")
+	}
+	out, err := echo.Execute(context.Background(), map[string]any{"text": "hi"})
+	if err != nil || out != "echo: hi" {
+		t.Errorf("echo returned %q, %v", out, err)
+	}
+
+	out, err = registrar.get("test__read_resource").Execute(context.Background(), map[string]any{"uri": "test://readme"})
+	if err != nil || out != "hello resource" {
+		t.Errorf("read_resource returned %q, %v", out, err)
+	}
+
+	out, err = registrar.get("test__get_prompt").Execute(context.Background(), map[string]any{
+		"name":      "greet",
+		"arguments": map[string]any{"name": "Ada"},
+	})
+	if err != nil || !strings.Contains(out, "Hello, Ada") {
+		t.Errorf("get_prompt returned %q, %v", out, err)
+	}
+}
+
+func TestStdioServerReconnect(t *testing.T) {
+	registrar := newMapRegistrar()
+	m := startManager(t, stdioServerConfig(t), registrar, nil)
+
+	_, err := registrar.get("test__crash").Execute(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected crash call to fail")
+	}
+
+	// 工具保持注册，服务器重启后恢复可用
+	waitFor(t, "reconnect", func() bool {
+		out, err := registrar.get("test__echo").Execute(context.Background(), map[string]any{"text": "back"})
+		return err == nil && out == "echo: back"
+	})
+	if !m.Status()[0].Connected {
+		t.Error("expected server to be connected after restart")
+	}
+}
+
+func TestToolsListChanged(t *testing.T) {
+	registrar := newMapRegistrar()
+	var mu sync.Mutex
+	changes := 0
+	startManager(t, stdioServerConfig(t), registrar, func() {
+		mu.Lock()
+		changes++
+		mu.Unlock()
+	})
+
+	if _, err := registrar.get("test__add_tool").Execute(context.Background(), nil); err != nil {
+		t.Fatalf("add_tool failed: %v", err)
+	}
+	waitFor(t, "extra tool", func() bool { return registrar.get("test__extra") != nil })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if changes < 2 {
+		t.Errorf("expected onChange after connect and list_changed, got %d", changes)
+	}
+}
+
+// newHTTPTestServer 以 Streamable HTTP 方式运行测试服务器，tools/call 使用 SSE 响应
+func newHTTPTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	s := &testServer{notify: func(*Message) {}}
+	const session = "session-1"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.Method == "initialize" {
+			w.Header().Set(SessionHeader, session)
+		} else if r.Header.Get(SessionHeader) != session {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		resp := s.handle(&msg)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		if msg.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPServerTools(t *testing.T) {
+	srv := newHTTPTestServer(t)
+	registrar := newMapRegistrar()
+	startManager(t, config.MCPServerConfig{
+		Name:       "remote",
+		URL:        srv.URL,
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+		AllowTools: []string{"echo"},
+	}, registrar, nil)
+
+	echo := registrar.get("remote__echo")
+	if echo == nil {
+		t.Fatal("remote__echo not registered")
+	}
+	if registrar.get("remote__crash") != nil {
+		t.Error("tool outside allow_tools was registered")
+	}
+	out, err := echo.Execute(context.Background(), map[string]any{"text": "over http"})
+	if err != nil || out != "echo: over http" {
+		t.Errorf("echo returned %q, %v", out, err)
+	}
+}
+
+func TestFormatToolResultError(t *testing.T) {
+	_, err := FormatToolResult(&CallToolResult{Content: []Content{TextContent("bad input")}, IsError: true})
+	if err == nil || err.Error() != "bad input" {
+		t.Errorf("expected error from isError result, got %v", err)
+	}
+	if name := ToolName("my.server", "do/thing"); name != "my_server__do_thing" {
+		t.Errorf("unexpected tool name %q", name)
+	}
+}
@@ -0,0 +1,398 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/smallnest/goclaw/agent"
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/internal/redact"
+	"github.com/smallnest/goclaw/session"
+)
+
+const (
+	// SessionScheme 会话记录资源的 URI 协议
+	SessionScheme = "session"
+
+	// maxWorkspaceResources 列出的工作区文件上限
+	maxWorkspaceResources = 500
+
+	// maxResourceSize 可读取的工作区文件大小上限
+	maxResourceSize = 1 << 20
+)
+
+// GoclawOptions 通过 MCP 暴露的 goclaw 能力，未设置的部分不暴露
+type GoclawOptions struct {
+	Runner     *agent.ToolRunner     // 工具，与 Agent 共用脱敏、输出缓存、不可信内容和确认规则
+	Sessions   *session.Manager      // 会话记录资源
+	FileSystem *tools.FileSystemTool // 工作区文件资源按其允许/拒绝路径过滤
+	Workspace  string
+	Skills     *agent.SkillsLoader // 技能作为提示词
+	Redactor   *redact.Redactor    // 资源内容脱敏
+}
+
+// NewGoclawServer 创建暴露 goclaw 工具、会话、工作区文件和技能的 MCP 服务器
+func NewGoclawServer(version string, opts GoclawOptions) *Server {
+	g := &goclawHandler{opts: opts}
+	serverOpts := ServerOptions{
+		Name:    ClientName,
+		Version: version,
+		Instructions: "goclaw tools run with the same filesystem, shell, redaction and approval policies as the goclaw agent. " +
+			"Side-effecting tools called after untrusted content (web pages, search results, files) need the user's confirmation.",
+	}
+	if opts.Runner != nil {
+		serverOpts.Tools = g
+	}
+	if opts.Sessions != nil || opts.Workspace != "" {
+		serverOpts.Resources = g
+	}
+	if opts.Skills != nil {
+		serverOpts.Prompts = g
+	}
+	return NewServer(serverOpts)
+}
+
+// goclawHandler 实现工具、资源和提示词处理器
+type goclawHandler struct {
+	opts   GoclawOptions
+	nextID atomic.Int64
+}
+
+type historyKey struct{}
+
+// toolHistory 会话中影响确认规则的消息：最近的不可信结果或用户确认
+type toolHistory struct {
+	messages []agent.AgentMessage
+	mu       sync.Mutex
+}
+
+// history 返回会话的工具调用历史
+func history(sess *ServerSession) *toolHistory {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	h, ok := sess.values[historyKey{}].(*toolHistory)
+	if !ok {
+		h = &toolHistory{}
+		sess.values[historyKey{}] = h
+	}
+	return h
+}
+
+func (h *toolHistory) snapshot() []agent.AgentMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]agent.AgentMessage(nil), h.messages...)
+}
+
+// record 记录结果，只保留最近一条会触发确认的不可信结果
+func (h *toolHistory) record(msg agent.AgentMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if untrusted, _ := msg.Metadata["untrusted"].(bool); untrusted {
+		h.messages = []agent.AgentMessage{msg}
+	}
+}
+
+// approve 记录用户确认，之前的不可信内容不再要求确认
+func (h *toolHistory) approve(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = []agent.AgentMessage{agent.UserApproval(text)}
+}
+
+// ListTools 列出工具
+func (g *goclawHandler) ListTools(ctx context.Context, sess *ServerSession) ([]Tool, error) {
+	list := make([]Tool, 0, len(g.opts.Runner.Tools()))
+	for _, t := range g.opts.Runner.Tools() {
+		list = append(list, Tool{Name: t.Name(), Description: t.Description(), InputSchema: t.Parameters()})
+	}
+	return list, nil
+}
+
+// CallTool 执行工具调用，需要确认时通过 elicitation 询问用户
+func (g *goclawHandler) CallTool(ctx context.Context, sess *ServerSession, params CallToolParams) (*CallToolResult, error) {
+	if !g.hasTool(params.Name) {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+
+	tc := agent.ToolCallContent{
+		ID:        fmt.Sprintf("mcp-%d", g.nextID.Add(1)),
+		Name:      params.Name,
+		Arguments: params.Arguments,
+	}
+	if tc.Arguments == nil {
+		tc.Arguments = map[string]any{}
+	}
+
+	h := history(sess)
+	if g.opts.Runner.NeedsApproval(tc, h.snapshot()) && sess.CanElicit() {
+		approved, err := g.elicitApproval(ctx, sess, tc)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			return &CallToolResult{Content: []Content{TextContent("Tool call declined by the user: " + tc.Name)}, IsError: true}, nil
+		}
+		h.approve("Approved " + tc.Name + " via MCP elicitation")
+	}
+
+	ctx = tools.WithSessionKey(ctx, "mcp:"+sess.ID())
+	ctx = tools.WithDeliveryContext(ctx, &tools.DeliveryContext{Channel: "mcp"})
+	if client := sess.ClientInfo().Name; client != "" {
+		ctx = tools.WithSenderID(ctx, client)
+	}
+
+	msg := g.opts.Runner.Run(ctx, tc, h.snapshot())
+	h.record(msg)
+	return toCallToolResult(msg), nil
+}
+
+// hasTool 判断工具是否暴露
+func (g *goclawHandler) hasTool(name string) bool {
+	for _, t := range g.opts.Runner.Tools() {
+		if t.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// elicitApproval 请用户确认工具调用
+func (g *goclawHandler) elicitApproval(ctx context.Context, sess *ServerSession, tc agent.ToolCallContent) (bool, error) {
+	args, _ := json.MarshalIndent(g.opts.Redactor.Map(tc.Arguments), "", "  ")
+	result, err := sess.Elicit(ctx, ElicitParams{
+		Message: fmt.Sprintf("goclaw: %s has side effects and this session has read untrusted content. Run it?\n\n%s", tc.Name, args),
+		RequestedSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.Action == "accept", nil
+}
+
+// toCallToolResult 将工具结果消息转换为 MCP 结果
+func toCallToolResult(msg agent.AgentMessage) *CallToolResult {
+	result := &CallToolResult{Content: []Content{}}
+	for _, block := range msg.Content {
+		switch b := block.(type) {
+		case agent.TextContent:
+			result.Content = append(result.Content, TextContent(b.Text))
+		case agent.ImageContent:
+			if b.Data != "" {
+				result.Content = append(result.Content, Content{Type: "image", Data: b.Data, MimeType: b.MimeType})
+			} else if b.URL != "" {
+				result.Content = append(result.Content, TextContent(b.URL))
+			}
+		}
+	}
+
+	if errValue, ok := msg.Metadata["error"]; ok && errValue != nil {
+		result.IsError = true
+		if len(result.Content) == 0 {
+			result.Content = append(result.Content, TextContent(fmt.Sprint(errValue)))
+		}
+		if details, ok := msg.Metadata["validation_errors"].(string); ok {
+			result.Content = append(result.Content, TextContent(details))
+		}
+	}
+	return result
+}
+
+// ListResources 列出会话记录和工作区文件
+func (g *goclawHandler) ListResources(ctx context.Context, sess *ServerSession) ([]Resource, error) {
+	var list []Resource
+	if g.opts.Sessions != nil {
+		keys, err := g.opts.Sessions.List()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			list = append(list, Resource{
+				URI:      sessionURI(key),
+				Name:     key,
+				MimeType: "text/markdown",
+			})
+		}
+	}
+	if g.opts.Workspace != "" {
+		list = append(list, g.workspaceResources()...)
+	}
+	return list, nil
+}
+
+// workspaceResources 列出工作区文件，跳过隐藏目录和文件系统策略拒绝的路径
+func (g *goclawHandler) workspaceResources() []Resource {
+	var list []Resource
+	_ = filepath.WalkDir(g.opts.Workspace, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != g.opts.Workspace {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !g.fileAllowed(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(g.opts.Workspace, path)
+		list = append(list, Resource{URI: fileURI(path), Name: filepath.ToSlash(rel)})
+		if len(list) >= maxWorkspaceResources {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return list
+}
+
+// fileAllowed 文件必须在工作区内，并且文件系统策略允许访问
+func (g *goclawHandler) fileAllowed(path string) bool {
+	rel, err := filepath.Rel(g.opts.Workspace, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return g.opts.FileSystem == nil || g.opts.FileSystem.IsAllowed(path)
+}
+
+// ReadResource 读取资源
+func (g *goclawHandler) ReadResource(ctx context.Context, sess *ServerSession, uri string) (*ReadResourceResult, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+
+	switch {
+	case u.Scheme == SessionScheme && g.opts.Sessions != nil:
+		key, err := url.PathUnescape(u.Opaque)
+		if err != nil || key == "" {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "invalid session uri: " + uri}
+		}
+		sessionData, err := g.opts.Sessions.Load(key)
+		if err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "session not found: " + key}
+		}
+		return &ReadResourceResult{Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "text/markdown",
+			Text:     g.opts.Redactor.String(renderTranscript(sessionData)),
+		}}}, nil
+
+	case u.Scheme == "file" && g.opts.Workspace != "":
+		path := filepath.Clean(filepath.FromSlash(u.Path))
+		if !g.fileAllowed(path) {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "access to " + uri + " is not allowed"}
+		}
+		return g.readFile(uri, path)
+	}
+	return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown resource: " + uri}
+}
+
+// readFile 读取工作区文件，非 UTF-8 内容以 base64 返回
+func (g *goclawHandler) readFile(uri, path string) (*ReadResourceResult, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "resource not found: " + uri}
+	}
+	if info.Size() > maxResourceSize {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: fmt.Sprintf("%s is larger than %d bytes, use the read_file tool", uri, maxResourceSize)}
+	}
+	var data []byte
+	if g.opts.FileSystem != nil {
+		data, err = g.opts.FileSystem.Jail().ReadFile(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents := ResourceContents{URI: uri}
+	if utf8.Valid(data) {
+		contents.MimeType = "text/plain"
+		contents.Text = g.opts.Redactor.String(string(data))
+	} else {
+		contents.MimeType = "application/octet-stream"
+		contents.Blob = base64.StdEncoding.EncodeToString(data)
+	}
+	return &ReadResourceResult{Contents: []ResourceContents{contents}}, nil
+}
+
+// sessionURI 返回会话记录的资源 URI
+func sessionURI(key string) string {
+	return SessionScheme + ":" + url.PathEscape(key)
+}
+
+// fileURI 返回文件的资源 URI
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// renderTranscript 将会话记录渲染为 Markdown
+func renderTranscript(s *session.Session) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Session %s\n", s.Key)
+	for _, msg := range s.Messages {
+		fmt.Fprintf(&sb, "\n## %s (%s)\n\n", msg.Role, msg.Timestamp.Format(time.RFC3339))
+		if msg.Content != "" {
+			sb.WriteString(msg.Content)
+			sb.WriteString("\n")
+		}
+		for _, tc := range msg.ToolCalls {
+			params, _ := json.Marshal(tc.Params)
+			fmt.Fprintf(&sb, "\n- tool call `%s` %s\n", tc.Name, params)
+		}
+	}
+	return sb.String()
+}
+
+// ListPrompts 将技能列为提示词
+func (g *goclawHandler) ListPrompts(ctx context.Context, sess *ServerSession) ([]Prompt, error) {
+	skills := g.opts.Skills.List()
+	sort.Slice(skills, func(i, j int) bool { return skills[i].Name < skills[j].Name })
+	list := make([]Prompt, 0, len(skills))
+	for _, skill := range skills {
+		list = append(list, Prompt{
+			Name:        skill.Name,
+			Description: skill.Description,
+			Arguments: []PromptArgument{{
+				Name:        "task",
+				Description: "What to do with this skill",
+			}},
+		})
+	}
+	return list, nil
+}
+
+// GetPrompt 返回技能内容，task 参数附加在后面
+func (g *goclawHandler) GetPrompt(ctx context.Context, sess *ServerSession, params GetPromptParams) (*GetPromptResult, error) {
+	skill, ok := g.opts.Skills.Get(params.Name)
+	if !ok {
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown prompt: " + params.Name}
+	}
+
+	text := skill.Content
+	if task := strings.TrimSpace(params.Arguments["task"]); task != "" {
+		text += "\n\n## Task\n\n" + task
+	}
+	return &GetPromptResult{
+		Description: skill.Description,
+		Messages:    []PromptMessage{{Role: "user", Content: TextContent(text)}},
+	}, nil
+}
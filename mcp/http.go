@@ -0,0 +1,290 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// SessionHeader Streamable HTTP 会话 ID 请求头
+const SessionHeader = "Mcp-Session-Id"
+
+// httpTransport Streamable HTTP 传输：每条消息 POST 到服务器，响应为 JSON 或 SSE 流，
+// 服务器主动发送的通知通过 GET SSE 流接收
+type httpTransport struct {
+	name     string
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	messages chan *Message
+
+	sessionID string
+	listening bool
+	mu        sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// newHTTPTransport 创建 HTTP 传输
+func newHTTPTransport(cfg config.MCPServerConfig) *httpTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpTransport{
+		name:     cfg.Name,
+		url:      cfg.URL,
+		headers:  cfg.Headers,
+		client:   &http.Client{},
+		messages: make(chan *Message, 16),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// newRequest 创建带会话和自定义请求头的请求
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(SessionHeader, t.sessionID)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) Send(ctx context.Context, msg *Message) error {
+	if t.ctx.Err() != nil {
+		return ErrClosed
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// 请求在调用方取消或传输关闭时中止
+	reqCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, cancel)
+
+	req, err := t.newRequest(reqCtx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		stop()
+		cancel()
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		stop()
+		cancel()
+		return fmt.Errorf("mcp server %s: %w", t.name, err)
+	}
+
+	if id := resp.Header.Get(SessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && t.hasSession():
+		// 服务器已丢弃会话，关闭连接以便重新初始化
+		resp.Body.Close()
+		stop()
+		cancel()
+		t.mu.Lock()
+		t.sessionID = ""
+		t.mu.Unlock()
+		_ = t.Close()
+		return fmt.Errorf("%w: session expired", ErrClosed)
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		stop()
+		cancel()
+		return fmt.Errorf("mcp server %s: HTTP %d: %s", t.name, resp.StatusCode, strings.TrimSpace(string(body)))
+	case resp.StatusCode == http.StatusAccepted || resp.ContentLength == 0:
+		resp.Body.Close()
+		stop()
+		cancel()
+		t.startListening()
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		// SSE 响应可能先推送通知，再返回结果，在后台读取
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			defer stop()
+			defer cancel()
+			defer resp.Body.Close()
+			t.readEvents(resp.Body)
+		}()
+		return nil
+	}
+
+	defer stop()
+	defer cancel()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return fmt.Errorf("mcp server %s: %w", t.name, err)
+	}
+	return t.deliverJSON(body)
+}
+
+// hasSession 是否已建立会话
+func (t *httpTransport) hasSession() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID != ""
+}
+
+// deliverJSON 投递单条消息或批量消息
+func (t *httpTransport) deliverJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '[' {
+		var batch []*Message
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return fmt.Errorf("mcp server %s: invalid response: %w", t.name, err)
+		}
+		for _, msg := range batch {
+			t.deliver(msg)
+		}
+		return nil
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("mcp server %s: invalid response: %w", t.name, err)
+	}
+	t.deliver(&msg)
+	return nil
+}
+
+// deliver 投递消息，传输关闭后丢弃
+func (t *httpTransport) deliver(msg *Message) {
+	select {
+	case t.messages <- msg:
+	case <-t.ctx.Done():
+	}
+}
+
+// readEvents 读取 SSE 流中的 message 事件
+func (t *httpTransport) readEvents(body io.Reader) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var data strings.Builder
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 && (event == "" || event == "message") {
+				if err := t.deliverJSON([]byte(data.String())); err != nil {
+					logger.Debug("Ignoring invalid MCP event", zap.String("server", t.name), zap.Error(err))
+				}
+			}
+			data.Reset()
+			event = ""
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		}
+	}
+}
+
+// startListening 建立会话后打开 GET SSE 流接收服务器通知，服务器不支持时忽略
+func (t *httpTransport) startListening() {
+	t.mu.Lock()
+	if t.listening || t.sessionID == "" {
+		t.mu.Unlock()
+		return
+	}
+	t.listening = true
+	t.mu.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for t.ctx.Err() == nil {
+			req, err := t.newRequest(t.ctx, http.MethodGet, nil)
+			if err != nil {
+				return
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			resp, err := t.client.Do(req)
+			if err != nil {
+				if t.ctx.Err() == nil {
+					logger.Debug("MCP notification stream failed", zap.String("server", t.name), zap.Error(err))
+				}
+			} else if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return
+			} else {
+				t.readEvents(resp.Body)
+				resp.Body.Close()
+			}
+
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}()
+}
+
+func (t *httpTransport) Messages() <-chan *Message {
+	return t.messages
+}
+
+// Close 结束会话并关闭消息通道
+func (t *httpTransport) Close() error {
+	t.once.Do(func() {
+		if t.hasSession() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			if req, err := t.newRequest(ctx, http.MethodDelete, nil); err == nil {
+				if resp, err := t.client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+			cancel()
+		}
+		t.cancel()
+		go func() {
+			t.wg.Wait()
+			close(t.messages)
+		}()
+	})
+	return nil
+}
@@ -0,0 +1,398 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTimeout 单次请求默认超时
+	defaultTimeout = 60 * time.Second
+	// connectTimeout 连接和初始化超时
+	connectTimeout = 30 * time.Second
+	// minBackoff 和 maxBackoff 重连间隔的上下限
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// stableConnection 连接保持超过该时长后重置重连间隔
+	stableConnection = time.Minute
+)
+
+var validServerName = regexp.MustCompile(`^[a-zA-Z0-9-]+(_[a-zA-Z0-9-]+)*$`)
+
+// Registrar 注册 MCP 工具的注册表（agent.ToolRegistry 实现此接口）
+type Registrar interface {
+	RegisterExisting(tool tools.Tool) error
+	Unregister(name string)
+}
+
+// ServerStatus 服务器连接状态
+type ServerStatus struct {
+	Name        string         `json:"name"`
+	Transport   string         `json:"transport"`
+	Connected   bool           `json:"connected"`
+	Error       string         `json:"error,omitempty"`
+	ServerInfo  Implementation `json:"server_info"`
+	ConnectedAt time.Time      `json:"connected_at,omitempty"`
+	Tools       []string       `json:"tools"`
+	Resources   int            `json:"resources"`
+	Prompts     int            `json:"prompts"`
+}
+
+// server 一个已配置的服务器及其当前连接
+type server struct {
+	cfg     config.MCPServerConfig
+	timeout time.Duration
+	refresh chan struct{}
+
+	mu          sync.Mutex
+	client      *Client
+	lastErr     error
+	connectedAt time.Time
+	registered  []string
+	resources   int
+	prompts     int
+}
+
+// connected 返回当前连接，断线重连期间返回错误
+func (s *server) connected() (*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil, fmt.Errorf("mcp server %s is not connected (reconnecting)", s.cfg.Name)
+	}
+	return s.client, nil
+}
+
+// Manager 连接配置的 MCP 服务器，将工具、资源和提示词注册为 Agent 工具，
+// 服务器崩溃后自动重连，收到 list_changed 通知后重新同步
+type Manager struct {
+	registrar Registrar
+	servers   []*server
+	onChange  func()
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager 创建 MCP 管理器，没有启用的服务器时返回 nil
+func NewManager(cfg *config.MCPConfig, registrar Registrar) (*Manager, error) {
+	m := &Manager{registrar: registrar}
+	seen := make(map[string]bool)
+	for _, sc := range cfg.Servers {
+		if sc.Disabled {
+			continue
+		}
+		if !validServerName.MatchString(sc.Name) {
+			return nil, fmt.Errorf("invalid mcp server name %q: use letters, digits, - and single _", sc.Name)
+		}
+		if seen[sc.Name] {
+			return nil, fmt.Errorf("duplicate mcp server name %q", sc.Name)
+		}
+		if sc.Command == "" && sc.URL == "" {
+			return nil, fmt.Errorf("mcp server %s: either command or url is required", sc.Name)
+		}
+		seen[sc.Name] = true
+
+		timeout := defaultTimeout
+		if sc.Timeout > 0 {
+			timeout = time.Duration(sc.Timeout) * time.Second
+		}
+		m.servers = append(m.servers, &server{
+			cfg:     sc,
+			timeout: timeout,
+			refresh: make(chan struct{}, 1),
+		})
+	}
+	if len(m.servers) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// SetOnChange 设置注册的工具变化后的回调（例如刷新 Agent 的工具列表）
+func (m *Manager) SetOnChange(fn func()) {
+	m.onChange = fn
+}
+
+// Start 在后台连接所有服务器，等待首次连接完成或 wait 超时后返回
+func (m *Manager) Start(ctx context.Context, wait time.Duration) {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	var first sync.WaitGroup
+	for _, s := range m.servers {
+		first.Add(1)
+		m.wg.Add(1)
+		go func(s *server) {
+			defer m.wg.Done()
+			m.run(ctx, s, first.Done)
+		}(s)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		first.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(wait):
+		logger.Warn("Some MCP servers are still connecting, their tools will be added when ready")
+	}
+}
+
+// Stop 断开所有服务器并注销工具
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	for _, s := range m.servers {
+		s.mu.Lock()
+		for _, name := range s.registered {
+			m.registrar.Unregister(name)
+		}
+		s.registered = nil
+		s.mu.Unlock()
+	}
+}
+
+// Status 返回所有服务器的状态
+func (m *Manager) Status() []ServerStatus {
+	result := make([]ServerStatus, 0, len(m.servers))
+	for _, s := range m.servers {
+		s.mu.Lock()
+		status := ServerStatus{
+			Name:        s.cfg.Name,
+			Transport:   transportName(s.cfg),
+			Connected:   s.client != nil,
+			ConnectedAt: s.connectedAt,
+			Tools:       append([]string(nil), s.registered...),
+			Resources:   s.resources,
+			Prompts:     s.prompts,
+		}
+		if s.client != nil {
+			status.ServerInfo = s.client.ServerInfo()
+		}
+		if s.lastErr != nil {
+			status.Error = s.lastErr.Error()
+		}
+		s.mu.Unlock()
+		result = append(result, status)
+	}
+	return result
+}
+
+// run 维持与服务器的连接，断开后按指数退避重连
+func (m *Manager) run(ctx context.Context, s *server, firstDone func()) {
+	var once sync.Once
+	signalFirst := func() { once.Do(firstDone) }
+	defer signalFirst()
+
+	backoff := minBackoff
+	for {
+		client, err := m.connect(ctx, s)
+		if err != nil {
+			s.mu.Lock()
+			s.lastErr = err
+			s.mu.Unlock()
+			logger.Warn("Failed to connect MCP server",
+				zap.String("server", s.cfg.Name),
+				zap.Duration("retry_in", backoff),
+				zap.Error(err))
+		} else {
+			connectedAt := time.Now()
+			m.sync(ctx, s, client)
+			signalFirst()
+
+			if !m.serve(ctx, s, client) {
+				return
+			}
+
+			s.mu.Lock()
+			s.client = nil
+			s.lastErr = ErrClosed
+			s.mu.Unlock()
+			if time.Since(connectedAt) > stableConnection {
+				backoff = minBackoff
+			}
+			logger.Warn("MCP server disconnected, reconnecting",
+				zap.String("server", s.cfg.Name),
+				zap.Duration("retry_in", backoff))
+		}
+		signalFirst()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// serve 处理 list_changed 通知直到连接断开，管理器停止时返回 false
+func (m *Manager) serve(ctx context.Context, s *server, client *Client) bool {
+	for {
+		select {
+		case <-client.Done():
+			return true
+		case <-s.refresh:
+			m.sync(ctx, s, client)
+		case <-ctx.Done():
+			_ = client.Close()
+			return false
+		}
+	}
+}
+
+// connect 建立连接并完成初始化
+func (m *Manager) connect(ctx context.Context, s *server) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	client, err := Connect(ctx, s.cfg, func(method string, params json.RawMessage) {
+		switch method {
+		case NotifyToolsListChanged, NotifyResourcesListChanged, NotifyPromptsListChanged:
+			select {
+			case s.refresh <- struct{}{}:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := client.ServerInfo()
+	logger.Info("MCP server connected",
+		zap.String("server", s.cfg.Name),
+		zap.String("transport", transportName(s.cfg)),
+		zap.String("server_name", info.Name),
+		zap.String("server_version", info.Version))
+	return client, nil
+}
+
+// sync 重新获取工具、资源和提示词并替换已注册的工具
+func (m *Manager) sync(ctx context.Context, s *server, client *Client) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	found, resources, prompts := discover(ctx, s, client)
+
+	s.mu.Lock()
+	for _, name := range s.registered {
+		m.registrar.Unregister(name)
+	}
+	registered := make([]string, 0, len(found))
+	for _, tool := range found {
+		if err := m.registrar.RegisterExisting(tool); err != nil {
+			logger.Warn("Failed to register MCP tool",
+				zap.String("server", s.cfg.Name),
+				zap.String("tool", tool.Name()),
+				zap.Error(err))
+			continue
+		}
+		registered = append(registered, tool.Name())
+	}
+	sort.Strings(registered)
+	s.client = client
+	s.lastErr = nil
+	s.connectedAt = time.Now()
+	s.registered = registered
+	s.resources = resources
+	s.prompts = prompts
+	s.mu.Unlock()
+
+	logger.Info("MCP server tools synced",
+		zap.String("server", s.cfg.Name),
+		zap.Int("tools", len(registered)),
+		zap.Int("resources", resources),
+		zap.Int("prompts", prompts))
+
+	if m.onChange != nil {
+		m.onChange()
+	}
+}
+
+// discover 列出服务器的工具、资源和提示词，返回要注册的工具
+func discover(ctx context.Context, s *server, client *Client) ([]tools.Tool, int, int) {
+	var found []tools.Tool
+	caps := client.Capabilities()
+
+	if caps.Tools != nil {
+		remote, err := client.ListTools(ctx)
+		if err != nil {
+			logger.Warn("Failed to list MCP tools", zap.String("server", s.cfg.Name), zap.Error(err))
+		}
+		for _, t := range remote {
+			if !AllowTool(s.cfg.AllowTools, s.cfg.DenyTools, t.Name) {
+				continue
+			}
+			found = append(found, &remoteTool{server: s, name: ToolName(s.cfg.Name, t.Name), tool: t})
+		}
+	}
+
+	var resources []Resource
+	if caps.Resources != nil {
+		var err error
+		if resources, err = client.ListResources(ctx); err != nil {
+			logger.Warn("Failed to list MCP resources", zap.String("server", s.cfg.Name), zap.Error(err))
+		}
+		if len(resources) > 0 {
+			found = append(found, &resourceTool{server: s, resources: resources})
+		}
+	}
+
+	var prompts []Prompt
+	if caps.Prompts != nil {
+		var err error
+		if prompts, err = client.ListPrompts(ctx); err != nil {
+			logger.Warn("Failed to list MCP prompts", zap.String("server", s.cfg.Name), zap.Error(err))
+		}
+		if len(prompts) > 0 {
+			found = append(found, &promptTool{server: s, prompts: prompts})
+		}
+	}
+
+	return found, len(resources), len(prompts)
+}
+
+// AllowTool 按允许和禁止列表（支持 * 通配）判断工具是否可用，禁止列表优先
+func AllowTool(allow, deny []string, name string) bool {
+	if matchAny(deny, name) {
+		return false
+	}
+	return len(allow) == 0 || matchAny(allow, name)
+}
+
+// matchAny 判断名称是否匹配任一模式
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p == name {
+			return true
+		}
+		if matched, err := filepath.Match(p, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// transportName 返回服务器使用的传输
+func transportName(cfg config.MCPServerConfig) string {
+	if cfg.Command != "" {
+		return "stdio"
+	}
+	return "http"
+}
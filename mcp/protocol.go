@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion 客户端请求的 MCP 协议版本
+const ProtocolVersion = "2025-03-26"
+
+// JSON-RPC 错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// 服务器通知
+const (
+	NotifyToolsListChanged     = "notifications/tools/list_changed"
+	NotifyResourcesListChanged = "notifications/resources/list_changed"
+	NotifyPromptsListChanged   = "notifications/prompts/list_changed"
+)
+
+// Message JSON-RPC 2.0 消息（请求、响应或通知）
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// IsResponse 是否为响应
+func (m *Message) IsResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// IsRequest 是否为请求（需要回复）
+func (m *Message) IsRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+// IsNotification 是否为通知
+func (m *Message) IsNotification() bool {
+	return m.Method != "" && len(m.ID) == 0
+}
+
+// RPCError JSON-RPC 错误
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Implementation 客户端或服务器信息
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ListChangedCapability 支持列表变化通知的能力
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability 资源能力
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ServerCapabilities 服务器能力
+type ServerCapabilities struct {
+	Tools     *ListChangedCapability `json:"tools,omitempty"`
+	Resources *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts   *ListChangedCapability `json:"prompts,omitempty"`
+	Logging   *struct{}              `json:"logging,omitempty"`
+}
+
+// InitializeParams initialize 请求参数
+type InitializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+// InitializeResult initialize 响应
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// Tool 服务器提供的工具
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// ListToolsResult tools/list 响应
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CallToolParams tools/call 请求参数
+type CallToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Content 工具结果或提示词消息中的内容块
+type Content struct {
+	Type     string            `json:"type"` // text、image、audio、resource、resource_link
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // base64
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+	URI      string            `json:"uri,omitempty"` // resource_link
+	Name     string            `json:"name,omitempty"`
+}
+
+// TextContent 创建文本内容块
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// CallToolResult tools/call 响应
+type CallToolResult struct {
+	Content           []Content `json:"content"`
+	StructuredContent any       `json:"structuredContent,omitempty"`
+	IsError           bool      `json:"isError,omitempty"`
+}
+
+// Resource 服务器提供的资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourcesResult resources/list 响应
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// ReadResourceParams resources/read 请求参数
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ResourceContents 资源内容，文本资源使用 Text，二进制资源使用 Blob（base64）
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ReadResourceResult resources/read 响应
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// PromptArgument 提示词参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt 服务器提供的提示词模板
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// ListPromptsResult prompts/list 响应
+type ListPromptsResult struct {
+	Prompts    []Prompt `json:"prompts"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// GetPromptParams prompts/get 请求参数
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptMessage 提示词消息
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult prompts/get 响应
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// paginatedParams 分页请求参数
+type paginatedParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
@@ -0,0 +1,547 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ToolHandler 提供服务器的工具
+type ToolHandler interface {
+	ListTools(ctx context.Context, session *ServerSession) ([]Tool, error)
+	CallTool(ctx context.Context, session *ServerSession, params CallToolParams) (*CallToolResult, error)
+}
+
+// ResourceHandler 提供服务器的资源
+type ResourceHandler interface {
+	ListResources(ctx context.Context, session *ServerSession) ([]Resource, error)
+	ReadResource(ctx context.Context, session *ServerSession, uri string) (*ReadResourceResult, error)
+}
+
+// PromptHandler 提供服务器的提示词
+type PromptHandler interface {
+	ListPrompts(ctx context.Context, session *ServerSession) ([]Prompt, error)
+	GetPrompt(ctx context.Context, session *ServerSession, params GetPromptParams) (*GetPromptResult, error)
+}
+
+// ServerOptions MCP 服务器选项，未设置的处理器对应的能力不会声明
+type ServerOptions struct {
+	Name         string
+	Version      string
+	Instructions string
+	Tools        ToolHandler
+	Resources    ResourceHandler
+	Prompts      PromptHandler
+}
+
+// ElicitParams elicitation/create 请求参数
+type ElicitParams struct {
+	Message         string         `json:"message"`
+	RequestedSchema map[string]any `json:"requestedSchema"`
+}
+
+// ElicitResult elicitation/create 响应，Action 为 accept、decline 或 cancel
+type ElicitResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// ErrNoClientChannel 当前请求无法向客户端发送请求
+var ErrNoClientChannel = errors.New("no channel to the mcp client")
+
+// sendFunc 向客户端发送一条消息
+type sendFunc func(msg *Message) error
+
+type senderContextKey struct{}
+
+// ServerSession 一个客户端会话
+type ServerSession struct {
+	id         string
+	clientInfo Implementation
+	clientCaps map[string]any
+
+	nextID   atomic.Int64
+	pending  map[string]chan *Message
+	inflight map[string]context.CancelFunc
+	values   map[any]any
+	mu       sync.Mutex
+}
+
+// newServerSession 创建会话
+func newServerSession(id string) *ServerSession {
+	return &ServerSession{
+		id:       id,
+		pending:  make(map[string]chan *Message),
+		inflight: make(map[string]context.CancelFunc),
+		values:   make(map[any]any),
+	}
+}
+
+// ID 返回会话 ID
+func (s *ServerSession) ID() string {
+	return s.id
+}
+
+// ClientInfo 返回客户端信息
+func (s *ServerSession) ClientInfo() Implementation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientInfo
+}
+
+// CanElicit 客户端是否支持向用户询问（elicitation）
+func (s *ServerSession) CanElicit() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.clientCaps["elicitation"]
+	return ok
+}
+
+// Value 返回会话上保存的值
+func (s *ServerSession) Value(key any) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// SetValue 在会话上保存值
+func (s *ServerSession) SetValue(key, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Elicit 请客户端向用户询问，只能在处理客户端请求期间调用
+func (s *ServerSession) Elicit(ctx context.Context, params ElicitParams) (*ElicitResult, error) {
+	var result ElicitResult
+	if err := s.request(ctx, "elicitation/create", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// request 向客户端发送请求并等待响应
+func (s *ServerSession) request(ctx context.Context, method string, params any, result any) error {
+	send, ok := ctx.Value(senderContextKey{}).(sendFunc)
+	if !ok {
+		return ErrNoClientChannel
+	}
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(s.nextID.Add(1), 10)
+	msg.ID = json.RawMessage(strconv.Quote("srv-" + id))
+
+	ch := make(chan *Message, 1)
+	s.mu.Lock()
+	s.pending[string(msg.ID)] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, string(msg.ID))
+		s.mu.Unlock()
+	}()
+
+	if err := send(msg); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver 将客户端的响应交给等待中的请求
+func (s *ServerSession) deliver(msg *Message) {
+	s.mu.Lock()
+	ch, ok := s.pending[string(msg.ID)]
+	s.mu.Unlock()
+	if ok {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// track 记录处理中的请求，收到取消通知时取消其上下文
+func (s *ServerSession) track(id json.RawMessage, cancel context.CancelFunc) func() {
+	key := string(id)
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel 取消处理中的请求
+func (s *ServerSession) cancel(id json.RawMessage) {
+	s.mu.Lock()
+	cancel, ok := s.inflight[string(id)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// Server MCP 服务器，支持 stdio 和 Streamable HTTP
+type Server struct {
+	opts     ServerOptions
+	sessions map[string]*ServerSession
+	mu       sync.Mutex
+}
+
+// NewServer 创建 MCP 服务器
+func NewServer(opts ServerOptions) *Server {
+	return &Server{opts: opts, sessions: make(map[string]*ServerSession)}
+}
+
+// handle 处理一条客户端消息，请求返回响应，其他消息返回 nil
+func (s *Server) handle(ctx context.Context, sess *ServerSession, msg *Message, send sendFunc) *Message {
+	switch {
+	case msg.IsResponse():
+		sess.deliver(msg)
+		return nil
+	case msg.IsNotification():
+		if msg.Method == "notifications/cancelled" {
+			var params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil {
+				sess.cancel(params.RequestID)
+			}
+		}
+		return nil
+	case !msg.IsRequest():
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Error: &RPCError{Code: CodeInvalidRequest, Message: "invalid request"}}
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, senderContextKey{}, send))
+	done := sess.track(msg.ID, cancel)
+	defer done()
+
+	resp := &Message{JSONRPC: "2.0", ID: msg.ID}
+	result, err := s.dispatch(ctx, sess, msg)
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
+
+// dispatch 按方法调用处理器
+func (s *Server) dispatch(ctx context.Context, sess *ServerSession, msg *Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		sess.mu.Lock()
+		sess.clientInfo = params.ClientInfo
+		sess.clientCaps = params.Capabilities
+		sess.mu.Unlock()
+		return s.initializeResult(params.ProtocolVersion), nil
+	case "ping":
+		return struct{}{}, nil
+	}
+
+	switch {
+	case s.opts.Tools != nil && msg.Method == "tools/list":
+		list, err := s.opts.Tools.ListTools(ctx, sess)
+		return ListToolsResult{Tools: nonNil(list)}, err
+	case s.opts.Tools != nil && msg.Method == "tools/call":
+		var params CallToolParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.opts.Tools.CallTool(ctx, sess, params)
+	case s.opts.Resources != nil && msg.Method == "resources/list":
+		list, err := s.opts.Resources.ListResources(ctx, sess)
+		return ListResourcesResult{Resources: nonNil(list)}, err
+	case s.opts.Resources != nil && msg.Method == "resources/read":
+		var params ReadResourceParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.opts.Resources.ReadResource(ctx, sess, params.URI)
+	case s.opts.Prompts != nil && msg.Method == "prompts/list":
+		list, err := s.opts.Prompts.ListPrompts(ctx, sess)
+		return ListPromptsResult{Prompts: nonNil(list)}, err
+	case s.opts.Prompts != nil && msg.Method == "prompts/get":
+		var params GetPromptParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.opts.Prompts.GetPrompt(ctx, sess, params)
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// initializeResult 返回服务器能力
+func (s *Server) initializeResult(clientVersion string) InitializeResult {
+	result := InitializeResult{
+		ProtocolVersion: ProtocolVersion,
+		ServerInfo:      Implementation{Name: s.opts.Name, Version: s.opts.Version},
+		Instructions:    s.opts.Instructions,
+	}
+	// 客户端请求的版本不早于我们支持的版本时按客户端版本回复（版本号为日期，可按字符串比较）
+	if clientVersion > ProtocolVersion {
+		result.ProtocolVersion = clientVersion
+	}
+	if s.opts.Tools != nil {
+		result.Capabilities.Tools = &ListChangedCapability{}
+	}
+	if s.opts.Resources != nil {
+		result.Capabilities.Resources = &ResourcesCapability{}
+	}
+	if s.opts.Prompts != nil {
+		result.Capabilities.Prompts = &ListChangedCapability{}
+	}
+	return result
+}
+
+// decodeParams 解析请求参数
+func decodeParams(msg *Message, v any) error {
+	if len(msg.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// nonNil 保证列表序列化为 [] 而不是 null
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+// ServeStdio 通过 stdin/stdout 服务单个客户端，输入结束或 ctx 取消时返回
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	sess := newServerSession("stdio")
+	var writeMu sync.Mutex
+	send := func(msg *Message) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err = out.Write(append(data, '\n'))
+		return err
+	}
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+			var msg Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				_ = send(&Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+				continue
+			}
+			// 请求并发处理，处理期间仍能读取客户端对 elicitation 的响应
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.handle(ctx, sess, &msg, send); resp != nil {
+					if err := send(resp); err != nil {
+						logger.Warn("Failed to write MCP response", zap.Error(err))
+					}
+				}
+			}()
+		}
+	}
+}
+
+// ServeHTTP 实现 Streamable HTTP 传输：POST 发送消息，DELETE 结束会话
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.servePost(w, r)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.sessions, r.Header.Get(SessionHeader))
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	default:
+		// 不提供独立的 GET 通知流
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// servePost 处理一条 POST 消息
+func (s *Server) servePost(w http.ResponseWriter, r *http.Request) {
+	var msg Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize)).Decode(&msg); err != nil {
+		writeJSON(w, http.StatusBadRequest, &Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+		return
+	}
+
+	var sess *ServerSession
+	if msg.Method == "initialize" {
+		sess = newServerSession(newSessionID())
+		s.mu.Lock()
+		s.sessions[sess.id] = sess
+		s.mu.Unlock()
+		w.Header().Set(SessionHeader, sess.id)
+	} else {
+		id := r.Header.Get(SessionHeader)
+		if id == "" {
+			http.Error(w, "missing "+SessionHeader+" header", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		sess = s.sessions[id]
+		s.mu.Unlock()
+		if sess == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+
+	if !msg.IsRequest() {
+		s.handle(r.Context(), sess, &msg, nil)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if msg.Method != "tools/call" {
+		writeJSON(w, http.StatusOK, s.handle(r.Context(), sess, &msg, nil))
+		return
+	}
+
+	// 工具调用使用 SSE 响应，执行期间可以向客户端发送 elicitation 请求
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	var writeMu sync.Mutex
+	send := func(out *Message) error {
+		data, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	if err := send(s.handle(r.Context(), sess, &msg, send)); err != nil {
+		logger.Debug("Failed to write MCP response", zap.Error(err))
+	}
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newSessionID 生成随机会话 ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// allowedOrigin 防止 DNS 重绑定：浏览器请求的 Origin 必须是本机或与 Host 相同
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	reqHost, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		reqHost = r.Host
+	}
+	return host == reqHost
+}
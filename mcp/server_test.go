@@ -0,0 +1,347 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smallnest/goclaw/agent"
+	"github.com/smallnest/goclaw/agent/tools"
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/session"
+)
+
+// fakeTool 记录调用次数的工具
+type fakeTool struct {
+	name   string
+	output string
+	calls  atomic.Int32
+}
+
+func (t *fakeTool) Name() string        { return t.name }
+func (t *fakeTool) Description() string { return "fake " + t.name }
+func (t *fakeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"url": map[string]interface{}{"type": "string"}},
+	}
+}
+func (t *fakeTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	t.calls.Add(1)
+	return t.output, nil
+}
+
+// pipeTransport 通过管道连接进程内服务器的客户端传输
+type pipeTransport struct {
+	w        io.WriteCloser
+	messages chan *Message
+	mu       sync.Mutex
+}
+
+func newPipeTransport(r io.Reader, w io.WriteCloser) *pipeTransport {
+	t := &pipeTransport{w: w, messages: make(chan *Message, 16)}
+	go func() {
+		defer close(t.messages)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var msg Message
+			if json.Unmarshal(scanner.Bytes(), &msg) == nil {
+				t.messages <- &msg
+			}
+		}
+	}()
+	return t
+}
+
+func (t *pipeTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err = t.w.Write(append(data, '\n'))
+	return err
+}
+
+func (t *pipeTransport) Messages() <-chan *Message { return t.messages }
+func (t *pipeTransport) Close() error              { return t.w.Close() }
+
+// goclawFixture 测试用的 goclaw MCP 服务器
+type goclawFixture struct {
+	server    *Server
+	workspace string
+	fetch     *fakeTool
+	exec      *fakeTool
+}
+
+func newGoclawFixture(t *testing.T) *goclawFixture {
+	t.Helper()
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	for path, content := range map[string]string{
+		"notes.md":               "remember the milk",
+		"secret/keys.txt":        "do not share",
+		".hidden/state":          "internal",
+		"../skills/sum/SKILL.md": "---\nname: summarize\ndescription: Summarize text\n---\nSummarize the input in three bullets.",
+	} {
+		full := filepath.Join(workspace, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := session.NewManager(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := sessions.GetOrCreate("telegram:42")
+	sess.AddMessage(session.Message{Role: "user", Content: "hello goclaw", Timestamp: time.Now()})
+	if err := sessions.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	skills := agent.NewSkillsLoader(dir, []string{filepath.Join(dir, "skills")})
+	if err := skills.Discover(); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := agent.NewUntrustedContentPolicy(&config.UntrustedContentConfig{
+		Enabled:         true,
+		Classifier:      agent.ClassifierOff,
+		RequireApproval: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &goclawFixture{
+		workspace: workspace,
+		fetch:     &fakeTool{name: "web_fetch", output: "page content"},
+		exec:      &fakeTool{name: "exec", output: "command ran"},
+	}
+	runner := agent.NewToolRunner(&agent.LoopConfig{Untrusted: policy}, agent.ToAgentTools([]tools.Tool{f.fetch, f.exec}))
+	f.server = NewGoclawServer("test", GoclawOptions{
+		Runner:     runner,
+		Sessions:   sessions,
+		FileSystem: tools.NewFileSystemTool(nil, []string{filepath.Join(workspace, "secret")}, workspace),
+		Workspace:  workspace,
+		Skills:     skills,
+	})
+	return f
+}
+
+// serveStdioPipes 在管道上运行服务器，返回客户端一侧的读写端
+func serveStdioPipes(t *testing.T, server *Server) (io.Reader, io.WriteCloser) {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = server.ServeStdio(ctx, serverIn, serverOut)
+		serverOut.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		clientOut.Close()
+		<-done
+	})
+	return clientIn, clientOut
+}
+
+func TestGoclawServerStdio(t *testing.T) {
+	f := newGoclawFixture(t)
+	r, w := serveStdioPipes(t, f.server)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient("goclaw", newPipeTransport(r, w), nil)
+	defer client.Close()
+	if err := client.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if info := client.ServerInfo(); info.Name != "goclaw" || info.Version != "test" {
+		t.Errorf("server info = %+v", info)
+	}
+
+	remote, err := client.ListTools(ctx)
+	if err != nil || len(remote) != 2 {
+		t.Fatalf("tools = %v, %v", remote, err)
+	}
+
+	result, err := client.CallTool(ctx, "web_fetch", map[string]any{"url": "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := FormatToolResult(result)
+	if err != nil || !strings.Contains(text, "page content") {
+		t.Errorf("web_fetch result = %q, %v", text, err)
+	}
+
+	// 读取不可信内容后，客户端不支持 elicitation 时副作用工具被拦截
+	result, err = client.CallTool(ctx, "exec", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || f.exec.calls.Load() != 0 {
+		t.Errorf("exec after untrusted content should be blocked, got %+v", result)
+	}
+
+	if _, err := client.CallTool(ctx, "missing", nil); err == nil {
+		t.Error("unknown tool should fail")
+	}
+
+	resources, err := client.ListResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uris []string
+	for _, res := range resources {
+		uris = append(uris, res.URI)
+	}
+	joined := strings.Join(uris, " ")
+	if !strings.Contains(joined, "session:telegram_42") || !strings.Contains(joined, "notes.md") {
+		t.Errorf("resources = %v", uris)
+	}
+	if strings.Contains(joined, "secret") || strings.Contains(joined, ".hidden") {
+		t.Errorf("denied or hidden files listed: %v", uris)
+	}
+
+	transcript, err := client.ReadResource(ctx, "session:telegram_42")
+	if err != nil || !strings.Contains(transcript.Contents[0].Text, "hello goclaw") {
+		t.Errorf("transcript = %+v, %v", transcript, err)
+	}
+	file, err := client.ReadResource(ctx, fileURI(filepath.Join(f.workspace, "notes.md")))
+	if err != nil || file.Contents[0].Text != "remember the milk" {
+		t.Errorf("notes.md = %+v, %v", file, err)
+	}
+	if _, err := client.ReadResource(ctx, fileURI(filepath.Join(f.workspace, "secret", "keys.txt"))); err == nil {
+		t.Error("denied file should not be readable")
+	}
+	if _, err := client.ReadResource(ctx, fileURI(filepath.Join(f.workspace, "..", "skills", "sum", "SKILL.md"))); err == nil {
+		t.Error("files outside the workspace should not be readable")
+	}
+
+	prompts, err := client.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "summarize" {
+		t.Fatalf("prompts = %+v, %v", prompts, err)
+	}
+	prompt, err := client.GetPrompt(ctx, "summarize", map[string]string{"task": "the release notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := prompt.Messages[0].Content.Text; !strings.Contains(msg, "three bullets") || !strings.Contains(msg, "the release notes") {
+		t.Errorf("prompt = %q", msg)
+	}
+}
+
+func TestGoclawServerElicitation(t *testing.T) {
+	for _, action := range []string{"accept", "decline"} {
+		t.Run(action, func(t *testing.T) {
+			f := newGoclawFixture(t)
+			r, w := serveStdioPipes(t, f.server)
+			lines := bufio.NewScanner(r)
+			send := func(line string) {
+				if _, err := w.Write([]byte(line + "\n")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			read := func() *Message {
+				if !lines.Scan() {
+					t.Fatal("server closed the connection")
+				}
+				var msg Message
+				if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+					t.Fatal(err)
+				}
+				return &msg
+			}
+
+			send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"elicitation":{}},"clientInfo":{"name":"ide","version":"1"}}}`)
+			if resp := read(); resp.Error != nil {
+				t.Fatalf("initialize: %v", resp.Error)
+			}
+			send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"web_fetch","arguments":{"url":"https://example.com"}}}`)
+			read()
+
+			send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"exec","arguments":{}}}`)
+			req := read()
+			if req.Method != "elicitation/create" {
+				t.Fatalf("expected elicitation request, got %+v", req)
+			}
+			send(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":{"action":"` + action + `"}}`)
+
+			resp := read()
+			var result CallToolResult
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				t.Fatal(err)
+			}
+			if approved := action == "accept"; result.IsError == approved || (f.exec.calls.Load() == 1) != approved {
+				t.Errorf("action %s: result %+v, exec calls %d", action, result, f.exec.calls.Load())
+			}
+		})
+	}
+}
+
+func TestGoclawServerHTTP(t *testing.T) {
+	f := newGoclawFixture(t)
+	srv := httptest.NewServer(f.server)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := Connect(ctx, config.MCPServerConfig{Name: "goclaw", URL: srv.URL}, nil)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.CallTool(ctx, "exec", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := FormatToolResult(result); err != nil || text != "command ran" {
+		t.Errorf("exec = %q, %v", text, err)
+	}
+	resources, err := client.ListResources(ctx)
+	if err != nil || len(resources) == 0 {
+		t.Errorf("resources = %v, %v", resources, err)
+	}
+
+	// 非 initialize 请求必须带会话 ID
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request without session: status %d", resp.StatusCode)
+	}
+
+	// 拒绝来自其他网站的浏览器请求
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin request: status %d", resp.StatusCode)
+	}
+}
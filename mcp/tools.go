@@ -0,0 +1,293 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ToolSeparator 服务器名与工具名之间的分隔符
+const ToolSeparator = "__"
+
+// maxToolNameLen 模型提供商允许的最大工具名长度
+const maxToolNameLen = 64
+
+// maxListedItems 工具描述中最多列出的资源或提示词数量
+const maxListedItems = 50
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolName 返回带服务器前缀的工具名（server__tool），替换模型不接受的字符
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server, "_") + ToolSeparator + invalidNameChars.ReplaceAllString(tool, "_")
+	if len(name) > maxToolNameLen {
+		name = name[:maxToolNameLen]
+	}
+	return name
+}
+
+// remoteTool 调用服务器上的工具
+type remoteTool struct {
+	server *server
+	name   string
+	tool   Tool
+}
+
+func (t *remoteTool) Name() string {
+	return t.name
+}
+
+func (t *remoteTool) Description() string {
+	desc := t.tool.Description
+	if desc == "" {
+		desc = t.tool.Name
+	}
+	return fmt.Sprintf("[MCP %s] %s", t.server.cfg.Name, desc)
+}
+
+func (t *remoteTool) Parameters() map[string]interface{} {
+	if len(t.tool.InputSchema) == 0 {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return t.tool.InputSchema
+}
+
+// MCPServer 返回工具所属的服务器，用于按 Agent 过滤
+func (t *remoteTool) MCPServer() string {
+	return t.server.cfg.Name
+}
+
+func (t *remoteTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	client, err := t.server.connected()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.server.timeout)
+	defer cancel()
+
+	result, err := client.CallTool(ctx, t.tool.Name, params)
+	if err != nil {
+		return "", fmt.Errorf("mcp tool %s failed: %w", t.name, err)
+	}
+	return FormatToolResult(result)
+}
+
+// FormatToolResult 将工具结果转换为文本，服务器标记 isError 时返回错误
+func FormatToolResult(result *CallToolResult) (string, error) {
+	var parts []string
+	for _, c := range result.Content {
+		if text := formatContent(c); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 && result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		if text == "" {
+			text = "tool returned an error"
+		}
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// formatContent 将内容块转换为文本，二进制内容只给出摘要
+func formatContent(c Content) string {
+	switch c.Type {
+	case "text":
+		return c.Text
+	case "image", "audio":
+		return fmt.Sprintf("[%s %s, %d bytes]", c.Type, c.MimeType, base64.StdEncoding.DecodedLen(len(c.Data)))
+	case "resource":
+		if c.Resource != nil {
+			return formatResourceContents(*c.Resource)
+		}
+	case "resource_link":
+		return fmt.Sprintf("[resource %s %s]", c.URI, c.Name)
+	}
+	return ""
+}
+
+// formatResourceContents 文本资源返回内容，二进制资源只给出摘要
+func formatResourceContents(rc ResourceContents) string {
+	if rc.Blob != "" {
+		return fmt.Sprintf("[binary resource %s %s, %d bytes]", rc.URI, rc.MimeType, base64.StdEncoding.DecodedLen(len(rc.Blob)))
+	}
+	return rc.Text
+}
+
+// resourceTool 读取服务器资源（server__read_resource）
+type resourceTool struct {
+	server    *server
+	resources []Resource
+}
+
+func (t *resourceTool) Name() string {
+	return ToolName(t.server.cfg.Name, "read_resource")
+}
+
+func (t *resourceTool) Description() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[MCP %s] Read a resource by URI. Available resources:", t.server.cfg.Name)
+	for i, r := range t.resources {
+		if i == maxListedItems {
+			fmt.Fprintf(&sb, "\n- ... and %d more", len(t.resources)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\n- %s", r.URI)
+		if r.Name != "" && r.Name != r.URI {
+			fmt.Fprintf(&sb, " (%s)", r.Name)
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&sb, ": %s", r.Description)
+		}
+	}
+	return sb.String()
+}
+
+func (t *resourceTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"uri": map[string]interface{}{
+				"type":        "string",
+				"description": "Resource URI",
+			},
+		},
+		"required": []string{"uri"},
+	}
+}
+
+func (t *resourceTool) MCPServer() string {
+	return t.server.cfg.Name
+}
+
+func (t *resourceTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	uri, _ := params["uri"].(string)
+	if uri == "" {
+		return "", errors.New("uri is required")
+	}
+	client, err := t.server.connected()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.server.timeout)
+	defer cancel()
+
+	result, err := client.ReadResource(ctx, uri)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	parts := make([]string, 0, len(result.Contents))
+	for _, rc := range result.Contents {
+		parts = append(parts, formatResourceContents(rc))
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// promptTool 获取服务器提示词（server__get_prompt）
+type promptTool struct {
+	server  *server
+	prompts []Prompt
+}
+
+func (t *promptTool) Name() string {
+	return ToolName(t.server.cfg.Name, "get_prompt")
+}
+
+func (t *promptTool) Description() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[MCP %s] Get a prompt template filled with arguments. Available prompts:", t.server.cfg.Name)
+	for i, p := range t.prompts {
+		if i == maxListedItems {
+			fmt.Fprintf(&sb, "\n- ... and %d more", len(t.prompts)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\n- %s", p.Name)
+		if len(p.Arguments) > 0 {
+			args := make([]string, 0, len(p.Arguments))
+			for _, a := range p.Arguments {
+				if a.Required {
+					args = append(args, a.Name+"*")
+				} else {
+					args = append(args, a.Name)
+				}
+			}
+			fmt.Fprintf(&sb, "(%s)", strings.Join(args, ", "))
+		}
+		if p.Description != "" {
+			fmt.Fprintf(&sb, ": %s", p.Description)
+		}
+	}
+	return sb.String()
+}
+
+func (t *promptTool) Parameters() map[string]interface{} {
+	names := make([]string, 0, len(t.prompts))
+	for _, p := range t.prompts {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Prompt name",
+				"enum":        names,
+			},
+			"arguments": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Prompt arguments (arguments marked * are required)",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+		},
+		"required": []string{"name"},
+	}
+}
+
+func (t *promptTool) MCPServer() string {
+	return t.server.cfg.Name
+}
+
+func (t *promptTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	args := make(map[string]string)
+	if raw, ok := params["arguments"].(map[string]interface{}); ok {
+		for k, v := range raw {
+			args[k] = fmt.Sprint(v)
+		}
+	}
+
+	client, err := t.server.connected()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.server.timeout)
+	defer cancel()
+
+	result, err := client.GetPrompt(ctx, name, args)
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+	var sb strings.Builder
+	if result.Description != "" {
+		sb.WriteString(result.Description + "\n\n")
+	}
+	for _, msg := range result.Messages {
+		fmt.Fprintf(&sb, "[%s]\n%s\n\n", msg.Role, formatContent(msg.Content))
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
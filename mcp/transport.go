@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/smallnest/goclaw/config"
+	"github.com/smallnest/goclaw/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ErrClosed 连接已关闭（服务器退出或被关闭）
+var ErrClosed = errors.New("mcp connection closed")
+
+// maxMessageSize 单条消息的最大字节数
+const maxMessageSize = 16 * 1024 * 1024
+
+// Transport 双向传输 JSON-RPC 消息
+type Transport interface {
+	// Send 发送一条消息
+	Send(ctx context.Context, msg *Message) error
+	// Messages 服务器发来的消息，连接断开后关闭
+	Messages() <-chan *Message
+	// Close 关闭连接
+	Close() error
+}
+
+// NewTransport 按服务器配置创建传输，command 优先于 url
+func NewTransport(cfg config.MCPServerConfig) (Transport, error) {
+	switch {
+	case cfg.Command != "":
+		return newStdioTransport(cfg)
+	case cfg.URL != "":
+		return newHTTPTransport(cfg), nil
+	default:
+		return nil, fmt.Errorf("mcp server %s: either command or url is required", cfg.Name)
+	}
+}
+
+// stdioTransport 通过子进程的 stdin/stdout 交换以换行分隔的 JSON 消息
+type stdioTransport struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	messages chan *Message
+	writeMu  sync.Mutex
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// newStdioTransport 启动服务器进程
+func newStdioTransport(cfg config.MCPServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	cmd.Dir = cfg.WorkingDir
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %s: %w", cfg.Name, err)
+	}
+
+	t := &stdioTransport{
+		name:     cfg.Name,
+		cmd:      cmd,
+		stdin:    stdin,
+		messages: make(chan *Message, 16),
+		closed:   make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	go t.logStderr(stderr)
+	return t, nil
+}
+
+// readLoop 读取服务器输出，进程退出后关闭消息通道
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer close(t.messages)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			logger.Debug("Ignoring invalid MCP message",
+				zap.String("server", t.name),
+				zap.Error(err))
+			continue
+		}
+		select {
+		case t.messages <- &msg:
+		case <-t.closed:
+			// 已关闭时丢弃剩余输出，直到进程退出
+		}
+	}
+
+	err := t.cmd.Wait()
+	select {
+	case <-t.closed:
+	default:
+		logger.Warn("MCP server process exited",
+			zap.String("server", t.name),
+			zap.Error(err))
+	}
+}
+
+// logStderr 将服务器的 stderr 写入调试日志
+func (t *stdioTransport) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logger.Debug("MCP server stderr",
+			zap.String("server", t.name),
+			zap.String("line", scanner.Text()))
+	}
+}
+
+func (t *stdioTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	select {
+	case <-t.closed:
+		return ErrClosed
+	default:
+	}
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) Messages() <-chan *Message {
+	return t.messages
+}
+
+// Close 关闭 stdin 让服务器自行退出，超时后强制结束进程
+func (t *stdioTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+		_ = t.stdin.Close()
+
+		exited := make(chan struct{})
+		go func() {
+			for range t.messages {
+			}
+			close(exited)
+		}()
+		select {
+		case <-exited:
+		case <-time.After(3 * time.Second):
+			_ = t.cmd.Process.Kill()
+		}
+	})
+	return nil
+}
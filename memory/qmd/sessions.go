@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/smallnest/goclaw/internal/redact"
 )
 
 // SessionFile 会话文件格式
@@ -75,44 +76,13 @@ func sanitizeSessionContent(session *SessionFile) string {
 	return sb.String()
 }
 
-// sanitizeText 对文本进行脱敏处理
+// sanitizeText 对文本进行脱敏处理：全局引擎处理凭据和已知密钥，导出时另外去掉邮箱和手机号
 func sanitizeText(text string) string {
-	// 移除或替换敏感信息
-	text = redactAPIKeys(text)
-	text = redactPasswords(text)
-	text = redactEmails(text)
-	text = redactPhoneNumbers(text)
-
-	return text
-}
-
-// 敏感信息正则表达式模式
-var (
-	apiKeyPattern   = regexp.MustCompile(`(?i)(api[_-]?key|apikey|secret|token)["\s:=]+([a-zA-Z0-9_\-]{16,})`)
-	passwordPattern = regexp.MustCompile(`(?i)(password|passwd|pwd)["\s:=]+([^\s]+)`)
-	emailPattern    = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
-	phonePattern    = regexp.MustCompile(`1[3-9]\d{9}`) // 中国手机号
-)
-
-// redactAPIKeys 替换 API 密钥
-func redactAPIKeys(text string) string {
-	return apiKeyPattern.ReplaceAllString(text, `$1 [REDACTED_API_KEY]`)
+	return piiRedactor.String(redact.String(text))
 }
 
-// redactPasswords 替换密码
-func redactPasswords(text string) string {
-	return passwordPattern.ReplaceAllString(text, `$1 [REDACTED_PASSWORD]`)
-}
-
-// redactEmails 替换邮箱
-func redactEmails(text string) string {
-	return emailPattern.ReplaceAllString(text, "[REDACTED_EMAIL]")
-}
-
-// redactPhoneNumbers 替换手机号
-func redactPhoneNumbers(text string) string {
-	return phonePattern.ReplaceAllString(text, "[REDACTED_PHONE]")
-}
+// piiRedactor 导出会话时使用的个人信息检测器
+var piiRedactor, _ = redact.NewWithDetectors(redact.PIIDetectors...)
 
 // ExportAllSessions 导出所有会话
 func ExportAllSessions(sessionDir, exportDir string, retentionDays int) error {
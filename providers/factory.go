@@ -17,15 +17,23 @@ const (
 	ProviderTypeOpenRouter ProviderType = "openrouter"
 )
 
-// NewProvider 创建提供商（支持故障转移和配置轮换）
+// NewProvider 创建提供商（支持故障转移和配置轮换），每次调用都会记录追踪 span
 func NewProvider(cfg *config.Config) (Provider, error) {
+	var provider Provider
+	var err error
+
 	// 如果启用了故障转移且配置了多个配置，使用轮换提供商
 	if cfg.Providers.Failover.Enabled && len(cfg.Providers.Profiles) > 0 {
-		return NewRotationProviderFromConfig(cfg)
+		provider, err = NewRotationProviderFromConfig(cfg)
+	} else {
+		// 否则使用单一提供商
+		provider, err = NewSimpleProvider(cfg)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// 否则使用单一提供商
-	return NewSimpleProvider(cfg)
+	return NewTracingProvider(provider, cfg.Agents.Defaults.Model), nil
 }
 
 // NewSimpleProvider 创建单一提供商
@@ -36,16 +44,23 @@ func NewSimpleProvider(cfg *config.Config) (Provider, error) {
 		return nil, err
 	}
 
+	var provider Provider
 	switch providerType {
 	case ProviderTypeOpenAI:
-		return NewOpenAIProvider(cfg.Providers.OpenAI.APIKey, cfg.Providers.OpenAI.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
+		provider, err = NewOpenAIProvider(cfg.Providers.OpenAI.APIKey, cfg.Providers.OpenAI.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
 	case ProviderTypeAnthropic:
-		return NewAnthropicProvider(cfg.Providers.Anthropic.APIKey, cfg.Providers.Anthropic.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
+		provider, err = NewAnthropicProvider(cfg.Providers.Anthropic.APIKey, cfg.Providers.Anthropic.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
 	case ProviderTypeOpenRouter:
-		return NewOpenRouterProvider(cfg.Providers.OpenRouter.APIKey, cfg.Providers.OpenRouter.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
+		provider, err = NewOpenRouterProvider(cfg.Providers.OpenRouter.APIKey, cfg.Providers.OpenRouter.BaseURL, model, cfg.Agents.Defaults.MaxTokens)
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", providerType)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 单一提供商以提供商类型作为指标中的配置名
+	return NewMetricsProvider(provider, model, string(providerType)), nil
 }
 
 // NewRotationProviderFromConfig 从配置创建轮换提供商
@@ -78,7 +93,7 @@ func NewRotationProviderFromConfig(cfg *config.Config) (Provider, error) {
 			priority = 1
 		}
 
-		rotation.AddProfile(profileCfg.Name, prov, profileCfg.APIKey, priority)
+		rotation.AddProfile(profileCfg.Name, NewMetricsProvider(prov, cfg.Agents.Defaults.Model, profileCfg.Name), profileCfg.APIKey, priority)
 	}
 
 	// 如果只有一个配置，返回第一个提供商
@@ -88,9 +103,10 @@ func NewRotationProviderFromConfig(cfg *config.Config) (Provider, error) {
 		if err != nil {
 			return nil, err
 		}
-		return prov, nil
+		return NewMetricsProvider(prov, cfg.Agents.Defaults.Model, p.Name), nil
 	}
 
+	rotation.registerMetrics()
 	return rotation, nil
 }
 
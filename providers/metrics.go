@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallnest/goclaw/internal/metrics"
+)
+
+// MetricsProvider 记录每次 LLM 调用的延迟和 token 用量，按模型和配置名区分
+type MetricsProvider struct {
+	provider Provider
+	model    string
+	profile  string
+}
+
+// NewMetricsProvider 创建带指标的提供商
+func NewMetricsProvider(provider Provider, model, profile string) *MetricsProvider {
+	return &MetricsProvider{
+		provider: provider,
+		model:    model,
+		profile:  profile,
+	}
+}
+
+// Chat 聊天
+func (p *MetricsProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, options ...ChatOption) (*Response, error) {
+	start := time.Now()
+	response, err := p.provider.Chat(ctx, messages, tools, options...)
+	p.observe(start, response, err)
+	return response, err
+}
+
+// ChatWithTools 聊天（带工具）
+func (p *MetricsProvider) ChatWithTools(ctx context.Context, messages []Message, tools []ToolDefinition, options ...ChatOption) (*Response, error) {
+	start := time.Now()
+	response, err := p.provider.ChatWithTools(ctx, messages, tools, options...)
+	p.observe(start, response, err)
+	return response, err
+}
+
+// Close 关闭连接
+func (p *MetricsProvider) Close() error {
+	return p.provider.Close()
+}
+
+// observe 记录本次调用
+func (p *MetricsProvider) observe(start time.Time, response *Response, err error) {
+	var promptTokens, completionTokens int
+	if response != nil {
+		promptTokens = response.Usage.PromptTokens
+		completionTokens = response.Usage.CompletionTokens
+	}
+	metrics.ObserveLLM(p.model, p.profile, time.Since(start), promptTokens, completionTokens, err)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/smallnest/goclaw/internal/metrics"
 	"github.com/smallnest/goclaw/types"
 )
 
@@ -216,6 +217,28 @@ func (p *RotationProvider) setCooldown(profileName string) {
 	profile.mu.Unlock()
 }
 
+// registerMetrics 注册各配置的熔断状态指标（冷却中为 1）
+func (p *RotationProvider) registerMetrics() {
+	metrics.RegisterLabeledGauge("provider_circuit_open", "Whether a provider profile is in cooldown after auth, rate limit or billing errors (1 = open).", "profile", func() map[string]float64 {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+
+		now := time.Now()
+		states := make(map[string]float64, len(p.profiles))
+		for name, profile := range p.profiles {
+			profile.mu.Lock()
+			open := !profile.CooldownUntil.IsZero() && now.Before(profile.CooldownUntil)
+			profile.mu.Unlock()
+			if open {
+				states[name] = 1
+			} else {
+				states[name] = 0
+			}
+		}
+		return states
+	})
+}
+
 // shouldSetCooldown 判断是否应该设置冷却
 func (p *RotationProvider) shouldSetCooldown(reason types.FailoverReason) bool {
 	switch reason {
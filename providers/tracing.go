@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/smallnest/goclaw/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingProvider 为每次 LLM 调用创建追踪 span，记录模型、token 用量和错误分类
+type TracingProvider struct {
+	provider Provider
+	model    string
+}
+
+// NewTracingProvider 创建带追踪的提供商
+func NewTracingProvider(provider Provider, model string) *TracingProvider {
+	return &TracingProvider{
+		provider: provider,
+		model:    model,
+	}
+}
+
+// Chat 聊天
+func (p *TracingProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, options ...ChatOption) (*Response, error) {
+	ctx, span := p.start(ctx, messages, tools)
+	response, err := p.provider.Chat(ctx, messages, tools, options...)
+	p.end(span, response, err)
+	return response, err
+}
+
+// ChatWithTools 聊天（带工具）
+func (p *TracingProvider) ChatWithTools(ctx context.Context, messages []Message, tools []ToolDefinition, options ...ChatOption) (*Response, error) {
+	ctx, span := p.start(ctx, messages, tools)
+	response, err := p.provider.ChatWithTools(ctx, messages, tools, options...)
+	p.end(span, response, err)
+	return response, err
+}
+
+// Close 关闭连接
+func (p *TracingProvider) Close() error {
+	return p.provider.Close()
+}
+
+// start 开始 LLM 调用的 span
+func (p *TracingProvider) start(ctx context.Context, messages []Message, tools []ToolDefinition) (context.Context, trace.Span) {
+	return telemetry.Tracer().Start(ctx, "llm.chat",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			telemetry.AttrModel.String(p.model),
+			attribute.Int("gen_ai.request.messages", len(messages)),
+			attribute.Int("gen_ai.request.tools", len(tools)),
+		))
+}
+
+// end 记录响应的 token 用量并结束 span
+func (p *TracingProvider) end(span trace.Span, response *Response, err error) {
+	if response != nil {
+		span.SetAttributes(
+			telemetry.AttrFinishReason.String(response.FinishReason),
+			telemetry.AttrPromptTokens.Int(response.Usage.PromptTokens),
+			telemetry.AttrCompletionTokens.Int(response.Usage.CompletionTokens),
+			telemetry.AttrTotalTokens.Int(response.Usage.TotalTokens),
+			attribute.Int("gen_ai.response.tool_calls", len(response.ToolCalls)),
+		)
+	}
+	telemetry.End(span, err)
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckpointMetadataKey 运行检查点在会话元数据中的键
+const CheckpointMetadataKey = "run_checkpoint"
+
+// 检查点状态
+const (
+	CheckpointStatusRunning     = "running"     // 运行中（进程退出时仍为此状态即表示运行被中断）
+	CheckpointStatusInterrupted = "interrupted" // 已检测到中断，等待用户重试
+)
+
+// RunCheckpoint 进行中的 Agent 运行的检查点
+type RunCheckpoint struct {
+	RunID      string     `json:"run_id"`
+	SessionKey string     `json:"session_key"`
+	Status     string     `json:"status"`
+	Phase      string     `json:"phase"` // started, llm_response, tool_result
+	Channel    string     `json:"channel"`
+	AccountID  string     `json:"account_id"`
+	ChatID     string     `json:"chat_id"`
+	SenderID   string     `json:"sender_id,omitempty"`
+	Prompt     string     `json:"prompt"`             // 触发本次运行的用户消息
+	Messages   []Message  `json:"messages,omitempty"` // 本次运行已产生的消息（含用户消息）
+	Todos      []TodoItem `json:"todos,omitempty"`    // 保存检查点时的任务清单
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SetCheckpoint 设置运行检查点
+func (s *Session) SetCheckpoint(cp *RunCheckpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]interface{})
+	}
+	s.Metadata[CheckpointMetadataKey] = cp
+}
+
+// GetCheckpoint 获取运行检查点，没有时返回 nil
+func (s *Session) GetCheckpoint() *RunCheckpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return decodeCheckpoint(s.Metadata[CheckpointMetadataKey])
+}
+
+// ClearCheckpoint 清除运行检查点
+func (s *Session) ClearCheckpoint() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Metadata, CheckpointMetadataKey)
+}
+
+// ListCheckpoints 扫描会话目录，返回所有带有运行检查点的会话中的检查点
+// 直接读取磁盘文件，不会写入会话缓存
+func (m *Manager) ListCheckpoints() ([]*RunCheckpoint, error) {
+	entries, err := os.ReadDir(m.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []*RunCheckpoint
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+
+		sess, err := m.load(strings.TrimSuffix(entry.Name(), ".jsonl"))
+		if err != nil {
+			continue
+		}
+		if cp := decodeCheckpoint(sess.Metadata[CheckpointMetadataKey]); cp != nil {
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+
+	return checkpoints, nil
+}
+
+// decodeCheckpoint 解析检查点（从磁盘加载后元数据是 map 形式）
+func decodeCheckpoint(value interface{}) *RunCheckpoint {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case *RunCheckpoint:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var cp RunCheckpoint
+		if err := json.Unmarshal(data, &cp); err != nil || cp.RunID == "" {
+			return nil
+		}
+		return &cp
+	}
+}
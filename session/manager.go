@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -82,11 +83,42 @@ func (s *Session) Clear() {
 	s.UpdatedAt = time.Now()
 }
 
+// GetMetadata 获取会话元数据
+func (s *Session) GetMetadata(key string) interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Metadata[key]
+}
+
+// SetMetadata 设置会话元数据，value 为 nil 时删除该键
+func (s *Session) SetMetadata(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if value == nil {
+		delete(s.Metadata, key)
+		return
+	}
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]interface{})
+	}
+	s.Metadata[key] = value
+}
+
+// Redactor 保存会话前对消息内容脱敏
+type Redactor interface {
+	String(s string) string
+	Map(m map[string]interface{}) map[string]interface{}
+}
+
 // Manager 会话管理器
 type Manager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	baseDir  string
+	sessions    map[string]*Session
+	mu          sync.RWMutex
+	baseDir     string
+	deleteHooks []func(key string)
+	redactor    Redactor
 }
 
 // NewManager 创建会话管理器
@@ -149,13 +181,21 @@ func (m *Manager) Save(session *Session) error {
 	}
 	defer file.Close()
 
+	m.mu.RLock()
+	redactor := m.redactor
+	m.mu.RUnlock()
+
 	// 写入元数据行
 	encoder := json.NewEncoder(file)
+	sessionMetadata := session.Metadata
+	if redactor != nil {
+		sessionMetadata = redactMetadata(redactor, sessionMetadata)
+	}
 	metadata := map[string]interface{}{
 		"_type":      "metadata",
 		"created_at": session.CreatedAt,
 		"updated_at": session.UpdatedAt,
-		"metadata":   session.Metadata,
+		"metadata":   sessionMetadata,
 	}
 	if err := encoder.Encode(metadata); err != nil {
 		return err
@@ -163,6 +203,9 @@ func (m *Manager) Save(session *Session) error {
 
 	// 写入消息
 	for _, msg := range session.Messages {
+		if redactor != nil {
+			msg = redactMessage(redactor, msg)
+		}
 		if err := encoder.Encode(msg); err != nil {
 			return err
 		}
@@ -179,7 +222,6 @@ func (m *Manager) Save(session *Session) error {
 // Delete 删除会话
 func (m *Manager) Delete(key string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	// 从缓存中删除
 	delete(m.sessions, key)
@@ -187,12 +229,70 @@ func (m *Manager) Delete(key string) error {
 	// 删除文件
 	filePath := m.sessionPath(key)
 	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		m.mu.Unlock()
 		return err
 	}
+	hooks := slices.Clone(m.deleteHooks)
+	m.mu.Unlock()
+
+	// 清理会话相关的数据（释放锁后调用，钩子可以再访问 Manager）
+	for _, hook := range hooks {
+		hook(key)
+	}
 
 	return nil
 }
 
+// SetRedactor 设置会话文件的脱敏器，设置后保存到磁盘的消息内容和工具参数会先脱敏（内存中保持原文）
+func (m *Manager) SetRedactor(redactor Redactor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redactor = redactor
+}
+
+// redactMetadata 返回脱敏后的元数据副本：运行检查点中的用户消息和已产生的消息与会话消息一样脱敏
+func redactMetadata(redactor Redactor, metadata map[string]interface{}) map[string]interface{} {
+	cp := decodeCheckpoint(metadata[CheckpointMetadataKey])
+	if cp == nil {
+		return metadata
+	}
+	redacted := *cp
+	redacted.Prompt = redactor.String(cp.Prompt)
+	redacted.Messages = make([]Message, len(cp.Messages))
+	for i, msg := range cp.Messages {
+		redacted.Messages[i] = redactMessage(redactor, msg)
+	}
+
+	out := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[CheckpointMetadataKey] = &redacted
+	return out
+}
+
+// redactMessage 返回脱敏后的消息副本
+func redactMessage(redactor Redactor, msg Message) Message {
+	msg.Content = redactor.String(msg.Content)
+	if len(msg.ToolCalls) > 0 {
+		toolCalls := make([]ToolCall, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			tc.Params = redactor.Map(tc.Params)
+			toolCalls[i] = tc
+		}
+		msg.ToolCalls = toolCalls
+	}
+	return msg
+}
+
+// OnDelete 注册会话删除回调，用于清理会话相关的数据（例如工具输出缓存）
+func (m *Manager) OnDelete(hook func(key string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteHooks = append(m.deleteHooks, hook)
+}
+
 // List 列出所有会话
 func (m *Manager) List() ([]string, error) {
 	m.mu.RLock()
@@ -216,6 +316,14 @@ func (m *Manager) List() ([]string, error) {
 	return keys, nil
 }
 
+// Load 从磁盘读取会话（不放入缓存），用于只读场景，例如 MCP 资源
+func (m *Manager) Load(key string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.load(key)
+}
+
 // load 从磁盘加载会话
 func (m *Manager) load(key string) (*Session, error) {
 	filePath := m.sessionPath(key)
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TodosMetadataKey 任务清单在会话元数据中的键
+const TodosMetadataKey = "todos"
+
+// 任务状态
+const (
+	TodoStatusPending    = "pending"
+	TodoStatusInProgress = "in_progress"
+	TodoStatusDone       = "done"
+)
+
+// TodoItem 任务清单中的一项
+type TodoItem struct {
+	ID        int       `json:"id"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidTodoStatus 检查任务状态是否有效
+func ValidTodoStatus(status string) bool {
+	switch status {
+	case TodoStatusPending, TodoStatusInProgress, TodoStatusDone:
+		return true
+	}
+	return false
+}
+
+// SetTodos 设置任务清单，列表为空时清除
+func (s *Session) SetTodos(items []TodoItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(items) == 0 {
+		delete(s.Metadata, TodosMetadataKey)
+		return
+	}
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]interface{})
+	}
+	s.Metadata[TodosMetadataKey] = append([]TodoItem(nil), items...)
+}
+
+// GetTodos 获取任务清单（返回副本）
+func (s *Session) GetTodos() []TodoItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return decodeTodos(s.Metadata[TodosMetadataKey])
+}
+
+// decodeTodos 解析任务清单（从磁盘加载后元数据是切片 map 形式）
+func decodeTodos(value interface{}) []TodoItem {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []TodoItem:
+		return append([]TodoItem(nil), v...)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var items []TodoItem
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil
+		}
+		return items
+	}
+}